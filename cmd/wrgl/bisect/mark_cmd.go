@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package bisect
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/wrgl/wrgl/cmd/wrgl/utils"
+	"github.com/wrgl/wrgl/pkg/objects"
+	"github.com/wrgl/wrgl/pkg/ref"
+)
+
+func goodCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "good [COMMIT...]",
+		Short: "Mark commits as good, which means they don't have the change. Defaults to the commit being tested.",
+		Args:  cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runMark(cmd, args, true)
+		},
+	}
+	return cmd
+}
+
+func badCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bad [COMMIT]",
+		Short: "Mark a commit as bad, which means it has the change. Defaults to the commit being tested.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runMark(cmd, args, false)
+		},
+	}
+	return cmd
+}
+
+// commitsToMark returns sums of commits given in args, or the commit being
+// tested if args is empty.
+func commitsToMark(db objects.Store, rs ref.Store, st *state, args []string) ([][]byte, error) {
+	if len(args) == 0 {
+		if st.Current == "" {
+			return nil, fmt.Errorf("no commit is being tested, specify COMMIT")
+		}
+		sum, err := hex.DecodeString(st.Current)
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{sum}, nil
+	}
+	sums := make([][]byte, len(args))
+	for i, arg := range args {
+		_, sum, _, err := ref.InterpretCommitName(db, rs, arg, false)
+		if err != nil {
+			return nil, err
+		}
+		sums[i] = sum
+	}
+	return sums, nil
+}
+
+func runMark(cmd *cobra.Command, args []string, good bool) error {
+	rd := utils.GetRepoDir(cmd)
+	defer rd.Close()
+	st, err := readState(rd)
+	if err != nil {
+		return err
+	}
+	db, err := rd.OpenObjectsStore()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	rs := rd.OpenRefStore()
+	sums, err := commitsToMark(db, rs, st, args)
+	if err != nil {
+		return err
+	}
+	_, err = mark(cmd, rd, db, st, sums, good)
+	return err
+}
@@ -0,0 +1,30 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package bisect
+
+import (
+	"errors"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/wrgl/wrgl/cmd/wrgl/utils"
+)
+
+func resetCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "reset",
+		Short: "Finish bisecting and clean up bisect state.",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rd := utils.GetRepoDir(cmd)
+			defer rd.Close()
+			err := os.Remove(rd.BisectStatePath())
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		},
+	}
+	return cmd
+}
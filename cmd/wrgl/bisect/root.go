@@ -0,0 +1,149 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package bisect
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/bits"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/wrgl/wrgl/pkg/bisect"
+	"github.com/wrgl/wrgl/pkg/local"
+	"github.com/wrgl/wrgl/pkg/objects"
+	"github.com/wrgl/wrgl/pkg/ref"
+	"gopkg.in/yaml.v3"
+)
+
+func RootCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bisect",
+		Short: "Use binary search to find the commit that introduced a change.",
+		Long: strings.Join([]string{
+			"Use binary search to find the commit that introduced a change. Start with",
+			"\"wrgl bisect start BAD GOOD\" where BAD is a commit that has the change and GOOD is an",
+			"older commit that doesn't. Each step picks a commit in between for you to test. Inspect",
+			"it (e.g. with \"wrgl export\") and mark it with \"wrgl bisect good\" or \"wrgl bisect bad\"",
+			"until the first bad commit is found, or let a script do the testing with",
+			"\"wrgl bisect run\". Finish with \"wrgl bisect reset\".",
+		}, " "),
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Usage()
+		},
+	}
+	cmd.AddCommand(startCmd())
+	cmd.AddCommand(goodCmd())
+	cmd.AddCommand(badCmd())
+	cmd.AddCommand(runCmd())
+	cmd.AddCommand(resetCmd())
+	return cmd
+}
+
+// state is the state of an ongoing bisect, saved to RepoDir.BisectStatePath
+// between runs. All sums are hex-encoded.
+type state struct {
+	Bad  string   `yaml:"bad"`
+	Good []string `yaml:"good,omitempty"`
+
+	// Current is the commit being tested
+	Current string `yaml:"current,omitempty"`
+}
+
+func readState(rd *local.RepoDir) (*state, error) {
+	b, err := os.ReadFile(rd.BisectStatePath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no bisect in progress, run \"wrgl bisect start\" first")
+	}
+	if err != nil {
+		return nil, err
+	}
+	st := &state{}
+	if err = yaml.Unmarshal(b, st); err != nil {
+		return nil, fmt.Errorf("error reading bisect state: %v", err)
+	}
+	return st, nil
+}
+
+func writeState(rd *local.RepoDir, st *state) error {
+	b, err := yaml.Marshal(st)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(rd.BisectStatePath(), b, 0644)
+}
+
+func decodeSums(sl []string) ([][]byte, error) {
+	sums := make([][]byte, len(sl))
+	for i, s := range sl {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, err
+		}
+		sums[i] = b
+	}
+	return sums, nil
+}
+
+// next picks the next commit to test and saves it as the current commit.
+// It returns true if the first bad commit is found.
+func next(cmd *cobra.Command, rd *local.RepoDir, db objects.Store, st *state) (done bool, err error) {
+	if len(st.Good) == 0 {
+		st.Current = ""
+		if err = writeState(rd, st); err != nil {
+			return false, err
+		}
+		cmd.Println("Waiting for good commit(s), run \"wrgl bisect good COMMIT\"")
+		return false, nil
+	}
+	bad, err := hex.DecodeString(st.Bad)
+	if err != nil {
+		return false, err
+	}
+	goods, err := decodeSums(st.Good)
+	if err != nil {
+		return false, err
+	}
+	candidates, err := bisect.Candidates(db, bad, goods)
+	if err != nil {
+		return false, err
+	}
+	if len(candidates) == 0 {
+		return false, fmt.Errorf("bad commit %x is also marked good", bad)
+	}
+	sum := bisect.Next(candidates)
+	st.Current = hex.EncodeToString(sum)
+	if err = writeState(rd, st); err != nil {
+		return false, err
+	}
+	com := candidates[string(sum)]
+	if len(candidates) == 1 {
+		cmd.Printf("%s is the first bad commit\n", st.Current)
+		zone, offset := time.Now().Zone()
+		cmd.Printf("Author: %s <%s>\n", com.AuthorName, com.AuthorEmail)
+		cmd.Printf("Date: %s\n", com.Time.In(time.FixedZone(zone, offset)))
+		cmd.Printf("\n    %s\n", com.Message)
+		return true, nil
+	}
+	left := len(candidates) - 1
+	cmd.Printf("Bisecting: %d commit(s) left to test (roughly %d step(s))\n", left, bits.Len(uint(left)))
+	cmd.Printf("[%s] %s\n", st.Current, ref.FirstLine(com.Message))
+	return false, nil
+}
+
+// mark marks commits as good or bad then picks the next commit to test.
+func mark(cmd *cobra.Command, rd *local.RepoDir, db objects.Store, st *state, sums [][]byte, good bool) (done bool, err error) {
+	for _, sum := range sums {
+		if good {
+			st.Good = append(st.Good, hex.EncodeToString(sum))
+		} else {
+			st.Bad = hex.EncodeToString(sum)
+		}
+	}
+	return next(cmd, rd, db, st)
+}
@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package bisect
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wrgl/wrgl/cmd/wrgl/utils"
+	"github.com/wrgl/wrgl/pkg/objects"
+	"github.com/wrgl/wrgl/pkg/ref"
+)
+
+func runCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "run SCRIPT [ARGS...]",
+		Short: "Bisect automatically by running a script against each commit being tested.",
+		Long: strings.Join([]string{
+			"Bisect automatically by running a script against each commit being tested. The commit",
+			"is exported to a temporary CSV file whose path is passed to the script as its last",
+			"argument. The commit sum is also available in environment variable WRGL_COMMIT. The",
+			"commit is marked good if the script exits with code 0, and bad otherwise.",
+		}, " "),
+		Example: utils.CombineExamples([]utils.Example{
+			{
+				Comment: "find the first commit where check_totals.py fails",
+				Line:    "wrgl bisect run python check_totals.py --column amount",
+			},
+		}),
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rd := utils.GetRepoDir(cmd)
+			defer rd.Close()
+			st, err := readState(rd)
+			if err != nil {
+				return err
+			}
+			if len(st.Good) == 0 || st.Current == "" {
+				return fmt.Errorf("bisect run requires a bad and at least one good commit")
+			}
+			db, err := rd.OpenObjectsStore()
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+			rs := rd.OpenRefStore()
+			for {
+				sum, err := hex.DecodeString(st.Current)
+				if err != nil {
+					return err
+				}
+				good, err := runScript(cmd, db, rs, sum, args)
+				if err != nil {
+					return err
+				}
+				done, err := mark(cmd, rd, db, st, [][]byte{sum}, good)
+				if err != nil {
+					return err
+				}
+				if done {
+					return nil
+				}
+			}
+		},
+	}
+	// flags after SCRIPT belong to SCRIPT
+	cmd.Flags().SetInterspersed(false)
+	return cmd
+}
+
+// exportCSV writes table of commit sum to a temporary CSV file and returns
+// its path.
+func exportCSV(db objects.Store, rs ref.Store, sum []byte) (string, error) {
+	com, err := objects.GetCommit(db, sum)
+	if err != nil {
+		return "", err
+	}
+	tbl, err := utils.GetTable(db, rs, com)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp("", fmt.Sprintf("wrgl_bisect_%s_*.csv", hex.EncodeToString(sum)[:7]))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	if err = w.Write(tbl.Columns); err != nil {
+		return "", err
+	}
+	var buf []byte
+	var blk [][]string
+	for _, blkSum := range tbl.Blocks {
+		blk, buf, err = objects.GetBlock(db, buf, blkSum)
+		if err != nil {
+			return "", err
+		}
+		if err = w.WriteAll(blk); err != nil {
+			return "", err
+		}
+	}
+	w.Flush()
+	if err = w.Error(); err != nil {
+		return "", err
+	}
+	return f.Name(), f.Close()
+}
+
+// runScript runs the script in args against commit sum and returns true if
+// the commit is good.
+func runScript(cmd *cobra.Command, db objects.Store, rs ref.Store, sum []byte, args []string) (bool, error) {
+	name, err := exportCSV(db, rs, sum)
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(name)
+	cmd.Printf("running %s\n", strings.Join(args, " "))
+	c := exec.Command(args[0], append(args[1:], name)...)
+	c.Env = append(os.Environ(), "WRGL_COMMIT="+hex.EncodeToString(sum))
+	c.Stdout = cmd.OutOrStdout()
+	c.Stderr = cmd.ErrOrStderr()
+	err = c.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error running %s: %v", args[0], err)
+	}
+	return true, nil
+}
@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package bisect
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/wrgl/wrgl/cmd/wrgl/utils"
+	"github.com/wrgl/wrgl/pkg/ref"
+)
+
+func startCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "start BAD [GOOD...]",
+		Short: "Start bisecting between a bad commit and good commits.",
+		Example: utils.CombineExamples([]utils.Example{
+			{
+				Comment: "find the first bad commit between the latest commit of main and a commit that is known to be good",
+				Line:    "wrgl bisect start main 43a5f3447e82b53a2574ef5af470df96",
+			},
+			{
+				Comment: "the latest commit of main is bad, the tenth latest commit is good",
+				Line:    "wrgl bisect start main main~9",
+			},
+		}),
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rd := utils.GetRepoDir(cmd)
+			defer rd.Close()
+			if _, err := os.Stat(rd.BisectStatePath()); err == nil {
+				return fmt.Errorf("a bisect is in progress, run \"wrgl bisect reset\" first")
+			}
+			db, err := rd.OpenObjectsStore()
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+			rs := rd.OpenRefStore()
+			st := &state{}
+			for i, arg := range args {
+				_, sum, _, err := ref.InterpretCommitName(db, rs, arg, false)
+				if err != nil {
+					return err
+				}
+				if i == 0 {
+					st.Bad = hex.EncodeToString(sum)
+				} else {
+					st.Good = append(st.Good, hex.EncodeToString(sum))
+				}
+			}
+			_, err = next(cmd, rd, db, st)
+			if err != nil {
+				// don't leave behind a bisect that can't continue
+				os.Remove(rd.BisectStatePath())
+			}
+			return err
+		},
+	}
+	return cmd
+}
@@ -0,0 +1,105 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package wrgl
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wrgl/wrgl/pkg/factory"
+)
+
+func TestBisectCmd(t *testing.T) {
+	rd, cleanup := createRepoDir(t)
+	defer cleanup()
+	db, err := rd.OpenObjectsStore()
+	require.NoError(t, err)
+	defer db.Close()
+	rs := rd.OpenRefStore()
+	sums := make([][]byte, 6)
+	for i := range sums {
+		v := "ok"
+		if i >= 2 {
+			v = "broken"
+		}
+		sums[i], _ = factory.CommitHead(t, db, rs, "main", []string{
+			"a,b",
+			fmt.Sprintf("1,%s", v),
+			fmt.Sprintf("%d,ok", i+2),
+		}, []uint32{0})
+	}
+	require.NoError(t, db.Close())
+
+	cmd := rootCmd()
+	cmd.SetArgs([]string{"bisect", "good"})
+	assert.Equal(t, fmt.Errorf("no bisect in progress, run \"wrgl bisect start\" first"), cmd.Execute())
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"bisect", "start", "main"})
+	assertCmdOutput(t, cmd, "Waiting for good commit(s), run \"wrgl bisect good COMMIT\"\n")
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"bisect", "good"})
+	assert.Equal(t, fmt.Errorf("no commit is being tested, specify COMMIT"), cmd.Execute())
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"bisect", "good", "main~5"})
+	buf := bytes.NewBuffer(nil)
+	cmd.SetOut(buf)
+	require.NoError(t, cmd.Execute())
+	assert.Contains(t, buf.String(), "Bisecting: 4 commit(s) left to test (roughly 3 step(s))\n")
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"bisect", "start", "main"})
+	assert.Equal(t, fmt.Errorf("a bisect is in progress, run \"wrgl bisect reset\" first"), cmd.Execute())
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"bisect", "bad", "main~2"})
+	buf = bytes.NewBuffer(nil)
+	cmd.SetOut(buf)
+	require.NoError(t, cmd.Execute())
+	assert.Contains(t, buf.String(), "Bisecting: 2 commit(s) left to test (roughly 2 step(s))\n")
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"bisect", "good", "main~4"})
+	buf = bytes.NewBuffer(nil)
+	cmd.SetOut(buf)
+	require.NoError(t, cmd.Execute())
+	assert.Contains(t, buf.String(), "Bisecting: 1 commit(s) left to test (roughly 1 step(s))\n")
+	assert.Contains(t, buf.String(), fmt.Sprintf("[%x]", sums[2]))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"bisect", "bad"})
+	buf = bytes.NewBuffer(nil)
+	cmd.SetOut(buf)
+	require.NoError(t, cmd.Execute())
+	assert.Contains(t, buf.String(), fmt.Sprintf("%x is the first bad commit\n", sums[2]))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"bisect", "reset"})
+	require.NoError(t, cmd.Execute())
+	_, err = os.Stat(rd.BisectStatePath())
+	assert.True(t, os.IsNotExist(err))
+
+	// bisect automatically with a script
+	dir := t.TempDir()
+	script := filepath.Join(dir, "check.sh")
+	require.NoError(t, os.WriteFile(script, []byte("grep -q '^1,ok$' \"$1\"\n"), 0755))
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"bisect", "start", "main", hex.EncodeToString(sums[0])})
+	require.NoError(t, cmd.Execute())
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"bisect", "run", "sh", script})
+	buf = bytes.NewBuffer(nil)
+	cmd.SetOut(buf)
+	require.NoError(t, cmd.Execute())
+	assert.Contains(t, buf.String(), fmt.Sprintf("running sh %s\n", script))
+	assert.Contains(t, buf.String(), fmt.Sprintf("%x is the first bad commit\n", sums[2]))
+}
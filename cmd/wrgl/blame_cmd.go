@@ -0,0 +1,305 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package wrgl
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/spf13/cobra"
+	"github.com/wrgl/wrgl/cmd/wrgl/utils"
+	"github.com/wrgl/wrgl/pkg/blame"
+	"github.com/wrgl/wrgl/pkg/diff"
+	"github.com/wrgl/wrgl/pkg/objects"
+	"github.com/wrgl/wrgl/pkg/ref"
+	"github.com/wrgl/wrgl/pkg/widgets"
+)
+
+var blameColumns = []string{"COMMIT", "AUTHOR", "TIME"}
+
+func blameCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "blame COMMIT [PK_VALUES...]",
+		Short: "Show which commit last added or modified each row.",
+		Long: strings.Join([]string{
+			"Show which commit last added or modified each row of a commit's table. Rows are",
+			"followed back through history for as long as they stay the same. The commit sum, author",
+			"and time of the commit that last changed each row are shown as extra columns.",
+			"If PK_VALUES are given, only rows with those primary key values are shown. Each",
+			"PK_VALUES is a comma-separated list of values, one for each primary key column.",
+			"With --cells, a single row is blamed cell by cell instead: for each column, the commit",
+			"that last changed the cell is shown along with the cell's value before that change.",
+			"Cells are matched across commits by column name, so a moved column keeps its history.",
+			"A column that replaces a removed column at the same position is treated as a rename,",
+			"and its history is followed under the old name, if the cell has the same value as in",
+			"the removed column. Otherwise the column is shown as added.",
+		}, " "),
+		Example: utils.CombineExamples([]utils.Example{
+			{
+				Comment: "show who last changed each row of branch main",
+				Line:    "wrgl blame main",
+			},
+			{
+				Comment: "only show rows with primary key 123 and 456, output as CSV",
+				Line:    "wrgl blame main 123 456 --no-gui",
+			},
+			{
+				Comment: "show a single row of a table with a composite primary key",
+				Line:    "wrgl blame main 'SKU-1,warehouse-2'",
+			},
+			{
+				Comment: "show which commit last changed each cell of a row, and the previous values",
+				Line:    "wrgl blame main 123 --cells",
+			},
+		}),
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rd := utils.GetRepoDir(cmd)
+			defer rd.Close()
+			if err := quitIfRepoDirNotExist(cmd, rd); err != nil {
+				return err
+			}
+			db, err := rd.OpenObjectsStore()
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+			rs := rd.OpenRefStore()
+			noGUI, err := cmd.Flags().GetBool("no-gui")
+			if err != nil {
+				return err
+			}
+			cells, err := cmd.Flags().GetBool("cells")
+			if err != nil {
+				return err
+			}
+			if cells && len(args) != 2 {
+				return fmt.Errorf("--cells requires exactly one PK_VALUES")
+			}
+			_, sum, commit, err := ref.InterpretCommitName(db, rs, args[0], false)
+			if err != nil {
+				return err
+			}
+			tbl, err := utils.GetTable(db, rs, commit)
+			if err != nil {
+				return err
+			}
+			if cells {
+				return blameCells(cmd, db, sum, tbl, args[1])
+			}
+			reader, err := blameRows(cmd, db, sum, tbl, args[1:])
+			if err != nil {
+				return err
+			}
+			columns := append(append([]string{}, tbl.Columns...), blameColumns...)
+			if noGUI {
+				return writeBlameCSV(cmd.OutOrStdout(), columns, reader)
+			}
+			return blameTable(hex.EncodeToString(sum), columns, tbl.PK, reader)
+		},
+	}
+	cmd.Flags().Bool("no-gui", false, "don't show the interactive table, instead print rows as CSV to stdout")
+	cmd.Flags().Bool("cells", false, "blame each cell of a single row instead, printing one line per column as CSV to stdout")
+	return cmd
+}
+
+// parsePKValues parses comma-separated primary key values of tbl
+func parsePKValues(tbl *objects.Table, arg string) ([]string, error) {
+	if len(tbl.PK) == 0 {
+		return nil, fmt.Errorf("table has no primary key, can't look up rows by primary key values")
+	}
+	values, err := csv.NewReader(strings.NewReader(arg)).Read()
+	if err != nil {
+		return nil, fmt.Errorf("error parsing primary key values %q: %v", arg, err)
+	}
+	if len(values) != len(tbl.PK) {
+		return nil, fmt.Errorf("expecting %d primary key values (%s), got %q", len(tbl.PK), strings.Join(tbl.PrimaryKey(), ","), arg)
+	}
+	return values, nil
+}
+
+// pkSumsFromArgs parses each argument as comma-separated primary key values and
+// returns their primary key sums.
+func pkSumsFromArgs(tbl *objects.Table, args []string) ([][]byte, error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+	enc := objects.NewStrListEncoder(true)
+	sums := make([][]byte, len(args))
+	for i, arg := range args {
+		values, err := parsePKValues(tbl, arg)
+		if err != nil {
+			return nil, err
+		}
+		sums[i] = objects.PKSum(enc, values)
+	}
+	return sums, nil
+}
+
+func blameRows(cmd *cobra.Command, db objects.Store, sum []byte, tbl *objects.Table, args []string) (*blameRowReader, error) {
+	wanted, err := pkSumsFromArgs(tbl, args)
+	if err != nil {
+		return nil, err
+	}
+	pkSums, err := blame.TablePKSums(db, tbl)
+	if err != nil {
+		return nil, err
+	}
+	rowReader, err := diff.NewRowListReader(db, tbl)
+	if err != nil {
+		return nil, err
+	}
+	var sums [][]byte
+	if len(wanted) == 0 {
+		sums = pkSums
+		for i := range pkSums {
+			rowReader.Add(uint32(i))
+		}
+	} else {
+		offsets := make(map[string]int, len(pkSums))
+		for i, b := range pkSums {
+			offsets[string(b)] = i
+		}
+		for i, b := range wanted {
+			off, ok := offsets[string(b)]
+			if !ok {
+				return nil, fmt.Errorf("no row with primary key values %q", args[i])
+			}
+			rowReader.Add(uint32(off))
+		}
+		sums = wanted
+	}
+	m, err := blame.Rows(db, sum, sums, *utils.GetLogger(cmd))
+	if err != nil {
+		return nil, err
+	}
+	commits := make([]*objects.Commit, len(sums))
+	for i, b := range sums {
+		commits[i] = m[string(b)]
+	}
+	return &blameRowReader{rows: rowReader, commits: commits}, nil
+}
+
+func blameCells(cmd *cobra.Command, db objects.Store, sum []byte, tbl *objects.Table, arg string) error {
+	values, err := parsePKValues(tbl, arg)
+	if err != nil {
+		return err
+	}
+	cells, err := blame.Cells(db, sum, values)
+	if errors.Is(err, blame.ErrRowNotFound) {
+		return fmt.Errorf("no row with primary key values %q", arg)
+	}
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(cmd.OutOrStdout())
+	if err = w.Write([]string{"COLUMN", "VALUE", "CHANGE", "PREVIOUS VALUE", "COMMIT", "AUTHOR", "TIME"}); err != nil {
+		return err
+	}
+	for _, cell := range cells {
+		change := "modified"
+		if cell.Added {
+			change = "added"
+		}
+		if err = w.Write(append(
+			[]string{cell.Column, cell.Value, change, cell.PrevValue},
+			commitBlameValues(cell.Commit)...,
+		)); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// commitBlameValues returns values of blameColumns for commit com
+func commitBlameValues(com *objects.Commit) []string {
+	return []string{
+		hex.EncodeToString(com.Sum),
+		fmt.Sprintf("%s <%s>", com.AuthorName, com.AuthorEmail),
+		com.Time.Format(time.RFC3339),
+	}
+}
+
+// blameRowReader reads rows from a RowListReader and appends blame columns
+// of the corresponding commit to each row.
+type blameRowReader struct {
+	off     int
+	rows    *diff.RowListReader
+	commits []*objects.Commit
+}
+
+func (r *blameRowReader) Read() ([]string, error) {
+	row, err := r.rows.Read()
+	if err != nil {
+		return nil, err
+	}
+	com := r.commits[r.off]
+	r.off++
+	return append(append(make([]string, 0, len(row)+len(blameColumns)), row...), commitBlameValues(com)...), nil
+}
+
+func (r *blameRowReader) Seek(offset int, whence int) (int, error) {
+	off, err := r.rows.Seek(offset, whence)
+	if err != nil {
+		return 0, err
+	}
+	r.off = off
+	return off, nil
+}
+
+func (r *blameRowReader) Len() int {
+	return r.rows.Len()
+}
+
+func writeBlameCSV(out io.Writer, columns []string, reader *blameRowReader) error {
+	w := csv.NewWriter(out)
+	if err := w.Write(columns); err != nil {
+		return err
+	}
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err = w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func blameTable(hash string, columns []string, pk []uint32, reader *blameRowReader) error {
+	app := tview.NewApplication().EnableMouse(true)
+
+	titleBar := tview.NewTextView().SetDynamicColors(true)
+	fmt.Fprintf(titleBar, "blame [yellow]%s[white]  ([teal]%d[white] rows)", hash, reader.Len())
+
+	tv := widgets.NewPreviewTable(reader, reader.Len(), columns, pk)
+
+	usageBar := widgets.DataTableUsage()
+
+	flex := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(titleBar, 1, 1, false).
+		AddItem(tv, 0, 1, true).
+		AddItem(usageBar, 0, 1, false)
+
+	app.SetBeforeDrawFunc(func(screen tcell.Screen) bool {
+		usageBar.BeforeDraw(screen, flex)
+		return false
+	})
+
+	return app.SetRoot(flex, true).SetFocus(flex).Run()
+}
@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package wrgl
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wrgl/wrgl/pkg/factory"
+	"github.com/wrgl/wrgl/pkg/objects"
+	"github.com/wrgl/wrgl/pkg/ref"
+)
+
+func expectedBlameCells(com *objects.Commit) string {
+	return fmt.Sprintf("%x,%s <%s>,%s", com.Sum, com.AuthorName, com.AuthorEmail, com.Time.Format(time.RFC3339))
+}
+
+func TestBlameCmd(t *testing.T) {
+	rd, cleanUp := createRepoDir(t)
+	defer cleanUp()
+
+	db, err := rd.OpenObjectsStore()
+	require.NoError(t, err)
+	rs := rd.OpenRefStore()
+	sum1, com1 := factory.Commit(t, db, []string{
+		"a,b,c",
+		"1,q,w",
+		"2,a,s",
+		"3,z,x",
+	}, []uint32{0}, nil)
+	sum2, com2 := factory.Commit(t, db, []string{
+		"a,b,c",
+		"1,q,e",
+		"2,a,s",
+		"3,z,x",
+	}, []uint32{0}, [][]byte{sum1})
+	require.NoError(t, ref.CommitHead(rs, "alpha", sum2, com2, nil))
+	sum3, com3 := factory.Commit(t, db, []string{
+		"a,b,c",
+		"1,q,w",
+		"2,a,s",
+	}, []uint32{0, 1}, nil)
+	require.NoError(t, ref.CommitHead(rs, "beta", sum3, com3, nil))
+	require.NoError(t, db.Close())
+
+	cmd := rootCmd()
+	cmd.SetArgs([]string{"blame", "alpha", "--no-gui"})
+	assertCmdOutput(t, cmd, strings.Join([]string{
+		"a,b,c,COMMIT,AUTHOR,TIME",
+		"1,q,e," + expectedBlameCells(com2),
+		"2,a,s," + expectedBlameCells(com1),
+		"3,z,x," + expectedBlameCells(com1),
+		"",
+	}, "\n"))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"blame", "alpha", "3", "1", "--no-gui"})
+	assertCmdOutput(t, cmd, strings.Join([]string{
+		"a,b,c,COMMIT,AUTHOR,TIME",
+		"3,z,x," + expectedBlameCells(com1),
+		"1,q,e," + expectedBlameCells(com2),
+		"",
+	}, "\n"))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"blame", "alpha", "4", "--no-gui"})
+	assertCmdFailed(t, cmd, "", fmt.Errorf(`no row with primary key values "4"`))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"blame", "beta", "2", "--no-gui"})
+	assertCmdFailed(t, cmd, "", fmt.Errorf(`expecting 2 primary key values (a,b), got "2"`))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"blame", "beta", "2,a", "--no-gui"})
+	assertCmdOutput(t, cmd, strings.Join([]string{
+		"a,b,c,COMMIT,AUTHOR,TIME",
+		"2,a,s," + expectedBlameCells(com3),
+		"",
+	}, "\n"))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"blame", "alpha", "1", "--cells"})
+	assertCmdOutput(t, cmd, strings.Join([]string{
+		"COLUMN,VALUE,CHANGE,PREVIOUS VALUE,COMMIT,AUTHOR,TIME",
+		"a,1,added,," + expectedBlameCells(com1),
+		"b,q,added,," + expectedBlameCells(com1),
+		"c,e,modified,w," + expectedBlameCells(com2),
+		"",
+	}, "\n"))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"blame", "alpha", "--cells"})
+	assertCmdFailed(t, cmd, "", fmt.Errorf("--cells requires exactly one PK_VALUES"))
+}
@@ -6,11 +6,15 @@ package branch
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/wrgl/wrgl/cmd/wrgl/utils"
 	"github.com/wrgl/wrgl/pkg/conf"
 	conffs "github.com/wrgl/wrgl/pkg/conf/fs"
+	"github.com/wrgl/wrgl/pkg/rowsource"
+	"github.com/wrgl/wrgl/pkg/schema"
+	"github.com/wrgl/wrgl/pkg/slice"
 )
 
 func configCmd() *cobra.Command {
@@ -52,6 +56,20 @@ func configCmd() *cobra.Command {
 			if err != nil {
 				return err
 			}
+			setFormat, err := cmd.Flags().GetString("set-format")
+			if err != nil {
+				return err
+			}
+			if setFormat != "" && !slice.StringSliceContains(rowsource.Formats, setFormat) {
+				return fmt.Errorf("invalid format %q, must be one of %s", setFormat, strings.Join(rowsource.Formats, ", "))
+			}
+			setTypes, err := cmd.Flags().GetStringSlice("set-types")
+			if err != nil {
+				return err
+			}
+			if _, err = schema.ParseTypes(setTypes); err != nil {
+				return err
+			}
 			setUpstreamRemote, err := cmd.Flags().GetString("set-upstream-remote")
 			if err != nil {
 				return err
@@ -66,7 +84,7 @@ func configCmd() *cobra.Command {
 			}
 			branch, ok := c.Branch[args[0]]
 
-			if setFile == "" && len(setPrimaryKey) == 0 && setDelimiter == 0 && setUpstreamRemote == "" && setUpstreamDest == "" {
+			if setFile == "" && len(setPrimaryKey) == 0 && setDelimiter == 0 && setFormat == "" && len(setTypes) == 0 && setUpstreamRemote == "" && setUpstreamDest == "" {
 				if !ok {
 					return fmt.Errorf("branch %q not found", args[0])
 				}
@@ -90,6 +108,12 @@ func configCmd() *cobra.Command {
 			if setDelimiter != 0 {
 				branch.Delimiter = setDelimiter
 			}
+			if setFormat != "" {
+				branch.Format = setFormat
+			}
+			if len(setTypes) > 0 {
+				branch.Types = setTypes
+			}
 			if setUpstreamRemote != "" {
 				branch.Remote = setUpstreamRemote
 			}
@@ -106,6 +130,8 @@ func configCmd() *cobra.Command {
 	cmd.Flags().String("set-file", "", "set branch.file config to a CSV file. If branch.file is set, then you don't need to specify CSV_FILE_PATH in subsequent commits to BRANCH.")
 	cmd.Flags().StringSlice("set-primary-key", nil, "set branch.primaryKey. If branch.primaryKey is set, then you don't need to specify PRIMARY_KEY in subsequent commits to BRANCH.")
 	cmd.Flags().String("set-delimiter", "", "set branch.delimiter. branch.delimiter tells Wrgl what delimiter to use when parsing branch.file")
+	cmd.Flags().String("set-format", "", "set branch.format. branch.format tells Wrgl the format of branch.file (csv, parquet, jsonl or xlsx) if it can't be detected from the file extension")
+	cmd.Flags().StringSlice("set-types", nil, "set branch.types, each written as COLUMN:TYPE. If branch.types is set, then you don't need to specify TYPES in subsequent commits to BRANCH.")
 	cmd.Flags().String("set-upstream-remote", "", "set branch.remote. When both branch.remote and branch.merge are set, you can run `wrgl pull BRANCH` without specifying remote and refspec")
 	cmd.Flags().String("set-upstream-dest", "", "set branch.merge. When both branch.remote and branch.merge are set, you can run `wrgl pull BRANCH` without specifying remote and refspec")
 	return cmd
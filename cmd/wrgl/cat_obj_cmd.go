@@ -20,7 +20,7 @@ func newCatObjCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "cat-obj OBJECT_SUM",
 		Short: "Print information for an object.",
-		Long:  "Print information for an object. This command only work for 3 types of objects: commit, table, and block.",
+		Long:  "Print information for an object. This command only work for 4 types of objects: commit, tag, table, and block.",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			hash, err := hex.DecodeString(args[0])
@@ -42,6 +42,10 @@ func newCatObjCmd() *cobra.Command {
 			if err == nil {
 				return catCommit(cmd, db, rs, commit)
 			}
+			tag, err := objects.GetTag(db, hash)
+			if err == nil {
+				return catTag(cmd, db, tag)
+			}
 			tbl, err := objects.GetTable(db, hash)
 			if err == nil {
 				return catTable(cmd, tbl)
@@ -75,13 +79,30 @@ func catCommit(cmd *cobra.Command, db objects.Store, rs ref.Store, commit *objec
 	return nil
 }
 
+func catTag(cmd *cobra.Command, db objects.Store, tag *objects.Tag) error {
+	out := cmd.OutOrStdout()
+	colorstring.Fprintf(out, "[yellow]target[white] %s", hex.EncodeToString(tag.Target))
+	if !objects.CommitExist(db, tag.Target) {
+		colorstring.Fprintf(out, " [red]<missing>[white]")
+	}
+	fmt.Fprintln(out)
+	colorstring.Fprintf(out, "[yellow]tagger[white] %s <%s>\n", tag.TaggerName, tag.TaggerEmail)
+	colorstring.Fprintf(out, "[yellow]time[white]   %d %s\n\n", tag.Time.Unix(), tag.Time.Format("-0700"))
+	colorstring.Fprintln(out, tag.Message)
+	return nil
+}
+
 func catTable(cmd *cobra.Command, tbl *objects.Table) error {
 	out := cmd.OutOrStdout()
 	cols := tbl.Columns
 	pk := tbl.PrimaryKey()
 	colorstring.Fprintf(out, "[yellow]columns[white] ([cyan]%d[white])\n\n", len(cols))
-	for _, col := range cols {
-		colorstring.Fprintf(out, "  %s\n", col)
+	for i, col := range cols {
+		if tbl.Types != nil {
+			colorstring.Fprintf(out, "  %s [cyan]%s[white]\n", col, tbl.Types[i])
+		} else {
+			colorstring.Fprintf(out, "  %s\n", col)
+		}
 	}
 	if len(pk) > 0 {
 		colorstring.Fprintf(out, "\n[yellow]primary key[white] ([cyan]%d[white])\n\n", len(pk))
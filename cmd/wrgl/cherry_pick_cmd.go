@@ -0,0 +1,132 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package wrgl
+
+import (
+	"encoding/hex"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/wrgl/wrgl/cmd/wrgl/utils"
+	"github.com/wrgl/wrgl/pkg/conf"
+	conffs "github.com/wrgl/wrgl/pkg/conf/fs"
+	"github.com/wrgl/wrgl/pkg/objects"
+	"github.com/wrgl/wrgl/pkg/ref"
+)
+
+func cherryPickCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cherry-pick COMMIT BRANCH",
+		Short: "Apply the changes of an existing commit onto a branch.",
+		Long: strings.Join([]string{
+			"Apply the row changes introduced by COMMIT onto BRANCH, creating a new commit on top",
+			"of BRANCH. The new commit keeps the author and message of COMMIT. Unlike \"wrgl merge\",",
+			"only the changes made by COMMIT itself are applied, not the changes of its ancestors.",
+			"Changes that conflict with BRANCH are shown in the merge UI.",
+		}, " "),
+		Example: utils.CombineExamples([]utils.Example{
+			{
+				Comment: "apply the latest commit of branch staging onto branch prod",
+				Line:    "wrgl cherry-pick staging prod",
+			},
+			{
+				Comment: "apply a commit onto branch prod",
+				Line:    "wrgl cherry-pick 43a5f3447e82b53a2574ef5af470df96 prod",
+			},
+			{
+				Comment: "don't show merge UI, output conflicts and resolved rows to CONFLICTS_SUM1_SUM2.csv instead",
+				Line:    "wrgl cherry-pick staging^ prod --no-gui",
+			},
+		}),
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rd := utils.GetRepoDir(cmd)
+			defer rd.Close()
+			s := conffs.NewStore(rd.FullPath, conffs.AggregateSource, "")
+			c, err := s.Open()
+			if err != nil {
+				return err
+			}
+			if err := utils.EnsureUserSet(cmd, c); err != nil {
+				return err
+			}
+			db, err := rd.OpenObjectsStore()
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+			rs := rd.OpenRefStore()
+			noCommit, err := cmd.Flags().GetBool("no-commit")
+			if err != nil {
+				return err
+			}
+			noGUI, err := cmd.Flags().GetBool("no-gui")
+			if err != nil {
+				return err
+			}
+			numWorkers, err := cmd.Flags().GetInt("num-workers")
+			if err != nil {
+				return err
+			}
+			return runCherryPick(cmd, c, db, rs, args, noCommit, noGUI, numWorkers)
+		},
+	}
+	cmd.Flags().Bool("no-commit", false, "perform the cherry-pick but don't create a commit, instead output the result to file MERGE_SUM1_SUM2.csv")
+	cmd.Flags().Bool("no-gui", false, "don't show mergetool, instead output conflicts (and resolved rows) to file CONFLICTS_SUM1_SUM2.csv")
+	cmd.Flags().IntP("num-workers", "n", runtime.GOMAXPROCS(0), "number of CPU threads to utilize (default to GOMAXPROCS)")
+	return cmd
+}
+
+func runCherryPick(
+	cmd *cobra.Command, c *conf.Config, db objects.Store, rs ref.Store, args []string,
+	noCommit, noGUI bool, numWorkers int,
+) error {
+	_, sum, com, err := ref.InterpretCommitName(db, rs, args[0], true)
+	if err != nil {
+		return err
+	}
+	branch := args[1]
+	headSum, err := ref.GetHead(rs, branch)
+	if err != nil {
+		return fmt.Errorf("can't get branch %q: %v", branch, err)
+	}
+	if len(com.Parents) == 0 {
+		return fmt.Errorf("can't cherry-pick commit %x: it has no parent", sum)
+	}
+	if len(com.Parents) > 1 {
+		return fmt.Errorf("can't cherry-pick commit %x: cherry-picking a merge commit is not supported", sum)
+	}
+	if ok, err := ref.IsAncestorOf(db, sum, headSum); err != nil {
+		return err
+	} else if ok {
+		return fmt.Errorf("commit %x is already part of branch %q", sum, branch)
+	}
+	return cherryPick(cmd, c, db, rs, branch, headSum, sum, com, noCommit, noGUI, numWorkers)
+}
+
+// cherryPick applies changes of commit com onto headSum and points branch to
+// the resulting commit.
+func cherryPick(
+	cmd *cobra.Command, c *conf.Config, db objects.Store, rs ref.Store, branch string, headSum, sum []byte,
+	com *objects.Commit, noCommit, noGUI bool, numWorkers int,
+) error {
+	return mergeTables(
+		cmd, db, rs, com.Parents[0], [][]byte{headSum, sum},
+		[]string{branch, hex.EncodeToString(sum)[:7]}, noCommit, noGUI, numWorkers,
+		func(tblSum []byte) error {
+			_, err := saveCommitOnBranch(cmd, db, rs, c.User, branch, &objects.Commit{
+				Table:       tblSum,
+				Message:     com.Message,
+				Time:        time.Now(),
+				AuthorName:  com.AuthorName,
+				AuthorEmail: com.AuthorEmail,
+				Parents:     [][]byte{headSum},
+			}, "cherry-pick", "commit "+hex.EncodeToString(sum))
+			return err
+		},
+	)
+}
@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package wrgl
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wrgl/wrgl/pkg/factory"
+	"github.com/wrgl/wrgl/pkg/objects"
+	"github.com/wrgl/wrgl/pkg/ref"
+	refhelpers "github.com/wrgl/wrgl/pkg/ref/helpers"
+)
+
+func TestCherryPickCmd(t *testing.T) {
+	rd, cleanup := createRepoDir(t)
+	defer cleanup()
+	db, err := rd.OpenObjectsStore()
+	require.NoError(t, err)
+	defer db.Close()
+	rs := rd.OpenRefStore()
+	base, _ := factory.CommitHead(t, db, rs, "prod", []string{
+		"a,b,c",
+		"1,q,w",
+		"2,a,s",
+		"3,z,x",
+	}, []uint32{0})
+	prodSum, _ := factory.CommitHead(t, db, rs, "prod", []string{
+		"a,b,c",
+		"1,q,w",
+		"2,a,s",
+		"3,z,x",
+		"4,s,d",
+	}, []uint32{0})
+	require.NoError(t, ref.SaveRef(rs, "heads/staging", base, "test", "test@domain.com", "branch", "created", nil))
+	_, _ = factory.CommitHead(t, db, rs, "staging", []string{
+		"a,b,c",
+		"1,q,r",
+		"2,a,s",
+		"3,z,x",
+	}, []uint32{0})
+	sum2, com2 := factory.CommitHead(t, db, rs, "staging", []string{
+		"a,b,c",
+		"1,q,r",
+		"2,a,f",
+	}, []uint32{0})
+	require.NoError(t, db.Close())
+
+	cmd := rootCmd()
+	cmd.SetArgs([]string{"cherry-pick", "staging~2", "prod"})
+	assert.Equal(t, fmt.Errorf("can't cherry-pick commit %x: it has no parent", base), cmd.Execute())
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"cherry-pick", "prod", "prod"})
+	assert.Equal(t, fmt.Errorf("commit %x is already part of branch %q", prodSum, "prod"), cmd.Execute())
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"cherry-pick", "staging", "prod"})
+	require.NoError(t, cmd.Execute())
+
+	// only row changes of the latest staging commit are applied
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"export", "prod"})
+	assertCmdOutput(t, cmd, "a,b,c\n1,q,w\n2,a,f\n4,s,d\n")
+
+	db, err = rd.OpenObjectsStore()
+	require.NoError(t, err)
+	defer db.Close()
+	sum, err := ref.GetHead(rs, "prod")
+	require.NoError(t, err)
+	refhelpers.AssertLatestReflogEqual(t, rs, "heads/prod", &ref.Reflog{
+		OldOID:      prodSum,
+		NewOID:      sum,
+		AuthorName:  "John Doe",
+		AuthorEmail: "john@domain.com",
+		Action:      "cherry-pick",
+		Message:     "commit " + hex.EncodeToString(sum2),
+	})
+	com, err := objects.GetCommit(db, sum)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{prodSum}, com.Parents)
+	assert.Equal(t, com2.Message, com.Message)
+	assert.Equal(t, com2.AuthorName, com.AuthorName)
+	assert.Equal(t, com2.AuthorEmail, com.AuthorEmail)
+}
@@ -0,0 +1,210 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package wrgl
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wrgl/wrgl/cmd/wrgl/fetch"
+	"github.com/wrgl/wrgl/cmd/wrgl/utils"
+	"github.com/wrgl/wrgl/pkg/conf"
+	conffs "github.com/wrgl/wrgl/pkg/conf/fs"
+	"github.com/wrgl/wrgl/pkg/credentials"
+	"github.com/wrgl/wrgl/pkg/local"
+	"github.com/wrgl/wrgl/pkg/pbar"
+	"github.com/wrgl/wrgl/pkg/ref"
+)
+
+func cloneCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "clone URL [DIR]",
+		Short: "Clone a repository into a new directory.",
+		Long: strings.Join([]string{
+			"Clone a repository into a new directory. The repository is created at DIR/.wrgl,",
+			"with DIR defaulting to the last segment of URL. The remote is added as \"origin\"",
+			"with refspec +refs/heads/*:refs/remotes/origin/*, then all remote branches are",
+			"fetched and a local branch is created for each of them, with branch.<name>.remote",
+			"and branch.<name>.merge set so that \"wrgl pull <name>\" works right away.",
+		}, " "),
+		Example: utils.CombineExamples([]utils.Example{
+			{
+				Comment: "clone a repository into directory \"my-repo\"",
+				Line:    "wrgl clone https://my-repo.domain.com/my-repo",
+			},
+			{
+				Comment: "clone into a specific directory",
+				Line:    "wrgl clone https://my-repo.domain.com/my-repo my-data",
+			},
+			{
+				Comment: "clone only the data of the last 2 commits of each branch",
+				Line:    "wrgl clone https://my-repo.domain.com/my-repo --depth 2",
+			},
+			{
+				Comment: "clone all refs as they are on the remote",
+				Line:    "wrgl clone https://my-repo.domain.com/my-repo --mirror",
+			},
+		}),
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			u := strings.TrimSuffix(args[0], "/")
+			if _, err := url.ParseRequestURI(u); err != nil {
+				return err
+			}
+			var dir string
+			if len(args) > 1 {
+				dir = args[1]
+			} else {
+				dir = cloneDirFromURL(u)
+			}
+			depth, err := cmd.Flags().GetInt32("depth")
+			if err != nil {
+				return err
+			}
+			mirror, err := cmd.Flags().GetBool("mirror")
+			if err != nil {
+				return err
+			}
+			badgerLog, err := cmd.Flags().GetString("badger-log")
+			if err != nil {
+				return err
+			}
+			return cloneRepo(cmd, u, dir, badgerLog, depth, mirror)
+		},
+	}
+	cmd.Flags().Int32P("depth", "d", 0, "The maximum depth pass which commits will be fetched shallowly. Shallow commits only have the metadata but not the data itself. If depth is set to 0 then all commits will be fetched in full.")
+	cmd.Flags().Bool("mirror", false, "mirror all refs of the remote repository (including tags and remote-tracking refs) instead of fetching branches into refs/remotes/origin/")
+	return cmd
+}
+
+// cloneDirFromURL returns the last non-empty segment of the URL's path, or
+// the URL's hostname if the path is empty.
+func cloneDirFromURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return ""
+	}
+	if name := path.Base(u.Path); name != "/" && name != "." {
+		return name
+	}
+	return u.Hostname()
+}
+
+func cloneRepo(cmd *cobra.Command, remoteURL, dir, badgerLog string, depth int32, mirror bool) (err error) {
+	wrglDir, err := filepath.Abs(filepath.Join(dir, ".wrgl"))
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(wrglDir); err == nil {
+		return fmt.Errorf("destination path %q already contains a repository", dir)
+	}
+	_, statErr := os.Stat(dir)
+	dirCreated := os.IsNotExist(statErr)
+	c, err := conffs.NewStore(wrglDir, conffs.AggregateSource, "").Open()
+	if err != nil {
+		return err
+	}
+	if err := utils.EnsureUserSet(cmd, c); err != nil {
+		return err
+	}
+	cmd.Printf("Cloning into %q...\n", dir)
+	if dirCreated {
+		if err = os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	defer func() {
+		if err != nil {
+			if dirCreated {
+				os.RemoveAll(dir)
+			} else {
+				os.RemoveAll(wrglDir)
+			}
+		}
+	}()
+	rd, err := local.NewRepoDir(wrglDir, badgerLog)
+	if err != nil {
+		return err
+	}
+	defer rd.Close()
+	if err = rd.Init(); err != nil {
+		return err
+	}
+	mirrorOpt := ""
+	if mirror {
+		mirrorOpt = "fetch"
+	}
+	if err = utils.SaveRemote(wrglDir, "origin", remoteURL, false, nil, mirrorOpt); err != nil {
+		return err
+	}
+	s := conffs.NewStore(wrglDir, conffs.AggregateSource, "")
+	c, err = s.Open()
+	if err != nil {
+		return err
+	}
+	db, err := rd.OpenObjectsStore()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	rs := rd.OpenRefStore()
+	cs, err := credentials.NewStore()
+	if err != nil {
+		return err
+	}
+	logger := utils.GetLogger(cmd)
+	cm := utils.NewClientMap(cs, *logger)
+	rem := c.Remote["origin"]
+	if err = utils.WithProgressBar(cmd, false, func(cmd *cobra.Command, barContainer *pbar.Container) error {
+		if err := fetch.Fetch(cmd, db, rs, cm, c.User, "origin", rem, rem.Fetch, false, depth, *logger, barContainer); err != nil {
+			return utils.HandleHTTPError(cmd, cs, rem.URL, err)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if mirror {
+		return nil
+	}
+	return createTrackingBranches(cmd, wrglDir, rs, c.User, "origin", remoteURL)
+}
+
+// createTrackingBranches creates a local branch for each remote-tracking
+// branch of remote and sets the branch's upstream to that remote branch.
+func createTrackingBranches(cmd *cobra.Command, wrglDir string, rs ref.Store, u *conf.User, remote, remoteURL string) error {
+	m, err := ref.ListRemoteRefs(rs, remote)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	s := conffs.NewStore(wrglDir, conffs.LocalSource, "")
+	c, err := s.Open()
+	if err != nil {
+		return err
+	}
+	if c.Branch == nil {
+		c.Branch = map[string]*conf.Branch{}
+	}
+	for _, name := range names {
+		if err := ref.SaveRef(rs, ref.HeadRef(name), m[name], u.Name, u.Email, "clone", "from "+remoteURL, nil); err != nil {
+			return err
+		}
+		c.Branch[name] = &conf.Branch{
+			Remote: remote,
+			Merge:  "refs/heads/" + name,
+		}
+		cmd.Printf("Branch %s set up to track remote branch %s from %s.\n", name, name, remote)
+	}
+	return s.Save(c)
+}
@@ -0,0 +1,149 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package wrgl
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wrgl/wrgl/pkg/conf"
+	conffs "github.com/wrgl/wrgl/pkg/conf/fs"
+	confhelpers "github.com/wrgl/wrgl/pkg/conf/helpers"
+	"github.com/wrgl/wrgl/pkg/factory"
+	"github.com/wrgl/wrgl/pkg/ref"
+	refhelpers "github.com/wrgl/wrgl/pkg/ref/helpers"
+	"github.com/wrgl/wrgl/pkg/testutils"
+)
+
+func TestCloneDirFromURL(t *testing.T) {
+	for _, c := range []struct {
+		url, dir string
+	}{
+		{"https://my-repo.domain.com/my-repo", "my-repo"},
+		{"https://my-repo.domain.com/users/john/data", "data"},
+		{"https://my-repo.domain.com", "my-repo.domain.com"},
+		{"http://localhost:8080/", "localhost"},
+	} {
+		assert.Equal(t, c.dir, cloneDirFromURL(c.url), "url %q", c.url)
+	}
+}
+
+func TestCreateTrackingBranches(t *testing.T) {
+	rd, cleanUp := createRepoDir(t)
+	defer cleanUp()
+	db, err := rd.OpenObjectsStore()
+	require.NoError(t, err)
+	rs := rd.OpenRefStore()
+	sum1, _ := factory.CommitRandom(t, db, nil)
+	sum2, _ := factory.CommitRandom(t, db, nil)
+	require.NoError(t, ref.SaveFetchRef(rs, ref.RemoteRef("origin", "main"), sum1, "John Doe", "john@domain.com", "origin", "storing head"))
+	require.NoError(t, ref.SaveFetchRef(rs, ref.RemoteRef("origin", "staging"), sum2, "John Doe", "john@domain.com", "origin", "storing head"))
+	require.NoError(t, db.Close())
+
+	cmd := rootCmd()
+	buf := bytes.NewBuffer(nil)
+	cmd.SetOut(buf)
+	require.NoError(t, createTrackingBranches(cmd, rd.FullPath, rs, &conf.User{
+		Name: "John Doe", Email: "john@domain.com",
+	}, "origin", "https://my-repo.domain.com/my-repo"))
+	assert.Equal(t, "Branch main set up to track remote branch main from origin.\nBranch staging set up to track remote branch staging from origin.\n", buf.String())
+
+	b, err := ref.GetHead(rs, "main")
+	require.NoError(t, err)
+	assert.Equal(t, sum1, b)
+	b, err = ref.GetHead(rs, "staging")
+	require.NoError(t, err)
+	assert.Equal(t, sum2, b)
+	refhelpers.AssertLatestReflogEqual(t, rs, "heads/main", &ref.Reflog{
+		NewOID:      sum1,
+		AuthorName:  "John Doe",
+		AuthorEmail: "john@domain.com",
+		Action:      "clone",
+		Message:     "from https://my-repo.domain.com/my-repo",
+	})
+
+	c, err := conffs.NewStore(rd.FullPath, conffs.LocalSource, "").Open()
+	require.NoError(t, err)
+	assert.Equal(t, &conf.Branch{Remote: "origin", Merge: "refs/heads/main"}, c.Branch["main"])
+	assert.Equal(t, &conf.Branch{Remote: "origin", Merge: "refs/heads/staging"}, c.Branch["staging"])
+}
+
+func TestCloneRemovesDirOnFailure(t *testing.T) {
+	cleanup := confhelpers.MockGlobalConf(t, true)
+	defer cleanup()
+	s := conffs.NewStore("", conffs.GlobalSource, "")
+	c, err := s.Open()
+	require.NoError(t, err)
+	c.User = &conf.User{Name: "John Doe", Email: "john@domain.com"}
+	require.NoError(t, s.Save(c))
+	rootDir, err := testutils.TempDir("", "test_wrgl_clone")
+	require.NoError(t, err)
+	defer os.RemoveAll(rootDir)
+
+	// a directory created by clone is removed entirely
+	dir := filepath.Join(rootDir, "my-repo")
+	cmd := rootCmd()
+	cmd.SetOut(bytes.NewBuffer(nil))
+	cmd.SetArgs([]string{"clone", "http://127.0.0.1:1/my-repo", dir})
+	require.Error(t, cmd.Execute())
+	_, err = os.Stat(dir)
+	assert.True(t, os.IsNotExist(err))
+
+	// an existing directory is kept, only the repository is removed
+	dir = filepath.Join(rootDir, "existing")
+	require.NoError(t, os.Mkdir(dir, 0755))
+	cmd = rootCmd()
+	cmd.SetOut(bytes.NewBuffer(nil))
+	cmd.SetArgs([]string{"clone", "http://127.0.0.1:1/my-repo", dir})
+	require.Error(t, cmd.Execute())
+	_, err = os.Stat(dir)
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join(dir, ".wrgl"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCloneRemovesDirOnFetchFailure(t *testing.T) {
+	cleanup := confhelpers.MockGlobalConf(t, true)
+	defer cleanup()
+	s := conffs.NewStore("", conffs.GlobalSource, "")
+	c, err := s.Open()
+	require.NoError(t, err)
+	c.User = &conf.User{Name: "John Doe", Email: "john@domain.com"}
+	require.NoError(t, s.Save(c))
+	rootDir, err := testutils.TempDir("", "test_wrgl_clone")
+	require.NoError(t, err)
+	defer os.RemoveAll(rootDir)
+
+	// the remote advertises a branch but fails to send its objects
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if r.Method == http.MethodGet && r.URL.Path == "/refs/" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"refs":{"heads/main":"0123456789abcdef0123456789abcdef"}}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"message":"something went wrong"}`))
+	}))
+	defer srv.Close()
+
+	dir := filepath.Join(rootDir, "my-repo")
+	cmd := rootCmd()
+	buf := bytes.NewBuffer(nil)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"clone", srv.URL, dir})
+	assert.Error(t, cmd.Execute())
+	assert.Contains(t, buf.String(), "Cloning into")
+	require.Greater(t, len(requests), 1, "clone should fail after listing refs")
+	_, err = os.Stat(dir)
+	assert.True(t, os.IsNotExist(err))
+}
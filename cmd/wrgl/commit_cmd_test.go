@@ -9,16 +9,22 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/wrgl/wrgl/pkg/local"
 	"github.com/wrgl/wrgl/pkg/objects"
 	"github.com/wrgl/wrgl/pkg/ref"
 	refhelpers "github.com/wrgl/wrgl/pkg/ref/helpers"
+	"github.com/wrgl/wrgl/pkg/rowsource"
 )
 
 func TestCommitCmd(t *testing.T) {
@@ -335,3 +341,433 @@ func TestCommitDetectPrimaryKeyChange(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, []string{"a", "d"}, tbl.PrimaryKey())
 }
+
+func TestCommitCmdFormats(t *testing.T) {
+	rd, cleanup := createRepoDir(t)
+	defer cleanup()
+
+	_, fp := createCSVFile(t, []string{
+		"a,b,c",
+		"1,q,w",
+		"2,a,s",
+		"3,z,x",
+	})
+	defer os.Remove(fp)
+	commitFile(t, "csv", fp, "a")
+
+	dir := t.TempDir()
+	for _, format := range []string{"parquet", "jsonl"} {
+		buf := bytes.NewBuffer(nil)
+		cmd := rootCmd()
+		cmd.SetArgs([]string{"export", "csv", "--format", format})
+		cmd.SetOut(buf)
+		require.NoError(t, cmd.Execute())
+
+		// format is detected from the file extension
+		fp := filepath.Join(dir, "data."+format)
+		require.NoError(t, os.WriteFile(fp, buf.Bytes(), 0644))
+		commitFile(t, format, fp, "a")
+
+		cmd = rootCmd()
+		cmd.SetArgs([]string{"commit", format + "-stdin", "-", "from stdin", "-p", "a", "-n", "1", "--format", format})
+		cmd.SetIn(bytes.NewReader(buf.Bytes()))
+		cmd.SetOut(io.Discard)
+		require.NoError(t, cmd.Execute())
+	}
+
+	// a file without a known extension, its format is saved with --set-file
+	b, err := os.ReadFile(filepath.Join(dir, "data.jsonl"))
+	require.NoError(t, err)
+	txtFile := filepath.Join(dir, "data.txt")
+	require.NoError(t, os.WriteFile(txtFile, b, 0644))
+	commitFile(t, "txt", txtFile, "a", "--format", "jsonl", "--set-file", "--set-primary-key")
+	cmd := rootCmd()
+	cmd.SetArgs([]string{"commit", "txt", "second commit", "-n", "1"})
+	buf := bytes.NewBuffer(nil)
+	cmd.SetOut(buf)
+	require.NoError(t, cmd.Execute())
+	assert.True(t, strings.HasSuffix(buf.String(), fmt.Sprintf("file %s hasn't changed since the last commit. Aborting.\n", txtFile)))
+
+	db, err := rd.OpenObjectsStore()
+	require.NoError(t, err)
+	rs := rd.OpenRefStore()
+	com, _, err := getCommitTable(db, rs, "csv")
+	require.NoError(t, err)
+	for _, branch := range []string{"parquet", "parquet-stdin", "jsonl", "jsonl-stdin", "txt"} {
+		com2, _, err := getCommitTable(db, rs, branch)
+		require.NoError(t, err)
+		assert.Equal(t, com.Table, com2.Table, branch)
+	}
+	require.NoError(t, db.Close())
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"commit", "x", fp, "msg", "-p", "a", "--format", "xml"})
+	assertCmdFailed(t, cmd, "", fmt.Errorf(`invalid format "xml", must be one of csv, parquet, jsonl, xlsx`))
+
+	pqFile := filepath.Join(dir, "data.parquet")
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"commit", "x", pqFile, "msg", "-p", "a", "--delimiter", "|"})
+	assertCmdFailed(t, cmd, "", fmt.Errorf("delimiter can only be set for CSV files but %s is read as parquet", pqFile))
+}
+
+func TestCommitCmdCompressed(t *testing.T) {
+	rd, cleanup := createRepoDir(t)
+	defer cleanup()
+
+	content := "a,b,c\n1,q,w\n2,a,s\n3,z,x\n"
+	_, fp := createCSVFile(t, strings.Split(strings.TrimSpace(content), "\n"))
+	defer os.Remove(fp)
+	commitFile(t, "csv", fp, "a")
+
+	dir := t.TempDir()
+	buf := bytes.NewBuffer(nil)
+	gw := gzip.NewWriter(buf)
+	_, err := gw.Write([]byte(strings.ReplaceAll(content, ",", "|")))
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+	gzFile := filepath.Join(dir, "data.csv.gz")
+	require.NoError(t, os.WriteFile(gzFile, buf.Bytes(), 0644))
+	commitFile(t, "gzip", gzFile, "a", "--delimiter", "|", "--set-file", "--set-primary-key")
+
+	// unchanged compressed branch file is detected
+	cmd := rootCmd()
+	cmd.SetArgs([]string{"commit", "gzip", "second commit", "-n", "1"})
+	buf = bytes.NewBuffer(nil)
+	cmd.SetOut(buf)
+	require.NoError(t, cmd.Execute())
+	assert.True(t, strings.HasSuffix(buf.String(), fmt.Sprintf("file %s hasn't changed since the last commit. Aborting.\n", gzFile)))
+
+	// format is detected from the extension before .zst
+	buf = bytes.NewBuffer(nil)
+	zw, err := zstd.NewWriter(buf)
+	require.NoError(t, err)
+	_, err = zw.Write([]byte("{\"a\": 1, \"b\": \"q\", \"c\": \"w\"}\n{\"a\": 2, \"b\": \"a\", \"c\": \"s\"}\n{\"a\": 3, \"b\": \"z\", \"c\": \"x\"}\n"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	zstFile := filepath.Join(dir, "data.jsonl.zst")
+	require.NoError(t, os.WriteFile(zstFile, buf.Bytes(), 0644))
+	commitFile(t, "zstd", zstFile, "a")
+
+	db, err := rd.OpenObjectsStore()
+	require.NoError(t, err)
+	rs := rd.OpenRefStore()
+	com, _, err := getCommitTable(db, rs, "csv")
+	require.NoError(t, err)
+	for _, branch := range []string{"gzip", "zstd"} {
+		com2, _, err := getCommitTable(db, rs, branch)
+		require.NoError(t, err)
+		assert.Equal(t, com.Table, com2.Table, branch)
+	}
+	require.NoError(t, db.Close())
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"commit", "x", fp + ".gz", "msg", "-p", "a"})
+	require.NoError(t, os.WriteFile(fp+".gz", []byte(content), 0644))
+	defer os.Remove(fp + ".gz")
+	assertCmdFailed(t, cmd, "", fmt.Errorf("error reading gzip stream: gzip: invalid header"))
+}
+
+func TestCommitCmdURL(t *testing.T) {
+	rd, cleanup := createRepoDir(t)
+	defer cleanup()
+
+	_, fp := createCSVFile(t, []string{
+		"a,b,c",
+		"1,q,w",
+		"2,a,s",
+		"3,z,x",
+	})
+	defer os.Remove(fp)
+	commitFile(t, "csv", fp, "a")
+	commitFile(t, "file-url", "file://"+filepath.ToSlash(fp), "a")
+
+	content, err := os.ReadFile(fp)
+	require.NoError(t, err)
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/data.csv" {
+			http.NotFound(rw, r)
+			return
+		}
+		rw.Write(content)
+	}))
+	defer ts.Close()
+	url := ts.URL + "/data.csv?token=abc"
+	commitFile(t, "http-url", url, "a", "--set-file", "--set-primary-key")
+
+	db, err := rd.OpenObjectsStore()
+	require.NoError(t, err)
+	rs := rd.OpenRefStore()
+	com, _, err := getCommitTable(db, rs, "csv")
+	require.NoError(t, err)
+	for _, branch := range []string{"file-url", "http-url"} {
+		com2, _, err := getCommitTable(db, rs, branch)
+		require.NoError(t, err)
+		assert.Equal(t, com.Table, com2.Table, branch)
+	}
+	require.NoError(t, db.Close())
+
+	// the commit cache is skipped but an unchanged response is still detected
+	cmd := rootCmd()
+	cmd.SetArgs([]string{"commit", "http-url", "second commit", "-n", "1"})
+	buf := bytes.NewBuffer(nil)
+	cmd.SetOut(buf)
+	require.NoError(t, cmd.Execute())
+	assert.True(t, strings.HasSuffix(buf.String(), fmt.Sprintf("file %s hasn't changed since the last commit. Aborting.\n", url)))
+
+	content = append(content, []byte("4,r,t\n")...)
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"commit", "http-url", "second commit", "-n", "1"})
+	require.NoError(t, cmd.Execute())
+	assertPK(t, rd, "http-url", []string{"a"})
+	db, err = rd.OpenObjectsStore()
+	require.NoError(t, err)
+	_, tbl, err := getCommitTable(db, rs, "http-url")
+	require.NoError(t, err)
+	assert.Equal(t, uint32(4), tbl.RowsCount)
+	require.NoError(t, db.Close())
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"commit", "x", ts.URL + "/missing.csv", "msg", "-p", "a"})
+	assertCmdFailed(t, cmd, "", fmt.Errorf("error fetching %s/missing.csv: 404 Not Found", ts.URL))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"commit", "x", "file://example.com/data.csv", "msg", "-p", "a"})
+	assertCmdFailed(t, cmd, "", fmt.Errorf(`file URL "file://example.com/data.csv" must not have a host other than localhost`))
+}
+
+func TestCommitCmdFromSQL(t *testing.T) {
+	rd, cleanup := createRepoDir(t)
+	defer cleanup()
+
+	_, fp := createCSVFile(t, []string{
+		"a,b,c",
+		"1,q,w",
+		"2,a,s",
+		"3,z,x",
+	})
+	defer os.Remove(fp)
+	commitFile(t, "csv", fp, "a")
+
+	source := "sqlite://" + filepath.Join(t.TempDir(), "data.db")
+	sqlDB, err := rowsource.OpenSQL(source)
+	require.NoError(t, err)
+	_, err = sqlDB.Exec(`CREATE TABLE data (a INTEGER, b TEXT, c TEXT);
+		INSERT INTO data VALUES (3, 'z', 'x'), (1, 'q', 'w'), (2, 'a', 's')`)
+	require.NoError(t, err)
+	require.NoError(t, sqlDB.Close())
+
+	query := "SELECT a, b, c FROM data"
+	cmd := rootCmd()
+	cmd.SetArgs([]string{"commit", "sql", "from db", "-p", "a", "-n", "1", "--from-sql", source, "--query", query})
+	require.NoError(t, cmd.Execute())
+
+	db, err := rd.OpenObjectsStore()
+	require.NoError(t, err)
+	rs := rd.OpenRefStore()
+	com, _, err := getCommitTable(db, rs, "csv")
+	require.NoError(t, err)
+	com2, _, err := getCommitTable(db, rs, "sql")
+	require.NoError(t, err)
+	assert.Equal(t, com.Table, com2.Table)
+	assert.Equal(t, "from db\n\nQuery: "+query, com2.Message)
+	require.NoError(t, db.Close())
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"commit", "sql", "from db", "-p", "a", "--from-sql", source})
+	assertCmdFailed(t, cmd, "", fmt.Errorf("flags --from-sql and --query must be set together"))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"commit", "sql", "from db", "-p", "a", "--from-sql", source, "--query", query, "--set-file"})
+	assertCmdFailed(t, cmd, "", fmt.Errorf("flags --all, --set-file, --delimiter and --format can't be used with --from-sql"))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"commit", "sql", fp, "from db", "-p", "a", "--from-sql", source, "--query", query})
+	assertCmdFailed(t, cmd, "", fmt.Errorf("expecting BRANCH and COMMIT_MESSAGE when committing from a SQL query"))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"commit", "sql", "from db", "-p", "a", "--from-sql", source, "--query", "SELECT * FROM missing"})
+	assertCmdFailed(t, cmd, "", fmt.Errorf("error running query: no such table: missing"))
+}
+
+func TestCommitCmdTypes(t *testing.T) {
+	rd, cleanup := createRepoDir(t)
+	defer cleanup()
+
+	_, fp := createCSVFile(t, []string{
+		"id,price,date",
+		"1,1.5,2022-01-01",
+		"2,,2022-01-02",
+		"3,2,",
+	})
+	defer os.Remove(fp)
+	commitFile(t, "alpha", fp, "id", "--types", "id:integer,price:decimal,date:date", "--set-file", "--set-primary-key", "--set-types")
+
+	db, err := rd.OpenObjectsStore()
+	require.NoError(t, err)
+	rs := rd.OpenRefStore()
+	_, tbl, err := getCommitTable(db, rs, "alpha")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"integer", "decimal", "date"}, tbl.Types)
+	require.NoError(t, db.Close())
+
+	// types are read from branch.types
+	cmd := rootCmd()
+	cmd.SetArgs([]string{"commit", "alpha", "second commit", "-n", "1"})
+	buf := bytes.NewBuffer(nil)
+	cmd.SetOut(buf)
+	require.NoError(t, cmd.Execute())
+	assert.True(t, strings.HasSuffix(buf.String(), fmt.Sprintf("file %s hasn't changed since the last commit. Aborting.\n", fp)))
+
+	// changing types creates a new table even if the file hasn't changed
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"commit", "alpha", "second commit", "-n", "1", "--types", "price:decimal"})
+	cmd.SetOut(io.Discard)
+	require.NoError(t, cmd.Execute())
+	db, err = rd.OpenObjectsStore()
+	require.NoError(t, err)
+	_, tbl, err = getCommitTable(db, rs, "alpha")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"string", "decimal", "string"}, tbl.Types)
+	require.NoError(t, db.Close())
+
+	appendToFile(t, fp, "\n4,3,2022-02-30")
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"commit", "alpha", "third commit", "-n", "1"})
+	assertCmdFailed(t, cmd, "\n", fmt.Errorf(`error ingesting rows: row with primary key [4]: column "date": invalid date "2022-02-30"`))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"commit", "alpha", "third commit", "-n", "1", "--types", "price:money"})
+	assertCmdFailed(t, cmd, "", fmt.Errorf(`invalid type "money" for column "price", must be one of string, integer, decimal, boolean, date, timestamp`))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"commit", "alpha", "third commit", "-n", "1", "--types", "amount:decimal"})
+	assertCmdFailed(t, cmd, "\n", fmt.Errorf(`error ingesting rows: typed column "amount" not found in columns`))
+}
+
+func TestCommitCmdRules(t *testing.T) {
+	rd, cleanup := createRepoDir(t)
+	defer cleanup()
+
+	_, fp := createCSVFile(t, []string{
+		"id,name",
+		"v1,Acme",
+		"v2,Globex",
+	})
+	defer os.Remove(fp)
+	commitFile(t, "vendors", fp, "id")
+
+	cmd := rootCmd()
+	cmd.SetArgs([]string{"config", "set", "branch.products.rules", `{"notNull":["sku"],"ranges":{"price":{"min":0}},"references":{"vendor":"vendors"}}`})
+	require.NoError(t, cmd.Execute())
+
+	_, fp = createCSVFile(t, []string{
+		"sku,price,vendor",
+		"a1,10,v1",
+		",5,v2",
+		"a3,-1,v3",
+	})
+	defer os.Remove(fp)
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"commit", "products", fp, "initial commit", "-p", "sku", "--set-file", "--set-primary-key"})
+	assertCmdFailed(t, cmd, "\n", fmt.Errorf(strings.Join([]string{
+		"error ingesting rows: found 3 rule violations:",
+		`  row 2 (line 3, column 1): column "sku" must not be empty`,
+		`  row 3 (line 4, column 4): value "-1" of column "price" is less than 0`,
+		`  row 3 (line 4, column 7): value "v3" of column "vendor" is not found in branch "vendors"`,
+	}, "\n")))
+	rs := rd.OpenRefStore()
+	_, err := ref.GetHead(rs, "products")
+	assert.Equal(t, ref.ErrKeyNotFound, err)
+
+	overrideCSVFile(t, fp, []string{
+		"sku,price,vendor",
+		"a1,10,v1",
+		"a2,5,",
+	})
+	commitFile(t, "products", fp, "sku", "--set-file", "--set-primary-key")
+
+	// rows are checked again even if the file is read from cache
+	appendToFile(t, fp, "a3,0,v9\n")
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"commit", "--all", "mass commit"})
+	cmd.SetOut(io.Discard)
+	assert.Equal(t, strings.Join([]string{
+		`error committing to branch "products": error ingesting rows: found 1 rule violation:`,
+		`  row 3 (line 4, column 6): value "v9" of column "vendor" is not found in branch "vendors"`,
+	}, "\n"), cmd.Execute().Error())
+}
+
+func TestCommitCmdOnDuplicate(t *testing.T) {
+	rd, cleanup := createRepoDir(t)
+	defer cleanup()
+
+	_, fp := createCSVFile(t, []string{
+		"id,name",
+		"1,Alice",
+		"2,\"Bob",
+		"Smith\"",
+		"1,Alicia",
+		"3,Carol",
+		"2,Bobby",
+	})
+	defer os.Remove(fp)
+
+	cmd := rootCmd()
+	cmd.SetArgs([]string{"commit", "alpha", fp, "initial commit", "-p", "id", "--on-duplicate", "error"})
+	cmd.SetOut(io.Discard)
+	assert.Equal(t, strings.Join([]string{
+		"error ingesting rows: found 2 primary key(s) shared by more than one row:",
+		"  [1] on lines 2, 5",
+		"  [2] on lines 3, 7",
+	}, "\n"), cmd.Execute().Error())
+	_, err := ref.GetHead(rd.OpenRefStore(), "alpha")
+	assert.Equal(t, ref.ErrKeyNotFound, err)
+
+	commitFile(t, "alpha", fp, "id", "--on-duplicate", "last")
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"export", "alpha"})
+	assertCmdOutput(t, cmd, strings.Join([]string{
+		"id,name",
+		"1,Alicia",
+		"2,Bobby",
+		"3,Carol",
+		"",
+	}, "\n"))
+
+	reportPath := filepath.Join(t.TempDir(), "dups.csv")
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"commit", "alpha", fp, "second commit", "-p", "id", "--on-duplicate", "report", "--duplicates-file", reportPath})
+	buf := bytes.NewBuffer(nil)
+	cmd.SetOut(buf)
+	require.NoError(t, cmd.Execute())
+	assert.Contains(t, buf.String(), fmt.Sprintf("Found 2 primary key(s) shared by more than one row, rows are written to %s\n", reportPath))
+	b, err := os.ReadFile(reportPath)
+	require.NoError(t, err)
+	assert.Equal(t, strings.Join([]string{
+		"line,id,name",
+		"2,1,Alice",
+		"5,1,Alicia",
+		"3,2,\"Bob",
+		"Smith\"",
+		"7,2,Bobby",
+		"",
+	}, "\n"), string(b))
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"export", "alpha"})
+	assertCmdOutput(t, cmd, strings.Join([]string{
+		"id,name",
+		"1,Alice",
+		"2,\"Bob",
+		"Smith\"",
+		"3,Carol",
+		"",
+	}, "\n"))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"commit", "alpha", fp, "third commit", "-p", "id", "--on-duplicate", "skip"})
+	assertCmdFailed(t, cmd, "", fmt.Errorf(`invalid value "skip" for --on-duplicate, must be one of first, last, error, report`))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"commit", "alpha", fp, "third commit", "-p", "id", "--duplicates-file", reportPath})
+	assertCmdFailed(t, cmd, "", fmt.Errorf("flag --duplicates-file can only be set with --on-duplicate report"))
+}
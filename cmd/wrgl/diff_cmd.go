@@ -8,9 +8,9 @@ import (
 	"encoding/csv"
 	"encoding/hex"
 	"fmt"
+	"io"
 	"os"
 	"path"
-	"path/filepath"
 	"regexp"
 	"sort"
 	"strings"
@@ -32,6 +32,8 @@ import (
 	"github.com/wrgl/wrgl/pkg/pbar"
 	"github.com/wrgl/wrgl/pkg/progress"
 	"github.com/wrgl/wrgl/pkg/ref"
+	"github.com/wrgl/wrgl/pkg/rowfilter"
+	"github.com/wrgl/wrgl/pkg/rowsource"
 	"github.com/wrgl/wrgl/pkg/slice"
 	"github.com/wrgl/wrgl/pkg/sorter"
 	"github.com/wrgl/wrgl/pkg/transaction"
@@ -43,7 +45,7 @@ func newDiffCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "diff { COMMIT | COMMIT_OR_FILE COMMIT_OR_FILE | BRANCH --branch-file | --all | --txid TRANSACTION_ID }",
 		Short: "Show changes between two commits",
-		Long:  "Show changes between two commits with an interactive diff table. A commit can be specified using shorten sum, full sum, or a reference name. If only one commit is specified, it will be compared with a parent commit. It is also possible to specify a local CSV file instead of a commit, in which case both arguments must be given and the flag --primary-key should also be set.",
+		Long:  "Show changes between two commits with an interactive diff table. A commit can be specified using shorten sum, full sum, or a reference name. If only one commit is specified, it will be compared with a parent commit. It is also possible to specify a file instead of a commit, either a local path, \"-\" for stdin, or a file:// or http(s):// URL, in which case both arguments must be given and the flag --primary-key should also be set.",
 		Example: strings.Join([]string{
 			`  # show changes compared to the previous commit`,
 			`  wrgl diff 1a2ed62`,
@@ -51,6 +53,15 @@ func newDiffCmd() *cobra.Command {
 			`  # don't show the interactive table, output to a CSV file instead`,
 			`  wrgl diff 1a2ed62 --no-gui`,
 			``,
+			`  # output only changes to rows that satisfy a filter expression`,
+			`  wrgl diff 1a2ed62 --no-gui --where "country = 'VN'"`,
+			``,
+			`  # output changes as JSON Lines, writing numeric columns as numbers`,
+			`  wrgl diff 1a2ed62 --no-gui --format jsonl --numbers`,
+			``,
+			`  # ignore numeric changes smaller than 0.01 in typed columns (see wrgl commit --types)`,
+			`  wrgl diff branch-1 branch-2 --tolerance 0.01`,
+			``,
 			`  # show changes between branches`,
 			`  wrgl diff branch-1 branch-2`,
 			``,
@@ -63,6 +74,13 @@ func newDiffCmd() *cobra.Command {
 			`  # show changes between a file and the head commit from a branch`,
 			`  wrgl diff my-file.csv my-branch`,
 			``,
+			`  # show changes between rows from stdin or a URL and the head commit from a branch`,
+			`  psql -c "COPY users TO STDOUT CSV HEADER" | wrgl diff - my-branch -p id`,
+			`  wrgl diff https://example.com/users.csv my-branch -p id`,
+			``,
+			`  # compressed files (.gz, .zst or .bz2) are decompressed as they are read`,
+			`  wrgl diff nightly.csv.gz my-branch`,
+			``,
 			`  # show diff between branch.file config (set with wrgl commit --set-file) and the latest commit of a branch`,
 			`  wrgl diff my-branch --branch-file`,
 			``,
@@ -117,6 +135,31 @@ func newDiffCmd() *cobra.Command {
 				return err
 			}
 
+			where, err := cmd.Flags().GetString("where")
+			if err != nil {
+				return err
+			}
+			if where != "" && (!noGUI || all || tid != nil) {
+				return fmt.Errorf("flag --where can only be used with --no-gui when comparing two commits")
+			}
+			format, err := cmd.Flags().GetString("format")
+			if err != nil {
+				return err
+			}
+			if format != "csv" && format != "json" && format != "jsonl" {
+				return fmt.Errorf("invalid format %q, must be one of csv, json, jsonl", format)
+			}
+			if format != "csv" && (!noGUI || all || tid != nil) {
+				return fmt.Errorf("flag --format can only be used with --no-gui when comparing two commits")
+			}
+			numbers, err := cmd.Flags().GetBool("numbers")
+			if err != nil {
+				return err
+			}
+			if numbers && format == "csv" {
+				return fmt.Errorf("flag --numbers can only be used with --format json or jsonl")
+			}
+
 			if tid != nil {
 				return diffTransaction(cmd, c, db, rs, *tid)
 			}
@@ -126,47 +169,65 @@ func newDiffCmd() *cobra.Command {
 			}
 
 			if noGUI {
+				if format == "json" || format == "jsonl" {
+					return runDiff(cmd, c, db, memStore, rs, pk, args, branchFile, false, outputDiffToJSON)
+				}
 				return runDiff(cmd, c, db, memStore, rs, pk, args, branchFile, false, outputDiffToCSV)
 			}
 			return runDiff(cmd, c, db, memStore, rs, pk, args, branchFile, false, outputDiffToTerminal)
 		},
 	}
-	cmd.Flags().Bool("no-gui", false, "don't show the diff table, instead output changes to file DIFF_SUM1_SUM2.csv")
+	cmd.Flags().Bool("no-gui", false, "don't show the diff table, instead output changes to file DIFF_SUM1_SUM2.csv (or .json, .jsonl depending on --format)")
 	cmd.Flags().StringSliceP("primary-key", "p", []string{}, "field names to be used as primary key (only applicable if diff target is a file)")
 	cmd.Flags().Bool("branch-file", false, "if only one argument is given and it is a branch name, compare against branch.file (if it is configured with wrgl commit --set-file)")
 	cmd.Flags().Bool("all", false, "show diff summary for all branches that have branch.file configured. This flag is automatically set when no argument is given and --txid is not set")
 	cmd.Flags().String("txid", "", "show diff summary for all changes with specified transaction id")
 	cmd.Flags().String("delimiter-1", "", "CSV delimiter of the first argument if the first argument is an external file. Defaults to comma.")
 	cmd.Flags().String("delimiter-2", "", "CSV delimiter of the second argument if the second argument is an external file. Defaults to comma.")
+	registerInputFormatFlag(cmd.Flags(), "format-1", "the first argument if it is an external file")
+	registerInputFormatFlag(cmd.Flags(), "format-2", "the second argument if it is an external file")
 	cmd.Flags().Bool("no-cache", false, "skip commit cache which by default keeps the command from ingesting the same file again if there has been no changes")
 	registerCommitFlags(cmd.Flags())
+	cmd.Flags().String("format", "csv", strings.Join([]string{
+		"with --no-gui, format of the output file, one of csv, json or jsonl. json writes a JSON array",
+		"to file DIFF_SUM1_SUM2.json and jsonl writes JSON Lines to file DIFF_SUM1_SUM2.jsonl, both with",
+		"one object per changed row such as {\"change\": \"modified\", \"old\": {...}, \"new\": {...}}.",
+	}, " "))
+	cmd.Flags().Bool("numbers", false, strings.Join([]string{
+		"with --format json or jsonl, write values of numeric columns (according to the table profile)",
+		"as JSON numbers, and empty values of those columns as null. By default all values are strings.",
+	}, " "))
+	cmd.Flags().Float64("tolerance", 0, strings.Join([]string{
+		"if the compared tables have typed columns (see wrgl commit --types), values are compared by type",
+		"so that e.g. \"1.0\" and \"1\" are equal. Numbers that differ by no more than this amount are also",
+		"considered equal.",
+	}, " "))
+	registerWhereFlag(cmd.Flags())
 	return cmd
 }
 
 func getSecondCommit(
 	cmd *cobra.Command, c *conf.Config, db objects.Store, memDB *objmock.Store, rs ref.Store,
-	pk []string, args []string, commit1 *objects.Commit, branchFile, quiet bool, delim rune,
+	pk []string, args []string, commit1 *objects.Commit, branchFile, quiet bool, delim rune, format string,
 ) (inUsedDB objects.Store, name, hash string, commit *objects.Commit, err error) {
 	if branchFile {
-		return getCommit(cmd, c, db, memDB, rs, pk, args[0], false, quiet, delim)
+		return getCommit(cmd, c, db, memDB, rs, pk, args[0], false, quiet, delim, format)
 	}
 	if len(args) > 1 {
-		return getCommit(cmd, c, db, memDB, rs, pk, args[1], false, quiet, delim)
+		return getCommit(cmd, c, db, memDB, rs, pk, args[1], false, quiet, delim, format)
 	}
 	if len(commit1.Parents) > 0 {
-		return getCommit(cmd, c, db, memDB, rs, pk, hex.EncodeToString(commit1.Parents[0]), false, quiet, delim)
+		return getCommit(cmd, c, db, memDB, rs, pk, hex.EncodeToString(commit1.Parents[0]), false, quiet, delim, format)
 	}
 	err = fmt.Errorf("specify the second object to diff against")
 	return
 }
 
-func createInMemCommit(cmd *cobra.Command, db *objmock.Store, pk []string, file *os.File, quiet bool, delim rune) (hash []byte, commit *objects.Commit, err error) {
+func createInMemCommit(cmd *cobra.Command, db *objmock.Store, pk []string, rows rowsource.Reader, quiet bool) (hash []byte, commit *objects.Commit, err error) {
 	logger := utils.GetLogger(cmd)
 	sum, err := ingestTable(
-		cmd, db, file, pk, quiet, *logger,
-		[]sorter.SorterOption{
-			sorter.WithDelimiter(delim),
-		},
+		cmd, db, rows, pk, quiet, *logger,
+		[]sorter.SorterOption{},
 		[]ingest.InserterOption{},
 	)
 	if err != nil {
@@ -201,15 +262,18 @@ func displayableCommitName(name string, sum []byte) string {
 
 func getCommit(
 	cmd *cobra.Command, c *conf.Config, db objects.Store, memStore *objmock.Store,
-	rs ref.Store, pk []string, cStr string, branchFile, quiet bool, delim rune,
+	rs ref.Store, pk []string, cStr string, branchFile, quiet bool, delim rune, format string,
 ) (inUsedDB objects.Store, name, hash string, commit *objects.Commit, err error) {
 	inUsedDB = db
-	var file *os.File
-	name, hashb, commit, err := ref.InterpretCommitName(db, rs, cStr, false)
-	if err != nil {
-		file, err = os.Open(cStr)
+	var hashb []byte
+	if !isStdinOrURL(cStr) {
+		name, hashb, commit, err = ref.InterpretCommitName(db, rs, cStr, false)
+	}
+	if isStdinOrURL(cStr) || err != nil {
+		var file io.ReadCloser
+		file, err = openInput(cmd, cStr)
 		if err != nil {
-			if filePattern.MatchString(cStr) {
+			if !isStdinOrURL(cStr) && filePattern.MatchString(cStr) {
 				err = fmt.Errorf("can't find file %s", cStr)
 				return
 			}
@@ -217,9 +281,14 @@ func getCommit(
 		}
 		inUsedDB = memStore
 		defer file.Close()
-		hashb, commit, err = createInMemCommit(cmd, memStore, pk, file, quiet, delim)
+		var rows rowsource.Reader
+		rows, err = newRowReader(file, cStr, format, delim)
+		if err != nil {
+			return
+		}
+		hashb, commit, err = createInMemCommit(cmd, memStore, pk, rows, quiet)
 		hash = hex.EncodeToString(hashb)
-		return inUsedDB, path.Base(file.Name()), hash, commit, err
+		return inUsedDB, inputName(cStr), hash, commit, err
 	}
 	if branchFile && strings.HasPrefix(name, "heads/") {
 		branchName := strings.TrimPrefix(name, "heads/")
@@ -235,8 +304,17 @@ func getCommit(
 			err = errFileNotSet
 			return
 		} else {
+			opts := branchIngestOptions(branch)
+			// rules are checked when committing, not when diffing
+			opts.Rules = nil
+			if delim != 0 {
+				opts.Delimiter = delim
+			}
+			if format != "" {
+				opts.Format = format
+			}
 			var tmpSum []byte
-			tmpSum, err = ensureTempCommit(cmd, db, rs, c, branchName, branch.File, branch.PrimaryKey, quiet, delim)
+			tmpSum, err = ensureTempCommit(cmd, db, rs, c, branchName, branch.File, opts, quiet)
 			if err != nil {
 				return
 			}
@@ -244,7 +322,7 @@ func getCommit(
 			if err != nil {
 				return
 			}
-			name = filepath.Base(branch.File)
+			name = inputName(branch.File)
 			hash = hex.EncodeToString(tmpSum)
 			return
 		}
@@ -334,16 +412,18 @@ func rowLabel(label, commitName, commitSum string) string {
 	return fmt.Sprintf("%s %s (%s)", label, commitName, commitSum[:7])
 }
 
+// writeRowChanges calls write with each changed row, row is nil if the row
+// is removed and oldRow is nil if the row is added. Rows are in the column
+// order of their own tables.
 func writeRowChanges(
 	cmd *cobra.Command,
-	w *csv.Writer,
 	db1, db2 objects.Store,
-	name1, name2 string,
-	commitHash1, commitHash2 string,
 	tbl1, tbl2 *objects.Table,
 	diffChan <-chan *objects.Diff,
 	pt progress.Tracker,
 	colDiff *diff.ColDiff,
+	filter *rowfilter.Filter,
+	write func(row, oldRow []string) error,
 ) (err error) {
 	buf, err := diff.NewBlockBuffer([]objects.Store{db1, db2}, []*objects.Table{tbl1, tbl2})
 	if err != nil {
@@ -371,7 +451,6 @@ func writeRowChanges(
 					if err != nil {
 						return err
 					}
-					row = colDiff.RearrangeRow(0, row)
 				}
 				if d.OldSum != nil {
 					blk, off := diff.RowToBlockAndOffset(d.OldOffset)
@@ -379,40 +458,15 @@ func writeRowChanges(
 					if err != nil {
 						return err
 					}
-					oldRow = colDiff.RearrangeBaseRow(oldRow)
 				}
-
-				if d.OldSum == nil {
-					err = w.Write(append(
-						[]string{rowLabel("ADDED IN", name1, commitHash1)},
-						row...,
-					))
-					if err != nil {
-						return err
-					}
-				} else if d.Sum == nil {
-					err = w.Write(append(
-						[]string{rowLabel("REMOVED IN", name1, commitHash1)},
-						oldRow...,
-					))
-					if err != nil {
-						return err
-					}
-				} else {
-					err = w.Write(append(
-						[]string{rowLabel("BASE ROW FROM", name2, commitHash2)},
-						oldRow...,
-					))
-					if err != nil {
-						return err
-					}
-					err = w.Write(append(
-						[]string{rowLabel("MODIFIED IN", name1, commitHash1)},
-						row...,
-					))
-					if err != nil {
-						return err
-					}
+				// a modified row is kept if either version satisfies the filter
+				if filter != nil &&
+					!(row != nil && filter.Match(colDiff.RearrangeRow(0, row))) &&
+					!(oldRow != nil && filter.Match(colDiff.RearrangeBaseRow(oldRow))) {
+					continue
+				}
+				if err = write(row, oldRow); err != nil {
+					return err
 				}
 			}
 		}
@@ -434,6 +488,10 @@ func outputDiffToCSV(
 	colDiff *diff.ColDiff,
 	tpd *diffprof.TableProfileDiff,
 ) (err error) {
+	filter, err := getWhereFilter(cmd, colDiff.Names)
+	if err != nil {
+		return
+	}
 	wd, err := os.Getwd()
 	if err != nil {
 		return
@@ -488,7 +546,30 @@ func outputDiffToCSV(
 
 	if uintSliceEqual(colDiff.BasePK, colDiff.OtherPK[0]) {
 		// primary key stays the same, we can compare individual rows now
-		err = writeRowChanges(cmd, w, db1, db2, name1, name2, commitHash1, commitHash2, tbl1, tbl2, diffChan, pt, colDiff)
+		err = writeRowChanges(cmd, db1, db2, tbl1, tbl2, diffChan, pt, colDiff, filter, func(row, oldRow []string) error {
+			if oldRow == nil {
+				return w.Write(append(
+					[]string{rowLabel("ADDED IN", name1, commitHash1)},
+					colDiff.RearrangeRow(0, row)...,
+				))
+			}
+			if row == nil {
+				return w.Write(append(
+					[]string{rowLabel("REMOVED IN", name1, commitHash1)},
+					colDiff.RearrangeBaseRow(oldRow)...,
+				))
+			}
+			if err := w.Write(append(
+				[]string{rowLabel("BASE ROW FROM", name2, commitHash2)},
+				colDiff.RearrangeBaseRow(oldRow)...,
+			)); err != nil {
+				return err
+			}
+			return w.Write(append(
+				[]string{rowLabel("MODIFIED IN", name1, commitHash1)},
+				colDiff.RearrangeRow(0, row)...,
+			))
+		})
 		if err != nil {
 			return
 		}
@@ -499,6 +580,90 @@ func outputDiffToCSV(
 	return nil
 }
 
+// outputDiffToJSON writes row changes to file DIFF_SUM1_SUM2.json (a JSON
+// array) or DIFF_SUM1_SUM2.jsonl (JSON Lines) depending on flag --format. Each
+// change is an object such as {"change": "modified", "old": {...}, "new": {...}}
+// where "old" and "new" are rows keyed by column names of their own table.
+func outputDiffToJSON(
+	cmd *cobra.Command,
+	db1, db2 objects.Store,
+	name1, name2 string,
+	commitHash1, commitHash2 string,
+	tbl1, tbl2 *objects.Table,
+	diffChan <-chan *objects.Diff,
+	pt progress.Tracker,
+	colDiff *diff.ColDiff,
+	tpd *diffprof.TableProfileDiff,
+) (err error) {
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return
+	}
+	numbers, err := cmd.Flags().GetBool("numbers")
+	if err != nil {
+		return
+	}
+	filter, err := getWhereFilter(cmd, colDiff.Names)
+	if err != nil {
+		return
+	}
+	var numeric1, numeric2 []bool
+	if numbers {
+		if numeric1, err = numericColumns(db1, tbl1, nil); err != nil {
+			return
+		}
+		if numeric2, err = numericColumns(db2, tbl2, nil); err != nil {
+			return
+		}
+	}
+	enc1 := newJSONObjectEncoder(tbl1.Columns, numeric1)
+	enc2 := newJSONObjectEncoder(tbl2.Columns, numeric2)
+	wd, err := os.Getwd()
+	if err != nil {
+		return
+	}
+	filename := fmt.Sprintf("DIFF_%s_%s.%s", commitHash1[:7], commitHash2[:7], format)
+	f, err := os.Create(path.Join(wd, filename))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	w := newJSONRowsWriter(f, format == "json")
+
+	if uintSliceEqual(colDiff.BasePK, colDiff.OtherPK[0]) {
+		var buf []byte
+		err = writeRowChanges(cmd, db1, db2, tbl1, tbl2, diffChan, pt, colDiff, filter, func(row, oldRow []string) error {
+			switch {
+			case oldRow == nil:
+				buf = append(buf[:0], `{"change": "added", "new": `...)
+			case row == nil:
+				buf = append(buf[:0], `{"change": "removed", "old": `...)
+			default:
+				buf = append(buf[:0], `{"change": "modified", "old": `...)
+			}
+			if oldRow != nil {
+				buf = enc2.appendObject(buf, oldRow)
+				if row != nil {
+					buf = append(buf, `, "new": `...)
+				}
+			}
+			if row != nil {
+				buf = enc1.appendObject(buf, row)
+			}
+			return w.writeObject(append(buf, '}'))
+		})
+		if err != nil {
+			return
+		}
+	}
+
+	if err = w.Close(); err != nil {
+		return
+	}
+	cmd.Printf("saved changes to file %s\n", filename)
+	return nil
+}
+
 func commitTitle(commitName, commitSum string) string {
 	if commitName == "" {
 		return fmt.Sprintf("[yellow]%s[white]", commitSum[:7])
@@ -507,7 +672,7 @@ func commitTitle(commitName, commitSum string) string {
 }
 
 func getDiffChan(
-	cmd *cobra.Command, db1, db2 objects.Store, rs ref.Store, commit1, commit2 *objects.Commit,
+	cmd *cobra.Command, db1, db2 objects.Store, rs ref.Store, commit1, commit2 *objects.Commit, tolerance float64,
 ) (tbl1, tbl2 *objects.Table, diffChan <-chan *objects.Diff, pt progress.Tracker, cd *diff.ColDiff, errChan chan error, err error) {
 	tbl1, err = utils.GetTable(db1, rs, commit1)
 	if err != nil {
@@ -535,6 +700,9 @@ func getDiffChan(
 	errChan = make(chan error, 10)
 	opts := []diff.DiffOption{}
 	opts = append(opts, diff.WithProgressInterval(65*time.Millisecond))
+	if tbl1.Types != nil || tbl2.Types != nil {
+		opts = append(opts, diff.WithTypedComparison(tolerance))
+	}
 	logger := utils.GetLogger(cmd)
 	diffChan, pt = diff.DiffTables(db1, db2, tbl1, tbl2, tblIdx1, tblIdx2, errChan, *logger, opts...)
 	return tbl1, tbl2, diffChan, pt, cd, errChan, nil
@@ -651,19 +819,9 @@ func outputDiffSummaryToTerminal(
 ) (string, error) {
 	sb := &strings.Builder{}
 	if !uintSliceEqual(cd.BasePK, cd.OtherPK[0]) {
-		_, addedCols, removedCols := slice.CompareStringSlices(tbl1.Columns, tbl2.Columns)
 		pkEqual := slice.StringSliceEqual(tbl1.PrimaryKey(), tbl2.PrimaryKey())
-		if len(addedCols) > 0 || len(removedCols) > 0 {
-			sb.WriteString("columns: ")
-			if len(addedCols) > 0 {
-				colorstring.Fprintf(sb, "[green]+%d[reset]", len(addedCols))
-				if len(removedCols) > 0 {
-					sb.WriteString("/")
-				}
-			}
-			if len(removedCols) > 0 {
-				colorstring.Fprintf(sb, "[red]-%d[reset]", len(removedCols))
-			}
+		if colsSum := columnsSummary(tbl1, tbl2); colsSum != "" {
+			sb.WriteString(colsSum)
 			if !pkEqual {
 				sb.WriteString("; ")
 			}
@@ -699,6 +857,27 @@ func outputDiffSummaryToTerminal(
 	return sb.String(), nil
 }
 
+// columnsSummary returns a colored summary of columns added to and removed
+// from tbl1 compared to tbl2, or an empty string if columns are the same.
+func columnsSummary(tbl1, tbl2 *objects.Table) string {
+	_, addedCols, removedCols := slice.CompareStringSlices(tbl1.Columns, tbl2.Columns)
+	if len(addedCols) == 0 && len(removedCols) == 0 {
+		return ""
+	}
+	sb := &strings.Builder{}
+	sb.WriteString("columns: ")
+	if len(addedCols) > 0 {
+		colorstring.Fprintf(sb, "[green]+%d[reset]", len(addedCols))
+		if len(removedCols) > 0 {
+			sb.WriteString("/")
+		}
+	}
+	if len(removedCols) > 0 {
+		colorstring.Fprintf(sb, "[red]-%d[reset]", len(removedCols))
+	}
+	return sb.String()
+}
+
 func diffTableProfiles(db1, db2 objects.Store, commit1, commit2 *objects.Commit) *diffprof.TableProfileDiff {
 	prof1, err := objects.GetTableProfile(db1, commit1.Table)
 	if err != nil {
@@ -726,11 +905,18 @@ func runDiff(
 		tpd *diffprof.TableProfileDiff,
 	) error,
 ) error {
+	if len(args) == 2 && args[0] == "-" && args[1] == "-" {
+		return fmt.Errorf("only one of the two files can be read from stdin")
+	}
 	delim1, err := utils.GetRuneFromFlag(cmd, "delimiter-1")
 	if err != nil {
 		return err
 	}
-	db1, name1, commitHash1, commit1, err := getCommit(cmd, c, db, memStore, rs, pk, args[0], branchFile, quiet, delim1)
+	format1, err := getInputFormat(cmd, "format-1")
+	if err != nil {
+		return err
+	}
+	db1, name1, commitHash1, commit1, err := getCommit(cmd, c, db, memStore, rs, pk, args[0], branchFile, quiet, delim1, format1)
 	if err != nil {
 		return err
 	}
@@ -739,12 +925,20 @@ func runDiff(
 	if err != nil {
 		return err
 	}
-	db2, name2, commitHash2, commit2, err := getSecondCommit(cmd, c, db, memStore, rs, pk, args, commit1, branchFile, quiet, delim2)
+	format2, err := getInputFormat(cmd, "format-2")
+	if err != nil {
+		return err
+	}
+	db2, name2, commitHash2, commit2, err := getSecondCommit(cmd, c, db, memStore, rs, pk, args, commit1, branchFile, quiet, delim2, format2)
 	if err != nil {
 		return err
 	}
 
-	tbl1, tbl2, diffChan, pt, cd, errChan, err := getDiffChan(cmd, db1, db2, rs, commit1, commit2)
+	tolerance, err := cmd.Flags().GetFloat64("tolerance")
+	if err != nil {
+		return err
+	}
+	tbl1, tbl2, diffChan, pt, cd, errChan, err := getDiffChan(cmd, db1, db2, rs, commit1, commit2, tolerance)
 	if err != nil {
 		return err
 	}
@@ -836,7 +1030,9 @@ func diffAllBranches(
 		if branch.File == "" {
 			continue
 		}
-		if _, err := os.Stat(branch.File); os.IsNotExist(err) {
+		if ok, err := inputExists(branch.File); err != nil {
+			return err
+		} else if !ok {
 			cmd.Printf("File %q does not exist, skipping branch %q.\n", branch.File, name)
 			continue
 		}
@@ -307,3 +307,158 @@ func TestDiffWithDelimiter(t *testing.T) {
 		}, "\n")
 	})
 }
+
+func TestDiffCmdWhere(t *testing.T) {
+	_, cleanup := createRepoDir(t)
+	defer cleanup()
+
+	_, fp1 := createCSVFile(t, []string{
+		"a,b,c",
+		"1,q,w",
+		"2,a,s",
+		"3,z,x",
+	})
+	defer os.Remove(fp1)
+	commitFile(t, "my-branch", fp1, "a")
+
+	_, fp2 := createCSVFile(t, []string{
+		"a,b,c",
+		"1,q,e",
+		"2,a,s",
+		"4,s,d",
+	})
+	defer os.Remove(fp2)
+	commitFile(t, "my-branch", fp2, "a")
+
+	assertDiffCSVEqual(t, []string{"my-branch", "my-branch^", "--where", "a >= 3"}, func(sum1, sum2 string) string {
+		return strings.Join([]string{
+			fmt.Sprintf("COLUMNS IN my-branch^ (%s),a,b,c", sum2),
+			fmt.Sprintf("COLUMNS IN my-branch (%s),a,b,c", sum1),
+			fmt.Sprintf("PRIMARY KEY IN my-branch^ (%s),true,,", sum2),
+			fmt.Sprintf("PRIMARY KEY IN my-branch (%s),true,,", sum1),
+			fmt.Sprintf("ADDED IN my-branch (%s),4,s,d", sum1),
+			fmt.Sprintf("REMOVED IN my-branch (%s),3,z,x", sum1),
+			"",
+		}, "\n")
+	})
+
+	assertDiffCSVEqual(t, []string{"my-branch", "my-branch^", "--where", "c = 'w'"}, func(sum1, sum2 string) string {
+		return strings.Join([]string{
+			fmt.Sprintf("COLUMNS IN my-branch^ (%s),a,b,c", sum2),
+			fmt.Sprintf("COLUMNS IN my-branch (%s),a,b,c", sum1),
+			fmt.Sprintf("PRIMARY KEY IN my-branch^ (%s),true,,", sum2),
+			fmt.Sprintf("PRIMARY KEY IN my-branch (%s),true,,", sum1),
+			fmt.Sprintf("BASE ROW FROM my-branch^ (%s),1,q,w", sum2),
+			fmt.Sprintf("MODIFIED IN my-branch (%s),1,q,e", sum1),
+			"",
+		}, "\n")
+	})
+
+	cmd := rootCmd()
+	cmd.SetArgs([]string{"diff", "my-branch", "--where", "a = 1"})
+	assert.Equal(t, fmt.Errorf("flag --where can only be used with --no-gui when comparing two commits"), cmd.Execute())
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"diff", "my-branch", "--no-gui", "--where", "d = 1"})
+	assert.Equal(t, fmt.Errorf("error parsing flag --where: unknown column \"d\""), cmd.Execute())
+}
+
+func TestDiffCmdJSON(t *testing.T) {
+	_, cleanup := createRepoDir(t)
+	defer cleanup()
+
+	_, fp1 := createCSVFile(t, []string{
+		"a,b,c",
+		"1,q,1.5",
+		"2,a,2",
+		"3,z,",
+	})
+	defer os.Remove(fp1)
+	commitFile(t, "my-branch", fp1, "a")
+
+	_, fp2 := createCSVFile(t, []string{
+		"a,b,c",
+		"1,q,3",
+		"2,a,2",
+		"4,s,4",
+	})
+	defer os.Remove(fp2)
+	commitFile(t, "my-branch", fp2, "a")
+
+	readDiffFile := func(args ...string) string {
+		t.Helper()
+		cmd := rootCmd()
+		cmd.SetArgs(append([]string{"diff", "my-branch", "my-branch^", "--no-gui"}, args...))
+		buf := bytes.NewBuffer(nil)
+		cmd.SetOut(buf)
+		require.NoError(t, cmd.Execute())
+		submatch := regexp.MustCompile(`DIFF_.+_.+\.jsonl?`).FindString(buf.String())
+		defer os.Remove(submatch)
+		b, err := os.ReadFile(submatch)
+		require.NoError(t, err)
+		return string(b)
+	}
+
+	assert.Equal(t, strings.Join([]string{
+		`{"change": "modified", "old": {"a": "1", "b": "q", "c": "1.5"}, "new": {"a": "1", "b": "q", "c": "3"}}`,
+		`{"change": "added", "new": {"a": "4", "b": "s", "c": "4"}}`,
+		`{"change": "removed", "old": {"a": "3", "b": "z", "c": ""}}`,
+		"",
+	}, "\n"), readDiffFile("--format", "jsonl"))
+
+	assert.Equal(t, strings.Join([]string{
+		`[`,
+		`  {"change": "modified", "old": {"a": 1, "b": "q", "c": 1.5}, "new": {"a": 1, "b": "q", "c": 3}},`,
+		`  {"change": "removed", "old": {"a": 3, "b": "z", "c": null}}`,
+		`]`,
+		"",
+	}, "\n"), readDiffFile("--format", "json", "--numbers", "--where", "a < 4"))
+
+	cmd := rootCmd()
+	cmd.SetArgs([]string{"diff", "my-branch", "--format", "json"})
+	assert.Equal(t, fmt.Errorf("flag --format can only be used with --no-gui when comparing two commits"), cmd.Execute())
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"diff", "my-branch", "--no-gui", "--numbers"})
+	assert.Equal(t, fmt.Errorf("flag --numbers can only be used with --format json or jsonl"), cmd.Execute())
+}
+
+func TestDiffCmdStdin(t *testing.T) {
+	_, cleanup := createRepoDir(t)
+	defer cleanup()
+
+	_, fp := createCSVFile(t, []string{
+		"a,b,c",
+		"1,q,w",
+		"2,a,s",
+	})
+	defer os.Remove(fp)
+	commitFile(t, "my-branch", fp, "a")
+
+	cmd := rootCmd()
+	cmd.SetArgs([]string{"diff", "-", "my-branch", "--primary-key", "a", "--no-gui"})
+	cmd.SetIn(strings.NewReader("a,b,c\n1,q,w\n2,a,d\n"))
+	buf := bytes.NewBuffer(nil)
+	cmd.SetOut(buf)
+	require.NoError(t, cmd.Execute())
+	pat := regexp.MustCompile(`DIFF_(.+)_(.+)\.csv`)
+	submatch := pat.FindStringSubmatch(buf.String())
+	require.NotNil(t, submatch)
+	defer os.Remove(submatch[0])
+	b, err := os.ReadFile(submatch[0])
+	require.NoError(t, err)
+	sum1, sum2 := submatch[1], submatch[2]
+	assert.Equal(t, strings.Join([]string{
+		fmt.Sprintf("COLUMNS IN my-branch (%s),a,b,c", sum2),
+		fmt.Sprintf("COLUMNS IN stdin (%s),a,b,c", sum1),
+		fmt.Sprintf("PRIMARY KEY IN my-branch (%s),true,,", sum2),
+		fmt.Sprintf("PRIMARY KEY IN stdin (%s),true,,", sum1),
+		fmt.Sprintf("BASE ROW FROM my-branch (%s),2,a,s", sum2),
+		fmt.Sprintf("MODIFIED IN stdin (%s),2,a,d", sum1),
+		"",
+	}, "\n"), string(b))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"diff", "-", "-", "--primary-key", "a", "--no-gui"})
+	assertCmdFailed(t, cmd, "", fmt.Errorf("only one of the two files can be read from stdin"))
+}
@@ -0,0 +1,349 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package wrgl
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wrgl/wrgl/pkg/objects"
+	"github.com/wrgl/wrgl/pkg/parquet"
+	"github.com/wrgl/wrgl/pkg/ref"
+	"github.com/wrgl/wrgl/pkg/rowsource"
+)
+
+func TestExportCmdWhere(t *testing.T) {
+	_, cleanup := createRepoDir(t)
+	defer cleanup()
+
+	_, fp := createCSVFile(t, []string{
+		"id,country,price",
+		"1,VN,9.5",
+		"2,VN,12",
+		"3,US,100",
+		"4,VN,10",
+	})
+	defer os.Remove(fp)
+	commitFile(t, "main", fp, "id")
+
+	cmd := rootCmd()
+	cmd.SetArgs([]string{"export", "main", "--where", "country = 'VN' AND price >= 10"})
+	assertCmdOutput(t, cmd, strings.Join([]string{
+		"id,country,price",
+		"2,VN,12",
+		"4,VN,10",
+		"",
+	}, "\n"))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"export", "main", "--where", "price >"})
+	assert.Equal(t, fmt.Errorf("error parsing flag --where: expected column name or value but got end of expression at position 7"), cmd.Execute())
+}
+
+func TestExportCmdColumns(t *testing.T) {
+	_, cleanup := createRepoDir(t)
+	defer cleanup()
+
+	_, fp := createCSVFile(t, []string{
+		"id,country,price,note",
+		"1,VN,9.5,a",
+		"2,VN,12,b",
+		"3,US,100,c",
+	})
+	defer os.Remove(fp)
+	commitFile(t, "main", fp, "id")
+
+	cmd := rootCmd()
+	cmd.SetArgs([]string{"export", "main", "--columns", "price,id"})
+	assertCmdOutput(t, cmd, strings.Join([]string{
+		"price,id",
+		"9.5,1",
+		"12,2",
+		"100,3",
+		"",
+	}, "\n"))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"export", "main", "--exclude-columns", "note,country"})
+	assertCmdOutput(t, cmd, strings.Join([]string{
+		"id,price",
+		"1,9.5",
+		"2,12",
+		"3,100",
+		"",
+	}, "\n"))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"export", "main", "--columns", "id,note", "--where", "country = 'VN' AND price > 10"})
+	assertCmdOutput(t, cmd, strings.Join([]string{
+		"id,note",
+		"2,b",
+		"",
+	}, "\n"))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"export", "main", "--columns", "id", "--exclude-columns", "note"})
+	assert.Equal(t, fmt.Errorf("flags --columns and --exclude-columns can't be used together"), cmd.Execute())
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"export", "main", "--columns", "id,name"})
+	assert.Equal(t, fmt.Errorf("column %q not found", "name"), cmd.Execute())
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"export", "main", "--columns", "id,id"})
+	assert.Equal(t, fmt.Errorf("column %q is selected more than once", "id"), cmd.Execute())
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"export", "main", "--exclude-columns", "id,country,price,note"})
+	assert.Equal(t, fmt.Errorf("all columns are excluded"), cmd.Execute())
+}
+
+func TestExportCmdParquet(t *testing.T) {
+	rd, cleanup := createRepoDir(t)
+	defer cleanup()
+
+	_, fp := createCSVFile(t, []string{
+		"id,name,price,qty,code",
+		"1,apple,9.5,2,1.0",
+		"2,banana,12,,2",
+		"3,cherry,100,5,3",
+	})
+	defer os.Remove(fp)
+	commitFile(t, "main", fp, "id")
+
+	db, err := rd.OpenObjectsStore()
+	require.NoError(t, err)
+	rs := rd.OpenRefStore()
+	_, _, commit, err := ref.InterpretCommitName(db, rs, "main", false)
+	require.NoError(t, err)
+	tbl, err := objects.GetTable(db, commit.Table)
+	require.NoError(t, err)
+	columns, err := parquetColumns(db, tbl, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []parquet.Column{
+		{Name: "id", Type: parquet.Int64},
+		{Name: "name", Type: parquet.String},
+		{Name: "price", Type: parquet.Double},
+		{Name: "qty", Type: parquet.Int64},
+		{Name: "code", Type: parquet.Double},
+	}, columns)
+	columns, err = parquetColumns(db, tbl, []uint32{3, 1})
+	require.NoError(t, err)
+	assert.Equal(t, []parquet.Column{
+		{Name: "qty", Type: parquet.Int64},
+		{Name: "name", Type: parquet.String},
+	}, columns)
+	require.NoError(t, db.Close())
+
+	cmd := rootCmd()
+	cmd.SetArgs([]string{"export", "main", "--format", "parquet", "--where", "qty > 1"})
+	buf := bytes.NewBuffer(nil)
+	cmd.SetOut(buf)
+	require.NoError(t, cmd.Execute())
+	b := buf.Bytes()
+	assert.Equal(t, "PAR1", string(b[:4]))
+	assert.Equal(t, "PAR1", string(b[len(b)-4:]))
+	assert.Contains(t, string(b), "cherry")
+	assert.NotContains(t, string(b), "banana")
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"export", "main", "--format", "parquet", "--delimiter", "|"})
+	assert.Equal(t, fmt.Errorf("flag --delimiter can only be used with --format csv"), cmd.Execute())
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"export", "main", "--format", "xlsx"})
+	assert.Equal(t, fmt.Errorf("invalid format %q, must be one of csv, parquet, json, jsonl", "xlsx"), cmd.Execute())
+}
+
+func TestExportCmdJSON(t *testing.T) {
+	_, cleanup := createRepoDir(t)
+	defer cleanup()
+
+	_, fp := createCSVFile(t, []string{
+		"id,name,price",
+		"1,apple,9.5",
+		"2,\"say \"\"hi\"\"\",",
+		"3,cherry,+100",
+	})
+	defer os.Remove(fp)
+	commitFile(t, "main", fp, "id")
+
+	cmd := rootCmd()
+	cmd.SetArgs([]string{"export", "main", "--format", "jsonl"})
+	assertCmdOutput(t, cmd, strings.Join([]string{
+		`{"id": "1", "name": "apple", "price": "9.5"}`,
+		`{"id": "2", "name": "say \"hi\"", "price": ""}`,
+		`{"id": "3", "name": "cherry", "price": "+100"}`,
+		"",
+	}, "\n"))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"export", "main", "--format", "json", "--numbers", "--columns", "price,name"})
+	assertCmdOutput(t, cmd, strings.Join([]string{
+		`[`,
+		`  {"price": 9.5, "name": "apple"},`,
+		`  {"price": null, "name": "say \"hi\""},`,
+		`  {"price": 100, "name": "cherry"}`,
+		`]`,
+		"",
+	}, "\n"))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"export", "main", "--format", "json", "--where", "id > 5"})
+	assertCmdOutput(t, cmd, "[]\n")
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"export", "main", "--numbers"})
+	assert.Equal(t, fmt.Errorf("flag --numbers can only be used with --format json or jsonl"), cmd.Execute())
+}
+
+func TestExportCmdToSQLUpsertFailure(t *testing.T) {
+	_, cleanup := createRepoDir(t)
+	defer cleanup()
+
+	_, fp := createCSVFile(t, []string{
+		"id,name",
+		"1,a",
+		"2,b",
+		"3,c",
+	})
+	defer os.Remove(fp)
+	commitFile(t, "main", fp, "id")
+	source := "sqlite://" + filepath.Join(t.TempDir(), "serving.db")
+	cmd := rootCmd()
+	cmd.SetArgs([]string{"export", "main", "--to-sql", source, "--table", "t"})
+	assertCmdOutput(t, cmd, "Exported 3 rows into table \"t\"\n")
+
+	db, err := rowsource.OpenSQL(source)
+	require.NoError(t, err)
+	_, err = db.Exec(`CREATE TRIGGER no_delete BEFORE DELETE ON t BEGIN SELECT RAISE(ABORT, 'no delete'); END`)
+	require.NoError(t, err)
+
+	// rows 2 and 3 are deleted, the first delete fails
+	overrideCSVFile(t, fp, []string{
+		"id,name",
+		"1,q",
+	})
+	commitFile(t, "main", fp, "id")
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"export", "main", "--to-sql", source, "--table", "t", "--mode", "upsert"})
+	err = cmd.Execute()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no delete")
+	assertSQLRows(t, source, `SELECT * FROM t ORDER BY id`, []string{
+		"id,name", "1,a", "2,b", "3,c",
+	})
+
+	_, err = db.Exec(`DROP TRIGGER no_delete`)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"export", "main", "--to-sql", source, "--table", "t", "--mode", "upsert"})
+	assertCmdOutput(t, cmd, "Upserted 1 rows and deleted 2 rows in table \"t\"\n")
+	assertSQLRows(t, source, `SELECT * FROM t ORDER BY id`, []string{
+		"id,name", "1,q",
+	})
+}
+
+func assertSQLRows(t *testing.T, source, query string, rows []string) {
+	t.Helper()
+	db, err := rowsource.OpenSQL(source)
+	require.NoError(t, err)
+	defer db.Close()
+	sqlRows, err := db.Query(query)
+	require.NoError(t, err)
+	defer sqlRows.Close()
+	r := rowsource.NewSQLReader(sqlRows)
+	var result []string
+	for {
+		row, err := r.Read()
+		if err != nil {
+			break
+		}
+		result = append(result, strings.Join(row, ","))
+	}
+	require.NoError(t, sqlRows.Err())
+	assert.Equal(t, rows, result)
+}
+
+func TestExportCmdToSQL(t *testing.T) {
+	rd, cleanup := createRepoDir(t)
+	defer cleanup()
+
+	_, fp := createCSVFile(t, []string{
+		"id,name,note",
+		"1,a,x",
+		"2,b,y",
+		"3,c,z",
+	})
+	defer os.Remove(fp)
+	commitFile(t, "main", fp, "id")
+	source := "sqlite://" + filepath.Join(t.TempDir(), "serving.db")
+
+	cmd := rootCmd()
+	cmd.SetArgs([]string{"export", "main", "--to-sql", source, "--table", "my table"})
+	assertCmdOutput(t, cmd, "Exported 3 rows into table \"my table\"\n")
+	assertSQLRows(t, source, `SELECT * FROM "my table" ORDER BY id`, []string{
+		"id,name,note", "1,a,x", "2,b,y", "3,c,z",
+	})
+
+	// upsert applies only the changes since the exported commit
+	overrideCSVFile(t, fp, []string{
+		"id,name,note",
+		"1,a,q",
+		"2,b,y",
+		"4,d,w",
+	})
+	commitFile(t, "main", fp, "id")
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"export", "main", "--to-sql", source, "--table", "my table", "--mode", "upsert"})
+	assertCmdOutput(t, cmd, "Upserted 2 rows and deleted 1 rows in table \"my table\"\n")
+	assertSQLRows(t, source, `SELECT * FROM "my table" ORDER BY id`, []string{
+		"id,name,note", "1,a,q", "2,b,y", "4,d,w",
+	})
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"export", "main", "--to-sql", source, "--table", "my table", "--mode", "upsert"})
+	sum, err := ref.GetHead(rd.OpenRefStore(), "main")
+	require.NoError(t, err)
+	shortSum := hex.EncodeToString(sum)[:7]
+	assertCmdOutput(t, cmd, fmt.Sprintf("Commit %s is already exported into table \"my table\"\n", shortSum))
+
+	// upsert into a table that hasn't been exported into inserts all rows
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"export", "main^", "--to-sql", source, "--table", "other", "--mode", "upsert"})
+	assertCmdOutput(t, cmd, "Upserted 3 rows into table \"other\"\n")
+	assertSQLRows(t, source, `SELECT * FROM other ORDER BY id`, []string{
+		"id,name,note", "1,a,x", "2,b,y", "3,c,z",
+	})
+
+	// columns changed since the last export
+	overrideCSVFile(t, fp, []string{
+		"id,name",
+		"1,a",
+	})
+	commitFile(t, "main", fp, "id")
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"export", "main", "--to-sql", source, "--table", "my table", "--mode", "upsert"})
+	assertCmdFailed(t, cmd, "", fmt.Errorf("columns or primary key have changed since commit %s was exported, use --mode replace instead", shortSum))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"export", "main", "--to-sql", source})
+	assertCmdFailed(t, cmd, "", fmt.Errorf("flag --table is required with --to-sql"))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"export", "main", "--to-sql", source, "--table", "t", "--where", "id = 1"})
+	assertCmdFailed(t, cmd, "", fmt.Errorf("flag --where can't be used with --to-sql"))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"export", "main", "--to-sql", source, "--table", "t", "--mode", "append"})
+	assertCmdFailed(t, cmd, "", fmt.Errorf(`invalid mode "append", must be one of replace, upsert`))
+}
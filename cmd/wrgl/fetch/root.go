@@ -226,7 +226,7 @@ func saveFetchedRefs(
 	// if a remote tag point to an existing object then save that tag
 	cm := bytesSliceToMap(fetchedCommits)
 	for r, sum := range maybeSaveTags {
-		if _, ok := cm[string(sum)]; ok || objects.CommitExist(db, sum) {
+		if _, ok := cm[string(sum)]; ok || objects.CommitExist(db, sum) || objects.TagExist(db, sum) {
 			_, err := ref.GetRef(rs, r)
 			if err != nil {
 				ref, err := conf.NewRefspec(r, r, false, false)
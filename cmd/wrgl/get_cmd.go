@@ -0,0 +1,185 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package wrgl
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wrgl/wrgl/cmd/wrgl/utils"
+	"github.com/wrgl/wrgl/pkg/objects"
+	"github.com/wrgl/wrgl/pkg/ref"
+	"github.com/wrgl/wrgl/pkg/slice"
+)
+
+func getCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "get COMMIT [PK_VALUES...]",
+		Short: "Print rows with the given primary key values.",
+		Long: strings.Join([]string{
+			"Print rows of a commit's table with the given primary key values. Each PK_VALUES is a",
+			"comma-separated list of values, one for each primary key column. Rows are located with the",
+			"table and block indices so the table is never scanned, which makes this command suitable",
+			"for fast lookups against a pinned commit. Rows are printed in the order of the given keys.",
+		}, " "),
+		Example: utils.CombineExamples([]utils.Example{
+			{
+				Comment: "print rows with primary key 123 and 456 from branch main",
+				Line:    "wrgl get main 123 456",
+			},
+			{
+				Comment: "look up a row of a table with a composite primary key, print as JSON",
+				Line:    "wrgl get 1a2ed62 'SKU-1,warehouse-2' --format json",
+			},
+			{
+				Comment: "look up keys listed in a CSV file, skipping keys that are not found",
+				Line:    "wrgl get main --keys-file keys.csv --ignore-missing",
+			},
+		}),
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rd := utils.GetRepoDir(cmd)
+			defer rd.Close()
+			if err := quitIfRepoDirNotExist(cmd, rd); err != nil {
+				return err
+			}
+			format, err := cmd.Flags().GetString("format")
+			if err != nil {
+				return err
+			}
+			if format != "csv" && format != "json" {
+				return fmt.Errorf("invalid format %q, must be either csv or json", format)
+			}
+			keysFile, err := cmd.Flags().GetString("keys-file")
+			if err != nil {
+				return err
+			}
+			ignoreMissing, err := cmd.Flags().GetBool("ignore-missing")
+			if err != nil {
+				return err
+			}
+			if len(args) == 1 && keysFile == "" {
+				return fmt.Errorf("specify PK_VALUES or --keys-file")
+			}
+			db, err := rd.OpenObjectsStore()
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+			rs := rd.OpenRefStore()
+			_, _, commit, err := ref.InterpretCommitName(db, rs, args[0], false)
+			if err != nil {
+				return err
+			}
+			tbl, err := utils.GetTable(db, rs, commit)
+			if err != nil {
+				return err
+			}
+			selected, err := getSelectedColumns(cmd, tbl.Columns)
+			if err != nil {
+				return err
+			}
+			if len(tbl.PK) == 0 {
+				return fmt.Errorf("table has no primary key, can't look up rows by primary key values")
+			}
+			keys := make([][]string, 0, len(args)-1)
+			for _, arg := range args[1:] {
+				values, err := parsePKValues(tbl, arg)
+				if err != nil {
+					return err
+				}
+				keys = append(keys, values)
+			}
+			if keysFile != "" {
+				records, err := readKeysFile(cmd, keysFile)
+				if err != nil {
+					return err
+				}
+				for _, rec := range records {
+					if len(rec) != len(tbl.PK) {
+						return fmt.Errorf("expecting %d primary key values (%s), got %q", len(tbl.PK), strings.Join(tbl.PrimaryKey(), ","), strings.Join(rec, ","))
+					}
+				}
+				keys = append(keys, records...)
+			}
+			rows, err := lookupRows(db, tbl, keys, ignoreMissing)
+			if err != nil {
+				return err
+			}
+			columns := tbl.Columns
+			if selected != nil {
+				columns = slice.IndicesToValues(tbl.Columns, selected)
+				for i, row := range rows {
+					rows[i] = slice.IndicesToValues(row, selected)
+				}
+			}
+			if format == "json" {
+				return writeRowsJSON(cmd.OutOrStdout(), columns, rows)
+			}
+			w := csv.NewWriter(cmd.OutOrStdout())
+			if err = w.Write(columns); err != nil {
+				return err
+			}
+			if err = w.WriteAll(rows); err != nil {
+				return err
+			}
+			return w.Error()
+		},
+	}
+	cmd.Flags().String("format", "csv", "output format, either csv or json. JSON output is an array with one object per row")
+	cmd.Flags().String("keys-file", "", "read primary key values from this CSV file (without header), one row per key. Use \"-\" to read from stdin")
+	cmd.Flags().Bool("ignore-missing", false, "skip keys that are not found instead of returning an error")
+	registerColumnsFlags(cmd.Flags())
+	return cmd
+}
+
+// readKeysFile reads each record of CSV file name as primary key values
+func readKeysFile(cmd *cobra.Command, name string) ([][]string, error) {
+	var r io.Reader
+	if name == "-" {
+		r = cmd.InOrStdin()
+	} else {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error reading keys file: %v", err)
+	}
+	return records, nil
+}
+
+// lookupRows returns rows of tbl with the given primary key values, in the
+// same order as keys.
+func lookupRows(db objects.Store, tbl *objects.Table, keys [][]string, ignoreMissing bool) ([][]string, error) {
+	tblIdx, err := objects.GetTableIndex(db, tbl.Sum)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([][]string, 0, len(keys))
+	for _, values := range keys {
+		row, err := objects.LookupRow(db, tbl, tblIdx, values)
+		if err != nil {
+			return nil, err
+		}
+		if row == nil {
+			if ignoreMissing {
+				continue
+			}
+			return nil, fmt.Errorf("no row with primary key values %q", strings.Join(values, ","))
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
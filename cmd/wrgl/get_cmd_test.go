@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package wrgl
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetCmd(t *testing.T) {
+	_, cleanup := createRepoDir(t)
+	defer cleanup()
+
+	rows := []string{"id,sub,name"}
+	for i := 0; i < 600; i++ {
+		rows = append(rows, fmt.Sprintf("%d,%d,name %d", i/2, i%2, i))
+	}
+	_, fp := createCSVFile(t, rows)
+	defer os.Remove(fp)
+	commitFile(t, "main", fp, "id,sub")
+
+	cmd := rootCmd()
+	cmd.SetArgs([]string{"get", "main", "0,1", "299,0", "150,1"})
+	assertCmdOutput(t, cmd, strings.Join([]string{
+		"id,sub,name",
+		"0,1,name 1",
+		"299,0,name 598",
+		"150,1,name 301",
+		"",
+	}, "\n"))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"get", "main", "10,0", "--columns", "name,id", "--format", "json"})
+	assertCmdOutput(t, cmd, strings.Join([]string{
+		"[",
+		`  {"name": "name 20", "id": "10"}`,
+		"]",
+		"",
+	}, "\n"))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"get", "main", "10,0", "300,0"})
+	assert.Equal(t, fmt.Errorf("no row with primary key values %q", "300,0"), cmd.Execute())
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"get", "main", "10"})
+	assert.Equal(t, fmt.Errorf("expecting 2 primary key values (id,sub), got %q", "10"), cmd.Execute())
+
+	keysFile := fp + ".keys"
+	require.NoError(t, os.WriteFile(keysFile, []byte("7,1\n300,0\n8,0\n"), 0644))
+	defer os.Remove(keysFile)
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"get", "main", "--keys-file", keysFile, "--ignore-missing", "--format", "json"})
+	assertCmdOutput(t, cmd, strings.Join([]string{
+		"[",
+		`  {"id": "7", "sub": "1", "name": "name 15"},`,
+		`  {"id": "8", "sub": "0", "name": "name 16"}`,
+		"]",
+		"",
+	}, "\n"))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"get", "main"})
+	assert.Equal(t, fmt.Errorf("specify PK_VALUES or --keys-file"), cmd.Execute())
+}
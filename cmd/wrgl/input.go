@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package wrgl
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wrgl/wrgl/cmd/wrgl/utils"
+)
+
+// isStdinOrURL reports whether input p is stdin or a URL, which can't be
+// mistaken for a reference name
+func isStdinOrURL(p string) bool {
+	return p == "-" || strings.HasPrefix(p, "file://") || strings.HasPrefix(p, "http://") || strings.HasPrefix(p, "https://")
+}
+
+// localInputPath returns the path of input p on the local file system. It
+// returns false if p is stdin or a http(s) URL, which can't be stat'ed.
+func localInputPath(p string) (string, bool, error) {
+	if p == "-" || strings.HasPrefix(p, "http://") || strings.HasPrefix(p, "https://") {
+		return "", false, nil
+	}
+	if !strings.HasPrefix(p, "file://") {
+		return p, true, nil
+	}
+	u, err := url.Parse(p)
+	if err != nil {
+		return "", false, err
+	}
+	if u.Host != "" && u.Host != "localhost" {
+		return "", false, fmt.Errorf("file URL %q must not have a host other than localhost", p)
+	}
+	return filepath.FromSlash(u.Path), true, nil
+}
+
+// inputName returns the base name of input p. It is used to detect the
+// format and compression of p and to name commits made from p.
+func inputName(p string) string {
+	if p == "-" {
+		return "stdin"
+	}
+	if strings.HasPrefix(p, "http://") || strings.HasPrefix(p, "https://") {
+		if u, err := url.Parse(p); err == nil {
+			return path.Base(u.Path)
+		}
+	}
+	return filepath.Base(p)
+}
+
+// openInput opens input p, which is "-" for stdin, a file:// or http(s)://
+// URL, or a local file path
+func openInput(cmd *cobra.Command, p string) (io.ReadCloser, error) {
+	if p == "-" {
+		return io.NopCloser(cmd.InOrStdin()), nil
+	}
+	if localPath, ok, err := localInputPath(p); err != nil {
+		return nil, err
+	} else if ok {
+		return os.Open(localPath)
+	}
+	req, err := http.NewRequestWithContext(cmd.Context(), http.MethodGet, p, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := utils.GetClient(cmd.Context()).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("error fetching %s: %s", p, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// inputExists reports whether input p exists. Only local files are checked,
+// other inputs are assumed to exist.
+func inputExists(p string) (bool, error) {
+	localPath, ok, err := localInputPath(p)
+	if err != nil || !ok {
+		return true, err
+	}
+	if _, err := os.Stat(localPath); os.IsNotExist(err) {
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,197 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package wrgl
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/wrgl/wrgl/pkg/ingest"
+	"github.com/wrgl/wrgl/pkg/objects"
+	"github.com/wrgl/wrgl/pkg/schema"
+	"github.com/wrgl/wrgl/pkg/slice"
+)
+
+// jsonObjectEncoder encodes rows as JSON objects keyed by column names
+type jsonObjectEncoder struct {
+	names [][]byte
+	// numeric marks columns whose values are written as JSON numbers, or
+	// null if empty. It is nil if all values are written as strings.
+	numeric []bool
+}
+
+func newJSONObjectEncoder(columns []string, numeric []bool) *jsonObjectEncoder {
+	names := make([][]byte, len(columns))
+	for i, col := range columns {
+		names[i], _ = json.Marshal(col)
+	}
+	return &jsonObjectEncoder{names: names, numeric: numeric}
+}
+
+// jsonNumber returns v as a JSON number. Numbers that are not valid JSON as
+// written (e.g. "+1", ".5") are reformatted, and false is returned for values
+// that have no JSON representation such as "NaN".
+func jsonNumber(v string) (string, bool) {
+	if (v[0] == '-' || (v[0] >= '0' && v[0] <= '9')) && json.Valid([]byte(v)) {
+		return v, true
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return "", false
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64), true
+}
+
+func (e *jsonObjectEncoder) appendObject(dst []byte, row []string) []byte {
+	dst = append(dst, '{')
+	for i, v := range row {
+		if i > 0 {
+			dst = append(dst, ", "...)
+		}
+		dst = append(dst, e.names[i]...)
+		dst = append(dst, ": "...)
+		if e.numeric != nil && e.numeric[i] {
+			if v == "" {
+				dst = append(dst, "null"...)
+				continue
+			}
+			if n, ok := jsonNumber(v); ok {
+				dst = append(dst, n...)
+				continue
+			}
+		}
+		b, _ := json.Marshal(v)
+		dst = append(dst, b...)
+	}
+	return append(dst, '}')
+}
+
+// jsonRowsWriter writes JSON objects either as JSON Lines, one object per
+// line, or as a JSON array with one object per line
+type jsonRowsWriter struct {
+	w     *bufio.Writer
+	array bool
+	n     int
+}
+
+func newJSONRowsWriter(w io.Writer, array bool) *jsonRowsWriter {
+	return &jsonRowsWriter{w: bufio.NewWriter(w), array: array}
+}
+
+func (w *jsonRowsWriter) writeObject(obj []byte) error {
+	if w.array {
+		if w.n == 0 {
+			w.w.WriteString("[\n  ")
+		} else {
+			w.w.WriteString(",\n  ")
+		}
+	}
+	w.n++
+	w.w.Write(obj)
+	if !w.array {
+		return w.w.WriteByte('\n')
+	}
+	return nil
+}
+
+// Close ends the JSON array if needed and flushes buffered output
+func (w *jsonRowsWriter) Close() error {
+	if w.array {
+		if w.n == 0 {
+			w.w.WriteString("[")
+		} else {
+			w.w.WriteString("\n")
+		}
+		w.w.WriteString("]\n")
+	}
+	return w.w.Flush()
+}
+
+// jsonRowWriter writes rows as JSON objects keyed by column names
+type jsonRowWriter struct {
+	*jsonRowsWriter
+	enc *jsonObjectEncoder
+	buf []byte
+}
+
+func newJSONRowWriter(w io.Writer, columns []string, numeric []bool, array bool) *jsonRowWriter {
+	return &jsonRowWriter{
+		jsonRowsWriter: newJSONRowsWriter(w, array),
+		enc:            newJSONObjectEncoder(columns, numeric),
+	}
+}
+
+func (w *jsonRowWriter) Write(row []string) error {
+	w.buf = w.enc.appendObject(w.buf[:0], row)
+	return w.writeObject(w.buf)
+}
+
+// writeRowsJSON writes rows as a JSON array of objects, keeping keys of each
+// object in the same order as columns.
+func writeRowsJSON(w io.Writer, columns []string, rows [][]string) error {
+	jw := newJSONRowWriter(w, columns, nil, true)
+	for _, row := range rows {
+		if err := jw.Write(row); err != nil {
+			return err
+		}
+	}
+	return jw.Close()
+}
+
+// getTableProfile returns the profile of tbl, profiling the table first if
+// it hasn't been profiled yet
+func getTableProfile(db objects.Store, tbl *objects.Table) (*objects.TableProfile, error) {
+	tblProf, err := objects.GetTableProfile(db, tbl.Sum)
+	if errors.Is(err, objects.ErrKeyNotFound) {
+		if err = ingest.ProfileTable(db, tbl.Sum, tbl); err != nil {
+			return nil, err
+		}
+		tblProf, err = objects.GetTableProfile(db, tbl.Sum)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tblProf, nil
+}
+
+// numericColumns reports for each column in columns (indices of tbl's
+// columns, or all columns if nil) whether its type is numeric, or for tables
+// without a schema, whether its profile shows that all of its values are
+// numbers
+func numericColumns(db objects.Store, tbl *objects.Table, columns []uint32) ([]bool, error) {
+	if tbl.Types != nil {
+		if columns == nil {
+			return typesAreNumeric(tbl.Types), nil
+		}
+		return typesAreNumeric(slice.IndicesToValues(tbl.Types, columns)), nil
+	}
+	tblProf, err := getTableProfile(db, tbl)
+	if err != nil {
+		return nil, err
+	}
+	if columns == nil {
+		columns = make([]uint32, len(tbl.Columns))
+		for i := range columns {
+			columns[i] = uint32(i)
+		}
+	}
+	numeric := make([]bool, len(columns))
+	for i, u := range columns {
+		col := tblProf.Columns[u]
+		numeric[i] = col.Min != nil && col.Max != nil
+	}
+	return numeric, nil
+}
+
+func typesAreNumeric(types []string) []bool {
+	numeric := make([]bool, len(types))
+	for i, typ := range types {
+		numeric[i] = schema.IsNumeric(typ)
+	}
+	return numeric
+}
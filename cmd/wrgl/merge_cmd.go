@@ -9,7 +9,6 @@ import (
 	"encoding/csv"
 	"encoding/hex"
 	"fmt"
-	"io"
 	"os"
 	"path"
 	"runtime"
@@ -30,6 +29,8 @@ import (
 	"github.com/wrgl/wrgl/pkg/objects"
 	"github.com/wrgl/wrgl/pkg/pbar"
 	"github.com/wrgl/wrgl/pkg/ref"
+	"github.com/wrgl/wrgl/pkg/rowsource"
+	"github.com/wrgl/wrgl/pkg/schema"
 	"github.com/wrgl/wrgl/pkg/slice"
 	"github.com/wrgl/wrgl/pkg/sorter"
 	"github.com/wrgl/wrgl/pkg/widgets"
@@ -216,37 +217,40 @@ func runMerge(
 	}
 	commits = nonAncestralCommits
 
-	baseSum, baseT, err := getTable(db, rs, baseCommit)
-	if err != nil {
-		return err
-	}
-	otherTs := make([]*objects.Table, len(commits))
-	otherSums := make([][]byte, len(commits))
-	for i, sum := range commits {
-		otherSums[i], otherTs[i], err = getTable(db, rs, sum)
-		if err != nil {
-			return err
-		}
-	}
-
-	if len(pk) == 0 {
-		pk = otherTs[0].PrimaryKey()
-	}
-
-	logger := utils.GetLogger(cmd)
 	if commitCSV != "" {
+		if len(pk) == 0 {
+			_, tbl, err := getTable(db, rs, commits[0])
+			if err != nil {
+				return err
+			}
+			pk = tbl.PrimaryKey()
+		}
 		file, err := os.Open(commitCSV)
 		if err != nil {
 			return err
 		}
+		defer file.Close()
 		delim, err := utils.GetRuneFromFlag(cmd, "delimiter")
 		if err != nil {
 			return err
 		}
+		types, err := mergeColumnTypes(cmd, db, rs, commits)
+		if err != nil {
+			return err
+		}
+		rows := rowsource.NewCSVReader(file, delim)
+		header, err := rows.Read()
+		if err != nil {
+			return err
+		}
+		header = append([]string(nil), header...)
 		sum, err := ingestTable(
-			cmd, db, file, pk, false, *logger,
-			[]sorter.SorterOption{sorter.WithDelimiter(delim)},
-			[]ingest.InserterOption{ingest.WithNumWorkers(numWorkers)},
+			cmd, db, &headerReader{header: header, rows: rows}, pk, false, *utils.GetLogger(cmd),
+			nil,
+			[]ingest.InserterOption{
+				ingest.WithNumWorkers(numWorkers),
+				ingest.WithColumnTypes(typesOfColumns(types, header)),
+			},
 		)
 		if err != nil {
 			return err
@@ -254,48 +258,104 @@ func runMerge(
 		return createMergeCommit(cmd, db, rs, commitNames, sum, commits, message, c)
 	}
 
-	buf, err := diff.BlockBufferWithSingleStore(db, append([]*objects.Table{baseT}, otherTs...))
+	return mergeTables(cmd, db, rs, baseCommit, commits, commitNames, noCommit, noGUI, numWorkers, func(sum []byte) error {
+		return createMergeCommit(cmd, db, rs, commitNames, sum, commits, message, c)
+	})
+}
+
+// newTablesMerger creates a merger for the tables of commits against the table
+// of baseCommit. The returned cleanup function should be called once the
+// merger is no longer needed.
+func newTablesMerger(cmd *cobra.Command, db objects.Store, rs ref.Store, baseCommit []byte, commits [][]byte) (
+	merger *merge.Merger, buf *diff.BlockBuffer, cleanup func(), err error,
+) {
+	baseSum, baseT, err := getTable(db, rs, baseCommit)
 	if err != nil {
-		return err
+		return
+	}
+	otherTs := make([]*objects.Table, len(commits))
+	otherSums := make([][]byte, len(commits))
+	for i, sum := range commits {
+		otherSums[i], otherTs[i], err = getTable(db, rs, sum)
+		if err != nil {
+			return
+		}
 	}
-	rowCollector, cleanup, err := merge.CreateRowCollector(db, baseT)
+
+	logger := utils.GetLogger(cmd)
+	buf, err = diff.BlockBufferWithSingleStore(db, append([]*objects.Table{baseT}, otherTs...))
 	if err != nil {
-		return err
+		return
 	}
-	defer cleanup()
-	merger, err := merge.NewMerger(db, rowCollector, buf, 65*time.Millisecond, baseT, otherTs, baseSum, otherSums, *logger)
+	rowCollector, cleanupCollector, err := merge.CreateRowCollector(db, baseT)
+	if err != nil {
+		return
+	}
+	merger, err = merge.NewMerger(db, rowCollector, buf, 65*time.Millisecond, baseT, otherTs, baseSum, otherSums, *logger)
+	if err != nil {
+		cleanupCollector()
+		return
+	}
+	cleanup = func() {
+		merger.Close()
+		cleanupCollector()
+	}
+	return
+}
+
+// removedColumns returns columns removed in any of the merged tables
+func removedColumns(cd *diff.ColDiff) map[int]struct{} {
+	removedCols := map[int]struct{}{}
+	for _, layer := range cd.Removed {
+		for col := range layer {
+			removedCols[int(col)] = struct{}{}
+		}
+	}
+	return removedCols
+}
+
+// mergeTables performs a three-way merge of the tables of commits against the
+// table of baseCommit. Conflicts are shown in the merge UI, or saved to a CSV
+// file if noGUI is true. Unless noCommit is true, the merge result is saved as
+// a new table and its sum is passed to commitResult.
+func mergeTables(
+	cmd *cobra.Command, db objects.Store, rs ref.Store, baseCommit []byte, commits [][]byte, commitNames []string,
+	noCommit, noGUI bool, numWorkers int, commitResult func(sum []byte) error,
+) error {
+	merger, buf, cleanup, err := newTablesMerger(cmd, db, rs, baseCommit, commits)
 	if err != nil {
 		return err
 	}
-	defer merger.Close()
+	defer cleanup()
 
 	if noGUI {
 		return outputConflicts(cmd, db, buf, merger, commitNames, baseCommit, commits)
+	}
+	cd, merges, err := collectMergeConflicts(cmd, merger)
+	if err != nil {
+		return err
+	}
+	var removedCols map[int]struct{}
+	if len(merges) == 0 {
+		removedCols = removedColumns(cd)
 	} else {
-		cd, merges, err := collectMergeConflicts(cmd, merger)
+		removedCols, err = displayMergeApp(cmd, buf, merger, commitNames, commits, baseCommit, cd, merges)
 		if err != nil {
 			return err
 		}
-		var removedCols map[int]struct{}
-		if len(merges) == 0 {
-			removedCols = map[int]struct{}{}
-			for _, layer := range cd.Removed {
-				for col := range layer {
-					removedCols[int(col)] = struct{}{}
-				}
-			}
-		} else {
-			removedCols, err = displayMergeApp(cmd, buf, merger, commitNames, commits, baseCommit, cd, merges)
-			if err != nil {
-				return err
-			}
-		}
-		if noCommit {
-			return saveMergeResultToCSV(cmd, merger, removedCols, commits)
-		} else {
-			return commitMergeResult(cmd, db, rs, merger, removedCols, numWorkers, commitNames, commits, message, c)
-		}
 	}
+	if noCommit {
+		return saveMergeResultToCSV(cmd, merger, removedCols, commits)
+	}
+	types, err := mergeColumnTypes(cmd, db, rs, commits)
+	if err != nil {
+		return err
+	}
+	sum, err := saveMergeResult(cmd, db, merger, removedCols, types, numWorkers)
+	if err != nil {
+		return err
+	}
+	return commitResult(sum)
 }
 
 func outputConflicts(cmd *cobra.Command, db objects.Store, buf *diff.BlockBuffer, merger *merge.Merger, commitNames []string, baseSum []byte, commits [][]byte) error {
@@ -489,7 +549,7 @@ func saveMergeResultToCSV(cmd *cobra.Command, merger *merge.Merger, removedCols
 func ingestTable(
 	cmd *cobra.Command,
 	db objects.Store,
-	file io.ReadCloser,
+	rows rowsource.Reader,
 	pk []string,
 	quiet bool,
 	logger logr.Logger,
@@ -507,7 +567,7 @@ func ingestTable(
 		if err != nil {
 			return fmt.Errorf("error creating new sorter: %w", err)
 		}
-		tableSum, err = ingest.IngestTable(db, s, file, pk, logger,
+		tableSum, err = ingest.IngestTableFromReader(db, s, rows, pk, logger,
 			append(inserterOpts, ingest.WithProgressBar(blkPT))...,
 		)
 		return err
@@ -515,28 +575,90 @@ func ingestTable(
 	return
 }
 
-func commitMergeResult(
+// headerReader returns header before rows read from rows
+type headerReader struct {
+	header []string
+	rows   rowsource.Reader
+}
+
+func (r *headerReader) Read() ([]string, error) {
+	if r.header != nil {
+		row := r.header
+		r.header = nil
+		return row, nil
+	}
+	return r.rows.Read()
+}
+
+// mergeColumnTypes returns column types of the tables of commits keyed by
+// column name, so that a merge result keeps the schema of the merged tables.
+// Columns of tables without types are strings. It returns nil if no table has
+// types, or if tables disagree on the type of a column, in which case the
+// merge result is saved without types.
+func mergeColumnTypes(cmd *cobra.Command, db objects.Store, rs ref.Store, commits [][]byte) (map[string]string, error) {
+	tables := make([]*objects.Table, len(commits))
+	typed := false
+	for i, sum := range commits {
+		_, tbl, err := getTable(db, rs, sum)
+		if err != nil {
+			return nil, err
+		}
+		tables[i] = tbl
+		typed = typed || tbl.Types != nil
+	}
+	if !typed {
+		return nil, nil
+	}
+	types := map[string]string{}
+	for _, tbl := range tables {
+		m := ingest.TableColumnTypes(tbl)
+		for _, col := range tbl.Columns {
+			typ, ok := m[col]
+			if !ok {
+				typ = schema.TypeString
+			}
+			if prev, ok := types[col]; ok && prev != typ {
+				cmd.Printf("Column %q is of type %s in one table and %s in another, saving merge result without column types\n", col, prev, typ)
+				return nil, nil
+			}
+			types[col] = typ
+		}
+	}
+	return types, nil
+}
+
+// typesOfColumns returns the types of columns that are in types. String
+// columns are left out since columns without a type are strings.
+func typesOfColumns(types map[string]string, columns []string) map[string]string {
+	m := map[string]string{}
+	for _, col := range columns {
+		if typ, ok := types[col]; ok && typ != schema.TypeString {
+			m[col] = typ
+		}
+	}
+	return m
+}
+
+// saveMergeResult saves merge result as a new table and returns its sum.
+// Values are validated against types, which are saved with the table.
+func saveMergeResult(
 	cmd *cobra.Command,
 	db objects.Store,
-	rs ref.Store,
 	merger *merge.Merger,
 	removedCols map[int]struct{},
+	types map[string]string,
 	numWorkers int,
-	commitNames []string,
-	commits [][]byte,
-	message string,
-	c *conf.Config,
-) error {
+) ([]byte, error) {
 	columns := merger.Columns(removedCols)
 	pk, err := slice.KeyIndices(columns, merger.PK())
 	if err != nil {
-		return err
+		return nil, err
 	}
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	blocks, err := merger.SortedBlocks(ctx, removedCols)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	logger := utils.GetLogger(cmd)
 	var sum []byte
@@ -550,19 +672,20 @@ func commitMergeResult(
 		sum, err = ingest.IngestTableFromBlocks(db, s, columns, pk, blocks, *logger,
 			ingest.WithNumWorkers(numWorkers),
 			ingest.WithProgressBar(blkPT),
+			ingest.WithColumnTypes(typesOfColumns(types, columns)),
 		)
 		return err
 	}); err != nil {
-		return err
+		return nil, err
 	}
 	tbl, err := objects.GetTable(db, sum)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if err = ingest.ProfileTable(db, sum, tbl); err != nil {
-		return err
+		return nil, err
 	}
-	return createMergeCommit(cmd, db, rs, commitNames, sum, commits, message, c)
+	return sum, nil
 }
 
 func createMergeCommit(cmd *cobra.Command, db objects.Store, rs ref.Store, commitNames []string, sum []byte, parents [][]byte, message string, c *conf.Config) error {
@@ -375,3 +375,46 @@ func TestMergeCmdNoFF(t *testing.T) {
 	assert.Equal(t, [][]byte{base, sum1}, com.Parents)
 	assert.Equal(t, "Merge \"branch-2\" into \"branch-1\"", com.Message)
 }
+
+func TestMergeCmdKeepsColumnTypes(t *testing.T) {
+	rd, cleanup := createRepoDir(t)
+	defer cleanup()
+	_, fp := createCSVFile(t, []string{
+		"a,b,c",
+		"1,10,q",
+		"2,20,w",
+	})
+	defer os.Remove(fp)
+	commitFile(t, "branch-1", fp, "a", "--types", "b:integer")
+	rs := rd.OpenRefStore()
+	base, err := ref.GetHead(rs, "branch-1")
+	require.NoError(t, err)
+	require.NoError(t, ref.SaveRef(rs, "heads/branch-2", base, "John Doe", "john@domain.com", "branch", "created from branch-1", nil))
+
+	overrideCSVFile(t, fp, []string{
+		"a,b,c",
+		"1,11,q",
+		"2,20,w",
+	})
+	commitFile(t, "branch-1", fp, "a", "--types", "b:integer")
+	overrideCSVFile(t, fp, []string{
+		"a,b,c",
+		"1,10,q",
+		"2,20,w",
+		"3,30,e",
+	})
+	commitFile(t, "branch-2", fp, "a", "--types", "b:integer")
+
+	cmd := rootCmd()
+	cmd.SetArgs([]string{"merge", "branch-1", "branch-2"})
+	require.NoError(t, cmd.Execute())
+
+	db, err := rd.OpenObjectsStore()
+	require.NoError(t, err)
+	defer db.Close()
+	_, tbl, err := getCommitTable(db, rs, "branch-1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, tbl.Columns)
+	assert.Equal(t, []string{"string", "integer", "string"}, tbl.Types)
+	assert.Equal(t, [][]string{{"1", "11", "q"}, {"2", "20", "w"}, {"3", "30", "e"}}, factory.GetRows(t, db, tbl))
+}
@@ -6,7 +6,6 @@ package wrgl
 import (
 	"encoding/hex"
 	"fmt"
-	"os"
 	"strings"
 
 	"github.com/gdamore/tcell/v2"
@@ -18,6 +17,8 @@ import (
 	"github.com/wrgl/wrgl/pkg/objects"
 	objmock "github.com/wrgl/wrgl/pkg/objects/mock"
 	"github.com/wrgl/wrgl/pkg/ref"
+	"github.com/wrgl/wrgl/pkg/rowfilter"
+	"github.com/wrgl/wrgl/pkg/slice"
 	"github.com/wrgl/wrgl/pkg/widgets"
 )
 
@@ -39,6 +40,18 @@ func newPreviewCmd() *cobra.Command {
 				Comment: "preview a file. Only works if the entire fit in memory",
 				Line:    "wrgl preview data.csv",
 			},
+			{
+				Comment: "preview a file from stdin or a URL",
+				Line:    "curl -s https://example.com/data.csv | wrgl preview -",
+			},
+			{
+				Comment: "preview only rows that satisfy a filter expression",
+				Line:    "wrgl preview my-branch --where \"country = 'VN' AND price > 10\"",
+			},
+			{
+				Comment: "preview all columns except a few wide ones",
+				Line:    "wrgl preview my-branch --exclude-columns description,notes",
+			},
 		}),
 		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -66,6 +79,10 @@ func newPreviewCmd() *cobra.Command {
 			if err != nil {
 				return err
 			}
+			format, err := getInputFormat(cmd, "format")
+			if err != nil {
+				return err
+			}
 			var sum []byte
 			var commit *objects.Commit
 			if txid != "" {
@@ -74,24 +91,34 @@ func newPreviewCmd() *cobra.Command {
 					return err
 				}
 			} else {
-				_, sum, commit, err = ref.InterpretCommitName(db, rs, args[0], false)
-				if err != nil {
-					if strings.HasPrefix(err.Error(), "can't find ") {
-						memStore := objmock.NewStore()
-						file, err := os.Open(args[0])
-						if err != nil {
-							return err
-						}
-						sum, commit, err = createInMemCommit(cmd, memStore, pk, file, false, delim)
-						if err != nil {
-							file.Close()
+				isFile := isStdinOrURL(args[0])
+				if !isFile {
+					_, sum, commit, err = ref.InterpretCommitName(db, rs, args[0], false)
+					if err != nil {
+						if !strings.HasPrefix(err.Error(), "can't find ") {
 							return err
 						}
+						isFile = true
+					}
+				}
+				if isFile {
+					memStore := objmock.NewStore()
+					file, err := openInput(cmd, args[0])
+					if err != nil {
+						return err
+					}
+					rows, err := newRowReader(file, args[0], format, delim)
+					if err != nil {
+						file.Close()
+						return err
+					}
+					sum, commit, err = createInMemCommit(cmd, memStore, pk, rows, false)
+					if err != nil {
 						file.Close()
-						db = memStore
-					} else {
 						return err
 					}
+					file.Close()
+					db = memStore
 				}
 			}
 			if commit == nil {
@@ -106,7 +133,10 @@ func newPreviewCmd() *cobra.Command {
 	}
 	cmd.Flags().StringSliceP("primary-key", "p", []string{}, "field names to be used as primary key (only applicable if preview target is a file)")
 	cmd.Flags().String("delimiter", "", "CSV delimiter to use when preview target is a file. Defaults to comma.")
+	registerInputFormatFlag(cmd.Flags(), "format", "the preview target if it is a file")
 	cmd.Flags().String("txid", "", "preview commit with specified transaction id. COMMIT must be a branch name.")
+	registerWhereFlag(cmd.Flags())
+	registerColumnsFlags(cmd.Flags())
 	return cmd
 }
 
@@ -135,14 +165,46 @@ func previewTable(cmd *cobra.Command, db objects.Store, hash string, commit *obj
 
 	// create title bar
 	titleBar := tview.NewTextView().SetDynamicColors(true)
-	fmt.Fprintf(titleBar, "[yellow]%s[white]  ([teal]%d[white] x [teal]%d[white])", hash, tbl.RowsCount, len(tbl.Columns))
 
 	// create table
-	rowReader, err := diff.NewTableReader(db, tbl)
+	selected, err := getSelectedColumns(cmd, tbl.Columns)
 	if err != nil {
 		return err
 	}
-	tv := widgets.NewPreviewTable(rowReader, int(tbl.RowsCount), tbl.Columns, tbl.PK)
+	columns, pk := tbl.Columns, tbl.PK
+	if selected != nil {
+		columns = slice.IndicesToValues(tbl.Columns, selected)
+		pk = nil
+		for i, u := range selected {
+			for _, v := range tbl.PK {
+				if u == v {
+					pk = append(pk, uint32(i))
+				}
+			}
+		}
+	}
+	filter, err := getWhereFilter(cmd, tbl.Columns)
+	if err != nil {
+		return err
+	}
+	var rowReader diff.RowReader
+	if filter != nil {
+		reader, err := filterTableRows(db, tbl, filter)
+		if err != nil {
+			return err
+		}
+		reader.SelectColumns(selected)
+		rowReader = reader
+		fmt.Fprintf(titleBar, "[yellow]%s[white]  ([teal]%d[white] of %d rows x [teal]%d[white])  where %s",
+			hash, rowReader.Len(), tbl.RowsCount, len(columns), tview.Escape(filter.String()))
+	} else {
+		rowReader, err = diff.NewTableColumnsReader(db, tbl, selected)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(titleBar, "[yellow]%s[white]  ([teal]%d[white] x [teal]%d[white])", hash, tbl.RowsCount, len(columns))
+	}
+	tv := widgets.NewPreviewTable(rowReader, rowReader.Len(), columns, pk)
 
 	usageBar := widgets.DataTableUsage()
 
@@ -158,3 +220,34 @@ func previewTable(cmd *cobra.Command, db objects.Store, hash string, commit *obj
 
 	return app.SetRoot(flex, true).SetFocus(flex).Run()
 }
+
+// filterTableRows returns a reader of rows in tbl that satisfy filter. Only
+// columns referenced in filter are decoded while scanning.
+func filterTableRows(db objects.Store, tbl *objects.Table, filter *rowfilter.Filter) (*diff.RowListReader, error) {
+	reader, err := diff.NewRowListReader(db, tbl)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := slice.KeyIndices(tbl.Columns, filter.Columns())
+	if err != nil {
+		return nil, err
+	}
+	filter, err = rowfilter.New(filter.String(), filter.Columns())
+	if err != nil {
+		return nil, err
+	}
+	var buf []byte
+	var blk [][]string
+	for i, sum := range tbl.Blocks {
+		blk, buf, err = objects.GetBlockColumns(db, buf, sum, refs)
+		if err != nil {
+			return nil, err
+		}
+		for j, row := range blk {
+			if filter.Match(row) {
+				reader.Add(uint32(i)*objects.BlockSize + uint32(j))
+			}
+		}
+	}
+	return reader, nil
+}
@@ -38,12 +38,16 @@ func newPruneCmd() *cobra.Command {
 
 func runPrune(cmd *cobra.Command, db objects.Store, rs ref.Store) error {
 	return utils.WithProgressBar(cmd, false, func(cmd *cobra.Command, barContainer *pbar.Container) error {
+		pruneTagsBar := barContainer.NewBar(-1, "removing tags", 0)
 		findCommitsBar := barContainer.NewBar(-1, "finding commits to remove", 0)
 		pruneTablesBar := barContainer.NewBar(-1, "removing small tables", 0)
 		pruneBlocksBar := barContainer.NewBar(-1, "removing blocks", 0)
 		pruneBlockIndicesBar := barContainer.NewBar(-1, "removing block indices", 0)
 		pruneCommitsBar := barContainer.NewBar(1, "removing commits", 0)
 		return prune.Prune(db, rs, &prune.PruneOptions{
+			PruneTagsPbar: func() pbar.Bar {
+				return pruneTagsBar
+			},
 			FindCommitsPbar: func() pbar.Bar {
 				return findCommitsBar
 			},
@@ -291,11 +291,19 @@ func identifyUpdates(
 		dst := s.Dst()
 		var sum []byte
 		if src != "" {
-			_, sum, _, err = ref.InterpretCommitName(db, rs, src, false)
+			var name string
+			name, sum, _, err = ref.InterpretCommitName(db, rs, src, false)
 			if err != nil {
 				err = fmt.Errorf("error interpreting %q: %v", src, err)
 				return
 			}
+			if strings.HasPrefix(name, "tags/") {
+				// push the tag object itself if src is an annotated tag
+				sum, err = tagObjectSum(db, rs, name, sum)
+				if err != nil {
+					return
+				}
+			}
 		}
 		dst, err = interpretDestination(remoteRefs, src, dst)
 		if err != nil {
@@ -357,6 +365,21 @@ func identifyUpdates(
 	return
 }
 
+func tagObjectSum(db objects.Store, rs ref.Store, name string, commitSum []byte) ([]byte, error) {
+	sum, err := ref.GetRef(rs, name)
+	if err != nil {
+		return nil, err
+	}
+	target, _, err := ref.PeelTag(db, sum)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(target, commitSum) {
+		return sum, nil
+	}
+	return commitSum, nil
+}
+
 func reportUpdateStatus(cmd *cobra.Command, updates []*receivePackUpdate) {
 	for _, u := range updates {
 		if u.ErrMsg == "" {
@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package wrgl
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wrgl/wrgl/cmd/wrgl/utils"
+	"github.com/wrgl/wrgl/pkg/objects"
+	"github.com/wrgl/wrgl/pkg/query"
+	"github.com/wrgl/wrgl/pkg/ref"
+)
+
+func queryCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "query SQL",
+		Short: "Run a SQL SELECT query against committed tables.",
+		Long: strings.Join([]string{
+			"Run a SQL SELECT query against tables of commits and print the result as CSV. Tables are",
+			"referenced by branch name or commit sum (or anything else that \"wrgl log\" accepts) in",
+			"the FROM and JOIN clauses, and can be given an alias with AS. Supported clauses are",
+			"SELECT (columns, *, COUNT, SUM, AVG, MIN and MAX), FROM, [INNER | LEFT] JOIN ... ON,",
+			"WHERE, GROUP BY, ORDER BY and LIMIT. Conditions use the same syntax as flag --where",
+			"of command \"wrgl export\".",
+			"\n\nWhen the WHERE clause pins all primary key columns to literal values, rows are looked up",
+			"by primary key instead of scanning the table. Likewise a JOIN whose ON condition matches",
+			"all primary key columns of the joined table looks up joined rows by primary key. Like",
+			"\"wrgl get\", these lookups find keys exactly as written, so \"WHERE id = 10\" doesn't find",
+			"a row whose id is \"010\" or \"10.0\". Other conditions compare numbers numerically.",
+			"GROUP BY and ORDER BY sort rows on disk so results don't have to fit in memory.",
+		}, " "),
+		Example: utils.CombineExamples([]utils.Example{
+			{
+				Comment: "count rows per country in branch main",
+				Line:    `wrgl query "SELECT country, COUNT(*) AS n FROM main GROUP BY country ORDER BY n DESC"`,
+			},
+			{
+				Comment: "look up a row by primary key",
+				Line:    `wrgl query "SELECT * FROM main WHERE id = '123'"`,
+			},
+			{
+				Comment: "join two branches, print as JSON",
+				Line:    `wrgl query --format json "SELECT o.id, p.name FROM orders AS o JOIN products AS p ON o.product_id = p.id"`,
+			},
+		}),
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := cmd.Flags().GetString("format")
+			if err != nil {
+				return err
+			}
+			if format != "csv" && format != "json" {
+				return fmt.Errorf("invalid format %q, must be either csv or json", format)
+			}
+			stmt, err := query.Parse(args[0])
+			if err != nil {
+				return fmt.Errorf("error parsing query: %v", err)
+			}
+			rd := utils.GetRepoDir(cmd)
+			defer rd.Close()
+			if err := quitIfRepoDirNotExist(cmd, rd); err != nil {
+				return err
+			}
+			db, err := rd.OpenObjectsStore()
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+			rs := rd.OpenRefStore()
+			tables := []*objects.Table{}
+			for _, name := range stmt.Commits() {
+				_, _, commit, err := ref.InterpretCommitName(db, rs, name, false)
+				if err != nil {
+					return err
+				}
+				tbl, err := utils.GetTable(db, rs, commit)
+				if err != nil {
+					return err
+				}
+				tables = append(tables, tbl)
+			}
+			e, err := query.NewExecutor(db, stmt, tables)
+			if err != nil {
+				return err
+			}
+			if format == "json" {
+				rows := [][]string{}
+				if err = e.Run(func(row []string) error {
+					rows = append(rows, row)
+					return nil
+				}); err != nil {
+					return err
+				}
+				return writeRowsJSON(cmd.OutOrStdout(), e.Columns(), rows)
+			}
+			w := csv.NewWriter(cmd.OutOrStdout())
+			if err = w.Write(e.Columns()); err != nil {
+				return err
+			}
+			if err = e.Run(w.Write); err != nil {
+				return err
+			}
+			w.Flush()
+			return w.Error()
+		},
+	}
+	cmd.Flags().String("format", "csv", "output format, either csv or json. JSON output is an array with one object per row")
+	return cmd
+}
@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package wrgl
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryCmd(t *testing.T) {
+	_, cleanup := createRepoDir(t)
+	defer cleanup()
+
+	rows := []string{"id,name,country"}
+	for i := 0; i < 600; i++ {
+		rows = append(rows, fmt.Sprintf("%d,name %d,%s", i, i, []string{"VN", "US", "JP"}[i%3]))
+	}
+	_, fp := createCSVFile(t, rows)
+	defer os.Remove(fp)
+	commitFile(t, "people", fp, "id")
+
+	_, fp2 := createCSVFile(t, []string{
+		"code,label",
+		"VN,Vietnam",
+		"US,United States",
+	})
+	defer os.Remove(fp2)
+	commitFile(t, "countries", fp2, "code")
+
+	cmd := rootCmd()
+	cmd.SetArgs([]string{"query", "SELECT id, name FROM people WHERE id IN (5, 599, 700)"})
+	assertCmdOutput(t, cmd, strings.Join([]string{
+		"id,name",
+		"5,name 5",
+		"599,name 599",
+		"",
+	}, "\n"))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"query", "SELECT c.label, COUNT(*) AS n, MAX(id) FROM people p LEFT JOIN countries c ON p.country = c.code GROUP BY c.label ORDER BY n DESC, 1"})
+	assertCmdOutput(t, cmd, strings.Join([]string{
+		"label,n,MAX(id)",
+		",200,599",
+		"United States,200,598",
+		"Vietnam,200,597",
+		"",
+	}, "\n"))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"query", "--format", "json", "SELECT name FROM people~0 WHERE country = 'JP' ORDER BY id DESC LIMIT 2"})
+	assertCmdOutput(t, cmd, strings.Join([]string{
+		"[",
+		`  {"name": "name 599"},`,
+		`  {"name": "name 596"}`,
+		"]",
+		"",
+	}, "\n"))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"query", "SELECT * FROM"})
+	assert.Equal(t, fmt.Errorf("error parsing query: expected commit or branch name but got end of query at position 13"), cmd.Execute())
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"query", "SELECT foo FROM people"})
+	assert.Equal(t, fmt.Errorf("unknown column %q", "foo"), cmd.Execute())
+}
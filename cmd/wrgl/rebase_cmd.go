@@ -0,0 +1,489 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package wrgl
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/wrgl/wrgl/cmd/wrgl/utils"
+	"github.com/wrgl/wrgl/pkg/conf"
+	conffs "github.com/wrgl/wrgl/pkg/conf/fs"
+	"github.com/wrgl/wrgl/pkg/ingest"
+	"github.com/wrgl/wrgl/pkg/local"
+	"github.com/wrgl/wrgl/pkg/objects"
+	"github.com/wrgl/wrgl/pkg/ref"
+	"github.com/wrgl/wrgl/pkg/rowsource"
+	"gopkg.in/yaml.v3"
+)
+
+func rebaseCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rebase { BRANCH UPSTREAM | --continue | --abort }",
+		Short: "Replay commits of a branch on top of another commit.",
+		Long: strings.Join([]string{
+			"Replay commits of BRANCH that are not in UPSTREAM on top of UPSTREAM, one commit at a time,",
+			"then point BRANCH to the last replayed commit. Each replayed commit keeps the author and",
+			"message of the original commit. Merge commits are left out, so the result is a linear",
+			"history. Commits whose changes are already in UPSTREAM are skipped. If a commit conflicts,",
+			"the merge UI is shown. If the merge UI is closed before the conflicts are resolved, or",
+			"--no-gui is given, the rebase stops and can be resumed with --continue or abandoned with",
+			"--abort. BRANCH is left untouched until the rebase finishes.",
+		}, " "),
+		Example: utils.CombineExamples([]utils.Example{
+			{
+				Comment: "replay commits of branch feature on top of branch main",
+				Line:    "wrgl rebase feature main",
+			},
+			{
+				Comment: "don't show merge UI, stop and output conflicts to CONFLICTS_SUM1_SUM2.csv instead",
+				Line:    "wrgl rebase feature main --no-gui",
+			},
+			{
+				Comment: "resume the rebase with conflicts resolved in a CSV file",
+				Line:    "wrgl rebase --continue --commit-csv resolved.csv",
+			},
+			{
+				Comment: "resume the rebase, showing merge UI again for the current commit",
+				Line:    "wrgl rebase --continue",
+			},
+			{
+				Comment: "abandon the rebase",
+				Line:    "wrgl rebase --abort",
+			},
+		}),
+		Args: cobra.RangeArgs(0, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rd := utils.GetRepoDir(cmd)
+			defer rd.Close()
+			if err := quitIfRepoDirNotExist(cmd, rd); err != nil {
+				return err
+			}
+			s := conffs.NewStore(rd.FullPath, conffs.AggregateSource, "")
+			c, err := s.Open()
+			if err != nil {
+				return err
+			}
+			if err := utils.EnsureUserSet(cmd, c); err != nil {
+				return err
+			}
+			cont, err := cmd.Flags().GetBool("continue")
+			if err != nil {
+				return err
+			}
+			abort, err := cmd.Flags().GetBool("abort")
+			if err != nil {
+				return err
+			}
+			st, err := readRebaseState(rd)
+			if err != nil {
+				return err
+			}
+			if abort {
+				if st == nil {
+					return fmt.Errorf("no rebase in progress")
+				}
+				if err = os.Remove(rd.RebaseStatePath()); err != nil {
+					return err
+				}
+				cmd.Printf("Rebase aborted, branch %q is left unchanged\n", st.Branch)
+				return nil
+			}
+			opts := &rebaseOptions{}
+			if opts.noGUI, err = cmd.Flags().GetBool("no-gui"); err != nil {
+				return err
+			}
+			if opts.commitCSV, err = cmd.Flags().GetString("commit-csv"); err != nil {
+				return err
+			}
+			if opts.numWorkers, err = cmd.Flags().GetInt("num-workers"); err != nil {
+				return err
+			}
+			if opts.delimiter, err = utils.GetRuneFromFlag(cmd, "delimiter"); err != nil {
+				return err
+			}
+			db, err := rd.OpenObjectsStore()
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+			rs := rd.OpenRefStore()
+			if cont {
+				if st == nil {
+					return fmt.Errorf("no rebase in progress")
+				}
+				return continueRebase(cmd, c, rd, db, rs, st, opts)
+			}
+			if st != nil {
+				return fmt.Errorf("a rebase of branch %q is in progress, use --continue or --abort", st.Branch)
+			}
+			if opts.commitCSV != "" {
+				return fmt.Errorf("--commit-csv can only be used with --continue")
+			}
+			if len(args) != 2 {
+				return fmt.Errorf("requires BRANCH and UPSTREAM")
+			}
+			return startRebase(cmd, c, rd, db, rs, args, opts)
+		},
+	}
+	cmd.Flags().Bool("continue", false, "resume the rebase that was stopped because of conflicts")
+	cmd.Flags().Bool("abort", false, "abandon the rebase that is in progress, leaving BRANCH unchanged")
+	cmd.Flags().Bool("no-gui", false, "don't show mergetool, instead stop and output conflicts (and resolved rows) to file CONFLICTS_SUM1_SUM2.csv")
+	cmd.Flags().String("commit-csv", "", "when used with --continue, use the specified CSV file as the result of the current commit instead of merging")
+	cmd.Flags().String("delimiter", "", "CSV delimiter of file given with --commit-csv, defaults to comma")
+	cmd.Flags().IntP("num-workers", "n", runtime.GOMAXPROCS(0), "number of CPU threads to utilize (default to GOMAXPROCS)")
+	return cmd
+}
+
+// rebaseState is the state of an ongoing rebase, saved to
+// RepoDir.RebaseStatePath between runs. All sums are hex-encoded.
+type rebaseState struct {
+	Branch   string `yaml:"branch"`
+	OrigHead string `yaml:"origHead"`
+	Onto     string `yaml:"onto"`
+
+	// Head is the last replayed commit
+	Head string `yaml:"head"`
+
+	// Commits are commits that are not replayed yet, oldest first. The first
+	// commit is the one that is being replayed.
+	Commits []string `yaml:"commits"`
+}
+
+type rebaseOptions struct {
+	noGUI      bool
+	commitCSV  string
+	delimiter  rune
+	numWorkers int
+}
+
+func readRebaseState(rd *local.RepoDir) (*rebaseState, error) {
+	b, err := os.ReadFile(rd.RebaseStatePath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	st := &rebaseState{}
+	if err = yaml.Unmarshal(b, st); err != nil {
+		return nil, fmt.Errorf("error reading rebase state: %v", err)
+	}
+	return st, nil
+}
+
+func writeRebaseState(rd *local.RepoDir, st *rebaseState) error {
+	b, err := yaml.Marshal(st)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(rd.RebaseStatePath(), b, 0644)
+}
+
+// commitsToRebase returns commits reachable from headSum but not from onto,
+// ancestors first. Merge commits are left out.
+func commitsToRebase(db objects.Store, headSum, onto []byte) ([][]byte, error) {
+	upstream := map[string]struct{}{}
+	q, err := ref.NewCommitsQueue(db, [][]byte{onto})
+	if err != nil {
+		return nil, err
+	}
+	for {
+		sum, _, err := q.PopInsertParents()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		upstream[string(sum)] = struct{}{}
+	}
+	if _, ok := upstream[string(headSum)]; ok {
+		return nil, nil
+	}
+	commits := map[string]*objects.Commit{}
+	q, err = ref.NewCommitsQueue(db, [][]byte{headSum})
+	if err != nil {
+		return nil, err
+	}
+	for {
+		sum, com, err := q.Pop()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := upstream[string(sum)]; ok {
+			continue
+		}
+		if len(com.Parents) == 0 {
+			return nil, fmt.Errorf("can't rebase commit %x: it has no parent", sum)
+		}
+		commits[string(sum)] = com
+		if err = q.InsertParents(com); err != nil {
+			return nil, err
+		}
+	}
+
+	// order commits so that a commit always comes after its parents
+	type frame struct {
+		sum []byte
+		i   int
+	}
+	result := [][]byte{}
+	stack := []*frame{{sum: headSum}}
+	visited := map[string]struct{}{string(headSum): {}}
+	for len(stack) > 0 {
+		f := stack[len(stack)-1]
+		com := commits[string(f.sum)]
+		if f.i < len(com.Parents) {
+			p := com.Parents[f.i]
+			f.i++
+			if _, ok := commits[string(p)]; !ok {
+				continue
+			}
+			if _, ok := visited[string(p)]; !ok {
+				visited[string(p)] = struct{}{}
+				stack = append(stack, &frame{sum: p})
+			}
+			continue
+		}
+		stack = stack[:len(stack)-1]
+		if len(com.Parents) == 1 {
+			result = append(result, f.sum)
+		}
+	}
+	return result, nil
+}
+
+// isLinearDescendant returns true if commit onto can be reached from headSum
+// without going through any merge commit.
+func isLinearDescendant(db objects.Store, headSum, onto []byte) (bool, error) {
+	sum := headSum
+	for !bytes.Equal(sum, onto) {
+		com, err := objects.GetCommit(db, sum)
+		if err != nil {
+			return false, err
+		}
+		if len(com.Parents) != 1 {
+			return false, nil
+		}
+		sum = com.Parents[0]
+	}
+	return true, nil
+}
+
+func startRebase(
+	cmd *cobra.Command, c *conf.Config, rd *local.RepoDir, db objects.Store, rs ref.Store, args []string,
+	opts *rebaseOptions,
+) error {
+	branch := args[0]
+	headSum, err := ref.GetHead(rs, branch)
+	if err != nil {
+		return fmt.Errorf("can't get branch %q: %v", branch, err)
+	}
+	_, onto, _, err := ref.InterpretCommitName(db, rs, args[1], false)
+	if err != nil {
+		return err
+	}
+	if ok, err := isLinearDescendant(db, headSum, onto); err != nil {
+		return err
+	} else if ok {
+		cmd.Printf("Branch %q is up to date\n", branch)
+		return nil
+	}
+	sums, err := commitsToRebase(db, headSum, onto)
+	if err != nil {
+		return err
+	}
+	if len(sums) == 0 {
+		if err = ref.SaveRef(rs, ref.HeadRef(branch), onto, c.User.Name, c.User.Email, "rebase", "fast-forward", nil); err != nil {
+			return err
+		}
+		cmd.Printf("Fast forward to %s\n", hex.EncodeToString(onto)[:7])
+		return nil
+	}
+	st := &rebaseState{
+		Branch:   branch,
+		OrigHead: hex.EncodeToString(headSum),
+		Onto:     hex.EncodeToString(onto),
+		Head:     hex.EncodeToString(onto),
+		Commits:  make([]string, len(sums)),
+	}
+	for i, sum := range sums {
+		st.Commits[i] = hex.EncodeToString(sum)
+	}
+	if err = writeRebaseState(rd, st); err != nil {
+		return err
+	}
+	return continueRebase(cmd, c, rd, db, rs, st, opts)
+}
+
+// rebaseStep replays the changes of commit com on top of headSum and returns
+// sum of the resulting table, or nil if the rebase should stop because of
+// conflicts.
+func rebaseStep(
+	cmd *cobra.Command, db objects.Store, rs ref.Store, branch string, headSum, sum []byte, com *objects.Commit,
+	opts *rebaseOptions,
+) ([]byte, error) {
+	commits := [][]byte{headSum, sum}
+	commitNames := []string{branch, hex.EncodeToString(sum)[:7]}
+	merger, buf, cleanup, err := newTablesMerger(cmd, db, rs, com.Parents[0], commits)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { cleanup() }()
+	cd, merges, err := collectMergeConflicts(cmd, merger)
+	if err != nil {
+		return nil, err
+	}
+	removedCols := removedColumns(cd)
+	if len(merges) > 0 {
+		if opts.noGUI {
+			// the merger can't be restarted, so a new one is needed to output conflicts
+			cleanup()
+			cleanup = func() {}
+			merger, buf, cleanup, err = newTablesMerger(cmd, db, rs, com.Parents[0], commits)
+			if err != nil {
+				return nil, err
+			}
+			return nil, outputConflicts(cmd, db, buf, merger, commitNames, com.Parents[0], commits)
+		}
+		removedCols, err = displayMergeApp(cmd, buf, merger, commitNames, commits, com.Parents[0], cd, merges)
+		if err != nil {
+			return nil, err
+		}
+	}
+	types, err := mergeColumnTypes(cmd, db, rs, commits)
+	if err != nil {
+		return nil, err
+	}
+	return saveMergeResult(cmd, db, merger, removedCols, types, opts.numWorkers)
+}
+
+func continueRebase(
+	cmd *cobra.Command, c *conf.Config, rd *local.RepoDir, db objects.Store, rs ref.Store, st *rebaseState,
+	opts *rebaseOptions,
+) error {
+	for len(st.Commits) > 0 {
+		headSum, err := hex.DecodeString(st.Head)
+		if err != nil {
+			return err
+		}
+		sum, err := hex.DecodeString(st.Commits[0])
+		if err != nil {
+			return err
+		}
+		com, err := objects.GetCommit(db, sum)
+		if err != nil {
+			return err
+		}
+		var tblSum []byte
+		if opts.commitCSV != "" {
+			_, tbl, err := getTable(db, rs, sum)
+			if err != nil {
+				return err
+			}
+			types, err := mergeColumnTypes(cmd, db, rs, [][]byte{headSum, sum})
+			if err != nil {
+				return err
+			}
+			file, err := os.Open(opts.commitCSV)
+			if err != nil {
+				return err
+			}
+			rows := rowsource.NewCSVReader(file, opts.delimiter)
+			header, err := rows.Read()
+			if err != nil {
+				file.Close()
+				return err
+			}
+			header = append([]string(nil), header...)
+			tblSum, err = ingestTable(
+				cmd, db, &headerReader{header: header, rows: rows}, tbl.PrimaryKey(), false, *utils.GetLogger(cmd),
+				nil,
+				[]ingest.InserterOption{
+					ingest.WithNumWorkers(opts.numWorkers),
+					ingest.WithColumnTypes(typesOfColumns(types, header)),
+				},
+			)
+			file.Close()
+			if err != nil {
+				return err
+			}
+			opts.commitCSV = ""
+		} else {
+			tblSum, err = rebaseStep(cmd, db, rs, st.Branch, headSum, sum, com, opts)
+			if err != nil {
+				return err
+			}
+			if tblSum == nil {
+				cmd.Printf("Could not apply commit %s. Resolve the conflicts and run \"wrgl rebase --continue --commit-csv FILE\", or run \"wrgl rebase --abort\" to stop rebasing.\n", st.Commits[0][:7])
+				return nil
+			}
+		}
+		head, err := objects.GetCommit(db, headSum)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(head.Table, tblSum) {
+			cmd.Printf("Skipped commit %s: its changes are already applied\n", st.Commits[0][:7])
+		} else {
+			newCom := &objects.Commit{
+				Table:       tblSum,
+				Message:     com.Message,
+				Time:        time.Now(),
+				AuthorName:  com.AuthorName,
+				AuthorEmail: com.AuthorEmail,
+				Parents:     [][]byte{headSum},
+			}
+			buf := bytes.NewBuffer(nil)
+			if _, err = newCom.WriteTo(buf); err != nil {
+				return err
+			}
+			newSum, err := objects.SaveCommit(db, buf.Bytes())
+			if err != nil {
+				return err
+			}
+			cmd.Printf("Applied commit %s as %s: %s\n", st.Commits[0][:7], hex.EncodeToString(newSum)[:7], ref.FirstLine(com.Message))
+			st.Head = hex.EncodeToString(newSum)
+		}
+		st.Commits = st.Commits[1:]
+		if err = writeRebaseState(rd, st); err != nil {
+			return err
+		}
+	}
+	return finishRebase(cmd, c, rd, rs, st)
+}
+
+func finishRebase(cmd *cobra.Command, c *conf.Config, rd *local.RepoDir, rs ref.Store, st *rebaseState) error {
+	sum, err := ref.GetHead(rs, st.Branch)
+	if err != nil {
+		return fmt.Errorf("can't get branch %q: %v", st.Branch, err)
+	}
+	if hex.EncodeToString(sum) != st.OrigHead {
+		return fmt.Errorf("branch %q has changed since the rebase started, run \"wrgl rebase --abort\" and start over", st.Branch)
+	}
+	headSum, err := hex.DecodeString(st.Head)
+	if err != nil {
+		return err
+	}
+	if err = ref.SaveRef(rs, ref.HeadRef(st.Branch), headSum, c.User.Name, c.User.Email, "rebase", "onto "+st.Onto, nil); err != nil {
+		return err
+	}
+	if err = os.Remove(rd.RebaseStatePath()); err != nil {
+		return err
+	}
+	cmd.Printf("Successfully rebased branch %q onto %s\n", st.Branch, st.Onto[:7])
+	return nil
+}
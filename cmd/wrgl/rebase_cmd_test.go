@@ -0,0 +1,263 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package wrgl
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wrgl/wrgl/pkg/factory"
+	"github.com/wrgl/wrgl/pkg/objects"
+	"github.com/wrgl/wrgl/pkg/ref"
+	refhelpers "github.com/wrgl/wrgl/pkg/ref/helpers"
+)
+
+func TestRebaseCmd(t *testing.T) {
+	rd, cleanup := createRepoDir(t)
+	defer cleanup()
+	db, err := rd.OpenObjectsStore()
+	require.NoError(t, err)
+	defer db.Close()
+	rs := rd.OpenRefStore()
+	base, _ := factory.CommitHead(t, db, rs, "main", []string{
+		"a,b,c",
+		"1,q,w",
+		"2,a,s",
+		"3,z,x",
+	}, []uint32{0})
+	mainSum, _ := factory.CommitHead(t, db, rs, "main", []string{
+		"a,b,c",
+		"1,q,w",
+		"2,a,s",
+		"3,z,v",
+	}, []uint32{0})
+	require.NoError(t, ref.SaveRef(rs, "heads/feature", base, "test", "test@domain.com", "branch", "created", nil))
+	_, com1 := factory.CommitHead(t, db, rs, "feature", []string{
+		"a,b,c",
+		"1,q,w",
+		"2,a,s",
+		"3,z,x",
+		"4,s,d",
+	}, []uint32{0})
+	// changes that are already in main are skipped
+	factory.CommitHead(t, db, rs, "feature", []string{
+		"a,b,c",
+		"1,q,w",
+		"2,a,s",
+		"3,z,v",
+		"4,s,d",
+	}, []uint32{0})
+	featureSum, com3 := factory.CommitHead(t, db, rs, "feature", []string{
+		"a,b,c",
+		"1,q,e",
+		"2,a,s",
+		"3,z,v",
+		"4,s,d",
+	}, []uint32{0})
+	require.NoError(t, db.Close())
+
+	cmd := rootCmd()
+	cmd.SetArgs([]string{"rebase", "feature"})
+	assert.Equal(t, fmt.Errorf("requires BRANCH and UPSTREAM"), cmd.Execute())
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"rebase", "--continue"})
+	assert.Equal(t, fmt.Errorf("no rebase in progress"), cmd.Execute())
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"rebase", "feature", "main"})
+	require.NoError(t, cmd.Execute())
+	_, err = os.Stat(rd.RebaseStatePath())
+	assert.True(t, os.IsNotExist(err))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"export", "feature"})
+	assertCmdOutput(t, cmd, "a,b,c\n1,q,e\n2,a,s\n3,z,v\n4,s,d\n")
+
+	db, err = rd.OpenObjectsStore()
+	require.NoError(t, err)
+	sum, err := ref.GetHead(rs, "feature")
+	require.NoError(t, err)
+	refhelpers.AssertLatestReflogEqual(t, rs, "heads/feature", &ref.Reflog{
+		OldOID:      featureSum,
+		NewOID:      sum,
+		AuthorName:  "John Doe",
+		AuthorEmail: "john@domain.com",
+		Action:      "rebase",
+		Message:     "onto " + hex.EncodeToString(mainSum),
+	})
+	com, err := objects.GetCommit(db, sum)
+	require.NoError(t, err)
+	assert.Equal(t, com3.Message, com.Message)
+	assert.Equal(t, com3.AuthorName, com.AuthorName)
+	require.Len(t, com.Parents, 1)
+	com, err = objects.GetCommit(db, com.Parents[0])
+	require.NoError(t, err)
+	assert.Equal(t, com1.Message, com.Message)
+	assert.Equal(t, com1.AuthorEmail, com.AuthorEmail)
+	assert.Equal(t, [][]byte{mainSum}, com.Parents)
+	require.NoError(t, db.Close())
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"rebase", "feature", "main"})
+	assertCmdOutput(t, cmd, "Branch \"feature\" is up to date\n")
+
+	// branch is fast-forwarded if it has no commit of its own
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"rebase", "main", "feature"})
+	assertCmdOutput(t, cmd, fmt.Sprintf("Fast forward to %s\n", hex.EncodeToString(sum)[:7]))
+}
+
+func TestRebaseCmdConflicts(t *testing.T) {
+	rd, cleanup := createRepoDir(t)
+	defer cleanup()
+	db, err := rd.OpenObjectsStore()
+	require.NoError(t, err)
+	defer db.Close()
+	rs := rd.OpenRefStore()
+	base, _ := factory.CommitHead(t, db, rs, "main", []string{
+		"a,b",
+		"1,q",
+		"2,a",
+	}, []uint32{0})
+	mainSum, _ := factory.CommitHead(t, db, rs, "main", []string{
+		"a,b",
+		"1,w",
+		"2,a",
+	}, []uint32{0})
+	require.NoError(t, ref.SaveRef(rs, "heads/feature", base, "test", "test@domain.com", "branch", "created", nil))
+	sum1, _ := factory.CommitHead(t, db, rs, "feature", []string{
+		"a,b",
+		"1,e",
+		"2,a",
+	}, []uint32{0})
+	featureSum, com2 := factory.CommitHead(t, db, rs, "feature", []string{
+		"a,b",
+		"1,e",
+		"2,a",
+		"3,z",
+	}, []uint32{0})
+	require.NoError(t, db.Close())
+
+	conflictsFile := fmt.Sprintf("CONFLICTS_%s_%s.csv", hex.EncodeToString(mainSum)[:7], hex.EncodeToString(sum1)[:7])
+	defer os.Remove(conflictsFile)
+	cmd := rootCmd()
+	cmd.SetArgs([]string{"rebase", "feature", "main", "--no-gui"})
+	buf := bytes.NewBuffer(nil)
+	cmd.SetOut(buf)
+	require.NoError(t, cmd.Execute())
+	assert.Contains(t, buf.String(), fmt.Sprintf("saved conflicts to file %s\n", conflictsFile))
+	assert.Contains(t, buf.String(), fmt.Sprintf("Could not apply commit %s.", hex.EncodeToString(sum1)[:7]))
+	_, err = os.Stat(rd.RebaseStatePath())
+	require.NoError(t, err)
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"rebase", "feature", "main"})
+	assert.Equal(t, fmt.Errorf("a rebase of branch \"feature\" is in progress, use --continue or --abort"), cmd.Execute())
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"rebase", "--abort"})
+	assertCmdOutput(t, cmd, "Rebase aborted, branch \"feature\" is left unchanged\n")
+	_, err = os.Stat(rd.RebaseStatePath())
+	assert.True(t, os.IsNotExist(err))
+	sum, err := ref.GetHead(rs, "feature")
+	require.NoError(t, err)
+	assert.Equal(t, featureSum, sum)
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"rebase", "feature", "main", "--no-gui"})
+	require.NoError(t, cmd.Execute())
+
+	_, fp := createCSVFile(t, []string{
+		"a,b",
+		"1,r",
+		"2,a",
+	})
+	defer os.Remove(fp)
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"rebase", "--continue", "--commit-csv", fp})
+	require.NoError(t, cmd.Execute())
+	_, err = os.Stat(rd.RebaseStatePath())
+	assert.True(t, os.IsNotExist(err))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"export", "feature"})
+	assertCmdOutput(t, cmd, "a,b\n1,r\n2,a\n3,z\n")
+
+	db, err = rd.OpenObjectsStore()
+	require.NoError(t, err)
+	defer db.Close()
+	sum, err = ref.GetHead(rs, "feature")
+	require.NoError(t, err)
+	com, err := objects.GetCommit(db, sum)
+	require.NoError(t, err)
+	assert.Equal(t, com2.Message, com.Message)
+	com, err = objects.GetCommit(db, com.Parents[0])
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{mainSum}, com.Parents)
+}
+
+func TestRebaseCmdContinueKeepsColumnTypes(t *testing.T) {
+	rd, cleanup := createRepoDir(t)
+	defer cleanup()
+	_, fp := createCSVFile(t, []string{
+		"a,b",
+		"1,10",
+		"2,20",
+	})
+	defer os.Remove(fp)
+	commitFile(t, "main", fp, "a", "--types", "b:integer")
+	rs := rd.OpenRefStore()
+	base, err := ref.GetHead(rs, "main")
+	require.NoError(t, err)
+	require.NoError(t, ref.SaveRef(rs, "heads/feature", base, "test", "test@domain.com", "branch", "created", nil))
+	overrideCSVFile(t, fp, []string{
+		"a,b",
+		"1,11",
+		"2,20",
+	})
+	commitFile(t, "main", fp, "a", "--types", "b:integer")
+	overrideCSVFile(t, fp, []string{
+		"a,b",
+		"1,12",
+		"2,20",
+	})
+	commitFile(t, "feature", fp, "a", "--types", "b:integer")
+	mainSum, err := ref.GetHead(rs, "main")
+	require.NoError(t, err)
+	featureSum, err := ref.GetHead(rs, "feature")
+	require.NoError(t, err)
+	conflictsFile := fmt.Sprintf("CONFLICTS_%s_%s.csv", hex.EncodeToString(mainSum)[:7], hex.EncodeToString(featureSum)[:7])
+	defer os.Remove(conflictsFile)
+
+	cmd := rootCmd()
+	cmd.SetArgs([]string{"rebase", "feature", "main", "--no-gui"})
+	buf := bytes.NewBuffer(nil)
+	cmd.SetOut(buf)
+	require.NoError(t, cmd.Execute())
+	assert.Contains(t, buf.String(), fmt.Sprintf("Could not apply commit %s.", hex.EncodeToString(featureSum)[:7]))
+
+	overrideCSVFile(t, fp, []string{
+		"a,b",
+		"1,13",
+		"2,20",
+	})
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"rebase", "--continue", "--commit-csv", fp})
+	require.NoError(t, cmd.Execute())
+
+	db, err := rd.OpenObjectsStore()
+	require.NoError(t, err)
+	defer db.Close()
+	com, tbl, err := getCommitTable(db, rs, "feature")
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{mainSum}, com.Parents)
+	assert.Equal(t, []string{"string", "integer"}, tbl.Types)
+	assert.Equal(t, [][]string{{"1", "13"}, {"2", "20"}}, factory.GetRows(t, db, tbl))
+}
@@ -0,0 +1,180 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package wrgl
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/wrgl/wrgl/cmd/wrgl/utils"
+	"github.com/wrgl/wrgl/pkg/conf"
+	conffs "github.com/wrgl/wrgl/pkg/conf/fs"
+	"github.com/wrgl/wrgl/pkg/objects"
+	"github.com/wrgl/wrgl/pkg/ref"
+)
+
+func revertCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "revert COMMIT [BRANCH]",
+		Short: "Create a new commit that undoes the changes of an existing commit.",
+		Long: strings.Join([]string{
+			"Create a new commit on top of BRANCH that undoes the row changes introduced by COMMIT:",
+			"rows added by COMMIT are removed, rows removed by COMMIT come back and rows modified",
+			"by COMMIT get their old values. Later changes to the same rows show up as conflicts in",
+			"the merge UI. If BRANCH is not given, COMMIT must refer to a branch (e.g. \"main\" or",
+			"\"main^\") and the new commit is created on that branch. Unlike \"wrgl reset\", this",
+			"command doesn't discard any commit.",
+		}, " "),
+		Example: utils.CombineExamples([]utils.Example{
+			{
+				Comment: "revert the latest commit of branch main",
+				Line:    "wrgl revert main",
+			},
+			{
+				Comment: "revert the third latest commit of branch main",
+				Line:    "wrgl revert main~2",
+			},
+			{
+				Comment: "revert a commit on branch main",
+				Line:    "wrgl revert 43a5f3447e82b53a2574ef5af470df96 main",
+			},
+			{
+				Comment: "don't show merge UI, output conflicts and resolved rows to CONFLICTS_SUM1_SUM2.csv instead",
+				Line:    "wrgl revert main^ --no-gui",
+			},
+		}),
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rd := utils.GetRepoDir(cmd)
+			defer rd.Close()
+			s := conffs.NewStore(rd.FullPath, conffs.AggregateSource, "")
+			c, err := s.Open()
+			if err != nil {
+				return err
+			}
+			if err := utils.EnsureUserSet(cmd, c); err != nil {
+				return err
+			}
+			db, err := rd.OpenObjectsStore()
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+			rs := rd.OpenRefStore()
+			noCommit, err := cmd.Flags().GetBool("no-commit")
+			if err != nil {
+				return err
+			}
+			noGUI, err := cmd.Flags().GetBool("no-gui")
+			if err != nil {
+				return err
+			}
+			numWorkers, err := cmd.Flags().GetInt("num-workers")
+			if err != nil {
+				return err
+			}
+			message, err := cmd.Flags().GetString("message")
+			if err != nil {
+				return err
+			}
+			return runRevert(cmd, c, db, rs, args, noCommit, noGUI, numWorkers, message)
+		},
+	}
+	cmd.Flags().Bool("no-commit", false, "perform the revert but don't create a commit, instead output the result to file MERGE_SUM1_SUM2.csv")
+	cmd.Flags().Bool("no-gui", false, "don't show mergetool, instead output conflicts (and resolved rows) to file CONFLICTS_SUM1_SUM2.csv")
+	cmd.Flags().StringP("message", "m", "", "commit message. Defaults to \"Revert <message of COMMIT>\"")
+	cmd.Flags().IntP("num-workers", "n", runtime.GOMAXPROCS(0), "number of CPU threads to utilize (default to GOMAXPROCS)")
+	return cmd
+}
+
+// branchOfCommit returns branch name if it's given, otherwise it returns the
+// branch that commitName refers to.
+func branchOfCommit(rs ref.Store, commitArg, commitName string, args []string) (string, error) {
+	if len(args) > 1 {
+		if _, err := ref.GetHead(rs, args[1]); err != nil {
+			return "", fmt.Errorf("can't get branch %q: %v", args[1], err)
+		}
+		return args[1], nil
+	}
+	if !strings.HasPrefix(commitName, "heads/") {
+		return "", fmt.Errorf("%q is not a branch, specify BRANCH", commitArg)
+	}
+	return strings.TrimPrefix(commitName, "heads/"), nil
+}
+
+func runRevert(
+	cmd *cobra.Command, c *conf.Config, db objects.Store, rs ref.Store, args []string,
+	noCommit, noGUI bool, numWorkers int, message string,
+) error {
+	name, sum, com, err := ref.InterpretCommitName(db, rs, args[0], true)
+	if err != nil {
+		return err
+	}
+	branch, err := branchOfCommit(rs, args[0], name, args)
+	if err != nil {
+		return err
+	}
+	if len(com.Parents) == 0 {
+		return fmt.Errorf("can't revert commit %x: it has no parent", sum)
+	}
+	if len(com.Parents) > 1 {
+		return fmt.Errorf("can't revert commit %x: reverting a merge commit is not supported", sum)
+	}
+	headSum, err := ref.GetHead(rs, branch)
+	if err != nil {
+		return err
+	}
+	if ok, err := ref.IsAncestorOf(db, sum, headSum); err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("commit %x is not part of branch %q", sum, branch)
+	}
+	if message == "" {
+		message = fmt.Sprintf("Revert %q\n\nThis reverts commit %x.", ref.FirstLine(com.Message), sum)
+	}
+	shortSum := hex.EncodeToString(sum)[:7]
+	// merging the parent into branch head with the reverted commit as base
+	// applies the inverse of the reverted commit's changes
+	return mergeTables(
+		cmd, db, rs, sum, [][]byte{headSum, com.Parents[0]},
+		[]string{branch, "parent of " + shortSum}, noCommit, noGUI, numWorkers,
+		func(tblSum []byte) error {
+			_, err := saveCommitOnBranch(cmd, db, rs, c.User, branch, &objects.Commit{
+				Table:       tblSum,
+				Message:     message,
+				Time:        time.Now(),
+				AuthorName:  c.User.Name,
+				AuthorEmail: c.User.Email,
+				Parents:     [][]byte{headSum},
+			}, "revert", "commit "+hex.EncodeToString(sum))
+			return err
+		},
+	)
+}
+
+// saveCommitOnBranch saves commit and points branch to it, recording the
+// action in branch's reflog under user u.
+func saveCommitOnBranch(
+	cmd *cobra.Command, db objects.Store, rs ref.Store, u *conf.User, branch string,
+	commit *objects.Commit, action, reflogMessage string,
+) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	if _, err := commit.WriteTo(buf); err != nil {
+		return nil, err
+	}
+	sum, err := objects.SaveCommit(db, buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if err = ref.SaveRef(rs, ref.HeadRef(branch), sum, u.Name, u.Email, action, reflogMessage, nil); err != nil {
+		return nil, err
+	}
+	cmd.Printf("[%s %s] %s\n", branch, hex.EncodeToString(sum)[:7], ref.FirstLine(commit.Message))
+	return sum, nil
+}
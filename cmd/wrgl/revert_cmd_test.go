@@ -0,0 +1,163 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package wrgl
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wrgl/wrgl/pkg/factory"
+	"github.com/wrgl/wrgl/pkg/objects"
+	"github.com/wrgl/wrgl/pkg/ref"
+	refhelpers "github.com/wrgl/wrgl/pkg/ref/helpers"
+)
+
+func TestRevertCmd(t *testing.T) {
+	rd, cleanup := createRepoDir(t)
+	defer cleanup()
+	db, err := rd.OpenObjectsStore()
+	require.NoError(t, err)
+	defer db.Close()
+	rs := rd.OpenRefStore()
+	sum1, _ := factory.CommitHead(t, db, rs, "main", []string{
+		"a,b,c",
+		"1,q,w",
+		"2,a,s",
+		"3,z,x",
+	}, []uint32{0})
+	sum2, com2 := factory.CommitHead(t, db, rs, "main", []string{
+		"a,b,c",
+		"1,q,e",
+		"2,a,s",
+		"4,s,d",
+	}, []uint32{0})
+	sum3, _ := factory.CommitHead(t, db, rs, "main", []string{
+		"a,b,c",
+		"1,q,e",
+		"2,a,f",
+		"4,s,d",
+		"5,r,t",
+	}, []uint32{0})
+	require.NoError(t, db.Close())
+
+	cmd := rootCmd()
+	cmd.SetArgs([]string{"revert", "main~2"})
+	assert.Equal(t, fmt.Errorf("can't revert commit %x: it has no parent", sum1), cmd.Execute())
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"revert", hex.EncodeToString(sum2)})
+	assert.Equal(t, fmt.Errorf("%q is not a branch, specify BRANCH", hex.EncodeToString(sum2)), cmd.Execute())
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"revert", hex.EncodeToString(sum2), "other"})
+	assert.Error(t, cmd.Execute())
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"revert", "main^", "--no-commit"})
+	require.NoError(t, cmd.Execute())
+	name := fmt.Sprintf("MERGE_%s_%s.csv", hex.EncodeToString(sum3)[:7], hex.EncodeToString(sum1)[:7])
+	defer os.Remove(name)
+	b, rows := readCSV(t, name)
+	assert.Equal(t, [][]string{
+		{"a", "b", "c"},
+		{"1", "q", "w"},
+		{"2", "a", "f"},
+		{"3", "z", "x"},
+		{"5", "r", "t"},
+	}, rows)
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"revert", hex.EncodeToString(sum2), "main"})
+	msg := fmt.Sprintf("Revert %q\n\nThis reverts commit %x.", ref.FirstLine(com2.Message), sum2)
+	require.NoError(t, cmd.Execute())
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"export", "main"})
+	assertCmdOutput(t, cmd, string(b))
+
+	db, err = rd.OpenObjectsStore()
+	require.NoError(t, err)
+	defer db.Close()
+	sum, err := ref.GetHead(rs, "main")
+	require.NoError(t, err)
+	refhelpers.AssertLatestReflogEqual(t, rs, "heads/main", &ref.Reflog{
+		OldOID:      sum3,
+		NewOID:      sum,
+		AuthorName:  "John Doe",
+		AuthorEmail: "john@domain.com",
+		Action:      "revert",
+		Message:     "commit " + hex.EncodeToString(sum2),
+	})
+	com, err := objects.GetCommit(db, sum)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{sum3}, com.Parents)
+	assert.Equal(t, msg, com.Message)
+	assert.Equal(t, "John Doe", com.AuthorName)
+	require.NoError(t, db.Close())
+
+	// a commit that is not part of branch can't be reverted
+	db, err = rd.OpenObjectsStore()
+	require.NoError(t, err)
+	sum4, com4 := factory.Commit(t, db, []string{
+		"a,b,c",
+		"1,q,w",
+	}, []uint32{0}, [][]byte{sum1})
+	require.NoError(t, ref.CommitHead(rs, "other", sum4, com4, nil))
+	require.NoError(t, db.Close())
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"revert", "other", "main"})
+	assert.Equal(t, fmt.Errorf("commit %x is not part of branch %q", sum4, "main"), cmd.Execute())
+}
+
+func TestRevertCmdNoGUI(t *testing.T) {
+	rd, cleanup := createRepoDir(t)
+	defer cleanup()
+	db, err := rd.OpenObjectsStore()
+	require.NoError(t, err)
+	defer db.Close()
+	rs := rd.OpenRefStore()
+	sum1, _ := factory.CommitHead(t, db, rs, "main", []string{
+		"a,b",
+		"1,q",
+		"2,a",
+	}, []uint32{0})
+	sum2, _ := factory.CommitHead(t, db, rs, "main", []string{
+		"a,b",
+		"1,w",
+		"2,a",
+	}, []uint32{0})
+	sum3, _ := factory.CommitHead(t, db, rs, "main", []string{
+		"a,b",
+		"1,e",
+		"2,a",
+	}, []uint32{0})
+	require.NoError(t, db.Close())
+
+	// row 1 was modified again after the reverted commit, resulting in a conflict
+	cmd := rootCmd()
+	cmd.SetArgs([]string{"revert", "main^", "--no-gui"})
+	name := fmt.Sprintf("CONFLICTS_%s_%s.csv", hex.EncodeToString(sum3)[:7], hex.EncodeToString(sum1)[:7])
+	assertCmdOutput(t, cmd, fmt.Sprintf("saved conflicts to file %s\n", name))
+	defer os.Remove(name)
+	_, rows := readCSV(t, name)
+	lines := make([]string, len(rows))
+	for i, row := range rows {
+		lines[i] = strings.Join(row, ",")
+	}
+	assert.Equal(t, []string{
+		",a,b",
+		fmt.Sprintf("COLUMNS IN main (%s),,", hex.EncodeToString(sum3)[:7]),
+		fmt.Sprintf("COLUMNS IN parent of %s (%s),,", hex.EncodeToString(sum2)[:7], hex.EncodeToString(sum1)[:7]),
+		fmt.Sprintf("BASE %s,1,w", hex.EncodeToString(sum2)[:7]),
+		fmt.Sprintf("main (%s),1,e", hex.EncodeToString(sum3)[:7]),
+		fmt.Sprintf("parent of %s (%s),1,q", hex.EncodeToString(sum2)[:7], hex.EncodeToString(sum1)[:7]),
+		"RESOLUTION,1,w",
+		",2,a",
+	}, lines)
+}
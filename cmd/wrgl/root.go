@@ -9,12 +9,14 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/wrgl/wrgl/cmd/wrgl/bisect"
 	"github.com/wrgl/wrgl/cmd/wrgl/branch"
 	"github.com/wrgl/wrgl/cmd/wrgl/config"
 	"github.com/wrgl/wrgl/cmd/wrgl/credentials"
 	"github.com/wrgl/wrgl/cmd/wrgl/fetch"
 	"github.com/wrgl/wrgl/cmd/wrgl/reflog"
 	"github.com/wrgl/wrgl/cmd/wrgl/remote"
+	"github.com/wrgl/wrgl/cmd/wrgl/tag"
 	"github.com/wrgl/wrgl/cmd/wrgl/transaction"
 	"github.com/wrgl/wrgl/cmd/wrgl/utils"
 )
@@ -80,18 +82,29 @@ func RootCmd() *cobra.Command {
 	utils.AddLoggerFlags(rootCmd.PersistentFlags())
 	utils.SetupProgressBarFlags(rootCmd.PersistentFlags())
 	rootCmd.AddCommand(newInitCmd())
+	rootCmd.AddCommand(cloneCmd())
 	rootCmd.AddCommand(newCommitCmd())
+	rootCmd.AddCommand(statusCmd())
 	rootCmd.AddCommand(newLogCmd())
+	rootCmd.AddCommand(showCmd())
+	rootCmd.AddCommand(blameCmd())
 	rootCmd.AddCommand(newPreviewCmd())
 	rootCmd.AddCommand(newDiffCmd())
 	rootCmd.AddCommand(newExportCmd())
+	rootCmd.AddCommand(getCmd())
+	rootCmd.AddCommand(queryCmd())
 	rootCmd.AddCommand(branch.RootCmd())
+	rootCmd.AddCommand(tag.RootCmd())
 	rootCmd.AddCommand(newPruneCmd())
 	rootCmd.AddCommand(newResetCmd())
 	rootCmd.AddCommand(newCatObjCmd())
 	rootCmd.AddCommand(fetch.RootCmd())
 	rootCmd.AddCommand(newPushCmd())
 	rootCmd.AddCommand(mergeCmd())
+	rootCmd.AddCommand(revertCmd())
+	rootCmd.AddCommand(cherryPickCmd())
+	rootCmd.AddCommand(rebaseCmd())
+	rootCmd.AddCommand(bisect.RootCmd())
 	rootCmd.AddCommand(pullCmd())
 	rootCmd.AddCommand(profileCmd())
 	rootCmd.AddCommand(config.RootCmd())
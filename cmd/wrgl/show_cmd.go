@@ -0,0 +1,165 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package wrgl
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/wrgl/wrgl/cmd/wrgl/utils"
+	apiclient "github.com/wrgl/wrgl/pkg/api/client"
+	"github.com/wrgl/wrgl/pkg/objects"
+	"github.com/wrgl/wrgl/pkg/ref"
+)
+
+func showCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "show COMMIT",
+		Short: "Show a commit along with a summary of its changes.",
+		Long: strings.Join([]string{
+			"Show a commit's metadata (author, date, message, parents and table shape) along with",
+			"a summary of row and column changes against its parent. For merge commits, a summary",
+			"is shown against each parent. A commit can be specified using shorten sum, full sum,",
+			"or a reference name.",
+		}, " "),
+		Example: utils.CombineExamples([]utils.Example{
+			{
+				Comment: "show the head commit of branch main",
+				Line:    "wrgl show main",
+			},
+			{
+				Comment: "show a commit by its full sum and print to stdout",
+				Line:    "wrgl show 1a2ed6248c7243cdaaecb98ac12213a7 --no-pager",
+			},
+		}),
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rd := utils.GetRepoDir(cmd)
+			defer rd.Close()
+			if err := quitIfRepoDirNotExist(cmd, rd); err != nil {
+				return err
+			}
+			db, err := rd.OpenObjectsStore()
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+			rs := rd.OpenRefStore()
+			_, _, commit, err := ref.InterpretCommitName(db, rs, args[0], false)
+			if err != nil {
+				return err
+			}
+			out, cleanOut, err := utils.PagerOrOut(cmd)
+			if err != nil {
+				return err
+			}
+			defer cleanOut()
+			return showCommit(cmd, db, rs, commit, out)
+		},
+	}
+	cmd.Flags().BoolP("no-pager", "P", false, "don't use PAGER")
+	return cmd
+}
+
+func showCommit(cmd *cobra.Command, db objects.Store, rs ref.Store, commit *objects.Commit, out io.Writer) error {
+	zone, offset := time.Now().Zone()
+	fmt.Fprintf(out, "commit %x\n", commit.Sum)
+	if n := len(commit.Parents); n > 0 {
+		sums := make([]string, n)
+		for i, p := range commit.Parents {
+			sums[i] = hex.EncodeToString(p)
+		}
+		label := "Parent"
+		if n > 1 {
+			label = "Parents"
+		}
+		fmt.Fprintf(out, "%s: %s\n", label, strings.Join(sums, " "))
+	}
+	fmt.Fprintf(out, "table %x", commit.Table)
+	tbl, err := objects.GetTable(db, commit.Table)
+	if err == nil {
+		fmt.Fprintf(out, " (%d columns, %d rows", len(tbl.Columns), tbl.RowsCount)
+		if pk := tbl.PrimaryKey(); len(pk) > 0 {
+			fmt.Fprintf(out, ", primary key: %s", strings.Join(pk, ","))
+		}
+		fmt.Fprintln(out, ")")
+	} else {
+		c := color.New(color.FgRed)
+		c.Fprint(out, " <missing")
+		if remote, err := apiclient.FindRemoteFor(db, rs, commit.Sum); err != nil {
+			return err
+		} else if remote != "" {
+			c.Fprintf(out, ", possibly reside on %s", remote)
+		}
+		c.Fprint(out, ">\n")
+	}
+	fmt.Fprintf(out, "Author: %s <%s>\n", commit.AuthorName, commit.AuthorEmail)
+	fmt.Fprintf(out, "Date: %s\n", commit.Time.In(time.FixedZone(zone, offset)))
+	fmt.Fprintf(out, "\n    %s\n", strings.ReplaceAll(commit.Message, "\n", "\n    "))
+	if tbl == nil || len(commit.Parents) == 0 {
+		return nil
+	}
+	fmt.Fprintln(out)
+	for _, parentSum := range commit.Parents {
+		sum, err := diffSummaryAgainst(cmd, db, rs, commit, parentSum)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Changes against %s: %s\n", hex.EncodeToString(parentSum)[:7], sum)
+	}
+	return nil
+}
+
+// diffSummaryAgainst returns a colored summary of the changes introduced by
+// commit compared to the commit with sum baseSum, usually one of its parents.
+func diffSummaryAgainst(cmd *cobra.Command, db objects.Store, rs ref.Store, commit *objects.Commit, baseSum []byte) (string, error) {
+	return diffSummaryBetween(cmd, db, db, rs, commit, baseSum)
+}
+
+// diffSummaryBetween is like diffSummaryAgainst but the table of commit is read
+// from db1 while the commit with sum baseSum and its table are read from db2.
+func diffSummaryBetween(cmd *cobra.Command, db1, db2 objects.Store, rs ref.Store, commit *objects.Commit, baseSum []byte) (string, error) {
+	base, err := objects.GetCommit(db2, baseSum)
+	if err != nil {
+		return "", fmt.Errorf("objects.GetCommit err: %v", err)
+	}
+	if !objects.TableExist(db2, base.Table) {
+		return color.New(color.FgRed).Sprintf("<table of %s missing>", hex.EncodeToString(baseSum)[:7]), nil
+	}
+	tbl1, tbl2, diffChan, _, cd, errChan, err := getDiffChan(cmd, db1, db2, rs, commit, base, 0)
+	if err != nil {
+		return "", err
+	}
+	sum, err := outputDiffSummaryToTerminal(
+		cmd, db1, db2, "", "", hex.EncodeToString(commit.Sum), hex.EncodeToString(baseSum),
+		tbl1, tbl2, diffChan, cd,
+	)
+	if err != nil {
+		return "", err
+	}
+	close(errChan)
+	if err, ok := <-errChan; ok {
+		return "", err
+	}
+	if uintSliceEqual(cd.BasePK, cd.OtherPK[0]) {
+		// outputDiffSummaryToTerminal only reports column changes when the
+		// primary key has changed
+		if colsSum := columnsSummary(tbl1, tbl2); colsSum != "" {
+			if sum == "" {
+				sum = colsSum
+			} else {
+				sum = colsSum + "; " + sum
+			}
+		}
+	}
+	if sum == "" {
+		return "no changes", nil
+	}
+	return sum, nil
+}
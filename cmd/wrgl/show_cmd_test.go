@@ -0,0 +1,144 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package wrgl
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wrgl/wrgl/pkg/factory"
+	"github.com/wrgl/wrgl/pkg/objects"
+	"github.com/wrgl/wrgl/pkg/ref"
+)
+
+func showCommitHeader(com *objects.Commit, tableShape string) []string {
+	zone, offset := time.Now().Zone()
+	lines := []string{fmt.Sprintf("commit %x", com.Sum)}
+	if len(com.Parents) == 1 {
+		lines = append(lines, fmt.Sprintf("Parent: %x", com.Parents[0]))
+	} else if len(com.Parents) > 1 {
+		lines = append(lines, fmt.Sprintf("Parents: %x %x", com.Parents[0], com.Parents[1]))
+	}
+	return append(lines,
+		fmt.Sprintf("table %x %s", com.Table, tableShape),
+		fmt.Sprintf("Author: %s <%s>", com.AuthorName, com.AuthorEmail),
+		fmt.Sprintf("Date: %s", com.Time.Truncate(time.Second).In(time.FixedZone(zone, offset))),
+		"",
+		"    "+com.Message,
+	)
+}
+
+func TestShowCmd(t *testing.T) {
+	rd, cleanUp := createRepoDir(t)
+	defer cleanUp()
+
+	db, err := rd.OpenObjectsStore()
+	require.NoError(t, err)
+	rs := rd.OpenRefStore()
+	sum1, com1 := factory.Commit(t, db, []string{
+		"a,b,c",
+		"1,q,w",
+		"2,a,s",
+		"3,z,x",
+	}, []uint32{0}, nil)
+	sum2, com2 := factory.Commit(t, db, []string{
+		"a,b,c",
+		"1,q,e",
+		"3,z,x",
+		"4,s,d",
+	}, []uint32{0}, [][]byte{sum1})
+	sum3, _ := factory.Commit(t, db, []string{
+		"a,b,d",
+		"1,q,w",
+		"2,a,s",
+		"3,z,x",
+	}, []uint32{0}, nil)
+	sum4, com4 := factory.Commit(t, db, []string{
+		"a,b,c",
+		"1,q,e",
+		"3,z,x",
+		"4,s,d",
+	}, []uint32{1}, [][]byte{sum2, sum3})
+	require.NoError(t, ref.CommitHead(rs, "alpha", sum4, com4, nil))
+	require.NoError(t, db.Close())
+
+	cmd := rootCmd()
+	cmd.SetArgs([]string{"show", hex.EncodeToString(sum1), "--no-pager"})
+	assertCmdOutput(t, cmd, strings.Join(append(
+		showCommitHeader(com1, "(3 columns, 3 rows, primary key: a)"),
+		"",
+	), "\n"))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"show", hex.EncodeToString(sum2), "--no-pager"})
+	buf := bytes.NewBuffer(nil)
+	cmd.SetOut(buf)
+	require.NoError(t, cmd.Execute())
+	assert.Equal(t, strings.Join(append(
+		showCommitHeader(com2, "(3 columns, 3 rows, primary key: a)"),
+		"",
+		fmt.Sprintf("Changes against %s: rows: +1/-1/m1", hex.EncodeToString(sum1)[:7]),
+		"",
+	), "\n"), removeColor(buf.String()))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"show", "alpha", "--no-pager"})
+	buf = bytes.NewBuffer(nil)
+	cmd.SetOut(buf)
+	require.NoError(t, cmd.Execute())
+	assert.Equal(t, strings.Join(append(
+		showCommitHeader(com4, "(3 columns, 3 rows, primary key: b)"),
+		"",
+		fmt.Sprintf("Changes against %s: primary key: a->b", hex.EncodeToString(sum2)[:7]),
+		fmt.Sprintf("Changes against %s: columns: +1/-1; primary key: a->b", hex.EncodeToString(sum3)[:7]),
+		"",
+	), "\n"), removeColor(buf.String()))
+
+	db, err = rd.OpenObjectsStore()
+	require.NoError(t, err)
+	sum5, com5 := factory.Commit(t, db, []string{
+		"a,b,d",
+		"1,q,w",
+		"2,a,s",
+		"3,z,x",
+	}, []uint32{0}, [][]byte{sum3})
+	sum6, com6 := factory.Commit(t, db, []string{
+		"a,b,c",
+		"1,q,w",
+		"2,a,s",
+		"3,z,x",
+	}, []uint32{0}, [][]byte{sum5})
+	require.NoError(t, db.Close())
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"show", hex.EncodeToString(sum5), "--no-pager"})
+	assertCmdOutput(t, cmd, strings.Join(append(
+		showCommitHeader(com5, "(3 columns, 3 rows, primary key: a)"),
+		"",
+		fmt.Sprintf("Changes against %s: no changes", hex.EncodeToString(sum3)[:7]),
+		"",
+	), "\n"))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"show", hex.EncodeToString(sum6), "--no-pager"})
+	buf = bytes.NewBuffer(nil)
+	cmd.SetOut(buf)
+	require.NoError(t, cmd.Execute())
+	assert.Equal(t, strings.Join(append(
+		showCommitHeader(com6, "(3 columns, 3 rows, primary key: a)"),
+		"",
+		fmt.Sprintf("Changes against %s: columns: +1/-1; rows: m3", hex.EncodeToString(sum5)[:7]),
+		"",
+	), "\n"), removeColor(buf.String()))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"show", "beta", "--no-pager"})
+	assertCmdFailed(t, cmd, "", fmt.Errorf("can't find branch beta"))
+}
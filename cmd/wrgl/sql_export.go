@@ -0,0 +1,380 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package wrgl
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wrgl/wrgl/cmd/wrgl/utils"
+	"github.com/wrgl/wrgl/pkg/diff"
+	"github.com/wrgl/wrgl/pkg/objects"
+	"github.com/wrgl/wrgl/pkg/rowsource"
+	"github.com/wrgl/wrgl/pkg/slice"
+	"github.com/wrgl/wrgl/pkg/sqlutil"
+)
+
+const (
+	sqlExportReplace = "replace"
+	sqlExportUpsert  = "upsert"
+
+	// sqlExportsTable records the last commit exported into each table of the
+	// target database, which lets upsert mode apply only the changes since
+	// then
+	sqlExportsTable = "wrgl_exports"
+)
+
+// sqlDialect holds the differences between databases that matter when
+// exporting a table
+type sqlDialect struct {
+	// quote quotes an identifier
+	quote func(name string) string
+	// placeholder returns the placeholder of the i-th (zero-based) parameter
+	placeholder func(i int) string
+	// keyType is the column type of primary key columns, other columns are
+	// TEXT
+	keyType string
+	// upsertClause returns the clause that turns an INSERT statement into an
+	// upsert, given quoted column names
+	upsertClause func(columns, pk []string) string
+}
+
+func quoteDouble(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func onConflictClause(columns, pk []string) string {
+	var sets []string
+	for _, col := range columns {
+		if !slice.StringSliceContains(pk, col) {
+			sets = append(sets, fmt.Sprintf("%s = excluded.%s", col, col))
+		}
+	}
+	if len(sets) == 0 {
+		return fmt.Sprintf(" ON CONFLICT (%s) DO NOTHING", strings.Join(pk, ", "))
+	}
+	return fmt.Sprintf(" ON CONFLICT (%s) DO UPDATE SET %s", strings.Join(pk, ", "), strings.Join(sets, ", "))
+}
+
+var sqlDialects = map[string]*sqlDialect{
+	rowsource.DriverPostgres: {
+		quote: quoteDouble,
+		placeholder: func(i int) string {
+			return fmt.Sprintf("$%d", i+1)
+		},
+		keyType:      "TEXT",
+		upsertClause: onConflictClause,
+	},
+	rowsource.DriverSQLite: {
+		quote: quoteDouble,
+		placeholder: func(i int) string {
+			return "?"
+		},
+		keyType:      "TEXT",
+		upsertClause: onConflictClause,
+	},
+	rowsource.DriverMySQL: {
+		quote: func(name string) string {
+			return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+		},
+		placeholder: func(i int) string {
+			return "?"
+		},
+		// MySQL can't index TEXT columns without a prefix length
+		keyType: "VARCHAR(255)",
+		upsertClause: func(columns, pk []string) string {
+			sets := make([]string, len(columns))
+			for i, col := range columns {
+				sets[i] = fmt.Sprintf("%s = VALUES(%s)", col, col)
+			}
+			return " ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+		},
+	},
+}
+
+// sqlExporter writes rows of a table into a database table
+type sqlExporter struct {
+	tx      *sql.Tx
+	d       *sqlDialect
+	table   string
+	columns []string
+	pk      []string
+	args    []interface{}
+}
+
+func (e *sqlExporter) quoteAll(names []string) []string {
+	result := make([]string, len(names))
+	for i, name := range names {
+		result[i] = e.d.quote(name)
+	}
+	return result
+}
+
+func (e *sqlExporter) placeholders(n int) string {
+	result := make([]string, n)
+	for i := range result {
+		result[i] = e.d.placeholder(i)
+	}
+	return strings.Join(result, ", ")
+}
+
+func (e *sqlExporter) createTable(ifNotExists bool) error {
+	defs := make([]string, len(e.columns))
+	for i, col := range e.columns {
+		typ := "TEXT"
+		if slice.StringSliceContains(e.pk, col) {
+			typ = e.d.keyType + " NOT NULL"
+		}
+		defs[i] = fmt.Sprintf("%s %s", e.d.quote(col), typ)
+	}
+	if len(e.pk) > 0 {
+		defs = append(defs, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(e.quoteAll(e.pk), ", ")))
+	}
+	stmt := "CREATE TABLE "
+	if ifNotExists {
+		stmt += "IF NOT EXISTS "
+	}
+	_, err := e.tx.Exec(fmt.Sprintf("%s%s (%s)", stmt, e.d.quote(e.table), strings.Join(defs, ", ")))
+	return err
+}
+
+// prepareInsert prepares a statement that inserts a row, or upserts it if
+// upsert is true
+func (e *sqlExporter) prepareInsert(upsert bool) (*sql.Stmt, error) {
+	cols := e.quoteAll(e.columns)
+	stmt := fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES (%s)",
+		e.d.quote(e.table), strings.Join(cols, ", "), e.placeholders(len(cols)),
+	)
+	if upsert {
+		stmt += e.d.upsertClause(cols, e.quoteAll(e.pk))
+	}
+	return e.tx.Prepare(stmt)
+}
+
+func (e *sqlExporter) prepareDelete() (*sql.Stmt, error) {
+	conds := make([]string, len(e.pk))
+	for i, col := range e.quoteAll(e.pk) {
+		conds[i] = fmt.Sprintf("%s = %s", col, e.d.placeholder(i))
+	}
+	return e.tx.Prepare(fmt.Sprintf("DELETE FROM %s WHERE %s", e.d.quote(e.table), strings.Join(conds, " AND ")))
+}
+
+func (e *sqlExporter) exec(stmt *sql.Stmt, values []string) error {
+	e.args = e.args[:0]
+	for _, v := range values {
+		e.args = append(e.args, v)
+	}
+	_, err := stmt.Exec(e.args...)
+	return err
+}
+
+// lastExportedCommit returns the sum of the commit last exported into the
+// table, or nil if the table hasn't been exported into
+func (e *sqlExporter) lastExportedCommit() ([]byte, error) {
+	_, err := e.tx.Exec(fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (%s %s NOT NULL PRIMARY KEY, %s TEXT NOT NULL)",
+		e.d.quote(sqlExportsTable), e.d.quote("table_name"), e.d.keyType, e.d.quote("commit_sum"),
+	))
+	if err != nil {
+		return nil, err
+	}
+	var s string
+	err = e.tx.QueryRow(fmt.Sprintf(
+		"SELECT %s FROM %s WHERE %s = %s",
+		e.d.quote("commit_sum"), e.d.quote(sqlExportsTable), e.d.quote("table_name"), e.d.placeholder(0),
+	), e.table).Scan(&s)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(s)
+}
+
+func (e *sqlExporter) saveExportedCommit(sum []byte) error {
+	cols := e.quoteAll([]string{"table_name", "commit_sum"})
+	_, err := e.tx.Exec(fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES (%s)%s",
+		e.d.quote(sqlExportsTable), strings.Join(cols, ", "), e.placeholders(2), e.d.upsertClause(cols, cols[:1]),
+	), e.table, hex.EncodeToString(sum))
+	return err
+}
+
+// insertAll inserts all rows of tbl, returning the number of rows written
+func (e *sqlExporter) insertAll(db objects.Store, tbl *objects.Table, upsert bool) (n int, err error) {
+	stmt, err := e.prepareInsert(upsert)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+	var buf []byte
+	var blk [][]string
+	for _, sum := range tbl.Blocks {
+		blk, buf, err = objects.GetBlock(db, buf, sum)
+		if err != nil {
+			return n, err
+		}
+		for _, row := range blk {
+			if err = e.exec(stmt, row); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+	return n, nil
+}
+
+// applyChanges upserts rows that were added or modified and deletes rows that
+// were removed since oldTbl. It returns the number of rows upserted and
+// deleted.
+func (e *sqlExporter) applyChanges(cmd *cobra.Command, db objects.Store, com, oldCom *objects.Commit, tbl, oldTbl *objects.Table) (upserted, deleted int, err error) {
+	tblIdx, err := objects.GetTableIndex(db, com.Table)
+	if err != nil {
+		return
+	}
+	oldTblIdx, err := objects.GetTableIndex(db, oldCom.Table)
+	if err != nil {
+		return
+	}
+	upsertStmt, err := e.prepareInsert(true)
+	if err != nil {
+		return
+	}
+	defer upsertStmt.Close()
+	deleteStmt, err := e.prepareDelete()
+	if err != nil {
+		return
+	}
+	defer deleteStmt.Close()
+	buf, err := diff.BlockBufferWithSingleStore(db, []*objects.Table{tbl, oldTbl})
+	if err != nil {
+		return
+	}
+	errChan := make(chan error, 1)
+	logger := utils.GetLogger(cmd)
+	diffChan, _ := diff.DiffTables(db, db, tbl, oldTbl, tblIdx, oldTblIdx, errChan, *logger)
+	pkValues := make([]string, len(oldTbl.PK))
+	for d := range diffChan {
+		if d.Sum != nil {
+			blk, off := diff.RowToBlockAndOffset(d.Offset)
+			var row []string
+			if row, err = buf.GetRow(0, blk, off); err != nil {
+				break
+			}
+			if err = e.exec(upsertStmt, row); err != nil {
+				break
+			}
+			upserted++
+			continue
+		}
+		blk, off := diff.RowToBlockAndOffset(d.OldOffset)
+		var row []string
+		if row, err = buf.GetRow(1, blk, off); err != nil {
+			break
+		}
+		for i, u := range oldTbl.PK {
+			pkValues[i] = row[u]
+		}
+		if err = e.exec(deleteStmt, pkValues); err != nil {
+			break
+		}
+		deleted++
+	}
+	// on early exit, let the differ run to completion instead of blocking
+	// forever on diffChan
+	for range diffChan {
+	}
+	close(errChan)
+	if diffErr, ok := <-errChan; ok && err == nil {
+		err = diffErr
+	}
+	return upserted, deleted, err
+}
+
+// exportToSQL writes the table of commit com into a table of the database
+// at source. In replace mode the table is recreated with all rows. In upsert
+// mode only rows that changed since the last exported commit are written, or
+// all rows if the table hasn't been exported into before.
+func exportToSQL(cmd *cobra.Command, db objects.Store, com *objects.Commit, tbl *objects.Table, source, table, mode string) error {
+	if mode != sqlExportReplace && mode != sqlExportUpsert {
+		return fmt.Errorf("invalid mode %q, must be one of %s, %s", mode, sqlExportReplace, sqlExportUpsert)
+	}
+	if mode == sqlExportUpsert && len(tbl.PK) == 0 {
+		return fmt.Errorf("mode %s requires a table with primary key", sqlExportUpsert)
+	}
+	driver, _, err := rowsource.SQLDriver(source)
+	if err != nil {
+		return err
+	}
+	sqlDB, err := rowsource.OpenSQL(source)
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+	return sqlutil.RunInTx(sqlDB, func(tx *sql.Tx) error {
+		e := &sqlExporter{
+			tx:      tx,
+			d:       sqlDialects[driver],
+			table:   table,
+			columns: tbl.Columns,
+			pk:      tbl.PrimaryKey(),
+		}
+		lastSum, err := e.lastExportedCommit()
+		if err != nil {
+			return err
+		}
+		switch {
+		case mode == sqlExportReplace:
+			if _, err = tx.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", e.d.quote(table))); err != nil {
+				return err
+			}
+			if err = e.createTable(false); err != nil {
+				return err
+			}
+			n, err := e.insertAll(db, tbl, false)
+			if err != nil {
+				return err
+			}
+			cmd.Printf("Exported %d rows into table %q\n", n, table)
+		case lastSum == nil:
+			if err = e.createTable(true); err != nil {
+				return err
+			}
+			n, err := e.insertAll(db, tbl, true)
+			if err != nil {
+				return err
+			}
+			cmd.Printf("Upserted %d rows into table %q\n", n, table)
+		default:
+			if bytes.Equal(lastSum, com.Sum) {
+				cmd.Printf("Commit %s is already exported into table %q\n", hex.EncodeToString(com.Sum)[:7], table)
+				return nil
+			}
+			oldCom, err := objects.GetCommit(db, lastSum)
+			if err != nil {
+				return fmt.Errorf("previously exported commit %s not found, use --mode %s instead: %v", hex.EncodeToString(lastSum), sqlExportReplace, err)
+			}
+			oldTbl, err := objects.GetTable(db, oldCom.Table)
+			if err != nil {
+				return err
+			}
+			if !slice.StringSliceEqual(oldTbl.Columns, tbl.Columns) || !slice.StringSliceEqual(oldTbl.PrimaryKey(), tbl.PrimaryKey()) {
+				return fmt.Errorf("columns or primary key have changed since commit %s was exported, use --mode %s instead", hex.EncodeToString(lastSum)[:7], sqlExportReplace)
+			}
+			upserted, deleted, err := e.applyChanges(cmd, db, com, oldCom, tbl, oldTbl)
+			if err != nil {
+				return err
+			}
+			cmd.Printf("Upserted %d rows and deleted %d rows in table %q\n", upserted, deleted, table)
+		}
+		return e.saveExportedCommit(com.Sum)
+	})
+}
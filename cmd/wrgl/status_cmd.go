@@ -0,0 +1,274 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package wrgl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wrgl/wrgl/cmd/wrgl/utils"
+	"github.com/wrgl/wrgl/pkg/conf"
+	conffs "github.com/wrgl/wrgl/pkg/conf/fs"
+	"github.com/wrgl/wrgl/pkg/objects"
+	objmock "github.com/wrgl/wrgl/pkg/objects/mock"
+	"github.com/wrgl/wrgl/pkg/ref"
+)
+
+func statusCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status [BRANCH...]",
+		Short: "Show status of configured branches.",
+		Long: strings.Join([]string{
+			"Show status of branches that are configured. For each branch with branch.file, show whether",
+			"the file is modified, missing or unchanged compared to the branch head, along with a summary",
+			"of the changes if the file is modified. A file that hasn't changed since \"wrgl commit\" or",
+			"\"wrgl diff\" last ingested it isn't read again, otherwise it is ingested in memory the same",
+			"way \"wrgl commit\" does: status never writes to the repository. For each branch with",
+			"branch.remote and branch.merge, show how many commits the branch is ahead of and behind its",
+			"upstream, as of the last fetch.",
+		}, " "),
+		Example: utils.CombineExamples([]utils.Example{
+			{
+				Comment: "show status of all configured branches",
+				Line:    "wrgl status",
+			},
+			{
+				Comment: "show status of branch main only",
+				Line:    "wrgl status main",
+			},
+		}),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rd := utils.GetRepoDir(cmd)
+			defer rd.Close()
+			if err := quitIfRepoDirNotExist(cmd, rd); err != nil {
+				return err
+			}
+			s := conffs.NewStore(rd.FullPath, conffs.AggregateSource, "")
+			c, err := s.Open()
+			if err != nil {
+				return err
+			}
+			db, err := rd.OpenObjectsStore()
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+			rs := rd.OpenRefStore()
+			names := args
+			if len(names) == 0 {
+				for name := range c.Branch {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+			}
+			if len(names) == 0 {
+				cmd.Println("No branch is configured")
+				return nil
+			}
+			for i, name := range names {
+				branch, ok := c.Branch[name]
+				if !ok {
+					return fmt.Errorf("branch %q is not configured", name)
+				}
+				if i > 0 {
+					cmd.Println()
+				}
+				if err := showBranchStatus(cmd, db, rs, c, name, branch); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	registerCommitFlags(cmd.Flags())
+	cmd.Flags().Bool("no-cache", false, "ingest each branch.file again even if it hasn't changed since the last time it was ingested")
+	return cmd
+}
+
+func showBranchStatus(cmd *cobra.Command, db objects.Store, rs ref.Store, c *conf.Config, name string, branch *conf.Branch) error {
+	cmd.Printf("branch %s\n", name)
+	headSum, err := ref.GetHead(rs, name)
+	if err != nil && !errors.Is(err, ref.ErrKeyNotFound) {
+		return err
+	}
+	if branch.File != "" {
+		status, err := branchFileStatus(cmd, db, rs, name, branch, headSum)
+		if err != nil {
+			return err
+		}
+		cmd.Printf("  file %s: %s\n", branch.File, status)
+	}
+	if branch.Remote != "" && branch.Merge != "" {
+		upstream, status, err := upstreamStatus(db, rs, c, branch, headSum)
+		if err != nil {
+			return err
+		}
+		cmd.Printf("  upstream %s: %s\n", upstream, status)
+	}
+	return nil
+}
+
+// branchFileStatus compares branch.file with the branch head. It doesn't
+// write to the repository: the table of the branch's temporary commit is
+// reused if the file hasn't changed since it was ingested, otherwise the file
+// is ingested into an in-memory store.
+func branchFileStatus(
+	cmd *cobra.Command, db objects.Store, rs ref.Store, name string, branch *conf.Branch, headSum []byte,
+) (string, error) {
+	if ok, err := inputExists(branch.File); err != nil {
+		return "", err
+	} else if !ok {
+		return "missing", nil
+	}
+	if headSum == nil {
+		return "not committed yet", nil
+	}
+	opts := branchIngestOptions(branch)
+	// rules are checked when committing, not when showing status
+	opts.Rules = nil
+	tableDB, tableSum, err := branchFileTable(cmd, db, rs, name, branch.File, opts)
+	if err != nil {
+		return "", fmt.Errorf("error reading file %q: %v", branch.File, err)
+	}
+	headCom, err := objects.GetCommit(db, headSum)
+	if err != nil {
+		return "", err
+	}
+	if bytes.Equal(tableSum, headCom.Table) {
+		return "unchanged", nil
+	}
+	summary, err := diffSummaryBetween(cmd, tableDB, db, rs, &objects.Commit{Table: tableSum}, headSum)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("modified (%s)", summary), nil
+}
+
+// branchFileTable returns the sum of the table ingested from file and the
+// store that holds it, which is db if the table of the branch's temporary
+// commit is reused, otherwise an in-memory store.
+func branchFileTable(cmd *cobra.Command, db objects.Store, rs ref.Store, name, file string, opts *ingestOptions) (objects.Store, []byte, error) {
+	noCache, err := cmd.Flags().GetBool("no-cache")
+	if err != nil {
+		return nil, nil, err
+	}
+	if !noCache {
+		com, err := freshTempCommit(db, rs, name, file, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		if com != nil {
+			return db, com.Table, nil
+		}
+	}
+	f, err := openInput(cmd, file)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	rows, err := newRowReader(f, file, opts.Format, opts.Delimiter)
+	if err != nil {
+		return nil, nil, err
+	}
+	memStore := objmock.NewStore()
+	sum, err := ingestRows(cmd, memStore, rs, rows, opts, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	return memStore, sum, nil
+}
+
+// upstreamStatus returns the remote-tracking ref of branch's upstream and how
+// far apart the branch and its upstream are.
+func upstreamStatus(db objects.Store, rs ref.Store, c *conf.Config, branch *conf.Branch, headSum []byte) (upstream, status string, err error) {
+	rem, ok := c.Remote[branch.Remote]
+	if !ok {
+		return branch.Remote + " " + branch.Merge, fmt.Sprintf("remote %q not found", branch.Remote), nil
+	}
+	for _, s := range rem.Fetch {
+		if s.SrcMatchRef(branch.Merge) {
+			upstream = s.Dst()
+			if s.IsGlob() {
+				upstream = s.DstForRef(branch.Merge)
+			}
+			break
+		}
+	}
+	if upstream == "" {
+		return branch.Remote + " " + branch.Merge, "not fetched by any refspec of remote", nil
+	}
+	upstreamSum, err := ref.GetRef(rs, strings.TrimPrefix(upstream, "refs/"))
+	if errors.Is(err, ref.ErrKeyNotFound) {
+		return upstream, "not fetched yet", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	if headSum == nil {
+		return upstream, "branch has no commit yet", nil
+	}
+	ahead, behind, err := countDivergence(db, headSum, upstreamSum)
+	if err != nil {
+		return "", "", err
+	}
+	switch {
+	case ahead == 0 && behind == 0:
+		status = "up to date"
+	case behind == 0:
+		status = fmt.Sprintf("ahead %d", ahead)
+	case ahead == 0:
+		status = fmt.Sprintf("behind %d", behind)
+	default:
+		status = fmt.Sprintf("ahead %d, behind %d", ahead, behind)
+	}
+	return upstream, status, nil
+}
+
+// ancestors returns sum and all of its ancestors
+func ancestors(db objects.Store, sum []byte) (map[string]struct{}, error) {
+	m := map[string]struct{}{}
+	q, err := ref.NewCommitsQueue(db, [][]byte{sum})
+	if err != nil {
+		return nil, err
+	}
+	for {
+		sum, _, err := q.PopInsertParents()
+		if errors.Is(err, io.EOF) {
+			return m, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		m[string(sum)] = struct{}{}
+	}
+}
+
+// countDivergence returns the number of commits reachable from sum1 but not
+// from sum2 and vice versa.
+func countDivergence(db objects.Store, sum1, sum2 []byte) (ahead, behind int, err error) {
+	m1, err := ancestors(db, sum1)
+	if err != nil {
+		return
+	}
+	m2, err := ancestors(db, sum2)
+	if err != nil {
+		return
+	}
+	for s := range m1 {
+		if _, ok := m2[s]; !ok {
+			ahead++
+		}
+	}
+	for s := range m2 {
+		if _, ok := m1[s]; !ok {
+			behind++
+		}
+	}
+	return
+}
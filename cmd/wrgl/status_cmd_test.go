@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package wrgl
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wrgl/wrgl/pkg/objects"
+	"github.com/wrgl/wrgl/pkg/ref"
+)
+
+func assertStatusOutput(t *testing.T, args []string, output string) {
+	t.Helper()
+	cmd := rootCmd()
+	cmd.SetArgs(append([]string{"status"}, args...))
+	buf := bytes.NewBuffer(nil)
+	cmd.SetOut(buf)
+	require.NoError(t, cmd.Execute())
+	assert.Equal(t, output, removeColor(buf.String()))
+}
+
+func TestStatusCmd(t *testing.T) {
+	rd, cleanup := createRepoDir(t)
+	defer cleanup()
+
+	assertStatusOutput(t, nil, "No branch is configured\n")
+
+	_, fp := createCSVFile(t, []string{
+		"a,b,c",
+		"1,q,w",
+		"2,a,s",
+		"3,z,x",
+	})
+	defer os.Remove(fp)
+	commitFile(t, "main", fp, "a", "--set-file", "--set-primary-key")
+	assertStatusOutput(t, nil, fmt.Sprintf("branch main\n  file %s: unchanged\n", fp))
+
+	overrideCSVFile(t, fp, []string{
+		"a,b,c",
+		"1,q,e",
+		"2,a,s",
+		"4,s,d",
+		"5,r,t",
+	})
+	countObjects := func() (n int) {
+		t.Helper()
+		db, err := rd.OpenObjectsStore()
+		require.NoError(t, err)
+		defer db.Close()
+		for _, get := range []func(objects.Store) ([][]byte, error){
+			objects.GetAllBlockKeys, objects.GetAllTableKeys, objects.GetAllCommitKeys,
+		} {
+			keys, err := get(db)
+			require.NoError(t, err)
+			n += len(keys)
+		}
+		return n
+	}
+	n := countObjects()
+	assertStatusOutput(t, nil, fmt.Sprintf("branch main\n  file %s: modified (rows: +2/-1/m1)\n", fp))
+
+	// status doesn't create any ref or object
+	rs := rd.OpenRefStore()
+	_, err := ref.GetHead(rs, "main-tmp")
+	assert.ErrorIs(t, err, ref.ErrKeyNotFound)
+	assert.Equal(t, n, countObjects())
+
+	cmd := rootCmd()
+	cmd.SetArgs([]string{"config", "set", "branch.other.file", "non-existent.csv"})
+	require.NoError(t, cmd.Execute())
+	assertStatusOutput(t, nil, fmt.Sprintf(
+		"branch main\n  file %s: modified (rows: +2/-1/m1)\n\nbranch other\n  file non-existent.csv: missing\n", fp,
+	))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"remote", "add", "origin", "https://my-repo.com"})
+	require.NoError(t, cmd.Execute())
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"config", "set", "branch.main.remote", "origin"})
+	require.NoError(t, cmd.Execute())
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"config", "set", "branch.main.merge", "refs/heads/main"})
+	require.NoError(t, cmd.Execute())
+	assertStatusOutput(t, []string{"main"}, fmt.Sprintf(
+		"branch main\n  file %s: modified (rows: +2/-1/m1)\n  upstream refs/remotes/origin/main: not fetched yet\n", fp,
+	))
+
+	sum1, err := ref.GetHead(rs, "main")
+	require.NoError(t, err)
+	require.NoError(t, ref.SaveRemoteRef(rs, "origin", "main", sum1, "test", "test@domain.com", "fetch", "from origin"))
+	assertStatusOutput(t, []string{"main"}, fmt.Sprintf(
+		"branch main\n  file %s: modified (rows: +2/-1/m1)\n  upstream refs/remotes/origin/main: up to date\n", fp,
+	))
+
+	commitFile(t, "main", "", "")
+	assertStatusOutput(t, []string{"main"}, fmt.Sprintf(
+		"branch main\n  file %s: unchanged\n  upstream refs/remotes/origin/main: ahead 1\n", fp,
+	))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"status", "unknown"})
+	assert.Equal(t, fmt.Errorf("branch %q is not configured", "unknown"), cmd.Execute())
+}
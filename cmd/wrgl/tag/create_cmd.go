@@ -0,0 +1,128 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package tag
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/wrgl/wrgl/cmd/wrgl/utils"
+	"github.com/wrgl/wrgl/pkg/conf"
+	conffs "github.com/wrgl/wrgl/pkg/conf/fs"
+	"github.com/wrgl/wrgl/pkg/objects"
+	"github.com/wrgl/wrgl/pkg/ref"
+)
+
+func createCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "create TAG COMMIT",
+		Short: "Create a new tag",
+		Long:  "Create a new tag that points to COMMIT. If a message is given with --message, an annotated tag is created which also records the tagger and the time of tagging.",
+		Example: utils.CombineExamples([]utils.Example{
+			{
+				Comment: "create a lightweight tag from branch head",
+				Line:    "wrgl tag create v1 main",
+			},
+			{
+				Comment: "create an annotated tag from commit sum",
+				Line:    "wrgl tag create v1 1234567890abcdef1234567890abcdef -m \"first release\"",
+			},
+			{
+				Comment: "move an existing tag to another commit",
+				Line:    "wrgl tag create v1 main^ --force",
+			},
+		}),
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rd := utils.GetRepoDir(cmd)
+			defer rd.Close()
+			db, err := rd.OpenObjectsStore()
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+			rs := rd.OpenRefStore()
+			s := conffs.NewStore(rd.FullPath, conffs.AggregateSource, "")
+			c, err := s.Open()
+			if err != nil {
+				return err
+			}
+			message, err := cmd.Flags().GetString("message")
+			if err != nil {
+				return err
+			}
+			force, err := cmd.Flags().GetBool("force")
+			if err != nil {
+				return err
+			}
+			if message != "" {
+				if err := utils.EnsureUserSet(cmd, c); err != nil {
+					return err
+				}
+			}
+			return createTag(cmd, c.User, db, rs, args[0], args[1], message, force)
+		},
+	}
+	cmd.Flags().StringP("message", "m", "", "create an annotated tag with the given message")
+	cmd.Flags().BoolP("force", "f", false, "replace the tag if it already exists")
+	return cmd
+}
+
+func validateNewTag(rs ref.Store, name string, force bool) error {
+	if !ref.HeadPattern.MatchString(name) {
+		return fmt.Errorf(`tag name "%s" is invalid`, name)
+	}
+	if _, err := ref.GetTag(rs, name); err == nil {
+		if !force {
+			return fmt.Errorf(`tag "%s" already exist`, name)
+		}
+		return ref.DeleteTag(rs, name)
+	}
+	return nil
+}
+
+func createTag(cmd *cobra.Command, u *conf.User, db objects.Store, rs ref.Store, name, commitStr, message string, force bool) error {
+	_, hash, commit, err := ref.InterpretCommitName(db, rs, commitStr, false)
+	if err != nil {
+		return err
+	}
+	if commit == nil {
+		return fmt.Errorf(`commit "%s" not found`, commitStr)
+	}
+	if err = validateNewTag(rs, name, force); err != nil {
+		return err
+	}
+	if message != "" {
+		err = saveAnnotatedTag(db, rs, name, &objects.Tag{
+			Target:      hash,
+			TaggerName:  u.Name,
+			TaggerEmail: u.Email,
+			Time:        time.Now(),
+			Message:     message,
+		})
+	} else {
+		err = ref.SaveTag(rs, name, hash)
+	}
+	if err != nil {
+		return err
+	}
+	cmd.Printf("created tag %s (%s)\n", name, hex.EncodeToString(hash))
+	return nil
+}
+
+func saveAnnotatedTag(db objects.Store, rs ref.Store, name string, tag *objects.Tag) error {
+	buf := bytes.NewBuffer(nil)
+	if _, err := tag.WriteTo(buf); err != nil {
+		return err
+	}
+	sum, err := objects.SaveTag(db, buf.Bytes())
+	if err != nil {
+		return err
+	}
+	tag.Sum = sum
+	return ref.SaveAnnotatedTag(rs, name, sum, tag)
+}
@@ -0,0 +1,42 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package tag
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/wrgl/wrgl/cmd/wrgl/utils"
+	"github.com/wrgl/wrgl/pkg/ref"
+)
+
+func deleteCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "delete TAG...",
+		Short: "Delete one or more tags",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rd := utils.GetRepoDir(cmd)
+			defer rd.Close()
+			rs := rd.OpenRefStore()
+			return deleteTags(cmd, rs, args)
+		},
+	}
+	return cmd
+}
+
+func deleteTags(cmd *cobra.Command, rs ref.Store, names []string) error {
+	for _, name := range names {
+		if _, err := ref.GetTag(rs, name); err != nil {
+			return fmt.Errorf(`tag %q does not exist`, name)
+		}
+	}
+	for _, name := range names {
+		if err := ref.DeleteTag(rs, name); err != nil {
+			return err
+		}
+		cmd.Println("deleted tag", name)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package tag
+
+import (
+	"fmt"
+
+	"github.com/gobwas/glob"
+	"github.com/spf13/cobra"
+	"github.com/wrgl/wrgl/cmd/wrgl/utils"
+	"github.com/wrgl/wrgl/pkg/ref"
+	"github.com/wrgl/wrgl/pkg/slice"
+)
+
+func listCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list [PATTERN...]",
+		Short: "List tags",
+		Example: utils.CombineExamples([]utils.Example{
+			{
+				Comment: "list all tags",
+				Line:    "wrgl tag list",
+			},
+			{
+				Comment: "list tags that match any of the given glob patterns",
+				Line:    "wrgl tag list \"v1.*\" \"release-*\"",
+			},
+		}),
+		Args: cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rd := utils.GetRepoDir(cmd)
+			defer rd.Close()
+			rs := rd.OpenRefStore()
+			globs := []glob.Glob{}
+			for _, pattern := range args {
+				g, err := glob.Compile(pattern)
+				if err != nil {
+					return err
+				}
+				globs = append(globs, g)
+			}
+			return listTag(cmd, rs, globs)
+		},
+	}
+	return cmd
+}
+
+func listTag(cmd *cobra.Command, rs ref.Store, globs []glob.Glob) error {
+	tagMap, err := ref.ListTags(rs)
+	if err != nil {
+		return err
+	}
+	names := []string{}
+	for name := range tagMap {
+		names = slice.InsertToSortedStringSlice(names, name)
+	}
+	for _, name := range names {
+		if len(globs) > 0 {
+			for _, g := range globs {
+				if g.Match(name) {
+					fmt.Fprintln(cmd.OutOrStdout(), name)
+					break
+				}
+			}
+		} else {
+			fmt.Fprintln(cmd.OutOrStdout(), name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package tag
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func RootCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tag",
+		Short: "Manage tags",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Usage()
+		},
+	}
+	cmd.AddCommand(listCmd())
+	cmd.AddCommand(createCmd())
+	cmd.AddCommand(deleteCmd())
+	cmd.AddCommand(showCmd())
+	return cmd
+}
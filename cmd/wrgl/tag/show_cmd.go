@@ -0,0 +1,65 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package tag
+
+import (
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/wrgl/wrgl/cmd/wrgl/utils"
+	"github.com/wrgl/wrgl/pkg/objects"
+	"github.com/wrgl/wrgl/pkg/ref"
+)
+
+func showCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "show TAG",
+		Short: "Show a tag and the commit it points to",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rd := utils.GetRepoDir(cmd)
+			defer rd.Close()
+			db, err := rd.OpenObjectsStore()
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+			rs := rd.OpenRefStore()
+			return showTag(cmd, db, rs, args[0])
+		},
+	}
+	return cmd
+}
+
+func showTag(cmd *cobra.Command, db objects.Store, rs ref.Store, name string) error {
+	sum, err := ref.GetTag(rs, name)
+	if err != nil {
+		return fmt.Errorf(`tag %q does not exist`, name)
+	}
+	commitSum, tag, err := ref.PeelTag(db, sum)
+	if err != nil {
+		return fmt.Errorf("ref.PeelTag err: %v", err)
+	}
+	com, err := objects.GetCommit(db, commitSum)
+	if err != nil {
+		return fmt.Errorf("objects.GetCommit err: %v", err)
+	}
+	out := cmd.OutOrStdout()
+	zone, offset := time.Now().Zone()
+	loc := time.FixedZone(zone, offset)
+	fmt.Fprintf(out, "tag %s\n", name)
+	if tag != nil {
+		fmt.Fprintf(out, "Tagger: %s <%s>\n", tag.TaggerName, tag.TaggerEmail)
+		fmt.Fprintf(out, "Date: %s\n", tag.Time.In(loc))
+		fmt.Fprintf(out, "\n    %s\n", tag.Message)
+	}
+	fmt.Fprintf(out, "\ncommit %s\n", hex.EncodeToString(commitSum))
+	fmt.Fprintf(out, "table %x\n", com.Table)
+	fmt.Fprintf(out, "Author: %s <%s>\n", com.AuthorName, com.AuthorEmail)
+	fmt.Fprintf(out, "Date: %s\n", com.Time.In(loc))
+	fmt.Fprintf(out, "\n    %s\n", com.Message)
+	return nil
+}
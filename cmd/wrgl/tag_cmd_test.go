@@ -0,0 +1,117 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package wrgl
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wrgl/wrgl/pkg/factory"
+	"github.com/wrgl/wrgl/pkg/objects"
+	"github.com/wrgl/wrgl/pkg/ref"
+)
+
+func TestTagCmd(t *testing.T) {
+	rd, cleanUp := createRepoDir(t)
+	defer cleanUp()
+
+	db, err := rd.OpenObjectsStore()
+	require.NoError(t, err)
+	rs := rd.OpenRefStore()
+	sum1, _ := factory.CommitHead(t, db, rs, "alpha", nil, nil)
+	sum2, _ := factory.CommitHead(t, db, rs, "alpha", nil, nil)
+	require.NoError(t, db.Close())
+
+	cmd := rootCmd()
+	cmd.SetArgs([]string{"tag", "create", "v1", "alpha^"})
+	assertCmdOutput(t, cmd, fmt.Sprintf("created tag v1 (%s)\n", hex.EncodeToString(sum1)))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"tag", "create", "v1", "alpha"})
+	assert.Equal(t, `tag "v1" already exist`, cmd.Execute().Error())
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"tag", "create", "v2", "alpha", "-m", "second release"})
+	assertCmdOutput(t, cmd, fmt.Sprintf("created tag v2 (%s)\n", hex.EncodeToString(sum2)))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"tag", "create", "rc-1", "alpha"})
+	assertCmdOutput(t, cmd, fmt.Sprintf("created tag rc-1 (%s)\n", hex.EncodeToString(sum2)))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"tag", "list"})
+	assertCmdOutput(t, cmd, "rc-1\nv1\nv2\n")
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"tag", "list", "v*"})
+	assertCmdOutput(t, cmd, "v1\nv2\n")
+
+	db, err = rd.OpenObjectsStore()
+	require.NoError(t, err)
+	tagSum, err := ref.GetTag(rs, "v2")
+	require.NoError(t, err)
+	tag, err := objects.GetTag(db, tagSum)
+	require.NoError(t, err)
+	assert.Equal(t, sum2, tag.Target)
+	assert.Equal(t, "John Doe", tag.TaggerName)
+	assert.Equal(t, "john@domain.com", tag.TaggerEmail)
+	assert.Equal(t, "second release", tag.Message)
+	b, err := ref.GetTag(rs, "v1")
+	require.NoError(t, err)
+	assert.Equal(t, sum1, b)
+	assert.False(t, objects.TagExist(db, b))
+	require.NoError(t, db.Close())
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"tag", "show", "v2"})
+	buf := bytes.NewBufferString("")
+	cmd.SetOut(buf)
+	require.NoError(t, cmd.Execute())
+	assert.Contains(t, buf.String(), "tag v2\nTagger: John Doe <john@domain.com>\n")
+	assert.Contains(t, buf.String(), "\n    second release\n")
+	assert.Contains(t, buf.String(), fmt.Sprintf("\ncommit %x\n", sum2))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"tag", "create", "v1", "alpha", "--force"})
+	assertCmdOutput(t, cmd, fmt.Sprintf("created tag v1 (%s)\n", hex.EncodeToString(sum2)))
+	b, err = ref.GetTag(rs, "v1")
+	require.NoError(t, err)
+	assert.Equal(t, sum2, b)
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"tag", "delete", "v3"})
+	assert.Equal(t, `tag "v3" does not exist`, cmd.Execute().Error())
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"tag", "delete", "v1", "rc-1"})
+	assertCmdOutput(t, cmd, "deleted tag v1\ndeleted tag rc-1\n")
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"tag", "list"})
+	assertCmdOutput(t, cmd, "v2\n")
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"tag", "create", "v3", "v2", "-m", "third release"})
+	assertCmdOutput(t, cmd, fmt.Sprintf("created tag v3 (%s)\n", hex.EncodeToString(sum2)))
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"tag", "delete", "v2"})
+	assertCmdOutput(t, cmd, "deleted tag v2\n")
+
+	cmd = rootCmd()
+	cmd.SetArgs([]string{"prune"})
+	require.NoError(t, cmd.Execute())
+	db, err = rd.OpenObjectsStore()
+	require.NoError(t, err)
+	defer db.Close()
+	assert.False(t, objects.TagExist(db, tagSum))
+	b, err = ref.GetTag(rs, "v3")
+	require.NoError(t, err)
+	assert.True(t, objects.TagExist(db, b))
+	assert.True(t, objects.CommitExist(db, sum2))
+}
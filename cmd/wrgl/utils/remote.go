@@ -26,11 +26,6 @@ func MustGetRemote(cmd *cobra.Command, c *conf.Config, name string) *conf.Remote
 
 func AddRemote(cmd *cobra.Command, name string, uri string) error {
 	wrglDir := MustWRGLDir(cmd)
-	s := conffs.NewStore(wrglDir, conffs.LocalSource, "")
-	c, err := s.Open()
-	if err != nil {
-		return err
-	}
 	tags, err := cmd.Flags().GetBool("tags")
 	if err != nil {
 		return err
@@ -43,6 +38,17 @@ func AddRemote(cmd *cobra.Command, name string, uri string) error {
 	if err != nil {
 		return err
 	}
+	return SaveRemote(wrglDir, name, uri, tags, track, mirror)
+}
+
+// SaveRemote adds a remote to the local config of the repository at wrglDir.
+// Mirror can be "fetch", "push" or empty, see "wrgl remote add --help".
+func SaveRemote(wrglDir, name, uri string, tags bool, track []string, mirror string) error {
+	s := conffs.NewStore(wrglDir, conffs.LocalSource, "")
+	c, err := s.Open()
+	if err != nil {
+		return err
+	}
 	if c.Remote == nil {
 		c.Remote = map[string]*conf.Remote{}
 	}
@@ -130,7 +130,10 @@ func (s *ReceivePackSession) negotiate(updates map[string]*payload.Update) (stat
 			if err != nil {
 				return nil, fmt.Errorf("error finding commits to send: %w", err)
 			}
-			s.sender, err = apiutils.NewObjectSender(s.db, commits, s.tablesToSend, s.finder.CommonCommmits(), s.maxPackfileSize)
+			s.sender, err = apiutils.NewObjectSender(
+				s.db, commits, s.tablesToSend, s.finder.CommonCommmits(), s.maxPackfileSize,
+				apiutils.WithSenderTags(s.finder.TagsToSend()),
+			)
 			if err != nil {
 				return nil, fmt.Errorf("error creating object sender: %w", err)
 			}
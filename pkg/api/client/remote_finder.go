@@ -5,6 +5,7 @@ package apiclient
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 
 	"github.com/wrgl/wrgl/pkg/objects"
@@ -23,6 +24,11 @@ func NewRemoteFinder(db objects.Store, rs ref.Store) (*RemoteFinder, error) {
 	travellers := map[string]*ref.Traveller{}
 	for name := range m {
 		travellers[name], err = ref.NewTraveller(db, rs, name)
+		if errors.Is(err, ref.ErrKeyNotFound) {
+			// lightweight tags don't have reflog
+			delete(travellers, name)
+			continue
+		}
 		if err != nil {
 			return nil, fmt.Errorf("ref.NewTraveller err: %v", err)
 		}
@@ -78,7 +78,7 @@ func NewUploadPackSession(db objects.Store, rs ref.Store, c *Client, advertised
 		neg.havesPerRoundTrip = defaultHavesPerRoundTrip
 	}
 	for _, b := range advertised {
-		if !objects.CommitExist(db, b) {
+		if !objects.CommitExist(db, b) && !objects.TagExist(db, b) {
 			neg.wants = append(neg.wants, b)
 		}
 	}
@@ -14,4 +14,5 @@ type GetTableResponse struct {
 	Columns   []string `json:"columns,omitempty"`
 	PK        []uint32 `json:"pk,omitempty"`
 	RowsCount uint32   `json:"rowsCount"`
+	Types     []string `json:"types,omitempty"`
 }
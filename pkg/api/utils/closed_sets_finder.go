@@ -24,6 +24,7 @@ type ClosedSetsFinder struct {
 	Wants         map[string]struct{}
 	commitLists   []*list.List
 	tableSumLists []*list.List
+	tags          []*objects.Tag
 	depth         int
 }
 
@@ -78,6 +79,29 @@ func (f *ClosedSetsFinder) TablesToSend() (tableSums map[string]struct{}, err er
 	return
 }
 
+// TagsToSend returns tag objects that were wanted. Their targets are
+// treated as wants and sent along with other commits.
+func (f *ClosedSetsFinder) TagsToSend() []*objects.Tag {
+	return f.tags
+}
+
+// peelTags replaces sums of tag objects with sums of their target commits.
+// If record is true, peeled tags are remembered so that they can be sent.
+func (f *ClosedSetsFinder) peelTags(sums [][]byte, record bool) ([][]byte, error) {
+	result := make([][]byte, len(sums))
+	for i, sum := range sums {
+		target, tag, err := ref.PeelTag(f.db, sum)
+		if err != nil {
+			return nil, err
+		}
+		if tag != nil && record {
+			f.tags = append(f.tags, tag)
+		}
+		result[i] = target
+	}
+	return result, nil
+}
+
 func (f *ClosedSetsFinder) isFullCommit(com *objects.Commit, sum []byte) (ok bool, err error) {
 	if com == nil {
 		com, err = objects.GetCommit(f.db, sum)
@@ -256,6 +280,12 @@ func (f *ClosedSetsFinder) Process(wants, haves [][]byte, done bool) (acks [][]b
 	if err != nil {
 		return nil, fmt.Errorf("NewCommitsQueue error: %w", err)
 	}
+	if wants, err = f.peelTags(wants, true); err != nil {
+		return nil, fmt.Errorf("peelTags error: %w", err)
+	}
+	if haves, err = f.peelTags(haves, false); err != nil {
+		return nil, fmt.Errorf("peelTags error: %w", err)
+	}
 	if len(wants) > 0 {
 		err = f.ensureWantsAreReachable(queue, wants)
 		if err != nil {
@@ -23,6 +23,7 @@ type ObjectReceiver struct {
 	db              objects.Store
 	expectedCommits map[string]struct{}
 	ReceivedCommits [][]byte
+	ReceivedTags    [][]byte
 	logger          logr.Logger
 	saveObjHook     func(objType int, sum []byte)
 	buf             []byte
@@ -126,6 +127,28 @@ func (r *ObjectReceiver) saveCommit(b []byte) (sum []byte, err error) {
 	return
 }
 
+func (r *ObjectReceiver) saveTag(b []byte) (sum []byte, err error) {
+	f := r.logDuration("save tag")
+	_, tag, err := objects.ReadTagFrom(bytes.NewReader(b))
+	if err != nil {
+		return
+	}
+	if !objects.CommitExist(r.db, tag.Target) {
+		return nil, fmt.Errorf("target commit %x does not exist", tag.Target)
+	}
+	sum, err = objects.SaveTag(r.db, b)
+	if err != nil {
+		return
+	}
+	delete(r.expectedCommits, string(sum))
+	r.ReceivedTags = append(r.ReceivedTags, sum)
+	if r.saveObjHook != nil {
+		r.saveObjHook(packfile.ObjectTag, sum)
+	}
+	f("sum", hex.EncodeToString(sum))
+	return
+}
+
 func (r *ObjectReceiver) Receive(pr *packfile.PackfileReader, bar pbar.Bar) (done bool, err error) {
 	for {
 		ot, b, err := pr.ReadObject()
@@ -149,6 +172,11 @@ func (r *ObjectReceiver) Receive(pr *packfile.PackfileReader, bar pbar.Bar) (don
 			if err != nil {
 				return false, fmt.Errorf("save commit error: %w", err)
 			}
+		case packfile.ObjectTag:
+			sum, err = r.saveTag(b)
+			if err != nil {
+				return false, fmt.Errorf("save tag error: %w", err)
+			}
 		default:
 			if ot != 0 || len(b) != 0 {
 				return false, fmt.Errorf("unrecognized object type %d", ot)
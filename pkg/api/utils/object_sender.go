@@ -26,6 +26,7 @@ type object struct {
 type ObjectSender struct {
 	db              objects.Store
 	commits         *list.List
+	tags            []*objects.Tag
 	tables          map[string]struct{}
 	objs            *list.List
 	commonTables    map[string]struct{}
@@ -63,7 +64,16 @@ func getCommonBlocks(db objects.Store, commonTables map[string]struct{}) (map[st
 	return commonBlocks, nil
 }
 
-func NewObjectSender(db objects.Store, toSend []*objects.Commit, tablesToSend map[string]struct{}, commonCommits [][]byte, maxPackfileSize uint64) (s *ObjectSender, err error) {
+type ObjectSenderOption func(s *ObjectSender)
+
+// WithSenderTags sends tag objects after all commits are sent
+func WithSenderTags(tags []*objects.Tag) ObjectSenderOption {
+	return func(s *ObjectSender) {
+		s.tags = tags
+	}
+}
+
+func NewObjectSender(db objects.Store, toSend []*objects.Commit, tablesToSend map[string]struct{}, commonCommits [][]byte, maxPackfileSize uint64, opts ...ObjectSenderOption) (s *ObjectSender, err error) {
 	if maxPackfileSize == 0 {
 		maxPackfileSize = defaultMaxPackfileSize
 	}
@@ -75,6 +85,9 @@ func NewObjectSender(db objects.Store, toSend []*objects.Commit, tablesToSend ma
 		buf:             bytes.NewBuffer(nil),
 		maxPackfileSize: maxPackfileSize,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
 	s.commonTables, err = getCommonTables(db, commonCommits)
 	if err != nil {
 		return nil, fmt.Errorf("error getting common tables: %w", err)
@@ -94,7 +107,7 @@ func NewObjectSender(db objects.Store, toSend []*objects.Commit, tablesToSend ma
 
 func (s *ObjectSender) enqueueNextCommit() (err error) {
 	if s.commits.Len() == 0 {
-		return
+		return s.enqueueTags()
 	}
 	com := s.commits.Remove(s.commits.Front()).(*objects.Commit)
 	if _, ok := s.tables[string(com.Table)]; ok {
@@ -118,6 +131,22 @@ func (s *ObjectSender) enqueueNextCommit() (err error) {
 	return nil
 }
 
+func (s *ObjectSender) enqueueTags() (err error) {
+	for _, tag := range s.tags {
+		s.buf.Reset()
+		_, err = tag.WriteTo(s.buf)
+		if err != nil {
+			err = fmt.Errorf("error writing tag: %w", err)
+			return
+		}
+		b := make([]byte, s.buf.Len())
+		copy(b, s.buf.Bytes())
+		s.objs.PushBack(object{Type: packfile.ObjectTag, Content: b, Sum: tag.Sum})
+	}
+	s.tags = nil
+	return nil
+}
+
 func (s *ObjectSender) enqueueTable(sum []byte) (err error) {
 	tbl, err := objects.GetTable(s.db, sum)
 	if errors.Is(err, objects.ErrKeyNotFound) {
@@ -183,5 +212,5 @@ func (s *ObjectSender) WriteObjects(w io.Writer, pb pbar.Bar) (done bool, info *
 			break
 		}
 	}
-	return s.objs.Len() == 0 && s.commits.Len() == 0, pw.Info, nil
+	return s.objs.Len() == 0 && s.commits.Len() == 0 && len(s.tags) == 0, pw.Info, nil
 }
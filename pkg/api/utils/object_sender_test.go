@@ -15,7 +15,9 @@ import (
 	"github.com/wrgl/wrgl/pkg/encoding/packfile"
 	"github.com/wrgl/wrgl/pkg/factory"
 	"github.com/wrgl/wrgl/pkg/objects"
+	objhelpers "github.com/wrgl/wrgl/pkg/objects/helpers"
 	objmock "github.com/wrgl/wrgl/pkg/objects/mock"
+	refmock "github.com/wrgl/wrgl/pkg/ref/mock"
 )
 
 func sendAll(t *testing.T, sender *apiutils.ObjectSender, receiver *apiutils.ObjectReceiver) {
@@ -96,3 +98,34 @@ func TestSendCommitButNotTable(t *testing.T) {
 	sendAll(t, s, r)
 	factory.AssertCommitsPersisted(t, db2, [][]byte{sum1, sum2})
 }
+
+func TestSendTags(t *testing.T) {
+	db1 := objmock.NewStore()
+	db2 := objmock.NewStore()
+	rs, cleanup := refmock.NewStore(t)
+	defer cleanup()
+
+	sum1, c1 := factory.CommitRandomN(t, db1, 5, 5, nil)
+	tagSum, tag := factory.AnnotatedTag(t, db1, rs, "v1", sum1)
+
+	finder := apiutils.NewClosedSetsFinder(db1, rs, 0)
+	_, err := finder.Process([][]byte{tagSum}, nil, true)
+	require.NoError(t, err)
+	commits, err := finder.CommitsToSend()
+	require.NoError(t, err)
+	objhelpers.AssertCommitsEqual(t, []*objects.Commit{c1}, commits, false)
+	require.Len(t, finder.TagsToSend(), 1)
+	objhelpers.AssertTagEqual(t, tag, finder.TagsToSend()[0])
+	tables, err := finder.TablesToSend()
+	require.NoError(t, err)
+
+	s, err := apiutils.NewObjectSender(db1, commits, tables, nil, uint64(10*1024), apiutils.WithSenderTags(finder.TagsToSend()))
+	require.NoError(t, err)
+	r := apiutils.NewObjectReceiver(db2, [][]byte{tagSum}, testr.New(t))
+	sendAll(t, s, r)
+	factory.AssertCommitsPersisted(t, db2, [][]byte{sum1})
+	assert.Equal(t, [][]byte{tagSum}, r.ReceivedTags)
+	tag2, err := objects.GetTag(db2, tagSum)
+	require.NoError(t, err)
+	objhelpers.AssertTagEqual(t, tag, tag2)
+}
@@ -0,0 +1,114 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package bisect
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/wrgl/wrgl/pkg/objects"
+	"github.com/wrgl/wrgl/pkg/ref"
+)
+
+// Candidates returns commits that could have introduced the change, which are
+// bad and its ancestors that are not ancestors of any good commit. Returned
+// commits are keyed by commit sum. An error is returned if a good commit is
+// not an ancestor of bad.
+func Candidates(db objects.Store, bad []byte, goods [][]byte) (map[string]*objects.Commit, error) {
+	for _, good := range goods {
+		base, err := ref.SeekCommonAncestor(db, bad, good)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(base, good) {
+			return nil, fmt.Errorf("good commit %x is not an ancestor of bad commit %x", good, bad)
+		}
+	}
+	goodAncestors := map[string]struct{}{}
+	if len(goods) > 0 {
+		q, err := ref.NewCommitsQueue(db, goods)
+		if err != nil {
+			return nil, err
+		}
+		for {
+			sum, _, err := q.PopInsertParents()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			goodAncestors[string(sum)] = struct{}{}
+		}
+	}
+	candidates := map[string]*objects.Commit{}
+	q, err := ref.NewCommitsQueue(db, [][]byte{bad})
+	if err != nil {
+		return nil, err
+	}
+	for {
+		sum, com, err := q.Pop()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := goodAncestors[string(sum)]; ok {
+			continue
+		}
+		candidates[string(sum)] = com
+		if err = q.InsertParents(com); err != nil {
+			return nil, err
+		}
+	}
+	return candidates, nil
+}
+
+// countAncestors returns the number of candidates that are sum or its
+// ancestors.
+func countAncestors(candidates map[string]*objects.Commit, sum []byte) int {
+	seen := map[string]struct{}{string(sum): {}}
+	stack := [][]byte{sum}
+	for len(stack) > 0 {
+		com := candidates[string(stack[len(stack)-1])]
+		stack = stack[:len(stack)-1]
+		for _, p := range com.Parents {
+			if _, ok := candidates[string(p)]; !ok {
+				continue
+			}
+			if _, ok := seen[string(p)]; !ok {
+				seen[string(p)] = struct{}{}
+				stack = append(stack, p)
+			}
+		}
+	}
+	return len(seen)
+}
+
+// Next returns the candidate to test next, which is the candidate that
+// splits candidates most evenly: whether it turns out good or bad, about half
+// of the candidates are left. If there's only one candidate left, it is the
+// first bad commit and it is returned.
+func Next(candidates map[string]*objects.Commit) []byte {
+	n := len(candidates)
+	var best []byte
+	bestScore := -1
+	for s := range candidates {
+		sum := []byte(s)
+		k := countAncestors(candidates, sum)
+		score := k
+		if n-k < score {
+			score = n - k
+		}
+		// break ties by sum to make the result stable
+		if score > bestScore || (score == bestScore && bytes.Compare(sum, best) < 0) {
+			best = sum
+			bestScore = score
+		}
+	}
+	return best
+}
@@ -0,0 +1,66 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package bisect_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wrgl/wrgl/pkg/bisect"
+	"github.com/wrgl/wrgl/pkg/factory"
+	objmock "github.com/wrgl/wrgl/pkg/objects/mock"
+)
+
+func TestBisect(t *testing.T) {
+	db := objmock.NewStore()
+	sums := make([][]byte, 8)
+	for i := range sums {
+		var parents [][]byte
+		if i > 0 {
+			parents = [][]byte{sums[i-1]}
+		}
+		sums[i], _ = factory.Commit(t, db, []string{
+			"a,b",
+			fmt.Sprintf("1,%d", i),
+		}, []uint32{0}, parents)
+	}
+
+	candidates, err := bisect.Candidates(db, sums[7], [][]byte{sums[0]})
+	require.NoError(t, err)
+	assert.Len(t, candidates, 7)
+	for _, sum := range sums[1:] {
+		assert.Contains(t, candidates, string(sum))
+	}
+	next := bisect.Next(candidates)
+	assert.True(t, bytes.Equal(next, sums[3]) || bytes.Equal(next, sums[4]))
+
+	// sums[5] is the first bad commit
+	bad := sums[7]
+	goods := [][]byte{sums[0]}
+	for i := 0; i < 4; i++ {
+		candidates, err = bisect.Candidates(db, bad, goods)
+		require.NoError(t, err)
+		next = bisect.Next(candidates)
+		if len(candidates) == 1 {
+			break
+		}
+		for j, sum := range sums {
+			if bytes.Equal(sum, next) {
+				if j < 5 {
+					goods = append(goods, sum)
+				} else {
+					bad = sum
+				}
+			}
+		}
+	}
+	assert.Len(t, candidates, 1)
+	assert.Equal(t, sums[5], next)
+
+	_, err = bisect.Candidates(db, sums[2], [][]byte{sums[3]})
+	assert.Equal(t, fmt.Errorf("good commit %x is not an ancestor of bad commit %x", sums[3], sums[2]), err)
+}
@@ -0,0 +1,178 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package blame
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/go-logr/logr"
+	"github.com/wrgl/wrgl/pkg/diff"
+	"github.com/wrgl/wrgl/pkg/objects"
+	"github.com/wrgl/wrgl/pkg/slice"
+)
+
+type pkSet map[string]struct{}
+
+// TablePKSums returns primary key sums of all rows in tbl, in the same order
+// as rows are stored. If tbl has no primary key, row sums are returned instead.
+func TablePKSums(db objects.Store, tbl *objects.Table) ([][]byte, error) {
+	sums := make([][]byte, 0, tbl.RowsCount)
+	var bb []byte
+	var idx *objects.BlockIndex
+	var err error
+	for _, sum := range tbl.BlockIndices {
+		idx, bb, err = objects.GetBlockIndex(db, bb, sum)
+		if err != nil {
+			return nil, fmt.Errorf("objects.GetBlockIndex err: %v", err)
+		}
+		for _, row := range idx.Rows {
+			sums = append(sums, row[:16])
+		}
+	}
+	return sums, nil
+}
+
+// Rows walks history starting from commit headSum to find, for each row of
+// the head table whose primary key sum is in pkSums, the commit that last
+// added or modified that row. Returned commits are keyed by primary key sum.
+// Primary key sums that don't exist in the head table are ignored.
+//
+// A row is followed into a parent commit as long as it stays the same there.
+// A row is attributed to a commit when it differs from all of the commit's
+// parents, when the commit has no parent, or when a parent's table can't be
+// compared row-by-row (primary key changed, or the table is missing in a
+// shallow repository).
+func Rows(db objects.Store, headSum []byte, pkSums [][]byte, logger logr.Logger) (map[string]*objects.Commit, error) {
+	head, err := objects.GetCommit(db, headSum)
+	if err != nil {
+		return nil, fmt.Errorf("objects.GetCommit err: %v", err)
+	}
+	tbl, err := objects.GetTable(db, head.Table)
+	if err != nil {
+		return nil, fmt.Errorf("objects.GetTable err: %v", err)
+	}
+	headPKs, err := TablePKSums(db, tbl)
+	if err != nil {
+		return nil, err
+	}
+	wanted := pkSet{}
+	for _, sum := range pkSums {
+		wanted[string(sum)] = struct{}{}
+	}
+	rows := pkSet{}
+	for _, sum := range headPKs {
+		if _, ok := wanted[string(sum)]; ok {
+			rows[string(sum)] = struct{}{}
+		}
+	}
+
+	result := map[string]*objects.Commit{}
+	if len(rows) == 0 {
+		return result, nil
+	}
+	pending := map[string]pkSet{string(head.Sum): rows}
+	w, err := newWalker(db, head.Sum)
+	if err != nil {
+		return nil, err
+	}
+	for len(pending) > 0 {
+		sum, com, err := w.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		rows, ok := pending[string(sum)]
+		if !ok {
+			continue
+		}
+		delete(pending, string(sum))
+		for _, parentSum := range com.Parents {
+			if len(rows) == 0 {
+				break
+			}
+			unchanged, err := unchangedRows(db, com, parentSum, rows, logger)
+			if err != nil {
+				return nil, err
+			}
+			if len(unchanged) == 0 {
+				continue
+			}
+			prows, ok := pending[string(parentSum)]
+			if !ok {
+				prows = pkSet{}
+				pending[string(parentSum)] = prows
+			}
+			for pk := range unchanged {
+				prows[pk] = struct{}{}
+				delete(rows, pk)
+			}
+			if err = w.push(parentSum); err != nil {
+				return nil, err
+			}
+		}
+		for pk := range rows {
+			result[pk] = com
+		}
+	}
+	return result, nil
+}
+
+// unchangedRows returns rows that stay the same between commit com and the
+// parent with sum parentSum.
+func unchangedRows(db objects.Store, com *objects.Commit, parentSum []byte, rows pkSet, logger logr.Logger) (pkSet, error) {
+	parent, err := objects.GetCommit(db, parentSum)
+	if err != nil {
+		return nil, fmt.Errorf("objects.GetCommit err: %v", err)
+	}
+	if string(parent.Table) == string(com.Table) {
+		return rows, nil
+	}
+	if !objects.TableExist(db, parent.Table) {
+		return nil, nil
+	}
+	tbl1, err := objects.GetTable(db, com.Table)
+	if err != nil {
+		return nil, fmt.Errorf("objects.GetTable err: %v", err)
+	}
+	tbl2, err := objects.GetTable(db, parent.Table)
+	if err != nil {
+		return nil, fmt.Errorf("objects.GetTable err: %v", err)
+	}
+	if !slice.StringSliceEqual(tbl1.PrimaryKey(), tbl2.PrimaryKey()) ||
+		(len(tbl1.PK) == 0 && !slice.StringSliceEqual(tbl1.Columns, tbl2.Columns)) {
+		// rows can't be matched between these tables
+		return nil, nil
+	}
+	tblIdx1, err := objects.GetTableIndex(db, com.Table)
+	if err != nil {
+		return nil, fmt.Errorf("objects.GetTableIndex err: %v", err)
+	}
+	tblIdx2, err := objects.GetTableIndex(db, parent.Table)
+	if err != nil {
+		return nil, fmt.Errorf("objects.GetTableIndex err: %v", err)
+	}
+	errChan := make(chan error, 1)
+	diffChan, _ := diff.DiffTables(db, db, tbl1, tbl2, tblIdx1, tblIdx2, errChan, logger)
+	changed := pkSet{}
+	for d := range diffChan {
+		if d.Sum != nil {
+			changed[string(d.PK)] = struct{}{}
+		}
+	}
+	close(errChan)
+	if err, ok := <-errChan; ok {
+		return nil, err
+	}
+	unchanged := pkSet{}
+	for pk := range rows {
+		if _, ok := changed[pk]; !ok {
+			unchanged[pk] = struct{}{}
+		}
+	}
+	return unchanged, nil
+}
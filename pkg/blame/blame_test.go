@@ -0,0 +1,200 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package blame_test
+
+import (
+	"testing"
+
+	"github.com/go-logr/logr/testr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wrgl/wrgl/pkg/blame"
+	"github.com/wrgl/wrgl/pkg/factory"
+	"github.com/wrgl/wrgl/pkg/objects"
+	objhelpers "github.com/wrgl/wrgl/pkg/objects/helpers"
+	objmock "github.com/wrgl/wrgl/pkg/objects/mock"
+)
+
+func blameRows(t *testing.T, db objects.Store, headSum []byte) map[string][]byte {
+	t.Helper()
+	com, err := objects.GetCommit(db, headSum)
+	require.NoError(t, err)
+	tbl, err := objects.GetTable(db, com.Table)
+	require.NoError(t, err)
+	pkSums, err := blame.TablePKSums(db, tbl)
+	require.NoError(t, err)
+	rows := [][]string{}
+	for _, sum := range tbl.Blocks {
+		blk, _, err := objects.GetBlock(db, nil, sum)
+		require.NoError(t, err)
+		rows = append(rows, blk...)
+	}
+	m, err := blame.Rows(db, headSum, pkSums, testr.New(t))
+	require.NoError(t, err)
+	assert.Len(t, m, len(pkSums))
+	result := map[string][]byte{}
+	for i, sum := range pkSums {
+		result[rows[i][0]] = m[string(sum)].Sum
+	}
+	return result
+}
+
+func TestBlameRows(t *testing.T) {
+	db := objmock.NewStore()
+	sum1, _ := factory.Commit(t, db, []string{
+		"a,b,c",
+		"1,q,w",
+		"2,a,s",
+		"3,z,x",
+	}, []uint32{0}, nil)
+	sum2, _ := factory.Commit(t, db, []string{
+		"a,b,c",
+		"1,q,e",
+		"2,a,s",
+		"3,z,x",
+		"4,s,d",
+	}, []uint32{0}, [][]byte{sum1})
+	sum3, _ := factory.Commit(t, db, []string{
+		"a,b,c",
+		"1,q,e",
+		"3,z,x",
+		"4,s,d",
+	}, []uint32{0}, [][]byte{sum2})
+	assert.Equal(t, map[string][]byte{
+		"1": sum2,
+		"3": sum1,
+		"4": sum2,
+	}, blameRows(t, db, sum3))
+
+	// rows are followed into whichever parent they stay the same in
+	sum4, _ := factory.Commit(t, db, []string{
+		"a,b,c",
+		"1,q,w",
+		"2,a,s",
+		"3,z,v",
+	}, []uint32{0}, [][]byte{sum1})
+	sum5, _ := factory.Commit(t, db, []string{
+		"a,b,c",
+		"1,q,e",
+		"3,z,v",
+		"4,s,d",
+		"5,r,t",
+	}, []uint32{0}, [][]byte{sum3, sum4})
+	assert.Equal(t, map[string][]byte{
+		"1": sum2,
+		"3": sum4,
+		"4": sum2,
+		"5": sum5,
+	}, blameRows(t, db, sum5))
+
+	// rows can't be followed past a primary key change
+	sum6, _ := factory.Commit(t, db, []string{
+		"a,b,c",
+		"1,q,e",
+		"3,z,v",
+		"4,s,d",
+		"5,r,t",
+	}, []uint32{0, 1}, [][]byte{sum5})
+	assert.Equal(t, map[string][]byte{
+		"1": sum6,
+		"3": sum6,
+		"4": sum6,
+		"5": sum6,
+	}, blameRows(t, db, sum6))
+
+	// only requested rows are blamed
+	enc := objects.NewStrListEncoder(true)
+	pk3 := objects.PKSum(enc, []string{"3"})
+	m, err := blame.Rows(db, sum3, [][]byte{pk3, objects.PKSum(enc, []string{"5"})}, testr.New(t))
+	require.NoError(t, err)
+	assert.Len(t, m, 1)
+	assert.Equal(t, sum1, m[string(pk3)].Sum)
+}
+
+func assertCellsEqual(t *testing.T, expected, cells []*blame.CellBlame) {
+	t.Helper()
+	require.Len(t, cells, len(expected))
+	for i, cell := range cells {
+		assert.Equal(t, expected[i].Column, cell.Column)
+		assert.Equal(t, expected[i].Value, cell.Value)
+		objhelpers.AssertCommitEqual(t, expected[i].Commit, cell.Commit)
+		assert.Equal(t, expected[i].Added, cell.Added, "column %q", cell.Column)
+		assert.Equal(t, expected[i].PrevValue, cell.PrevValue, "column %q", cell.Column)
+	}
+}
+
+func TestBlameCells(t *testing.T) {
+	db := objmock.NewStore()
+	sum1, com1 := factory.Commit(t, db, []string{
+		"a,b,c",
+		"1,q,w",
+		"2,a,s",
+	}, []uint32{0}, nil)
+	sum2, com2 := factory.Commit(t, db, []string{
+		"a,c,b",
+		"1,e,q",
+		"2,s,a",
+	}, []uint32{0}, [][]byte{sum1})
+	sum3, com3 := factory.Commit(t, db, []string{
+		"a,c,b,d",
+		"1,e,r,t",
+		"2,s,a,g",
+		"3,z,x,c",
+	}, []uint32{0}, [][]byte{sum2})
+
+	cells, err := blame.Cells(db, sum3, []string{"1"})
+	require.NoError(t, err)
+	assertCellsEqual(t, []*blame.CellBlame{
+		{Column: "a", Value: "1", Commit: com1, Added: true},
+		{Column: "c", Value: "e", Commit: com2, PrevValue: "w"},
+		{Column: "b", Value: "r", Commit: com3, PrevValue: "q"},
+		{Column: "d", Value: "t", Commit: com3, Added: true},
+	}, cells)
+
+	cells, err = blame.Cells(db, sum3, []string{"3"})
+	require.NoError(t, err)
+	assertCellsEqual(t, []*blame.CellBlame{
+		{Column: "a", Value: "3", Commit: com3, Added: true},
+		{Column: "c", Value: "z", Commit: com3, Added: true},
+		{Column: "b", Value: "x", Commit: com3, Added: true},
+		{Column: "d", Value: "c", Commit: com3, Added: true},
+	}, cells)
+
+	// cells are followed into whichever parent they stay the same in
+	sum4, com4 := factory.Commit(t, db, []string{
+		"a,b,c",
+		"1,q,y",
+		"2,a,s",
+	}, []uint32{0}, [][]byte{sum1})
+	sum5, com5 := factory.Commit(t, db, []string{
+		"a,c,b,d",
+		"1,y,r,u",
+	}, []uint32{0}, [][]byte{sum3, sum4})
+	cells, err = blame.Cells(db, sum5, []string{"1"})
+	require.NoError(t, err)
+	assertCellsEqual(t, []*blame.CellBlame{
+		{Column: "a", Value: "1", Commit: com1, Added: true},
+		{Column: "c", Value: "y", Commit: com4, PrevValue: "w"},
+		{Column: "b", Value: "r", Commit: com3, PrevValue: "q"},
+		{Column: "d", Value: "u", Commit: com5, PrevValue: "t"},
+	}, cells)
+
+	_, err = blame.Cells(db, sum5, []string{"2"})
+	assert.Equal(t, blame.ErrRowNotFound, err)
+
+	// renamed columns are followed under their old names, a column that
+	// replaces another with a different value is added
+	sum6, com6 := factory.Commit(t, db, []string{
+		"a,e,b,f",
+		"1,y,r,v",
+	}, []uint32{0}, [][]byte{sum5})
+	cells, err = blame.Cells(db, sum6, []string{"1"})
+	require.NoError(t, err)
+	assertCellsEqual(t, []*blame.CellBlame{
+		{Column: "a", Value: "1", Commit: com1, Added: true},
+		{Column: "e", Value: "y", Commit: com4, PrevValue: "w"},
+		{Column: "b", Value: "r", Commit: com3, PrevValue: "q"},
+		{Column: "f", Value: "v", Commit: com6, Added: true},
+	}, cells)
+}
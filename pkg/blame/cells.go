@@ -0,0 +1,220 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package blame
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/wrgl/wrgl/pkg/diff"
+	"github.com/wrgl/wrgl/pkg/objects"
+	"github.com/wrgl/wrgl/pkg/slice"
+)
+
+var ErrRowNotFound = errors.New("row not found")
+
+// CellBlame records the commit that last changed a cell.
+type CellBlame struct {
+	Column string
+	Value  string
+
+	// Commit is the commit that last changed this cell
+	Commit *objects.Commit
+
+	// Added is true if the cell didn't exist before Commit, either because
+	// the row or the column was added by Commit.
+	Added bool
+
+	// PrevValue is the value of this cell before Commit changed it. It is
+	// empty if Added is true.
+	PrevValue string
+}
+
+type commitRow struct {
+	tbl *objects.Table
+	row []string
+}
+
+type cellsBlamer struct {
+	db       objects.Store
+	pkValues []string
+	rows     map[string]*commitRow
+}
+
+// getRow returns the table and row of commit com, or nil if the table is
+// missing or it doesn't contain the row.
+func (b *cellsBlamer) getRow(com *objects.Commit) (*commitRow, error) {
+	if r, ok := b.rows[string(com.Sum)]; ok {
+		return r, nil
+	}
+	var r *commitRow
+	if objects.TableExist(b.db, com.Table) {
+		tbl, err := objects.GetTable(b.db, com.Table)
+		if err != nil {
+			return nil, fmt.Errorf("objects.GetTable err: %v", err)
+		}
+		if len(tbl.PK) == len(b.pkValues) {
+			tblIdx, err := objects.GetTableIndex(b.db, com.Table)
+			if err != nil {
+				return nil, fmt.Errorf("objects.GetTableIndex err: %v", err)
+			}
+			row, err := objects.LookupRow(b.db, tbl, tblIdx, b.pkValues)
+			if err != nil {
+				return nil, err
+			}
+			if row != nil {
+				r = &commitRow{tbl: tbl, row: row}
+			}
+		}
+	}
+	b.rows[string(com.Sum)] = r
+	return r, nil
+}
+
+// Cells walks history starting from commit headSum to find, for each cell of
+// the row with the given primary key values, the commit that last changed it
+// and its value before that. Cells are returned in the same order as columns
+// of the head table. ErrRowNotFound is returned if the head table doesn't
+// have such a row.
+//
+// Cells are matched between a commit and its parent by column name using
+// ColDiff, so a column that is moved still keeps its history. A column added
+// in the same position as a column removed from the parent is considered a
+// rename, and is followed under its old name, only if its cell has the same
+// value as the removed column's cell. Otherwise the column is considered
+// added. A cell is followed into a parent for as long as its value stays the
+// same there.
+func Cells(db objects.Store, headSum []byte, pkValues []string) ([]*CellBlame, error) {
+	head, err := objects.GetCommit(db, headSum)
+	if err != nil {
+		return nil, fmt.Errorf("objects.GetCommit err: %v", err)
+	}
+	b := &cellsBlamer{
+		db:       db,
+		pkValues: pkValues,
+		rows:     map[string]*commitRow{},
+	}
+	hr, err := b.getRow(head)
+	if err != nil {
+		return nil, err
+	}
+	if hr == nil {
+		return nil, ErrRowNotFound
+	}
+	cells := make([]*CellBlame, len(hr.tbl.Columns))
+	cols := map[string]*CellBlame{}
+	for i, col := range hr.tbl.Columns {
+		cells[i] = &CellBlame{
+			Column: col,
+			Value:  hr.row[i],
+		}
+		cols[col] = cells[i]
+	}
+	pending := map[string]map[string]*CellBlame{string(head.Sum): cols}
+	w, err := newWalker(db, head.Sum)
+	if err != nil {
+		return nil, err
+	}
+	for len(pending) > 0 {
+		sum, com, err := w.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		cols, ok := pending[string(sum)]
+		if !ok {
+			continue
+		}
+		delete(pending, string(sum))
+		cr, err := b.getRow(com)
+		if err != nil {
+			return nil, err
+		}
+		// prevValues holds the first value that differs in a parent
+		prevValues := map[string]string{}
+		for _, parentSum := range com.Parents {
+			if len(cols) == 0 {
+				break
+			}
+			parent, err := objects.GetCommit(db, parentSum)
+			if err != nil {
+				return nil, fmt.Errorf("objects.GetCommit err: %v", err)
+			}
+			pr, err := b.getRow(parent)
+			if err != nil {
+				return nil, err
+			}
+			if pr == nil || !slice.StringSliceEqual(pr.tbl.PrimaryKey(), cr.tbl.PrimaryKey()) {
+				continue
+			}
+			cd := diff.CompareColumns(
+				[2][]string{pr.tbl.Columns, pr.tbl.PrimaryKey()},
+				[2][]string{cr.tbl.Columns, cr.tbl.PrimaryKey()},
+			)
+			renamed := renamedColumns(cd)
+			var followed bool
+			for i, name := range cd.Names {
+				cell, ok := cols[name]
+				if !ok {
+					continue
+				}
+				j := uint32(i)
+				_, added := cd.Added[0][j]
+				if added {
+					if j, ok = renamed[uint32(i)]; !ok {
+						continue
+					}
+				}
+				v := pr.row[cd.BaseIdx[j]]
+				if v != cr.row[cd.OtherIdx[0][uint32(i)]] {
+					// a differing value means the column wasn't renamed
+					if _, ok := prevValues[name]; !ok && !added {
+						prevValues[name] = v
+					}
+					continue
+				}
+				if _, ok := pending[string(parentSum)]; !ok {
+					pending[string(parentSum)] = map[string]*CellBlame{}
+				}
+				pending[string(parentSum)][cd.Names[j]] = cell
+				delete(cols, name)
+				followed = true
+			}
+			if followed {
+				if err = w.push(parentSum); err != nil {
+					return nil, err
+				}
+			}
+		}
+		for name, cell := range cols {
+			cell.Commit = com
+			if v, ok := prevValues[name]; ok {
+				cell.PrevValue = v
+			} else {
+				cell.Added = true
+			}
+		}
+	}
+	return cells, nil
+}
+
+// renamedColumns returns, for each column added by cd, the removed column
+// that occupied the same position in the base table, which it may have been
+// renamed from. Indices are those of cd.Names.
+func renamedColumns(cd *diff.ColDiff) map[uint32]uint32 {
+	removed := map[uint32]uint32{}
+	for j := range cd.Removed[0] {
+		removed[cd.BaseIdx[j]] = j
+	}
+	m := map[uint32]uint32{}
+	for i := range cd.Added[0] {
+		if j, ok := removed[cd.OtherIdx[0][i]]; ok {
+			m[i] = j
+		}
+	}
+	return m
+}
@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package blame
+
+import (
+	"fmt"
+
+	"github.com/wrgl/wrgl/pkg/objects"
+	"github.com/wrgl/wrgl/pkg/ref"
+)
+
+// walker visits commits from newest to oldest, starting from a head commit.
+// Unlike CommitsQueue alone, a commit that has already been visited is
+// visited again if it's pushed afterward, which happens when commit times
+// are out of order.
+type walker struct {
+	db     objects.Store
+	q      *ref.CommitsQueue
+	popped map[string]struct{}
+	again  [][]byte
+}
+
+func newWalker(db objects.Store, headSum []byte) (*walker, error) {
+	q, err := ref.NewCommitsQueue(db, [][]byte{headSum})
+	if err != nil {
+		return nil, err
+	}
+	return &walker{
+		db:     db,
+		q:      q,
+		popped: map[string]struct{}{},
+	}, nil
+}
+
+// next returns the next commit to visit, or io.EOF if there is none left
+func (w *walker) next() (sum []byte, com *objects.Commit, err error) {
+	if n := len(w.again); n > 0 {
+		sum = w.again[n-1]
+		w.again = w.again[:n-1]
+		com, err = objects.GetCommit(w.db, sum)
+		if err != nil {
+			return nil, nil, fmt.Errorf("objects.GetCommit err: %v", err)
+		}
+	} else {
+		sum, com, err = w.q.Pop()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	w.popped[string(sum)] = struct{}{}
+	return sum, com, nil
+}
+
+// push schedules commit sum to be visited
+func (w *walker) push(sum []byte) error {
+	if _, ok := w.popped[string(sum)]; ok {
+		w.again = append(w.again, sum)
+		return nil
+	}
+	return w.q.Insert(sum)
+}
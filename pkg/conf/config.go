@@ -48,6 +48,47 @@ type Branch struct {
 
 	// Delimiter is the CSV delimiter of File. Defaults to comma.
 	Delimiter rune `yaml:"delimiter,omitempty" json:"delimiter,omitempty"`
+
+	// Format is the format of File, one of csv, parquet, jsonl or xlsx. Detected from the
+	// file extension if not set.
+	Format string `yaml:"format,omitempty" json:"format,omitempty"`
+
+	// Types are the column types used during commit if no types are specified, each written
+	// as COLUMN:TYPE.
+	Types []string `yaml:"types,omitempty" json:"types,omitempty"`
+
+	// Rules are validation rules that rows must satisfy to be committed to this branch.
+	Rules *Rules `yaml:"rules,omitempty" json:"rules,omitempty"`
+}
+
+// Rules are validation rules checked against every row during commit. Except for NotNull,
+// rules only apply to non-empty values.
+type Rules struct {
+	// NotNull lists columns that must not have empty values.
+	NotNull []string `yaml:"notNull,omitempty" json:"notNull,omitempty"`
+
+	// Unique lists columns whose values must be unique. Each column is checked on its own.
+	Unique []string `yaml:"unique,omitempty" json:"unique,omitempty"`
+
+	// Patterns maps column names to regular expressions that values must match.
+	Patterns map[string]string `yaml:"patterns,omitempty" json:"patterns,omitempty"`
+
+	// Ranges maps column names to the range of numbers that values must fall in.
+	Ranges map[string]*Range `yaml:"ranges,omitempty" json:"ranges,omitempty"`
+
+	// Allowed maps column names to the only values allowed in those columns.
+	Allowed map[string][]string `yaml:"allowed,omitempty" json:"allowed,omitempty"`
+
+	// References maps column names to branch names. Values must be found in the primary key
+	// of the head commit of the referenced branch, which must have a single column primary
+	// key.
+	References map[string]string `yaml:"references,omitempty" json:"references,omitempty"`
+}
+
+// Range is an inclusive range of numbers. Either end can be left unset.
+type Range struct {
+	Min *float64 `yaml:"min,omitempty" json:"min,omitempty"`
+	Max *float64 `yaml:"max,omitempty" json:"max,omitempty"`
 }
 
 type AuthKeycloak struct {
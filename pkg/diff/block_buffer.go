@@ -36,6 +36,7 @@ type blockEl struct {
 type BlockBuffer struct {
 	db            []objects.Store
 	tbl           []*objects.Table
+	columns       [][]uint32
 	buf           *list.List
 	blkBuf        []byte
 	maxSize, size uint64
@@ -49,6 +50,7 @@ func NewBlockBuffer(db []objects.Store, tbl []*objects.Table) (*BlockBuffer, err
 	return &BlockBuffer{
 		db:      db,
 		tbl:     tbl,
+		columns: make([][]uint32, len(tbl)),
 		buf:     list.New(),
 		maxSize: maxSize,
 	}, nil
@@ -62,11 +64,29 @@ func BlockBufferWithSingleStore(db objects.Store, tbl []*objects.Table) (*BlockB
 	return NewBlockBuffer(sl, tbl)
 }
 
+// SelectColumns makes rows of the given table only contain the given columns,
+// in the given order. Unselected columns are not decoded at all.
+func (buf *BlockBuffer) SelectColumns(table byte, columns []uint32) {
+	buf.columns[table] = columns
+	// drop blocks decoded with the previous selection
+	for el := buf.buf.Front(); el != nil; {
+		next := el.Next()
+		if be := el.Value.(*blockEl); be.Table == table {
+			buf.size -= buf.buf.Remove(el).(*blockEl).Size
+		}
+		el = next
+	}
+}
+
 func (buf *BlockBuffer) addBlock(table byte, offset uint32) (blk [][]string, err error) {
 	if buf.size >= buf.maxSize {
 		buf.size -= buf.buf.Remove(buf.buf.Back()).(*blockEl).Size
 	}
-	blk, buf.blkBuf, err = objects.GetBlock(buf.db[table], buf.blkBuf, buf.tbl[table].Blocks[offset])
+	if cols := buf.columns[table]; cols != nil {
+		blk, buf.blkBuf, err = objects.GetBlockColumns(buf.db[table], buf.blkBuf, buf.tbl[table].Blocks[offset], cols)
+	} else {
+		blk, buf.blkBuf, err = objects.GetBlock(buf.db[table], buf.blkBuf, buf.tbl[table].Blocks[offset])
+	}
 	if err != nil {
 		return nil, err
 	}
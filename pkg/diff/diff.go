@@ -10,6 +10,7 @@ import (
 	"github.com/go-logr/logr"
 	"github.com/wrgl/wrgl/pkg/objects"
 	"github.com/wrgl/wrgl/pkg/progress"
+	"github.com/wrgl/wrgl/pkg/schema"
 )
 
 func strSliceEqual(s1, s2 []string) bool {
@@ -32,6 +33,8 @@ type Differ struct {
 	tbl1, tbl2       *objects.Table
 	tblIdx1, tblIdx2 [][]string
 	errChan          chan<- error
+	typedComparison  bool
+	tolerance        float64
 }
 
 type DiffOption func(*Differ)
@@ -51,6 +54,53 @@ func WithEmitUnchangedRow() DiffOption {
 	}
 }
 
+// WithTypedComparison tells DiffTables to compare values of modified rows
+// using the table schema, so that values written differently such as "1.0"
+// and "1", and numbers that differ by no more than tolerance, are not
+// reported as changes. It only takes effect when both tables have the same
+// columns and at most one schema between them.
+func WithTypedComparison(tolerance float64) DiffOption {
+	return func(d *Differ) {
+		d.typedComparison = true
+		d.tolerance = tolerance
+	}
+}
+
+// comparisonTypes returns the column types used to compare rows, or nil if
+// rows should be compared by their sums only
+func (d *Differ) comparisonTypes(colsEqual bool) []string {
+	if !d.typedComparison || !colsEqual {
+		return nil
+	}
+	if d.tbl1.Types == nil {
+		return d.tbl2.Types
+	}
+	if d.tbl2.Types != nil && !strSliceEqual(d.tbl1.Types, d.tbl2.Types) {
+		return nil
+	}
+	return d.tbl1.Types
+}
+
+// rowChanged compares rows at off1 in tbl1 and off2 in tbl2 value by value
+func (d *Differ) rowChanged(buf *BlockBuffer, types []string, off1, off2 uint32) (bool, error) {
+	blk, off := RowToBlockAndOffset(off1)
+	row1, err := buf.GetRow(0, blk, off)
+	if err != nil {
+		return true, err
+	}
+	blk, off = RowToBlockAndOffset(off2)
+	row2, err := buf.GetRow(1, blk, off)
+	if err != nil {
+		return true, err
+	}
+	for i, typ := range types {
+		if !schema.Equal(typ, row1[i], row2[i], d.tolerance) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func (d *Differ) diffTables() (<-chan *objects.Diff, progress.Tracker) {
 	diffChan := make(chan *objects.Diff)
 	pt := progress.NewSingleTracker(d.progressInterval, 0)
@@ -90,13 +140,29 @@ func DiffTables(db1, db2 objects.Store, tbl1, tbl2 *objects.Table, tblIdx1, tblI
 
 func (d *Differ) diffRows(diffChan chan<- *objects.Diff, pt *progress.SingleTracker, colsEqual bool) error {
 	pt.SetTotal(int64(d.tbl1.RowsCount + d.tbl2.RowsCount))
-	var current int64
-	err := iterateAndMatch(d.db1, d.db2, d.tbl1, d.tbl2, d.tblIdx1, d.tblIdx2, d.logger, func(pk, row1, row2 []byte, off1, off2 uint32) {
+	var (
+		current int64
+		buf     *BlockBuffer
+		cmpErr  error
+		err     error
+	)
+	types := d.comparisonTypes(colsEqual)
+	if types != nil {
+		buf, err = NewBlockBuffer([]objects.Store{d.db1, d.db2}, []*objects.Table{d.tbl1, d.tbl2})
+		if err != nil {
+			return err
+		}
+	}
+	err = iterateAndMatch(d.db1, d.db2, d.tbl1, d.tbl2, d.tblIdx1, d.tblIdx2, d.logger, func(pk, row1, row2 []byte, off1, off2 uint32) {
 		current++
 		pt.SetCurrent(current)
 		if row2 != nil {
+			changed := !colsEqual || !bytes.Equal(row1, row2)
+			if changed && types != nil && cmpErr == nil {
+				changed, cmpErr = d.rowChanged(buf, types, off1, off2)
+			}
 			// TODO: build a way to debug diff process
-			if d.emitUnchangedRow || !colsEqual || !bytes.Equal(row1, row2) {
+			if d.emitUnchangedRow || changed {
 				diffChan <- &objects.Diff{
 					PK:        pk,
 					Sum:       row1,
@@ -116,6 +182,9 @@ func (d *Differ) diffRows(diffChan chan<- *objects.Diff, pt *progress.SingleTrac
 	if err != nil {
 		return err
 	}
+	if cmpErr != nil {
+		return cmpErr
+	}
 	return iterateAndMatch(d.db2, d.db1, d.tbl2, d.tbl1, d.tblIdx2, d.tblIdx1, d.logger, func(pk, row1, row2 []byte, off1, off2 uint32) {
 		current++
 		pt.SetCurrent(current)
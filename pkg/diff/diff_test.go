@@ -321,6 +321,60 @@ func TestDiffSameBlockDifferentPK(t *testing.T) {
 	assert.Len(t, events, 3)
 }
 
+func ingestTypedTable(t *testing.T, db objects.Store, rows [][]string, types map[string]string) (*objects.Table, [][]string) {
+	t.Helper()
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, csv.NewWriter(buf).WriteAll(rows))
+	s, err := sorter.NewSorter()
+	require.NoError(t, err)
+	sum, err := ingest.IngestTable(db, s, io.NopCloser(bytes.NewReader(buf.Bytes())), rows[0][:1], testr.New(t), ingest.WithColumnTypes(types))
+	require.NoError(t, err)
+	return getTable(t, db, sum)
+}
+
+func TestDiffTypedComparison(t *testing.T) {
+	db := objmock.NewStore()
+	logger := testr.New(t)
+	types := map[string]string{"a": "integer", "b": "decimal", "c": "date"}
+	tbl1, tblIdx1 := ingestTypedTable(t, db, [][]string{
+		{"a", "b", "c"},
+		{"1", "1.0", "2022-01-01"},
+		{"2", "2.001", "2022-01-02"},
+		{"3", "3.1", "2022-01-03"},
+	}, types)
+	tbl2, tblIdx2 := ingestTypedTable(t, db, [][]string{
+		{"a", "b", "c"},
+		{"1", "1", "2022-01-01"},
+		{"2", "2", "2022-01-02"},
+		{"3", "3", "2022-01-03"},
+	}, types)
+
+	for _, c := range []struct {
+		opts []DiffOption
+		pks  []string
+	}{
+		{nil, []string{"1", "2", "3"}},
+		{[]DiffOption{WithTypedComparison(0)}, []string{"2", "3"}},
+		{[]DiffOption{WithTypedComparison(0.01)}, []string{"3"}},
+	} {
+		errChan := make(chan error, 10)
+		diffChan, _ := DiffTables(db, db, tbl1, tbl2, tblIdx1, tblIdx2, errChan, logger, c.opts...)
+		pks := []string{}
+		for e := range diffChan {
+			blk, off := RowToBlockAndOffset(e.Offset)
+			buf, err := BlockBufferWithSingleStore(db, []*objects.Table{tbl1})
+			require.NoError(t, err)
+			row, err := buf.GetRow(0, blk, off)
+			require.NoError(t, err)
+			pks = append(pks, row[0])
+		}
+		close(errChan)
+		err, ok := <-errChan
+		assert.False(t, ok, "error was %v", err)
+		assert.Equal(t, c.pks, pks)
+	}
+}
+
 func ingestRawCSV(b *testing.B, db objects.Store, rows [][]string) (*objects.Table, [][]string) {
 	b.Helper()
 	buf := bytes.NewBuffer(nil)
@@ -26,6 +26,11 @@ func NewRowListReader(db objects.Store, tbl *objects.Table) (*RowListReader, err
 	}, nil
 }
 
+// SelectColumns makes Read only return the given columns
+func (r *RowListReader) SelectColumns(columns []uint32) {
+	r.buf.SelectColumns(0, columns)
+}
+
 func (r *RowListReader) Add(row uint32) {
 	r.rows = append(r.rows, row)
 }
@@ -23,10 +23,17 @@ type tableReader struct {
 }
 
 func NewTableReader(db objects.Store, tbl *objects.Table) (RowReader, error) {
+	return NewTableColumnsReader(db, tbl, nil)
+}
+
+// NewTableColumnsReader returns a reader that only reads the given columns of
+// tbl. All columns are read if columns is nil.
+func NewTableColumnsReader(db objects.Store, tbl *objects.Table, columns []uint32) (RowReader, error) {
 	buf, err := BlockBufferWithSingleStore(db, []*objects.Table{tbl})
 	if err != nil {
 		return nil, err
 	}
+	buf.SelectColumns(0, columns)
 	return &tableReader{
 		buf: buf,
 		tbl: tbl,
@@ -76,3 +76,22 @@ func TestRowReader(t *testing.T) {
 		assert.Equal(t, rows[i+681], row)
 	}
 }
+
+func TestTableColumnsReader(t *testing.T) {
+	db := objmock.NewStore()
+	rows := testutils.BuildRawCSV(4, 700)
+	sorter.SortRows(rows, []uint32{0})
+	tbl := ingestRows(t, db, rows)
+
+	r, err := NewTableColumnsReader(db, tbl, []uint32{3, 0})
+	require.NoError(t, err)
+	assert.Equal(t, 700, r.Len())
+
+	_, err = r.Seek(250, io.SeekStart)
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		row, err := r.Read()
+		require.NoError(t, err)
+		assert.Equal(t, []string{rows[i+251][3], rows[i+251][0]}, row)
+	}
+}
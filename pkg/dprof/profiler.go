@@ -9,6 +9,7 @@ import (
 	"strconv"
 
 	"github.com/wrgl/wrgl/pkg/objects"
+	"github.com/wrgl/wrgl/pkg/schema"
 )
 
 const (
@@ -65,8 +66,8 @@ func (m *Profiler) Process(row []string) {
 			col.MinStrLen = uint16(n)
 		}
 		if m.isNumber[i] {
-			n, err := strconv.ParseFloat(v, 64)
-			if err != nil {
+			n, ok := ParseNumber(v)
+			if !ok {
 				m.isNumber[i] = false
 				col.Min = nil
 				col.Max = nil
@@ -92,6 +93,17 @@ func (m *Profiler) Process(row []string) {
 	}
 }
 
+// ParseNumber returns the numeric value of v and true if v is a number, the
+// same way the profiler decides whether a column is numeric. "NaN" and
+// infinities are not numbers since they can't be compared like numbers.
+func ParseNumber(v string) (float64, bool) {
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+		return 0, false
+	}
+	return n, true
+}
+
 func floatPtr(f float64) *float64 {
 	return &f
 }
@@ -145,3 +157,21 @@ func (m *Profiler) Summarize() *objects.TableProfile {
 		Columns:   m.columns,
 	}
 }
+
+// ApplyTypes removes numeric statistics from columns whose type in the table
+// schema isn't numeric, e.g. a boolean column of 0s and 1s. It does nothing
+// if types is nil.
+func ApplyTypes(tblProf *objects.TableProfile, types []string) {
+	for i, typ := range types {
+		if i >= len(tblProf.Columns) || typ == schema.TypeString || schema.IsNumeric(typ) {
+			continue
+		}
+		col := tblProf.Columns[i]
+		col.Min = nil
+		col.Max = nil
+		col.Mean = nil
+		col.Median = nil
+		col.StdDeviation = nil
+		col.Percentiles = nil
+	}
+}
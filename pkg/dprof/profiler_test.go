@@ -166,3 +166,20 @@ func TestPercentiles(t *testing.T) {
 		3, 7, 10, 14.69, 17, 21.69, 24, 28.69, 31, 34, 38, 41, 45, 48, 52.69, 55, 59.69, 62, 66.69,
 	}, sum.Columns[0].Percentiles)
 }
+
+func TestApplyTypes(t *testing.T) {
+	p := NewProfiler([]string{"a", "b"})
+	for _, row := range [][]string{{"1", "0"}, {"2", "1"}, {"3", "1"}} {
+		p.Process(row)
+	}
+	tblProf := p.Summarize()
+	ApplyTypes(tblProf, []string{"integer", "boolean"})
+	assert.Equal(t, floatPtr(2), tblProf.Columns[0].Mean)
+	assert.Nil(t, tblProf.Columns[1].Min)
+	assert.Nil(t, tblProf.Columns[1].Max)
+	assert.Nil(t, tblProf.Columns[1].Mean)
+	assert.Nil(t, tblProf.Columns[1].Median)
+	assert.Nil(t, tblProf.Columns[1].StdDeviation)
+	assert.Nil(t, tblProf.Columns[1].Percentiles)
+	assert.NotEmpty(t, tblProf.Columns[1].TopValues)
+}
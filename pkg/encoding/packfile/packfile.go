@@ -21,12 +21,14 @@ const (
 	ObjectCommit int = iota + 1
 	ObjectTable
 	ObjectBlock
+	ObjectTag
 )
 
 var typeStrs = map[int]string{
 	ObjectCommit: "commit",
 	ObjectTable:  "table",
 	ObjectBlock:  "block",
+	ObjectTag:    "tag",
 }
 
 func encodeObjTypeAndLen(buf encoding.Bufferer, objType int, u uint64) []byte {
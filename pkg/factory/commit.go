@@ -73,6 +73,25 @@ func CommitTag(t *testing.T, db objects.Store, rs ref.Store, tag string, rows []
 	return sum, c
 }
 
+func AnnotatedTag(t *testing.T, db objects.Store, rs ref.Store, name string, target []byte) ([]byte, *objects.Tag) {
+	t.Helper()
+	tag := &objects.Tag{
+		Target:      target,
+		Time:        time.Now(),
+		TaggerName:  testutils.BrokenRandomLowerAlphaString(10),
+		TaggerEmail: testutils.BrokenRandomLowerAlphaString(6) + "@domain.com",
+		Message:     testutils.BrokenRandomAlphaNumericString(10),
+	}
+	buf := bytes.NewBuffer(nil)
+	_, err := tag.WriteTo(buf)
+	require.NoError(t, err)
+	sum, err := objects.SaveTag(db, buf.Bytes())
+	require.NoError(t, err)
+	tag.Sum = sum
+	require.NoError(t, ref.SaveAnnotatedTag(rs, name, sum, tag))
+	return sum, tag
+}
+
 func SdumpCommit(t *testing.T, db objects.Store, sum []byte) string {
 	t.Helper()
 	lines := []string{
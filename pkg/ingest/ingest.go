@@ -8,6 +8,7 @@ import (
 
 	"github.com/go-logr/logr"
 	"github.com/wrgl/wrgl/pkg/objects"
+	"github.com/wrgl/wrgl/pkg/rowsource"
 	"github.com/wrgl/wrgl/pkg/sorter"
 )
 
@@ -24,5 +25,16 @@ func IngestTableFromBlocks(db objects.Store, sorter *sorter.Sorter, columns []st
 
 func IngestTable(db objects.Store, sorter *sorter.Sorter, f io.ReadCloser, pk []string, logger logr.Logger, opts ...InserterOption) ([]byte, error) {
 	i := NewInserter(db, sorter, logger, opts...)
-	return i.ingestTable(f, pk)
+	return i.ingestTable(func() error {
+		return sorter.SortFile(f, pk)
+	})
+}
+
+// IngestTableFromReader ingests rows read from r. The first row read holds
+// column names.
+func IngestTableFromReader(db objects.Store, sorter *sorter.Sorter, r rowsource.Reader, pk []string, logger logr.Logger, opts ...InserterOption) ([]byte, error) {
+	i := NewInserter(db, sorter, logger, opts...)
+	return i.ingestTable(func() error {
+		return sorter.SortReader(r, pk)
+	})
 }
@@ -5,6 +5,7 @@ package ingest
 
 import (
 	"encoding/csv"
+	"fmt"
 	"io"
 	"os"
 	"testing"
@@ -99,6 +100,46 @@ func TestFillEmptyColumnName(t *testing.T) {
 	assert.Equal(t, []string{"unnamed__1", "a", "unnamed__2", "b"}, tbl.Columns)
 }
 
+func TestIngestTableWithTypes(t *testing.T) {
+	rows := [][]string{
+		{"id", "price", "active", "date"},
+		{"1", "1.5", "1", "2022-01-01"},
+		{"2", "", "0", "2022-01-02"},
+		{"3", "2", "1", ""},
+	}
+	f := writeCSV(t, rows)
+	defer os.Remove(f.Name())
+	db := objmock.NewStore()
+	s, err := sorter.NewSorter()
+	require.NoError(t, err)
+	types := map[string]string{"price": "decimal", "active": "boolean", "date": "date"}
+
+	sum, err := IngestTable(db, s, f, rows[0][:1], testr.New(t), WithColumnTypes(types))
+	require.NoError(t, err)
+	tbl, err := objects.GetTable(db, sum)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"string", "decimal", "boolean", "date"}, tbl.Types)
+	tblProf, err := objects.GetTableProfile(db, sum)
+	require.NoError(t, err)
+	assert.NotNil(t, tblProf.Columns[1].Mean)
+	assert.Nil(t, tblProf.Columns[2].Mean)
+
+	rows = append(rows, []string{"4", "2", "1", "2022-02-30"})
+	f = writeCSV(t, rows)
+	defer os.Remove(f.Name())
+	s, err = sorter.NewSorter()
+	require.NoError(t, err)
+	_, err = IngestTable(db, s, f, rows[0][:1], testr.New(t), WithColumnTypes(types))
+	assert.Equal(t, &Error{Message: `row with primary key [4]: column "date": invalid date "2022-02-30"`}, err)
+
+	f = writeCSV(t, rows)
+	defer os.Remove(f.Name())
+	s, err = sorter.NewSorter()
+	require.NoError(t, err)
+	_, err = IngestTable(db, s, f, rows[0][:1], testr.New(t), WithColumnTypes(map[string]string{"amount": "decimal"}))
+	assert.Equal(t, fmt.Errorf(`typed column "amount" not found in columns`), err)
+}
+
 func BenchmarkIngestTable(b *testing.B) {
 	rows := testutils.BuildRawCSV(100, b.N)
 	f := writeCSV(b, rows)
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"io"
 	"os"
 	"os/signal"
 	"sort"
@@ -12,8 +11,11 @@ import (
 
 	"github.com/go-logr/logr"
 	"github.com/pckhoi/meow"
+	"github.com/wrgl/wrgl/pkg/dprof"
 	"github.com/wrgl/wrgl/pkg/objects"
 	"github.com/wrgl/wrgl/pkg/pbar"
+	"github.com/wrgl/wrgl/pkg/schema"
+	"github.com/wrgl/wrgl/pkg/slice"
 	"github.com/wrgl/wrgl/pkg/sorter"
 )
 
@@ -36,6 +38,7 @@ type Inserter struct {
 	numWorkers  int
 	sorter      *sorter.Sorter
 	logger      logr.Logger
+	columnTypes map[string]string
 }
 
 type InserterOption func(*Inserter)
@@ -52,6 +55,14 @@ func WithNumWorkers(n int) InserterOption {
 	}
 }
 
+// WithColumnTypes sets the type of some columns by name. Types are saved as
+// the table schema and every value is validated against its column type.
+func WithColumnTypes(types map[string]string) InserterOption {
+	return func(i *Inserter) {
+		i.columnTypes = types
+	}
+}
+
 func NewInserter(db objects.Store, sorter *sorter.Sorter, logger logr.Logger, opts ...InserterOption) *Inserter {
 	i := &Inserter{
 		db:         db,
@@ -99,6 +110,12 @@ func (i *Inserter) insertBlock() {
 			i.errChan <- err
 			return
 		}
+		if i.tbl.Types != nil {
+			if err = i.validateBlock(blk.Block); err != nil {
+				i.errChan <- err
+				return
+			}
+		}
 		i.logger.Info("index block", "blockSum", sum, "indexSum", blkIdxSum)
 		i.asyncBlocks = append(i.asyncBlocks, asyncBlock{
 			Offset: blk.Offset,
@@ -112,6 +129,26 @@ func (i *Inserter) insertBlock() {
 	}
 }
 
+// validateBlock returns an error for the first value in block that doesn't
+// match its column type
+func (i *Inserter) validateBlock(block []byte) error {
+	_, rows, err := objects.ReadBlockFrom(bytes.NewReader(block))
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		for j, typ := range i.tbl.Types {
+			if err := schema.Validate(typ, row[j]); err != nil {
+				if len(i.tbl.PK) > 0 {
+					return &Error{Message: fmt.Sprintf("row with primary key %v: column %q: %v", slice.IndicesToValues(row, i.tbl.PK), i.tbl.Columns[j], err)}
+				}
+				return &Error{Message: fmt.Sprintf("row %v: column %q: %v", row, i.tbl.Columns[j], err)}
+			}
+		}
+	}
+	return nil
+}
+
 func (o *Inserter) sortBlocks() (blkPKs [][]string) {
 	n := len(o.asyncBlocks)
 	sort.Slice(o.asyncBlocks, func(i, j int) bool {
@@ -176,6 +213,11 @@ func (i *Inserter) ingestTableFromBlocks(columns []string, pk []uint32) ([]byte,
 	}
 	columns = ensureColumnNamesAreNotEmpty(columns)
 	i.tbl = objects.NewTable(columns, pk)
+	types, err := schema.ColumnTypes(i.columnTypes, columns)
+	if err != nil {
+		return nil, err
+	}
+	i.tbl.Types = types
 	i.errChan = make(chan error, i.numWorkers)
 	for j := 0; j < i.numWorkers; j++ {
 		i.wg.Add(1)
@@ -221,6 +263,7 @@ func (i *Inserter) ingestTableFromBlocks(columns []string, pk []uint32) ([]byte,
 	buf.Reset()
 	ts := i.sorter.TableSummary()
 	if ts != nil {
+		dprof.ApplyTypes(ts, i.tbl.Types)
 		_, err = ts.WriteTo(buf)
 		if err != nil {
 			return nil, err
@@ -234,7 +277,9 @@ func (i *Inserter) ingestTableFromBlocks(columns []string, pk []uint32) ([]byte,
 	return sum, nil
 }
 
-func (i *Inserter) ingestTable(f io.ReadCloser, pk []string) ([]byte, error) {
+// ingestTable calls sortRows to fill the sorter, then saves sorted rows as a
+// new table
+func (i *Inserter) ingestTable(sortRows func() error) ([]byte, error) {
 	defer i.sorter.Close()
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt)
@@ -255,7 +300,7 @@ func (i *Inserter) ingestTable(f io.ReadCloser, pk []string) ([]byte, error) {
 		done <- true
 		close(done)
 	}()
-	if err := i.sorter.SortFile(f, pk); err != nil {
+	if err := sortRows(); err != nil {
 		return nil, err
 	}
 	return i.IngestTableFromSorter(i.sorter.Columns, i.sorter.PK)
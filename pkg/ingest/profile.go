@@ -28,6 +28,7 @@ func ProfileTable(db objects.Store, sum []byte, tbl *objects.Table) error {
 		}
 	}
 	tblProf := profiler.Summarize()
+	dprof.ApplyTypes(tblProf, tbl.Types)
 	buf := bytes.NewBuffer(nil)
 	_, err = tblProf.WriteTo(buf)
 	if err != nil {
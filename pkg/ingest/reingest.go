@@ -11,6 +11,19 @@ import (
 	"github.com/wrgl/wrgl/pkg/sorter"
 )
 
+// TableColumnTypes returns the types of tbl's columns keyed by column name,
+// or nil if tbl has no types
+func TableColumnTypes(tbl *objects.Table) map[string]string {
+	if tbl.Types == nil {
+		return nil
+	}
+	types := make(map[string]string, len(tbl.Types))
+	for i, typ := range tbl.Types {
+		types[tbl.Columns[i]] = typ
+	}
+	return types
+}
+
 func reingestTable(db objects.Store, s *sorter.Sorter, tbl *objects.Table, logger logr.Logger, opts ...InserterOption) (newTableSum []byte, err error) {
 	s.Reset()
 	s.SetColumns(tbl.Columns)
@@ -30,6 +43,10 @@ func reingestTable(db objects.Store, s *sorter.Sorter, tbl *objects.Table, logge
 			s.AddRow(row)
 		}
 	}
+	if types := TableColumnTypes(tbl); types != nil {
+		// keep the schema of the original table
+		opts = append([]InserterOption{WithColumnTypes(types)}, opts...)
+	}
 	inserter := NewInserter(db, s, logger, opts...)
 	return inserter.IngestTableFromSorter(s.Columns, s.PK)
 }
@@ -63,6 +63,18 @@ func (d *RepoDir) KVPath() string {
 	return filepath.Join(d.FullPath, "kv")
 }
 
+// RebaseStatePath returns path of the file that holds state of an ongoing
+// rebase
+func (d *RepoDir) RebaseStatePath() string {
+	return filepath.Join(d.FullPath, "rebase.yaml")
+}
+
+// BisectStatePath returns path of the file that holds state of an ongoing
+// bisect
+func (d *RepoDir) BisectStatePath() string {
+	return filepath.Join(d.FullPath, "bisect.yaml")
+}
+
 func (d *RepoDir) openBadger() (*badger.DB, error) {
 	opts := badger.DefaultOptions(d.KVPath()).
 		WithLoggingLevel(badger.ERROR)
@@ -99,6 +99,13 @@ func IndexBlockFromBytes(dec *StrListDecoder, hash *meow.Digest, e *StrListEdito
 	return idx, nil
 }
 
+// PKSum returns the sum under which a row with the given primary key values
+// is indexed.
+func PKSum(enc *StrListEncoder, pkValues []string) []byte {
+	arr := meow.Checksum(0, enc.Encode(pkValues))
+	return arr[:]
+}
+
 func (idx *BlockIndex) Len() int {
 	return len(idx.Rows)
 }
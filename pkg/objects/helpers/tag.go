@@ -0,0 +1,33 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package objhelpers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wrgl/wrgl/pkg/objects"
+	"github.com/wrgl/wrgl/pkg/testutils"
+)
+
+func RandomTag() *objects.Tag {
+	return &objects.Tag{
+		Target:      testutils.SecureRandomBytes(16),
+		TaggerName:  "John Doe",
+		TaggerEmail: "john@doe.com",
+		Time:        time.Now(),
+		Message:     "release v1\n\napproved by Jane",
+	}
+}
+
+func AssertTagEqual(t *testing.T, a, b *objects.Tag) {
+	t.Helper()
+	require.Equal(t, a.Target, b.Target, "target not equal")
+	require.Equal(t, a.TaggerName, b.TaggerName, "tagger name not equal")
+	require.Equal(t, a.TaggerEmail, b.TaggerEmail, "tagger email not equal")
+	require.Equal(t, a.Message, b.Message, "message not equal")
+	require.Equal(t, a.Time.Unix(), b.Time.Unix(), "time not equal")
+	require.Equal(t, a.Time.Format("-0700"), b.Time.Format("-0700"), "time not equal")
+}
@@ -5,6 +5,7 @@ package objects
 
 import (
 	"bytes"
+	"encoding/binary"
 	"sort"
 
 	"github.com/klauspost/compress/s2"
@@ -18,6 +19,7 @@ var (
 	tblIdxPrefix = []byte("tblidx/")
 	comPrefix    = []byte("com/")
 	tblSumPrefix = []byte("tblsum/")
+	tagPrefix    = []byte("tag/")
 )
 
 func Prefixes() []string {
@@ -28,6 +30,7 @@ func Prefixes() []string {
 		string(tblIdxPrefix),
 		string(comPrefix),
 		string(tblSumPrefix),
+		string(tagPrefix),
 	}
 }
 
@@ -55,6 +58,10 @@ func tableProfileKey(sum []byte) []byte {
 	return append(tblSumPrefix, sum...)
 }
 
+func tagKey(sum []byte) []byte {
+	return append(tagPrefix, sum...)
+}
+
 func saveObj(s Store, k, v []byte) (err error) {
 	b := make([]byte, len(v))
 	copy(b, v)
@@ -111,6 +118,15 @@ func SaveCommit(s Store, content []byte) (sum []byte, err error) {
 	return arr[:], nil
 }
 
+func SaveTag(s Store, content []byte) (sum []byte, err error) {
+	arr := meow.Checksum(0, content)
+	err = saveObj(s, tagKey(arr[:]), content)
+	if err != nil {
+		return
+	}
+	return arr[:], nil
+}
+
 func GetBlockBytes(s Store, sum []byte) ([]byte, error) {
 	return s.Get(blockKey(sum))
 }
@@ -128,6 +144,31 @@ func GetBlock(s Store, buf, sum []byte) (blk [][]string, dst []byte, err error)
 	return
 }
 
+// GetBlockColumns is like GetBlock but only decodes the given columns. Each
+// returned row contains values of columns in the given order.
+func GetBlockColumns(s Store, buf, sum []byte, columns []uint32) (blk [][]string, dst []byte, err error) {
+	b, err := GetBlockBytes(s, sum)
+	if err != nil {
+		return
+	}
+	dst, err = s2.Decode(buf, b)
+	if err != nil {
+		return
+	}
+	n := int(binary.BigEndian.Uint32(dst))
+	blk = make([][]string, n)
+	off := 4
+	for i := 0; i < n; i++ {
+		m, err := ValidateStrListBytes(dst[off:])
+		if err != nil {
+			return nil, nil, err
+		}
+		blk[i] = StrList(dst[off : off+m]).ReadColumns(columns)
+		off += m
+	}
+	return blk, dst, nil
+}
+
 func GetBlockIndex(s Store, buf, sum []byte) (idx *BlockIndex, dst []byte, err error) {
 	b, err := s.Get(blockIndexKey(sum))
 	if err != nil {
@@ -160,6 +201,32 @@ func GetTableIndex(s Store, sum []byte) ([][]string, error) {
 	return idx, err
 }
 
+// LookupRow returns the row of tbl with the given primary key values, or nil
+// if there is no such row. tblIdx is the table index as returned by
+// GetTableIndex.
+func LookupRow(s Store, tbl *Table, tblIdx [][]string, pkValues []string) ([]string, error) {
+	// find the last block that starts at or before pkValues
+	i := sort.Search(len(tblIdx), func(i int) bool {
+		return StringSliceIsLess(nil, pkValues, tblIdx[i])
+	}) - 1
+	if i < 0 {
+		return nil, nil
+	}
+	idx, _, err := GetBlockIndex(s, nil, tbl.BlockIndices[i])
+	if err != nil {
+		return nil, err
+	}
+	off, sum := idx.Get(PKSum(NewStrListEncoder(true), pkValues))
+	if sum == nil {
+		return nil, nil
+	}
+	blk, _, err := GetBlock(s, nil, tbl.Blocks[i])
+	if err != nil {
+		return nil, err
+	}
+	return blk[off], nil
+}
+
 func GetTableProfile(s Store, sum []byte) (*TableProfile, error) {
 	b, err := s.Get(tableProfileKey(sum))
 	if err != nil {
@@ -183,6 +250,16 @@ func GetCommit(s Store, sum []byte) (*Commit, error) {
 	return com, err
 }
 
+func GetTag(s Store, sum []byte) (*Tag, error) {
+	b, err := s.Get(tagKey(sum))
+	if err != nil {
+		return nil, err
+	}
+	_, tag, err := ReadTagFrom(bytes.NewReader(b))
+	tag.Sum = sum
+	return tag, err
+}
+
 func DeleteBlock(s Store, sum []byte) error {
 	return s.Delete(blockKey(sum))
 }
@@ -207,6 +284,10 @@ func DeleteCommit(s Store, sum []byte) error {
 	return s.Delete(commitKey(sum))
 }
 
+func DeleteTag(s Store, sum []byte) error {
+	return s.Delete(tagKey(sum))
+}
+
 func BlockExist(s Store, sum []byte) bool {
 	return s.Exist(blockKey(sum))
 }
@@ -227,6 +308,10 @@ func CommitExist(s Store, sum []byte) bool {
 	return s.Exist(commitKey(sum))
 }
 
+func TagExist(s Store, sum []byte) bool {
+	return s.Exist(tagKey(sum))
+}
+
 func getAllKeys(s Store, prefix []byte) ([][]byte, error) {
 	sl, err := s.FilterKey(prefix)
 	if err != nil {
@@ -267,6 +352,10 @@ func GetAllCommitKeys(s Store) ([][]byte, error) {
 	return getAllKeys(s, comPrefix)
 }
 
+func GetAllTagKeys(s Store) ([][]byte, error) {
+	return getAllKeys(s, tagPrefix)
+}
+
 func DeleteAllCommit(s Store) error {
 	return s.Clear(comPrefix)
 }
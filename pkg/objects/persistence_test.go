@@ -5,12 +5,14 @@ package objects_test
 
 import (
 	"bytes"
+	"fmt"
 	"sort"
 	"testing"
 
 	"github.com/pckhoi/meow"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/wrgl/wrgl/pkg/factory"
 	"github.com/wrgl/wrgl/pkg/objects"
 	objhelpers "github.com/wrgl/wrgl/pkg/objects/helpers"
 	objmock "github.com/wrgl/wrgl/pkg/objects/mock"
@@ -122,6 +124,30 @@ func TestSaveTableIndex(t *testing.T) {
 	assert.Equal(t, objects.ErrKeyNotFound, err)
 }
 
+func TestLookupRow(t *testing.T) {
+	s := objmock.NewStore()
+	rows := []string{"a,b,c"}
+	for i := 0; i < 600; i++ {
+		rows = append(rows, fmt.Sprintf("%d,%d,x%d", i/2, i%2, i))
+	}
+	tbl, err := objects.GetTable(s, factory.BuildTable(t, s, rows, []uint32{0, 1}))
+	require.NoError(t, err)
+	require.Greater(t, len(tbl.Blocks), 2)
+	tblIdx, err := objects.GetTableIndex(s, tbl.Sum)
+	require.NoError(t, err)
+
+	for _, i := range []int{0, 1, 254, 255, 256, 511, 599} {
+		row, err := objects.LookupRow(s, tbl, tblIdx, []string{fmt.Sprint(i / 2), fmt.Sprint(i % 2)})
+		require.NoError(t, err)
+		assert.Equal(t, []string{fmt.Sprint(i / 2), fmt.Sprint(i % 2), fmt.Sprintf("x%d", i)}, row)
+	}
+	for _, pk := range [][]string{{"0", "2"}, {"", ""}, {"300", "0"}, {"99999", "0"}} {
+		row, err := objects.LookupRow(s, tbl, tblIdx, pk)
+		require.NoError(t, err)
+		assert.Nil(t, row)
+	}
+}
+
 func floatPtr(f float64) *float64 {
 	return &f
 }
@@ -215,3 +241,32 @@ func TestSaveCommit(t *testing.T) {
 	require.NoError(t, objects.DeleteAllCommit(s))
 	assert.False(t, objects.CommitExist(s, sum2))
 }
+
+func TestGetBlockColumns(t *testing.T) {
+	s := objmock.NewStore()
+	rows := []string{"a,b,c"}
+	for i := 0; i < 300; i++ {
+		rows = append(rows, fmt.Sprintf("%d,,x%d", i, i))
+	}
+	tbl, err := objects.GetTable(s, factory.BuildTable(t, s, rows, []uint32{0}))
+	require.NoError(t, err)
+	require.Len(t, tbl.Blocks, 2)
+
+	var buf []byte
+	var blk [][]string
+	for i, sum := range tbl.Blocks {
+		full, _, err := objects.GetBlock(s, nil, sum)
+		require.NoError(t, err)
+		blk, buf, err = objects.GetBlockColumns(s, buf, sum, []uint32{2, 1, 0})
+		require.NoError(t, err)
+		require.Len(t, blk, len(full), "block %d", i)
+		for j, row := range full {
+			assert.Equal(t, []string{row[2], row[1], row[0]}, blk[j])
+		}
+		blk, buf, err = objects.GetBlockColumns(s, buf, sum, []uint32{2})
+		require.NoError(t, err)
+		for j, row := range full {
+			assert.Equal(t, []string{row[2]}, blk[j])
+		}
+	}
+}
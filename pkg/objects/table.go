@@ -22,6 +22,10 @@ type Table struct {
 	RowsCount    uint32
 	Blocks       [][]byte
 	BlockIndices [][]byte
+	// Types holds the type of each column (see package schema), or nil if the
+	// table has no schema. It is written after block indices and only when
+	// set, so tables without a schema keep the same sum.
+	Types []string
 }
 
 func BlocksCount(rowsCount uint32) uint32 {
@@ -89,6 +93,13 @@ func (t *Table) WriteTo(w io.Writer) (int64, error) {
 		}
 		total += int64(n)
 	}
+	if t.Types != nil {
+		n, err := objline.WriteField(w, misc.NewBuffer(nil), "types", objline.WriteBytes(NewStrListEncoder(true).Encode(t.Types)))
+		if err != nil {
+			return total, err
+		}
+		total += n
+	}
 	return total, nil
 }
 
@@ -165,7 +176,17 @@ func (t *Table) ReadFrom(r io.Reader) (int64, error) {
 		total += int64(n)
 		t.BlockIndices[i] = b
 	}
-	return total, nil
+	n, err = objline.ReadField(encoding.NewParser(r), "types", func(p *encoding.Parser) (n int64, err error) {
+		n, t.Types, err = NewStrListDecoder(false).Read(p)
+		return
+	})
+	if errors.Is(err, io.EOF) {
+		return total, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return total + n, nil
 }
 
 func ReadTableFrom(r io.Reader) (int64, *Table, error) {
@@ -44,6 +44,40 @@ func TestTableReader(t *testing.T) {
 	assert.Equal(t, []uint32{0, 1}, table2.PK)
 }
 
+func TestTableReaderWithTypes(t *testing.T) {
+	table := &Table{
+		Columns:   []string{"a", "b"},
+		PK:        []uint32{0},
+		RowsCount: 10,
+		Blocks: [][]byte{
+			testutils.SecureRandomBytes(16),
+		},
+		BlockIndices: [][]byte{
+			testutils.SecureRandomBytes(16),
+		},
+	}
+	buf := misc.NewBuffer(nil)
+	_, err := table.WriteTo(buf)
+	require.NoError(t, err)
+	noTypes := append([]byte(nil), buf.Bytes()...)
+
+	table.Types = []string{"integer", "date"}
+	buf = misc.NewBuffer(nil)
+	n, err := table.WriteTo(buf)
+	require.NoError(t, err)
+	assert.Len(t, buf.Bytes(), int(n))
+	assert.Equal(t, noTypes, buf.Bytes()[:len(noTypes)])
+
+	n, table2, err := ReadTableFrom(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Len(t, buf.Bytes(), int(n))
+	assert.Equal(t, table, table2)
+
+	_, table3, err := ReadTableFrom(bytes.NewReader(noTypes))
+	require.NoError(t, err)
+	assert.Nil(t, table3.Types)
+}
+
 func TestTableReaderParseError(t *testing.T) {
 	buf := misc.NewBuffer([]byte("columns "))
 	buf.Write(NewStrListEncoder(true).Encode([]string{"a", "b", "c"}))
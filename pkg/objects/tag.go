@@ -0,0 +1,85 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package objects
+
+import (
+	"io"
+	"time"
+
+	"github.com/wrgl/wrgl/pkg/encoding"
+	"github.com/wrgl/wrgl/pkg/encoding/objline"
+	"github.com/wrgl/wrgl/pkg/misc"
+)
+
+// Tag is an annotated tag. It points to a commit and records who created
+// the tag, when and why.
+type Tag struct {
+	Sum         []byte
+	Target      []byte
+	TaggerName  string
+	TaggerEmail string
+	Time        time.Time
+	Message     string
+}
+
+func (t *Tag) WriteTo(w io.Writer) (int64, error) {
+	buf := misc.NewBuffer(nil)
+	var total int64
+	for _, l := range []fieldEncode{
+		{"target", objline.WriteBytes(t.Target)},
+		{"taggerName", func(w io.Writer, buf encoding.Bufferer) (n int64, err error) {
+			return objline.WriteString(w, buf, t.TaggerName)
+		}},
+		{"taggerEmail", func(w io.Writer, buf encoding.Bufferer) (n int64, err error) {
+			return objline.WriteString(w, buf, t.TaggerEmail)
+		}},
+		{"time", func(w io.Writer, buf encoding.Bufferer) (n int64, err error) {
+			return objline.WriteTime(w, buf, t.Time)
+		}},
+		{"message", func(w io.Writer, buf encoding.Bufferer) (n int64, err error) {
+			return objline.WriteString(w, buf, t.Message)
+		}},
+	} {
+		n, err := objline.WriteField(w, buf, l.label, l.f)
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+func (t *Tag) ReadFrom(r io.Reader) (int64, error) {
+	parser := encoding.NewParser(r)
+	t.Target = make([]byte, 16)
+	var total int64
+	for _, l := range []fieldDecode{
+		{"target", objline.ReadBytes(t.Target)},
+		{"taggerName", func(p *encoding.Parser) (int64, error) {
+			return objline.ReadString(p, &t.TaggerName)
+		}},
+		{"taggerEmail", func(p *encoding.Parser) (int64, error) {
+			return objline.ReadString(p, &t.TaggerEmail)
+		}},
+		{"time", func(p *encoding.Parser) (int64, error) {
+			return objline.ReadTime(p, &t.Time)
+		}},
+		{"message", func(p *encoding.Parser) (int64, error) {
+			return objline.ReadString(p, &t.Message)
+		}},
+	} {
+		n, err := objline.ReadField(parser, l.label, l.f)
+		if err != nil {
+			return 0, err
+		}
+		total += int64(n)
+	}
+	return total, nil
+}
+
+func ReadTagFrom(r io.Reader) (int64, *Tag, error) {
+	t := &Tag{}
+	n, err := t.ReadFrom(r)
+	return n, t, err
+}
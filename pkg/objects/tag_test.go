@@ -0,0 +1,51 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package objects_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wrgl/wrgl/pkg/objects"
+	objhelpers "github.com/wrgl/wrgl/pkg/objects/helpers"
+	objmock "github.com/wrgl/wrgl/pkg/objects/mock"
+)
+
+func TestWriteTag(t *testing.T) {
+	tag := objhelpers.RandomTag()
+	buf := bytes.NewBufferString("")
+	n, err := tag.WriteTo(buf)
+	require.NoError(t, err)
+	assert.Len(t, buf.Bytes(), int(n))
+	n, tag2, err := objects.ReadTagFrom(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Len(t, buf.Bytes(), int(n))
+	objhelpers.AssertTagEqual(t, tag, tag2)
+}
+
+func TestSaveTag(t *testing.T) {
+	s := objmock.NewStore()
+
+	tag := objhelpers.RandomTag()
+	buf := bytes.NewBuffer(nil)
+	_, err := tag.WriteTo(buf)
+	require.NoError(t, err)
+	sum, err := objects.SaveTag(s, buf.Bytes())
+	require.NoError(t, err)
+	assert.True(t, objects.TagExist(s, sum))
+	assert.False(t, objects.CommitExist(s, sum))
+	obj, err := objects.GetTag(s, sum)
+	require.NoError(t, err)
+	assert.Equal(t, sum, obj.Sum)
+	objhelpers.AssertTagEqual(t, tag, obj)
+	sl, err := objects.GetAllTagKeys(s)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{sum}, sl)
+	require.NoError(t, objects.DeleteTag(s, sum))
+	assert.False(t, objects.TagExist(s, sum))
+	_, err = objects.GetTag(s, sum)
+	assert.Equal(t, objects.ErrKeyNotFound, err)
+}
@@ -0,0 +1,252 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package parquet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var errShortPage = errors.New("page data is too short")
+
+// unpack reads n values of bitWidth bits each, packed from the least
+// significant bit of each byte, from the start of b
+func unpack(dst []uint64, b []byte, bitWidth, n int) ([]uint64, error) {
+	if (n*bitWidth+7)/8 > len(b) {
+		return nil, errShortPage
+	}
+	var bit int
+	for i := 0; i < n; i++ {
+		var v uint64
+		for j := 0; j < bitWidth; j++ {
+			if b[bit>>3]&(1<<(bit&7)) != 0 {
+				v |= 1 << j
+			}
+			bit++
+		}
+		dst = append(dst, v)
+	}
+	return dst, nil
+}
+
+// decodeHybrid decodes n values encoded with the RLE/bit-packing hybrid
+// encoding (without length prefix). It returns the values and the number of
+// bytes read.
+func decodeHybrid(b []byte, bitWidth, n int) ([]uint64, int, error) {
+	if bitWidth > 64 {
+		return nil, 0, fmt.Errorf("invalid bit width %d", bitWidth)
+	}
+	values := make([]uint64, 0, n)
+	var off int
+	for len(values) < n {
+		header, m := binary.Uvarint(b[off:])
+		if m <= 0 {
+			return nil, 0, errShortPage
+		}
+		off += m
+		if header&1 == 0 {
+			count := int(header >> 1)
+			size := (bitWidth + 7) / 8
+			if off+size > len(b) {
+				return nil, 0, errShortPage
+			}
+			var v uint64
+			for i := 0; i < size; i++ {
+				v |= uint64(b[off+i]) << (8 * i)
+			}
+			off += size
+			for i := 0; i < count && len(values) < n; i++ {
+				values = append(values, v)
+			}
+		} else {
+			count := int(header>>1) * 8
+			size := count * bitWidth / 8
+			if off+size > len(b) {
+				// the last run may be truncated when it holds more values than
+				// needed
+				size = len(b) - off
+				if bitWidth > 0 {
+					count = size * 8 / bitWidth
+				}
+			}
+			if count > n-len(values) {
+				count = n - len(values)
+			}
+			var err error
+			values, err = unpack(values, b[off:], bitWidth, count)
+			if err != nil {
+				return nil, 0, err
+			}
+			off += size
+			if count == 0 {
+				return nil, 0, errShortPage
+			}
+		}
+	}
+	return values, off, nil
+}
+
+// decodeLevels decodes n definition levels encoded with the RLE/bit-packing
+// hybrid encoding prefixed with the encoded length (data page v1). It returns
+// the levels and the number of bytes read.
+func decodeLevels(b []byte, bitWidth, n int) ([]uint64, int, error) {
+	if len(b) < 4 {
+		return nil, 0, errShortPage
+	}
+	size := int(binary.LittleEndian.Uint32(b))
+	if size > len(b)-4 {
+		return nil, 0, errShortPage
+	}
+	levels, _, err := decodeHybrid(b[4:4+size], bitWidth, n)
+	return levels, 4 + size, err
+}
+
+func uvarint(b []byte, off *int) (uint64, error) {
+	v, n := binary.Uvarint(b[*off:])
+	if n <= 0 {
+		return 0, errShortPage
+	}
+	*off += n
+	return v, nil
+}
+
+func zigzagVarint(b []byte, off *int) (int64, error) {
+	v, err := uvarint(b, off)
+	return int64(v>>1) ^ -int64(v&1), err
+}
+
+// decodeDeltaBinaryPacked decodes values encoded with DELTA_BINARY_PACKED. It
+// returns the values and the number of bytes read.
+func decodeDeltaBinaryPacked(b []byte) ([]int64, int, error) {
+	var off int
+	blockSize, err := uvarint(b, &off)
+	if err != nil {
+		return nil, 0, err
+	}
+	numMiniBlocks, err := uvarint(b, &off)
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := uvarint(b, &off)
+	if err != nil {
+		return nil, 0, err
+	}
+	first, err := zigzagVarint(b, &off)
+	if err != nil {
+		return nil, 0, err
+	}
+	if numMiniBlocks == 0 || blockSize%numMiniBlocks != 0 || total > uint64(len(b))*64 {
+		return nil, 0, fmt.Errorf("invalid DELTA_BINARY_PACKED header")
+	}
+	perMiniBlock := int(blockSize / numMiniBlocks)
+	values := make([]int64, 0, total)
+	values = append(values, first)
+	last := first
+	var deltas []uint64
+	for uint64(len(values)) < total {
+		minDelta, err := zigzagVarint(b, &off)
+		if err != nil {
+			return nil, 0, err
+		}
+		if off+int(numMiniBlocks) > len(b) {
+			return nil, 0, errShortPage
+		}
+		widths := b[off : off+int(numMiniBlocks)]
+		off += int(numMiniBlocks)
+		for _, w := range widths {
+			if uint64(len(values)) >= total {
+				break
+			}
+			if w > 64 {
+				return nil, 0, fmt.Errorf("invalid bit width %d", w)
+			}
+			deltas, err = unpack(deltas[:0], b[off:], int(w), perMiniBlock)
+			if err != nil {
+				return nil, 0, err
+			}
+			off += perMiniBlock * int(w) / 8
+			for _, d := range deltas {
+				if uint64(len(values)) >= total {
+					break
+				}
+				// wrapping arithmetic as required by the spec
+				last = int64(uint64(last) + uint64(minDelta) + d)
+				values = append(values, last)
+			}
+		}
+	}
+	if total == 0 {
+		values = values[:0]
+	}
+	return values, off, nil
+}
+
+// decodeDeltaLengthByteArray decodes n values encoded with
+// DELTA_LENGTH_BYTE_ARRAY
+func decodeDeltaLengthByteArray(b []byte, n int) ([][]byte, error) {
+	lengths, off, err := decodeDeltaBinaryPacked(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(lengths) < n {
+		return nil, errShortPage
+	}
+	values := make([][]byte, n)
+	for i := range values {
+		l := lengths[i]
+		if l < 0 || l > int64(len(b)-off) {
+			return nil, errShortPage
+		}
+		values[i] = b[off : off+int(l)]
+		off += int(l)
+	}
+	return values, nil
+}
+
+// decodeDeltaByteArray decodes n values encoded with DELTA_BYTE_ARRAY. prev
+// is the last value of the previous page of the column chunk: some writers,
+// including parquet-mr before 1.8 and Apache Arrow, keep sharing prefixes
+// with it instead of starting each page with an empty prefix.
+func decodeDeltaByteArray(b []byte, n int, prev []byte) ([][]byte, error) {
+	prefixes, off, err := decodeDeltaBinaryPacked(b)
+	if err != nil {
+		return nil, err
+	}
+	suffixes, err := decodeDeltaLengthByteArray(b[off:], n)
+	if err != nil {
+		return nil, err
+	}
+	if len(prefixes) < n {
+		return nil, errShortPage
+	}
+	values := make([][]byte, n)
+	for i, suffix := range suffixes {
+		p := prefixes[i]
+		if p < 0 || p > int64(len(prev)) {
+			return nil, fmt.Errorf("invalid DELTA_BYTE_ARRAY prefix length %d", p)
+		}
+		v := make([]byte, 0, int(p)+len(suffix))
+		v = append(v, prev[:p]...)
+		v = append(v, suffix...)
+		values[i] = v
+		prev = v
+	}
+	return values, nil
+}
+
+// decodeByteStreamSplit decodes n values of size bytes each encoded with
+// BYTE_STREAM_SPLIT, returning them as contiguous little-endian values
+func decodeByteStreamSplit(b []byte, size, n int) ([]byte, error) {
+	if len(b) < size*n {
+		return nil, errShortPage
+	}
+	out := make([]byte, size*n)
+	for i := 0; i < n; i++ {
+		for j := 0; j < size; j++ {
+			out[i*size+j] = b[j*n+i]
+		}
+	}
+	return out, nil
+}
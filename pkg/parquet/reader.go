@@ -0,0 +1,435 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Parquet enum values that are only read
+const (
+	physicalBoolean           = 0
+	physicalInt32             = 1
+	physicalInt96             = 3
+	physicalFloat             = 4
+	physicalFixedLenByteArray = 7
+
+	repetitionRepeated = 2
+
+	encodingPlainDictionary    = 2
+	encodingDeltaBinaryPacked  = 5
+	encodingDeltaLengthByteArr = 6
+	encodingDeltaByteArray     = 7
+	encodingRLEDictionary      = 8
+	encodingByteStreamSplit    = 9
+
+	codecUncompressed = 0
+	codecGzip         = 2
+	codecZstd         = 6
+
+	pageTypeDictionary = 2
+	pageTypeDataV2     = 3
+)
+
+var codecNames = []string{"UNCOMPRESSED", "SNAPPY", "GZIP", "LZO", "BROTLI", "LZ4", "ZSTD", "LZ4_RAW"}
+
+type chunkInfo struct {
+	codec      int32
+	numValues  int64
+	offset     int64
+	size       int64
+	dictOffset int64
+}
+
+type rowGroupInfo struct {
+	numRows int64
+	chunks  []chunkInfo
+}
+
+// Reader reads rows of a Parquet file as strings. Only flat schemas, where
+// every column is a required or optional primitive field, are supported.
+// Nulls are read as empty strings. A whole row group is decoded at once.
+type Reader struct {
+	r         io.ReaderAt
+	columns   []*columnSchema
+	rowGroups []rowGroupInfo
+	numRows   int64
+
+	rg     int
+	values [][]string
+	row    int
+	zstd   *zstd.Decoder
+}
+
+// NewReader reads the footer of a Parquet file of the given size
+func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	if size < 12 {
+		return nil, fmt.Errorf("not a Parquet file: file is too short")
+	}
+	tail := make([]byte, 8)
+	if _, err := r.ReadAt(tail, size-8); err != nil {
+		return nil, err
+	}
+	if string(tail[4:]) != magic {
+		return nil, fmt.Errorf("not a Parquet file: magic bytes not found")
+	}
+	metaLen := int64(binary.LittleEndian.Uint32(tail))
+	if metaLen > size-12 {
+		return nil, fmt.Errorf("invalid Parquet footer length %d", metaLen)
+	}
+	meta := make([]byte, metaLen)
+	if _, err := r.ReadAt(meta, size-8-metaLen); err != nil {
+		return nil, err
+	}
+	reader := &Reader{r: r}
+	if err := reader.decodeFileMetaData(meta); err != nil {
+		return nil, fmt.Errorf("error decoding Parquet footer: %v", err)
+	}
+	return reader, nil
+}
+
+// Columns returns column names
+func (r *Reader) Columns() []string {
+	names := make([]string, len(r.columns))
+	for i, col := range r.columns {
+		names[i] = col.name
+	}
+	return names
+}
+
+// NumRows returns the number of rows in the file
+func (r *Reader) NumRows() int64 {
+	return r.numRows
+}
+
+// Read returns the next row, or io.EOF after the last row
+func (r *Reader) Read() ([]string, error) {
+	for r.values == nil || r.row >= int(r.rowGroups[r.rg-1].numRows) {
+		if r.rg >= len(r.rowGroups) {
+			return nil, io.EOF
+		}
+		if err := r.readRowGroup(); err != nil {
+			return nil, err
+		}
+	}
+	row := make([]string, len(r.columns))
+	for i, values := range r.values {
+		row[i] = values[r.row]
+	}
+	r.row++
+	return row, nil
+}
+
+func (r *Reader) readRowGroup() error {
+	rg := r.rowGroups[r.rg]
+	r.rg++
+	if len(rg.chunks) != len(r.columns) {
+		return fmt.Errorf("row group %d has %d columns, expecting %d", r.rg-1, len(rg.chunks), len(r.columns))
+	}
+	if r.values == nil {
+		r.values = make([][]string, len(r.columns))
+	}
+	for i, col := range r.columns {
+		values, err := r.readChunk(col, rg.chunks[i])
+		if err != nil {
+			return fmt.Errorf("error reading column %q: %v", col.name, err)
+		}
+		if int64(len(values)) != rg.numRows {
+			return fmt.Errorf("column %q has %d values in row group %d, expecting %d", col.name, len(values), r.rg-1, rg.numRows)
+		}
+		r.values[i] = values
+	}
+	r.row = 0
+	return nil
+}
+
+func (r *Reader) decompress(codec int32, b []byte, size int32) ([]byte, error) {
+	switch codec {
+	case codecUncompressed:
+		return b, nil
+	case codecSnappy:
+		return snappy.Decode(nil, b)
+	case codecGzip:
+		gr, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		out := bytes.NewBuffer(make([]byte, 0, size))
+		if _, err = io.Copy(out, gr); err != nil {
+			return nil, err
+		}
+		return out.Bytes(), nil
+	case codecZstd:
+		if r.zstd == nil {
+			d, err := zstd.NewReader(nil)
+			if err != nil {
+				return nil, err
+			}
+			r.zstd = d
+		}
+		return r.zstd.DecodeAll(b, make([]byte, 0, size))
+	}
+	name := fmt.Sprintf("%d", codec)
+	if codec >= 0 && int(codec) < len(codecNames) {
+		name = codecNames[codec]
+	}
+	return nil, fmt.Errorf("unsupported compression codec %s", name)
+}
+
+type pageHeader struct {
+	typ              int32
+	uncompressedSize int32
+	compressedSize   int32
+	numValues        int32
+	encoding         int32
+	// defLevelsLen, repLevelsLen and compressed are only set for data page v2
+	defLevelsLen int32
+	repLevelsLen int32
+	compressed   bool
+}
+
+func decodePageHeader(d *thriftDecoder) (*pageHeader, error) {
+	h := &pageHeader{compressed: true}
+	readDataHeader := func(v2 bool) error {
+		return d.readStruct(func(id int16, typ byte) (err error) {
+			switch {
+			case id == 1:
+				h.numValues, err = d.i32()
+			case id == 2 && !v2, id == 4 && v2:
+				h.encoding, err = d.i32()
+			case id == 5 && v2:
+				h.defLevelsLen, err = d.i32()
+			case id == 6 && v2:
+				h.repLevelsLen, err = d.i32()
+			case id == 7 && v2:
+				h.compressed = typ == thriftBoolTrue
+			default:
+				err = d.skip(typ)
+			}
+			return
+		})
+	}
+	err := d.readStruct(func(id int16, typ byte) (err error) {
+		switch id {
+		case 1:
+			h.typ, err = d.i32()
+		case 2:
+			h.uncompressedSize, err = d.i32()
+		case 3:
+			h.compressedSize, err = d.i32()
+		case 5, 7:
+			// data page and dictionary page headers share the fields that
+			// are read
+			err = readDataHeader(false)
+		case 8:
+			err = readDataHeader(true)
+		default:
+			err = d.skip(typ)
+		}
+		return
+	})
+	return h, err
+}
+
+// chunkState holds what decoding a data page needs from the pages before it
+// in the same column chunk
+type chunkState struct {
+	dict []string
+	// prev is the last DELTA_BYTE_ARRAY encoded value
+	prev []byte
+}
+
+// readChunk decodes all values of a column chunk
+func (r *Reader) readChunk(col *columnSchema, info chunkInfo) ([]string, error) {
+	offset := info.offset
+	if info.dictOffset > 0 && info.dictOffset < offset {
+		offset = info.dictOffset
+	}
+	buf := make([]byte, info.size)
+	if _, err := r.r.ReadAt(buf, offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+	values := make([]string, 0, info.numValues)
+	st := &chunkState{}
+	for off := 0; int64(len(values)) < info.numValues; {
+		if off >= len(buf) {
+			return nil, fmt.Errorf("column chunk ends after %d of %d values", len(values), info.numValues)
+		}
+		d := &thriftDecoder{buf: buf[off:]}
+		h, err := decodePageHeader(d)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding page header: %v", err)
+		}
+		off += d.off
+		if h.compressedSize < 0 || int(h.compressedSize) > len(buf)-off {
+			return nil, errShortPage
+		}
+		page := buf[off : off+int(h.compressedSize)]
+		off += int(h.compressedSize)
+		switch h.typ {
+		case pageTypeDictionary:
+			page, err = r.decompress(info.codec, page, h.uncompressedSize)
+			if err != nil {
+				return nil, err
+			}
+			st.dict, err = col.decodePlain(page, int(h.numValues))
+			if err != nil {
+				return nil, fmt.Errorf("error decoding dictionary page: %v", err)
+			}
+		case pageTypeData:
+			page, err = r.decompress(info.codec, page, h.uncompressedSize)
+			if err != nil {
+				return nil, err
+			}
+			var levels []uint64
+			if col.optional {
+				var n int
+				levels, n, err = decodeLevels(page, 1, int(h.numValues))
+				if err != nil {
+					return nil, fmt.Errorf("error decoding definition levels: %v", err)
+				}
+				page = page[n:]
+			}
+			values, err = col.decodeValues(values, page, h.encoding, int(h.numValues), levels, st)
+			if err != nil {
+				return nil, err
+			}
+		case pageTypeDataV2:
+			n := int(h.repLevelsLen) + int(h.defLevelsLen)
+			if h.repLevelsLen < 0 || h.defLevelsLen < 0 || n > len(page) {
+				return nil, errShortPage
+			}
+			var levels []uint64
+			if col.optional {
+				levels, _, err = decodeHybrid(page[h.repLevelsLen:n], 1, int(h.numValues))
+				if err != nil {
+					return nil, fmt.Errorf("error decoding definition levels: %v", err)
+				}
+			}
+			page = page[n:]
+			if h.compressed {
+				page, err = r.decompress(info.codec, page, h.uncompressedSize-int32(n))
+				if err != nil {
+					return nil, err
+				}
+			}
+			values, err = col.decodeValues(values, page, h.encoding, int(h.numValues), levels, st)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return values, nil
+}
+
+func (r *Reader) decodeFileMetaData(b []byte) error {
+	d := &thriftDecoder{buf: b}
+	var schema []*schemaElement
+	err := d.readStruct(func(id int16, typ byte) (err error) {
+		switch id {
+		case 2:
+			var n int
+			_, n, err = d.listHeader()
+			for i := 0; i < n && err == nil; i++ {
+				var elem *schemaElement
+				elem, err = decodeSchemaElement(d)
+				schema = append(schema, elem)
+			}
+		case 3:
+			r.numRows, err = d.zigzag()
+		case 4:
+			var n int
+			_, n, err = d.listHeader()
+			for i := 0; i < n && err == nil; i++ {
+				var rg rowGroupInfo
+				rg, err = decodeRowGroup(d)
+				r.rowGroups = append(r.rowGroups, rg)
+			}
+		default:
+			err = d.skip(typ)
+		}
+		return
+	})
+	if err != nil {
+		return err
+	}
+	if len(schema) == 0 {
+		return fmt.Errorf("schema is empty")
+	}
+	if int(schema[0].numChildren) != len(schema)-1 {
+		return fmt.Errorf("nested columns are not supported")
+	}
+	for _, elem := range schema[1:] {
+		if elem.numChildren > 0 {
+			return fmt.Errorf("column %q: nested columns are not supported", elem.name)
+		}
+		if elem.repetition == repetitionRepeated {
+			return fmt.Errorf("column %q: repeated columns are not supported", elem.name)
+		}
+		col, err := newColumnSchema(elem)
+		if err != nil {
+			return err
+		}
+		r.columns = append(r.columns, col)
+	}
+	return nil
+}
+
+func decodeRowGroup(d *thriftDecoder) (rg rowGroupInfo, err error) {
+	err = d.readStruct(func(id int16, typ byte) (err error) {
+		switch id {
+		case 1:
+			var n int
+			_, n, err = d.listHeader()
+			for i := 0; i < n && err == nil; i++ {
+				var c chunkInfo
+				c, err = decodeColumnChunk(d)
+				rg.chunks = append(rg.chunks, c)
+			}
+		case 3:
+			rg.numRows, err = d.zigzag()
+		default:
+			err = d.skip(typ)
+		}
+		return
+	})
+	return
+}
+
+func decodeColumnChunk(d *thriftDecoder) (c chunkInfo, err error) {
+	err = d.readStruct(func(id int16, typ byte) (err error) {
+		if id == 1 {
+			return fmt.Errorf("column chunks in external files are not supported")
+		}
+		if id != 3 {
+			return d.skip(typ)
+		}
+		return d.readStruct(func(id int16, typ byte) (err error) {
+			switch id {
+			case 4:
+				c.codec, err = d.i32()
+			case 5:
+				c.numValues, err = d.zigzag()
+			case 7:
+				c.size, err = d.zigzag()
+			case 9:
+				c.offset, err = d.zigzag()
+			case 11:
+				c.dictOffset, err = d.zigzag()
+			default:
+				err = d.skip(typ)
+			}
+			return
+		})
+	})
+	return
+}
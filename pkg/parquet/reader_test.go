@@ -0,0 +1,223 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package parquet
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThriftDecoder(t *testing.T) {
+	e := &thriftEncoder{}
+	e.beginStruct()
+	e.i32(1, -1)
+	e.binary(4, "ab")
+	e.structField(20)
+	e.i64(1, 300)
+	e.endStruct()
+	e.list(21, thriftI32, 2)
+	e.listI32(1)
+	e.listI32(2)
+	e.i64(22, 7)
+	e.endStruct()
+
+	d := &thriftDecoder{buf: e.buf}
+	var ids []int16
+	var i32 int32
+	var s string
+	var i64 int64
+	require.NoError(t, d.readStruct(func(id int16, typ byte) (err error) {
+		ids = append(ids, id)
+		switch id {
+		case 1:
+			i32, err = d.i32()
+		case 4:
+			s, err = d.str()
+		case 22:
+			i64, err = d.zigzag()
+		default:
+			err = d.skip(typ)
+		}
+		return
+	}))
+	assert.Equal(t, []int16{1, 4, 20, 21, 22}, ids)
+	assert.Equal(t, int32(-1), i32)
+	assert.Equal(t, "ab", s)
+	assert.Equal(t, int64(7), i64)
+	assert.Equal(t, len(e.buf), d.off)
+
+	d = &thriftDecoder{buf: e.buf[:5]}
+	assert.Equal(t, errThriftEOF, d.skip(thriftStruct))
+}
+
+func TestDecodeHybrid(t *testing.T) {
+	// bit-packed run of 0 to 7 with bit width 3, example from the spec
+	values, n, err := decodeHybrid([]byte{0x03, 0x88, 0xc6, 0xfa}, 3, 8)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{0, 1, 2, 3, 4, 5, 6, 7}, values)
+	assert.Equal(t, 4, n)
+
+	// RLE run followed by a bit-packed run that holds more values than needed
+	values, _, err = decodeHybrid([]byte{0x06, 0x01, 0x03, 0x05}, 1, 6)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1, 1, 1, 1, 0, 1}, values)
+
+	_, _, err = decodeHybrid([]byte{0x06, 0x01}, 1, 4)
+	assert.Equal(t, errShortPage, err)
+
+	levels, n, err := decodeLevels(encodeLevels(nil, []byte{1, 1, 0, 1}), 1, 4)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1, 1, 0, 1}, levels)
+	assert.Equal(t, 10, n)
+}
+
+func TestDecodeDeltaBinaryPacked(t *testing.T) {
+	// 1, 2, 3, 4, 5: all deltas equal the min delta so bit widths are 0
+	values, n, err := decodeDeltaBinaryPacked([]byte{0x80, 0x01, 0x04, 0x05, 0x02, 0x02, 0, 0, 0, 0})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 3, 4, 5}, values)
+	assert.Equal(t, 10, n)
+
+	// 7, 5, 3, 1, 2, 3, 4, 5: min delta -2, deltas packed with bit width 2
+	b := []byte{0x80, 0x01, 0x04, 0x08, 0x0e, 0x03, 2, 0, 0, 0, 0xc0, 0x3f, 0, 0, 0, 0, 0, 0, 'x'}
+	values, n, err = decodeDeltaBinaryPacked(b)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{7, 5, 3, 1, 2, 3, 4, 5}, values)
+	assert.Equal(t, len(b)-1, n)
+
+	// lengths 2, 2, 1 followed by the concatenated values
+	arrs, err := decodeDeltaLengthByteArray([]byte{0x80, 0x01, 0x04, 0x03, 0x04, 0x01, 1, 0, 0, 0, 0x01, 0, 0, 0, 'a', 'b', 'a', 'c', 'd'}, 3)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("ab"), []byte("ac"), []byte("d")}, arrs)
+}
+
+func TestDecodeValues(t *testing.T) {
+	col := &columnSchema{physical: physicalByteArray, optional: true}
+	// bit width 1, a bit-packed run with indices 1, 0, 1
+	values, err := col.decodeValues([]string{"x"}, []byte{1, 0x03, 0x05}, encodingRLEDictionary, 4, []uint64{1, 0, 1, 1}, &chunkState{dict: []string{"a", "b"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"x", "b", "", "a", "b"}, values)
+
+	_, err = col.decodeValues(nil, []byte{1, 0x03, 0x05}, encodingRLEDictionary, 1, nil, &chunkState{})
+	assert.EqualError(t, err, "dictionary page not found")
+
+	col = &columnSchema{physical: physicalInt32, kind: kindDecimal, scale: 2}
+	values, err = col.decodeValues(nil, []byte{0x0c, 0xfe, 0xff, 0xff, 5, 0, 0, 0}, encodingPlain, 2, nil, &chunkState{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-5.00", "0.05"}, values)
+
+	col = &columnSchema{physical: physicalFixedLenByteArray, typeLength: 2, kind: kindDecimal, scale: 1}
+	values, err = col.decodeValues(nil, []byte{0xff, 0x85, 0x01, 0x00}, encodingPlain, 2, nil, &chunkState{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-12.3", "25.6"}, values)
+}
+
+func TestReader(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	w := NewWriter(buf, []Column{{"id", Int64}, {"name", String}, {"price", Double}})
+	w.rowGroupSize = 40
+	rows := [][]string{
+		{"1", "a", "1.5"},
+		{"", "bc", ""},
+		{"3", "d", "2"},
+		{"4", "", "-1000"},
+	}
+	for _, row := range rows {
+		require.NoError(t, w.Write(row))
+	}
+	require.NoError(t, w.Close())
+	assert.Len(t, w.rowGroups, 2)
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id", "name", "price"}, r.Columns())
+	assert.Equal(t, int64(4), r.NumRows())
+	for _, row := range rows {
+		sl, err := r.Read()
+		require.NoError(t, err)
+		assert.Equal(t, row, sl)
+	}
+	_, err = r.Read()
+	assert.Equal(t, io.EOF, err)
+
+	buf.Reset()
+	w = NewWriter(buf, []Column{{"id", Int64}})
+	require.NoError(t, w.Close())
+	r, err = NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id"}, r.Columns())
+	_, err = r.Read()
+	assert.Equal(t, io.EOF, err)
+
+	_, err = NewReader(bytes.NewReader([]byte("a,b\n1,2\n3,4\n")), 12)
+	assert.EqualError(t, err, "not a Parquet file: magic bytes not found")
+}
+
+// TestReaderTestdata reads files written by the Apache Arrow Parquet writer
+// with dictionary pages, data page v2, gzip and zstd compression and delta
+// encodings. See testdata/generate.
+func TestReaderTestdata(t *testing.T) {
+	files, err := filepath.Glob("testdata/*.parquet")
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+	for _, name := range files {
+		t.Run(filepath.Base(name), func(t *testing.T) {
+			f, err := os.Open(name)
+			require.NoError(t, err)
+			defer f.Close()
+			st, err := f.Stat()
+			require.NoError(t, err)
+			r, err := NewReader(f, st.Size())
+			require.NoError(t, err)
+
+			csvFile, err := os.Open(name[:len(name)-len(".parquet")] + ".csv")
+			require.NoError(t, err)
+			defer csvFile.Close()
+			expected, err := csv.NewReader(csvFile).ReadAll()
+			require.NoError(t, err)
+
+			assert.Equal(t, expected[0], r.Columns())
+			assert.Equal(t, int64(len(expected)-1), r.NumRows())
+			for _, row := range expected[1:] {
+				sl, err := r.Read()
+				require.NoError(t, err)
+				require.Equal(t, row, sl)
+			}
+			_, err = r.Read()
+			assert.Equal(t, io.EOF, err)
+		})
+	}
+}
+
+func TestReaderNestedColumns(t *testing.T) {
+	e := &thriftEncoder{}
+	e.beginStruct()
+	e.i32(1, 1)
+	e.list(2, thriftStruct, 3)
+	e.beginStruct()
+	e.binary(4, "schema")
+	e.i32(5, 1)
+	e.endStruct()
+	e.beginStruct()
+	e.i32(3, repetitionOptional)
+	e.binary(4, "address")
+	e.i32(5, 1)
+	e.endStruct()
+	e.beginStruct()
+	e.i32(1, physicalByteArray)
+	e.i32(3, repetitionOptional)
+	e.binary(4, "city")
+	e.endStruct()
+	e.i64(3, 0)
+	e.endStruct()
+	r := &Reader{}
+	assert.EqualError(t, r.decodeFileMetaData(e.buf), "nested columns are not supported")
+}
@@ -0,0 +1,468 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package parquet
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parquet converted types that affect how values are read
+const (
+	convertedDecimal         = 5
+	convertedDate            = 6
+	convertedTimeMillis      = 7
+	convertedTimeMicros      = 8
+	convertedTimestampMillis = 9
+	convertedTimestampMicros = 10
+	convertedUint8           = 11
+	convertedUint64          = 14
+)
+
+type schemaElement struct {
+	name        string
+	physical    int32
+	typeLength  int32
+	repetition  int32
+	numChildren int32
+	converted   int32
+	scale       int32
+	logical     logicalType
+}
+
+// logicalType holds the parts of a LogicalType union that affect how values
+// are read
+type logicalType struct {
+	// id is the id of the union field that is set, or 0 if none is
+	id       int16
+	scale    int32
+	unit     time.Duration
+	utc      bool
+	unsigned bool
+}
+
+// Ids of LogicalType union fields
+const (
+	logicalDecimal   = 5
+	logicalDate      = 6
+	logicalTime      = 7
+	logicalTimestamp = 8
+	logicalInteger   = 10
+	logicalUUID      = 14
+)
+
+func decodeSchemaElement(d *thriftDecoder) (*schemaElement, error) {
+	elem := &schemaElement{physical: -1, converted: -1}
+	err := d.readStruct(func(id int16, typ byte) (err error) {
+		switch id {
+		case 1:
+			elem.physical, err = d.i32()
+		case 2:
+			elem.typeLength, err = d.i32()
+		case 3:
+			elem.repetition, err = d.i32()
+		case 4:
+			elem.name, err = d.str()
+		case 5:
+			elem.numChildren, err = d.i32()
+		case 6:
+			elem.converted, err = d.i32()
+		case 7:
+			elem.scale, err = d.i32()
+		case 10:
+			elem.logical, err = decodeLogicalType(d)
+		default:
+			err = d.skip(typ)
+		}
+		return
+	})
+	return elem, err
+}
+
+func decodeLogicalType(d *thriftDecoder) (lt logicalType, err error) {
+	err = d.readStruct(func(id int16, typ byte) error {
+		lt.id = id
+		if typ != thriftStruct {
+			return d.skip(typ)
+		}
+		return d.readStruct(func(fid int16, typ byte) (err error) {
+			switch {
+			case id == logicalDecimal && fid == 1:
+				lt.scale, err = d.i32()
+			case (id == logicalTime || id == logicalTimestamp) && fid == 1:
+				lt.utc = typ == thriftBoolTrue
+			case (id == logicalTime || id == logicalTimestamp) && fid == 2:
+				err = d.readStruct(func(uid int16, typ byte) error {
+					switch uid {
+					case 1:
+						lt.unit = time.Millisecond
+					case 2:
+						lt.unit = time.Microsecond
+					case 3:
+						lt.unit = time.Nanosecond
+					}
+					return d.skip(typ)
+				})
+			case id == logicalInteger && fid == 2:
+				lt.unsigned = typ == thriftBoolFalse
+			default:
+				err = d.skip(typ)
+			}
+			return
+		})
+	})
+	return
+}
+
+// valueKind determines how a value is formatted as a string
+type valueKind int
+
+const (
+	kindPlain valueKind = iota
+	kindUnsigned
+	kindDecimal
+	kindDate
+	kindTime
+	kindTimestamp
+	kindUUID
+)
+
+// columnSchema describes a leaf column and decodes its values as strings
+type columnSchema struct {
+	name       string
+	physical   int32
+	typeLength int
+	optional   bool
+	kind       valueKind
+	scale      int
+	unit       time.Duration
+	utc        bool
+}
+
+func newColumnSchema(elem *schemaElement) (*columnSchema, error) {
+	col := &columnSchema{
+		name:       elem.name,
+		physical:   elem.physical,
+		typeLength: int(elem.typeLength),
+		optional:   elem.repetition == repetitionOptional,
+		utc:        true,
+	}
+	if col.physical < physicalBoolean || col.physical > physicalFixedLenByteArray {
+		return nil, fmt.Errorf("column %q: unknown type %d", col.name, col.physical)
+	}
+	if col.physical == physicalFixedLenByteArray && col.typeLength <= 0 {
+		return nil, fmt.Errorf("column %q: invalid type length %d", col.name, col.typeLength)
+	}
+	switch lt := elem.logical; lt.id {
+	case logicalDecimal:
+		col.kind = kindDecimal
+		col.scale = int(lt.scale)
+	case logicalDate:
+		col.kind = kindDate
+	case logicalTime, logicalTimestamp:
+		col.kind = kindTime
+		if lt.id == logicalTimestamp {
+			col.kind = kindTimestamp
+		}
+		col.unit = lt.unit
+		col.utc = lt.utc
+	case logicalInteger:
+		if lt.unsigned {
+			col.kind = kindUnsigned
+		}
+	case logicalUUID:
+		col.kind = kindUUID
+	case 0:
+		switch c := elem.converted; {
+		case c == convertedDecimal:
+			col.kind = kindDecimal
+			col.scale = int(elem.scale)
+		case c == convertedDate:
+			col.kind = kindDate
+		case c == convertedTimeMillis, c == convertedTimeMicros:
+			col.kind = kindTime
+			col.unit = time.Millisecond
+			if c == convertedTimeMicros {
+				col.unit = time.Microsecond
+			}
+		case c == convertedTimestampMillis, c == convertedTimestampMicros:
+			col.kind = kindTimestamp
+			col.unit = time.Millisecond
+			if c == convertedTimestampMicros {
+				col.unit = time.Microsecond
+			}
+		case c >= convertedUint8 && c <= convertedUint64:
+			col.kind = kindUnsigned
+		}
+	}
+	if (col.kind == kindTime || col.kind == kindTimestamp) && col.unit == 0 {
+		col.unit = time.Millisecond
+	}
+	return col, nil
+}
+
+// size returns the number of bytes of each PLAIN encoded value, or 0 if values
+// don't have a fixed size
+func (c *columnSchema) size() int {
+	switch c.physical {
+	case physicalInt32, physicalFloat:
+		return 4
+	case physicalInt64, physicalDouble:
+		return 8
+	case physicalInt96:
+		return 12
+	case physicalFixedLenByteArray:
+		return c.typeLength
+	}
+	return 0
+}
+
+func formatFloat(f float64, bitSize int) string {
+	if abs := math.Abs(f); abs == 0 || (abs >= 1e-6 && abs < 1e21) {
+		return strconv.FormatFloat(f, 'f', -1, bitSize)
+	}
+	return strconv.FormatFloat(f, 'g', -1, bitSize)
+}
+
+func formatDecimal(v *big.Int, scale int) string {
+	s := v.String()
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	if scale > 0 {
+		if len(s) <= scale {
+			s = strings.Repeat("0", scale-len(s)+1) + s
+		}
+		s = s[:len(s)-scale] + "." + s[len(s)-scale:]
+	} else if scale < 0 && s != "0" {
+		s += strings.Repeat("0", -scale)
+	}
+	if neg {
+		return "-" + s
+	}
+	return s
+}
+
+func (c *columnSchema) formatTime(t time.Time) string {
+	if c.utc {
+		return t.Format(time.RFC3339Nano)
+	}
+	return t.Format("2006-01-02T15:04:05.999999999")
+}
+
+func (c *columnSchema) formatInt(v int64) string {
+	switch c.kind {
+	case kindUnsigned:
+		if c.physical == physicalInt32 {
+			return strconv.FormatUint(uint64(uint32(v)), 10)
+		}
+		return strconv.FormatUint(uint64(v), 10)
+	case kindDecimal:
+		return formatDecimal(big.NewInt(v), c.scale)
+	case kindDate:
+		return time.Unix(v*86400, 0).UTC().Format("2006-01-02")
+	case kindTime:
+		return time.Unix(0, 0).UTC().Add(time.Duration(v) * c.unit).Format("15:04:05.999999999")
+	case kindTimestamp:
+		var t time.Time
+		switch c.unit {
+		case time.Millisecond:
+			t = time.UnixMilli(v)
+		case time.Microsecond:
+			t = time.UnixMicro(v)
+		default:
+			t = time.Unix(0, v)
+		}
+		return c.formatTime(t.UTC())
+	}
+	return strconv.FormatInt(v, 10)
+}
+
+func (c *columnSchema) formatBytes(b []byte) string {
+	switch {
+	case c.physical == physicalInt96:
+		// legacy timestamps: nanoseconds of the day followed by the Julian day
+		nanos := int64(binary.LittleEndian.Uint64(b))
+		days := int64(binary.LittleEndian.Uint32(b[8:])) - 2440588
+		return time.Unix(days*86400, nanos).UTC().Format(time.RFC3339Nano)
+	case c.kind == kindDecimal:
+		v := new(big.Int).SetBytes(b)
+		if len(b) > 0 && b[0]&0x80 != 0 {
+			v.Sub(v, new(big.Int).Lsh(big.NewInt(1), uint(len(b)*8)))
+		}
+		return formatDecimal(v, c.scale)
+	case c.kind == kindUUID && len(b) == 16:
+		s := hex.EncodeToString(b)
+		return s[:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+	}
+	return string(b)
+}
+
+// decodePlain decodes n PLAIN encoded values
+func (c *columnSchema) decodePlain(b []byte, n int) ([]string, error) {
+	values := make([]string, n)
+	if c.physical == physicalBoolean {
+		bits, err := unpack(nil, b, 1, n)
+		if err != nil {
+			return nil, err
+		}
+		for i, v := range bits {
+			values[i] = strconv.FormatBool(v == 1)
+		}
+		return values, nil
+	}
+	if c.physical == physicalByteArray {
+		var off int
+		for i := range values {
+			if off+4 > len(b) {
+				return nil, errShortPage
+			}
+			l := int(binary.LittleEndian.Uint32(b[off:]))
+			off += 4
+			if l < 0 || l > len(b)-off {
+				return nil, errShortPage
+			}
+			values[i] = c.formatBytes(b[off : off+l])
+			off += l
+		}
+		return values, nil
+	}
+	size := c.size()
+	if len(b) < size*n {
+		return nil, errShortPage
+	}
+	for i := range values {
+		v := b[i*size : (i+1)*size]
+		switch c.physical {
+		case physicalInt32:
+			values[i] = c.formatInt(int64(int32(binary.LittleEndian.Uint32(v))))
+		case physicalInt64:
+			values[i] = c.formatInt(int64(binary.LittleEndian.Uint64(v)))
+		case physicalFloat:
+			values[i] = formatFloat(float64(math.Float32frombits(binary.LittleEndian.Uint32(v))), 32)
+		case physicalDouble:
+			values[i] = formatFloat(math.Float64frombits(binary.LittleEndian.Uint64(v)), 64)
+		default:
+			values[i] = c.formatBytes(v)
+		}
+	}
+	return values, nil
+}
+
+// decodeValues decodes the values of a data page and appends them to dst.
+// levels holds the definition level of each value of an optional column,
+// values with level 0 are nulls and are appended as empty strings.
+func (c *columnSchema) decodeValues(dst []string, b []byte, encoding int32, numValues int, levels []uint64, st *chunkState) ([]string, error) {
+	n := numValues
+	if levels != nil {
+		n = 0
+		for _, l := range levels {
+			if l == 1 {
+				n++
+			}
+		}
+	}
+	var values []string
+	var err error
+	switch encoding {
+	case encodingPlain:
+		values, err = c.decodePlain(b, n)
+	case encodingPlainDictionary, encodingRLEDictionary:
+		if st.dict == nil {
+			return nil, fmt.Errorf("dictionary page not found")
+		}
+		if n == 0 {
+			break
+		}
+		if len(b) == 0 {
+			return nil, errShortPage
+		}
+		var indices []uint64
+		indices, _, err = decodeHybrid(b[1:], int(b[0]), n)
+		if err != nil {
+			break
+		}
+		values = make([]string, n)
+		for i, idx := range indices {
+			if idx >= uint64(len(st.dict)) {
+				return nil, fmt.Errorf("dictionary index %d is out of range", idx)
+			}
+			values[i] = st.dict[idx]
+		}
+	case encodingRLE:
+		if c.physical != physicalBoolean {
+			return nil, fmt.Errorf("RLE encoding is only supported for BOOLEAN columns")
+		}
+		var bits []uint64
+		bits, _, err = decodeLevels(b, 1, n)
+		values = make([]string, len(bits))
+		for i, v := range bits {
+			values[i] = strconv.FormatBool(v == 1)
+		}
+	case encodingDeltaBinaryPacked:
+		if c.physical != physicalInt32 && c.physical != physicalInt64 {
+			return nil, fmt.Errorf("DELTA_BINARY_PACKED encoding is only supported for integer columns")
+		}
+		var ints []int64
+		ints, _, err = decodeDeltaBinaryPacked(b)
+		if err == nil && len(ints) < n {
+			err = errShortPage
+		}
+		values = make([]string, n)
+		for i := 0; i < n && err == nil; i++ {
+			v := ints[i]
+			if c.physical == physicalInt32 {
+				v = int64(int32(v))
+			}
+			values[i] = c.formatInt(v)
+		}
+	case encodingDeltaLengthByteArr, encodingDeltaByteArray:
+		var arrs [][]byte
+		if encoding == encodingDeltaByteArray {
+			arrs, err = decodeDeltaByteArray(b, n, st.prev)
+			if len(arrs) > 0 {
+				st.prev = arrs[len(arrs)-1]
+			}
+		} else {
+			arrs, err = decodeDeltaLengthByteArray(b, n)
+		}
+		values = make([]string, len(arrs))
+		for i, v := range arrs {
+			values[i] = c.formatBytes(v)
+		}
+	case encodingByteStreamSplit:
+		size := c.size()
+		if size == 0 || c.physical == physicalInt96 {
+			return nil, fmt.Errorf("BYTE_STREAM_SPLIT encoding is not supported for this column type")
+		}
+		var plain []byte
+		plain, err = decodeByteStreamSplit(b, size, n)
+		if err == nil {
+			values, err = c.decodePlain(plain, n)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported encoding %d", encoding)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if levels == nil {
+		return append(dst, values...), nil
+	}
+	var j int
+	for _, l := range levels {
+		if l == 1 {
+			dst = append(dst, values[j])
+			j++
+		} else {
+			dst = append(dst, "")
+		}
+	}
+	return dst, nil
+}
@@ -0,0 +1,188 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+// Command generate writes the Parquet files in pkg/parquet/testdata with the
+// Apache Arrow Parquet writer, so that the reader is tested against files it
+// didn't write itself. Next to each Parquet file, it writes a CSV file with
+// the rows that the reader is expected to read. Run it from
+// pkg/parquet/testdata/generate:
+//
+//	go run . ..
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/array"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+	"github.com/apache/arrow/go/v15/parquet"
+	"github.com/apache/arrow/go/v15/parquet/compress"
+	"github.com/apache/arrow/go/v15/parquet/pqarrow"
+)
+
+const numRows = 300
+
+var names = []string{"apple", "apricot", "banana", "blueberry", "cherry"}
+
+func buildRecord(schema *arrow.Schema) arrow.Record {
+	b := array.NewRecordBuilder(memory.DefaultAllocator, schema)
+	defer b.Release()
+	for i := 0; i < numRows; i++ {
+		for j, field := range schema.Fields() {
+			fb := b.Field(j)
+			if field.Nullable && i%7 == 3 {
+				fb.AppendNull()
+				continue
+			}
+			switch fb := fb.(type) {
+			case *array.Int32Builder:
+				// deltas of both signs and varying widths
+				fb.Append(int32((i%11-5)*(i%13)*1000 - i))
+			case *array.Int64Builder:
+				fb.Append(int64(i)*int64(i)*1_000_003 - 1<<40)
+			case *array.Float64Builder:
+				fb.Append(float64(i) / 4)
+			case *array.StringBuilder:
+				fb.Append(fmt.Sprintf("%s-%d", names[i%len(names)], i/len(names)))
+			case *array.BooleanBuilder:
+				fb.Append(i%3 == 0)
+			}
+		}
+	}
+	return b.NewRecord()
+}
+
+func write(dir, name string, schema *arrow.Schema, opts ...parquet.WriterProperty) error {
+	f, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	opts = append([]parquet.WriterProperty{
+		parquet.WithCreatedBy("wrgl testdata generator"),
+		parquet.WithStats(false),
+		// several pages per column chunk and several row groups per file
+		parquet.WithDataPageSize(128),
+		parquet.WithBatchSize(16),
+		parquet.WithMaxRowGroupLength(200),
+	}, opts...)
+	w, err := pqarrow.NewFileWriter(schema, f, parquet.NewWriterProperties(opts...), pqarrow.DefaultWriterProps())
+	if err != nil {
+		return err
+	}
+	rec := buildRecord(schema)
+	defer rec.Release()
+	if err := w.Write(rec); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return writeCSV(filepath.Join(dir, strings.TrimSuffix(name, ".parquet")+".csv"), rec)
+}
+
+// writeCSV writes the header and rows of rec formatted the way the reader
+// formats them
+func writeCSV(name string, rec arrow.Record) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	row := make([]string, rec.NumCols())
+	for j, field := range rec.Schema().Fields() {
+		row[j] = field.Name
+	}
+	if err := w.Write(row); err != nil {
+		return err
+	}
+	for i := 0; i < int(rec.NumRows()); i++ {
+		for j, col := range rec.Columns() {
+			if col.IsNull(i) {
+				row[j] = ""
+				continue
+			}
+			switch col := col.(type) {
+			case *array.Int32:
+				row[j] = strconv.FormatInt(int64(col.Value(i)), 10)
+			case *array.Int64:
+				row[j] = strconv.FormatInt(col.Value(i), 10)
+			case *array.Float64:
+				row[j] = strconv.FormatFloat(col.Value(i), 'f', -1, 64)
+			case *array.String:
+				row[j] = col.Value(i)
+			case *array.Boolean:
+				row[j] = strconv.FormatBool(col.Value(i))
+			}
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func main() {
+	dir := "."
+	if len(os.Args) > 1 {
+		dir = os.Args[1]
+	}
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "id", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "name", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "score", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
+		{Name: "active", Type: arrow.FixedWidthTypes.Boolean, Nullable: true},
+	}, nil)
+	deltaSchema := arrow.NewSchema([]arrow.Field{
+		{Name: "i32", Type: arrow.PrimitiveTypes.Int32},
+		{Name: "i64", Type: arrow.PrimitiveTypes.Int64, Nullable: true},
+		{Name: "length", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "prefix", Type: arrow.BinaryTypes.String},
+	}, nil)
+	for _, f := range []struct {
+		name   string
+		schema *arrow.Schema
+		opts   []parquet.WriterProperty
+	}{
+		{"dictionary_gzip.parquet", schema, []parquet.WriterProperty{
+			parquet.WithDictionaryDefault(true),
+			// falls back to PLAIN once the dictionary is full
+			parquet.WithDictionaryPageSizeLimit(256),
+			parquet.WithDataPageVersion(parquet.DataPageV1),
+			parquet.WithCompression(compress.Codecs.Gzip),
+		}},
+		{"dictionary_v2_zstd.parquet", schema, []parquet.WriterProperty{
+			parquet.WithDictionaryDefault(true),
+			parquet.WithDictionaryPageSizeLimit(256),
+			parquet.WithDataPageVersion(parquet.DataPageV2),
+			parquet.WithCompression(compress.Codecs.Zstd),
+		}},
+		{"delta_v1.parquet", deltaSchema, deltaOptions(parquet.DataPageV1, compress.Codecs.Uncompressed)},
+		{"delta_v2_zstd.parquet", deltaSchema, deltaOptions(parquet.DataPageV2, compress.Codecs.Zstd)},
+	} {
+		if err := write(dir, f.name, f.schema, f.opts...); err != nil {
+			fmt.Fprintf(os.Stderr, "error writing %s: %v\n", f.name, err)
+			os.Exit(1)
+		}
+	}
+}
+
+func deltaOptions(version parquet.DataPageVersion, codec compress.Compression) []parquet.WriterProperty {
+	return []parquet.WriterProperty{
+		parquet.WithDictionaryDefault(false),
+		parquet.WithDataPageVersion(version),
+		parquet.WithCompression(codec),
+		parquet.WithEncodingFor("i32", parquet.Encodings.DeltaBinaryPacked),
+		parquet.WithEncodingFor("i64", parquet.Encodings.DeltaBinaryPacked),
+		parquet.WithEncodingFor("length", parquet.Encodings.DeltaLengthByteArray),
+		parquet.WithEncodingFor("prefix", parquet.Encodings.DeltaByteArray),
+	}
+}
@@ -0,0 +1,267 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package parquet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Thrift compact protocol type ids
+const (
+	thriftI32    byte = 5
+	thriftI64    byte = 6
+	thriftBinary byte = 8
+	thriftList   byte = 9
+	thriftStruct byte = 12
+)
+
+// thriftEncoder encodes Parquet metadata with the Thrift compact protocol. It
+// only supports the types that Parquet metadata written by this package needs.
+type thriftEncoder struct {
+	buf []byte
+	// lastIDs is the stack of the last field id of each struct being written,
+	// field ids are encoded as deltas from the last one
+	lastIDs []int16
+}
+
+func (e *thriftEncoder) varint(v uint64) {
+	e.buf = binary.AppendUvarint(e.buf, v)
+}
+
+func (e *thriftEncoder) zigzag(v int64) {
+	e.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (e *thriftEncoder) field(id int16, typ byte) {
+	last := &e.lastIDs[len(e.lastIDs)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		e.buf = append(e.buf, byte(delta)<<4|typ)
+	} else {
+		e.buf = append(e.buf, typ)
+		e.zigzag(int64(id))
+	}
+	*last = id
+}
+
+func (e *thriftEncoder) i32(id int16, v int32) {
+	e.field(id, thriftI32)
+	e.zigzag(int64(v))
+}
+
+func (e *thriftEncoder) i64(id int16, v int64) {
+	e.field(id, thriftI64)
+	e.zigzag(v)
+}
+
+func (e *thriftEncoder) binary(id int16, s string) {
+	e.field(id, thriftBinary)
+	e.listBinary(s)
+}
+
+// list writes the header of a list field with n elements of type elemType.
+// Elements are written next with listI32, listBinary or beginStruct.
+func (e *thriftEncoder) list(id int16, elemType byte, n int) {
+	e.field(id, thriftList)
+	if n < 15 {
+		e.buf = append(e.buf, byte(n)<<4|elemType)
+	} else {
+		e.buf = append(e.buf, 0xf0|elemType)
+		e.varint(uint64(n))
+	}
+}
+
+func (e *thriftEncoder) listI32(v int32) {
+	e.zigzag(int64(v))
+}
+
+func (e *thriftEncoder) listBinary(s string) {
+	e.varint(uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// structField begins a struct field, which must be ended with endStruct
+func (e *thriftEncoder) structField(id int16) {
+	e.field(id, thriftStruct)
+	e.beginStruct()
+}
+
+func (e *thriftEncoder) beginStruct() {
+	e.lastIDs = append(e.lastIDs, 0)
+}
+
+func (e *thriftEncoder) endStruct() {
+	e.buf = append(e.buf, 0)
+	e.lastIDs = e.lastIDs[:len(e.lastIDs)-1]
+}
+
+// Thrift compact protocol type ids that are only read
+const (
+	thriftBoolTrue  byte = 1
+	thriftBoolFalse byte = 2
+	thriftByte      byte = 3
+	thriftI16       byte = 4
+	thriftDouble    byte = 7
+	thriftSet       byte = 10
+	thriftMap       byte = 11
+)
+
+var errThriftEOF = errors.New("unexpected end of thrift data")
+
+// thriftDecoder decodes Thrift compact protocol structs. Structs are read
+// field by field with readStruct, and fields that are not needed are skipped.
+type thriftDecoder struct {
+	buf []byte
+	off int
+}
+
+func (d *thriftDecoder) readByte() (byte, error) {
+	if d.off >= len(d.buf) {
+		return 0, errThriftEOF
+	}
+	b := d.buf[d.off]
+	d.off++
+	return b, nil
+}
+
+func (d *thriftDecoder) varint() (uint64, error) {
+	v, n := binary.Uvarint(d.buf[d.off:])
+	if n <= 0 {
+		return 0, errThriftEOF
+	}
+	d.off += n
+	return v, nil
+}
+
+func (d *thriftDecoder) zigzag() (int64, error) {
+	v, err := d.varint()
+	if err != nil {
+		return 0, err
+	}
+	return int64(v>>1) ^ -int64(v&1), nil
+}
+
+func (d *thriftDecoder) i32() (int32, error) {
+	v, err := d.zigzag()
+	return int32(v), err
+}
+
+func (d *thriftDecoder) binary() ([]byte, error) {
+	n, err := d.varint()
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(d.buf)-d.off) < n {
+		return nil, errThriftEOF
+	}
+	b := d.buf[d.off : d.off+int(n)]
+	d.off += int(n)
+	return b, nil
+}
+
+func (d *thriftDecoder) str() (string, error) {
+	b, err := d.binary()
+	return string(b), err
+}
+
+// listHeader returns the element type and size of a list or set
+func (d *thriftDecoder) listHeader() (elemType byte, n int, err error) {
+	b, err := d.readByte()
+	if err != nil {
+		return
+	}
+	elemType = b & 0x0f
+	n = int(b >> 4)
+	if n == 15 {
+		var v uint64
+		v, err = d.varint()
+		if err != nil {
+			return
+		}
+		if v > uint64(len(d.buf)) {
+			return 0, 0, errThriftEOF
+		}
+		n = int(v)
+	}
+	return
+}
+
+// readStruct calls fn with the id and type of each field of a struct. fn must
+// read the field's value, or skip it with d.skip.
+func (d *thriftDecoder) readStruct(fn func(id int16, typ byte) error) error {
+	var last int16
+	for {
+		b, err := d.readByte()
+		if err != nil {
+			return err
+		}
+		if b == 0 {
+			return nil
+		}
+		typ := b & 0x0f
+		id := last + int16(b>>4)
+		if b>>4 == 0 {
+			v, err := d.zigzag()
+			if err != nil {
+				return err
+			}
+			id = int16(v)
+		}
+		last = id
+		if err = fn(id, typ); err != nil {
+			return err
+		}
+	}
+}
+
+// skip skips a value of type typ
+func (d *thriftDecoder) skip(typ byte) error {
+	var err error
+	switch typ {
+	case thriftBoolTrue, thriftBoolFalse:
+	case thriftByte:
+		_, err = d.readByte()
+	case thriftI16, thriftI32, thriftI64:
+		_, err = d.varint()
+	case thriftDouble:
+		if d.off+8 > len(d.buf) {
+			return errThriftEOF
+		}
+		d.off += 8
+	case thriftBinary:
+		_, err = d.binary()
+	case thriftList, thriftSet:
+		var elemType byte
+		var n int
+		elemType, n, err = d.listHeader()
+		for i := 0; i < n && err == nil; i++ {
+			if elemType == thriftBoolTrue || elemType == thriftBoolFalse {
+				_, err = d.readByte()
+			} else {
+				err = d.skip(elemType)
+			}
+		}
+	case thriftMap:
+		var n uint64
+		n, err = d.varint()
+		if err != nil || n == 0 {
+			return err
+		}
+		var b byte
+		b, err = d.readByte()
+		for i := uint64(0); i < n && err == nil; i++ {
+			if err = d.skip(b >> 4); err == nil {
+				err = d.skip(b & 0x0f)
+			}
+		}
+	case thriftStruct:
+		err = d.readStruct(func(id int16, typ byte) error {
+			return d.skip(typ)
+		})
+	default:
+		err = fmt.Errorf("unknown thrift type %d", typ)
+	}
+	return err
+}
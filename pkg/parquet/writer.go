@@ -0,0 +1,347 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+// Package parquet reads and writes flat tables as Parquet files. Writer only
+// implements the subset of the format needed to export wrgl tables: each
+// column chunk is a single snappy-compressed data page with PLAIN encoded
+// values. Reader reads files with flat schemas written by other tools.
+package parquet
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/golang/snappy"
+)
+
+// Type is the type of a Parquet column
+type Type int
+
+const (
+	// String columns are UTF8 byte arrays. Empty values are written as empty
+	// strings.
+	String Type = iota
+	// Int64 columns are optional INT64 columns. Empty values are written as
+	// nulls.
+	Int64
+	// Double columns are optional DOUBLE columns. Empty values are written
+	// as nulls.
+	Double
+)
+
+func (t Type) String() string {
+	switch t {
+	case Int64:
+		return "INT64"
+	case Double:
+		return "DOUBLE"
+	}
+	return "UTF8"
+}
+
+// physical returns the Parquet physical type of t
+func (t Type) physical() int32 {
+	switch t {
+	case Int64:
+		return physicalInt64
+	case Double:
+		return physicalDouble
+	}
+	return physicalByteArray
+}
+
+// Column describes a column of the written file
+type Column struct {
+	Name string
+	Type Type
+}
+
+// DefaultRowGroupSize is the approximate number of bytes of encoded values
+// that are buffered before a row group is written
+const DefaultRowGroupSize = 64 << 20
+
+const magic = "PAR1"
+
+// Parquet enum values
+const (
+	physicalInt64     = 2
+	physicalDouble    = 5
+	physicalByteArray = 6
+
+	repetitionRequired = 0
+	repetitionOptional = 1
+
+	convertedUTF8 = 0
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	codecSnappy = 1
+
+	pageTypeData = 0
+)
+
+type columnChunk struct {
+	values []byte
+	// defLevels holds the definition level (0 for null, 1 for present) of
+	// each value of an optional column
+	defLevels []byte
+}
+
+type chunkMeta struct {
+	numValues        int64
+	uncompressedSize int64
+	compressedSize   int64
+	dataPageOffset   int64
+}
+
+type rowGroupMeta struct {
+	numRows int64
+	chunks  []chunkMeta
+}
+
+// Writer writes rows as a Parquet file. Rows are buffered in memory until
+// there are enough of them to write a row group.
+type Writer struct {
+	w            io.Writer
+	off          int64
+	columns      []Column
+	rowGroupSize int
+
+	chunks    []*columnChunk
+	numRows   int
+	size      int
+	totalRows int64
+	rowGroups []rowGroupMeta
+
+	// nums holds parsed values of Int64 and Double columns of the row being
+	// written
+	nums []uint64
+}
+
+func NewWriter(w io.Writer, columns []Column) *Writer {
+	chunks := make([]*columnChunk, len(columns))
+	for i := range chunks {
+		chunks[i] = &columnChunk{}
+	}
+	return &Writer{
+		w:            w,
+		columns:      columns,
+		rowGroupSize: DefaultRowGroupSize,
+		chunks:       chunks,
+	}
+}
+
+func (w *Writer) optional(i int) bool {
+	return w.columns[i].Type != String
+}
+
+// Write adds a row to the file. Values of Int64 and Double columns must be
+// either empty or valid numbers. Values are all parsed before any is added, so
+// if a value is invalid, the row is skipped and the writer stays usable.
+func (w *Writer) Write(row []string) error {
+	if len(row) != len(w.columns) {
+		return fmt.Errorf("expecting %d values, got %d", len(w.columns), len(row))
+	}
+	w.nums = w.nums[:0]
+	for i, v := range row {
+		typ := w.columns[i].Type
+		if typ == String || v == "" {
+			w.nums = append(w.nums, 0)
+			continue
+		}
+		var u uint64
+		if typ == Int64 {
+			d, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return fmt.Errorf("column %q: %q is not a valid %s", w.columns[i].Name, v, Int64)
+			}
+			u = uint64(d)
+		} else {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return fmt.Errorf("column %q: %q is not a valid %s", w.columns[i].Name, v, Double)
+			}
+			u = math.Float64bits(f)
+		}
+		w.nums = append(w.nums, u)
+	}
+	for i, v := range row {
+		c := w.chunks[i]
+		n := len(c.values)
+		switch {
+		case !w.optional(i):
+			c.values = binary.LittleEndian.AppendUint32(c.values, uint32(len(v)))
+			c.values = append(c.values, v...)
+		case v == "":
+			c.defLevels = append(c.defLevels, 0)
+		default:
+			c.defLevels = append(c.defLevels, 1)
+			c.values = binary.LittleEndian.AppendUint64(c.values, w.nums[i])
+		}
+		w.size += len(c.values) - n
+	}
+	w.numRows++
+	if w.size >= w.rowGroupSize {
+		return w.flushRowGroup()
+	}
+	return nil
+}
+
+func (w *Writer) write(b []byte) error {
+	n, err := w.w.Write(b)
+	w.off += int64(n)
+	return err
+}
+
+// encodeLevels encodes definition levels with the RLE/bit-packing hybrid
+// encoding, using only RLE runs, prefixed with the encoded length
+func encodeLevels(dst []byte, levels []byte) []byte {
+	start := len(dst)
+	dst = append(dst, 0, 0, 0, 0)
+	for i := 0; i < len(levels); {
+		j := i + 1
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		dst = binary.AppendUvarint(dst, uint64(j-i)<<1)
+		dst = append(dst, levels[i])
+		i = j
+	}
+	binary.LittleEndian.PutUint32(dst[start:], uint32(len(dst)-start-4))
+	return dst
+}
+
+func (w *Writer) flushRowGroup() error {
+	if w.off == 0 {
+		if err := w.write([]byte(magic)); err != nil {
+			return err
+		}
+	}
+	rg := rowGroupMeta{numRows: int64(w.numRows)}
+	var page []byte
+	for i, c := range w.chunks {
+		page = page[:0]
+		if w.optional(i) {
+			page = encodeLevels(page, c.defLevels)
+		}
+		page = append(page, c.values...)
+		compressed := snappy.Encode(nil, page)
+
+		e := &thriftEncoder{}
+		e.beginStruct()
+		e.i32(1, pageTypeData)
+		e.i32(2, int32(len(page)))
+		e.i32(3, int32(len(compressed)))
+		e.structField(5)
+		e.i32(1, int32(w.numRows))
+		e.i32(2, encodingPlain)
+		e.i32(3, encodingRLE)
+		e.i32(4, encodingRLE)
+		e.endStruct()
+		e.endStruct()
+
+		meta := chunkMeta{
+			numValues:        int64(w.numRows),
+			uncompressedSize: int64(len(e.buf) + len(page)),
+			compressedSize:   int64(len(e.buf) + len(compressed)),
+			dataPageOffset:   w.off,
+		}
+		if err := w.write(e.buf); err != nil {
+			return err
+		}
+		if err := w.write(compressed); err != nil {
+			return err
+		}
+		rg.chunks = append(rg.chunks, meta)
+		c.values = c.values[:0]
+		c.defLevels = c.defLevels[:0]
+	}
+	w.rowGroups = append(w.rowGroups, rg)
+	w.totalRows += int64(w.numRows)
+	w.numRows = 0
+	w.size = 0
+	return nil
+}
+
+func (w *Writer) encodeFileMetaData() []byte {
+	e := &thriftEncoder{}
+	e.beginStruct()
+	e.i32(1, 1)
+	e.list(2, thriftStruct, len(w.columns)+1)
+	e.beginStruct()
+	e.binary(4, "schema")
+	e.i32(5, int32(len(w.columns)))
+	e.endStruct()
+	for _, col := range w.columns {
+		e.beginStruct()
+		e.i32(1, col.Type.physical())
+		if col.Type == String {
+			e.i32(3, repetitionRequired)
+			e.binary(4, col.Name)
+			e.i32(6, convertedUTF8)
+		} else {
+			e.i32(3, repetitionOptional)
+			e.binary(4, col.Name)
+		}
+		e.endStruct()
+	}
+	e.i64(3, w.totalRows)
+	e.list(4, thriftStruct, len(w.rowGroups))
+	for _, rg := range w.rowGroups {
+		e.beginStruct()
+		e.list(1, thriftStruct, len(rg.chunks))
+		var totalSize int64
+		for i, c := range rg.chunks {
+			col := w.columns[i]
+			e.beginStruct()
+			e.i64(2, c.dataPageOffset)
+			e.structField(3)
+			e.i32(1, col.Type.physical())
+			if w.optional(i) {
+				e.list(2, thriftI32, 2)
+				e.listI32(encodingPlain)
+				e.listI32(encodingRLE)
+			} else {
+				e.list(2, thriftI32, 1)
+				e.listI32(encodingPlain)
+			}
+			e.list(3, thriftBinary, 1)
+			e.listBinary(col.Name)
+			e.i32(4, codecSnappy)
+			e.i64(5, c.numValues)
+			e.i64(6, c.uncompressedSize)
+			e.i64(7, c.compressedSize)
+			e.i64(9, c.dataPageOffset)
+			e.endStruct()
+			e.endStruct()
+			totalSize += c.uncompressedSize
+		}
+		e.i64(2, totalSize)
+		e.i64(3, rg.numRows)
+		e.endStruct()
+	}
+	e.binary(6, "wrgl")
+	e.endStruct()
+	return e.buf
+}
+
+// Close writes remaining rows and the file footer. It doesn't close the
+// underlying writer.
+func (w *Writer) Close() error {
+	if w.numRows > 0 {
+		if err := w.flushRowGroup(); err != nil {
+			return err
+		}
+	} else if w.off == 0 {
+		if err := w.write([]byte(magic)); err != nil {
+			return err
+		}
+	}
+	meta := w.encodeFileMetaData()
+	meta = binary.LittleEndian.AppendUint32(meta, uint32(len(meta)))
+	return w.write(append(meta, magic...))
+}
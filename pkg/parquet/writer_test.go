@@ -0,0 +1,115 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThriftEncoder(t *testing.T) {
+	e := &thriftEncoder{}
+	e.beginStruct()
+	e.i32(1, -1)
+	e.binary(4, "ab")
+	e.structField(20)
+	e.i64(1, 300)
+	e.endStruct()
+	e.list(21, thriftI32, 2)
+	e.listI32(1)
+	e.listI32(2)
+	e.endStruct()
+	assert.Equal(t, []byte{
+		// field 1: i32 -1
+		0x15, 0x01,
+		// field 4: binary "ab"
+		0x38, 0x02, 'a', 'b',
+		// field 20: struct, id doesn't fit in a delta
+		0x0c, 0x28,
+		// field 1: i64 300, end of struct
+		0x16, 0xd8, 0x04, 0x00,
+		// field 21: list of 2 i32
+		0x19, 0x25, 0x02, 0x04,
+		// end of struct
+		0x00,
+	}, e.buf)
+}
+
+func TestEncodeLevels(t *testing.T) {
+	b := encodeLevels(nil, []byte{1, 1, 1, 0, 1})
+	assert.Equal(t, []byte{6, 0, 0, 0, 0x06, 1, 0x02, 0, 0x02, 1}, b)
+	assert.Equal(t, []byte{0, 0, 0, 0}, encodeLevels(nil, nil))
+}
+
+func TestWriter(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	w := NewWriter(buf, []Column{{"id", Int64}, {"name", String}, {"price", Double}})
+	w.rowGroupSize = 40
+	require.NoError(t, w.Write([]string{"1", "a", "1.5"}))
+	require.NoError(t, w.Write([]string{"", "bc", ""}))
+	assert.Len(t, w.rowGroups, 0)
+	require.NoError(t, w.Write([]string{"3", "d", "2"}))
+	assert.Len(t, w.rowGroups, 1)
+	require.NoError(t, w.Write([]string{"4", "", "-1e3"}))
+	assert.EqualError(t, w.Write([]string{"1.5", "a", "1"}), `column "id": "1.5" is not a valid INT64`)
+	assert.EqualError(t, w.Write([]string{"1", "a", "x"}), `column "price": "x" is not a valid DOUBLE`)
+	assert.EqualError(t, w.Write([]string{"1", "a"}), `expecting 3 values, got 2`)
+	require.NoError(t, w.Close())
+	assert.Len(t, w.rowGroups, 2)
+	assert.Equal(t, int64(4), w.totalRows)
+
+	b := buf.Bytes()
+	assert.Equal(t, []byte(magic), b[:4])
+	assert.Equal(t, []byte(magic), b[len(b)-4:])
+	metaLen := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+	assert.Equal(t, w.encodeFileMetaData(), b[len(b)-8-metaLen:len(b)-8])
+
+	// the first column chunk holds ids of the first 3 rows
+	chunk := w.rowGroups[0].chunks[0]
+	assert.Equal(t, int64(4), chunk.dataPageOffset)
+	assert.Equal(t, int64(3), chunk.numValues)
+	body := encodeLevels(nil, []byte{1, 0, 1})
+	body = binary.LittleEndian.AppendUint64(body, 1)
+	body = binary.LittleEndian.AppendUint64(body, 3)
+	page := b[chunk.dataPageOffset : chunk.dataPageOffset+chunk.compressedSize]
+	assert.True(t, bytes.HasSuffix(page, snappy.Encode(nil, body)))
+	assert.Equal(t, chunk.compressedSize-int64(len(snappy.Encode(nil, body))), chunk.uncompressedSize-int64(len(body)))
+}
+
+func TestWriterInvalidRow(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	w := NewWriter(buf, []Column{{"id", Int64}, {"name", String}, {"price", Double}})
+	require.NoError(t, w.Write([]string{"1", "a", "1.5"}))
+	// id and name are valid but price isn't, no value of the row is written
+	assert.EqualError(t, w.Write([]string{"2", "b", "x"}), `column "price": "x" is not a valid DOUBLE`)
+	require.NoError(t, w.Write([]string{"3", "c", ""}))
+	require.NoError(t, w.Close())
+	assert.Equal(t, int64(2), w.totalRows)
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	for _, row := range [][]string{{"1", "a", "1.5"}, {"3", "c", ""}} {
+		sl, err := r.Read()
+		require.NoError(t, err)
+		assert.Equal(t, row, sl)
+	}
+	_, err = r.Read()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestWriterEmpty(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	w := NewWriter(buf, []Column{{"id", Int64}})
+	require.NoError(t, w.Close())
+	b := buf.Bytes()
+	assert.Equal(t, []byte(magic), b[:4])
+	assert.Equal(t, []byte(magic), b[len(b)-4:])
+	assert.Len(t, w.rowGroups, 0)
+}
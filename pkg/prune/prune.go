@@ -105,12 +105,40 @@ func pruneTables(db objects.Store, survivingCommits [][]byte, allBlockKeys, allB
 	}
 }
 
+func pruneTags(db objects.Store, rs ref.Store) runProgressFunc {
+	return func(pbarAdd func()) (err error) {
+		refMap, err := ref.ListAllRefs(rs)
+		if err != nil {
+			return
+		}
+		referenced := map[string]struct{}{}
+		for _, sum := range refMap {
+			referenced[string(sum)] = struct{}{}
+		}
+		tagKeys, err := objects.GetAllTagKeys(db)
+		if err != nil {
+			return
+		}
+		for _, sum := range tagKeys {
+			if _, ok := referenced[string(sum)]; ok {
+				continue
+			}
+			if err = objects.DeleteTag(db, sum); err != nil {
+				return
+			}
+			pbarAdd()
+		}
+		return nil
+	}
+}
+
 type PruneOptions struct {
 	FindCommitsPbar       func() pbar.Bar
 	PruneTablesPbar       func() pbar.Bar
 	PruneBlocksPbar       func() pbar.Bar
 	PruneBlockIndicesPbar func() pbar.Bar
 	PruneCommitsPbar      func() pbar.Bar
+	PruneTagsPbar         func() pbar.Bar
 }
 
 type runProgressFunc func(pbarAdd func()) (err error)
@@ -132,6 +160,10 @@ func Prune(db objects.Store, rs ref.Store, opts *PruneOptions) (err error) {
 	if opts == nil {
 		opts = &PruneOptions{}
 	}
+	// remove tags that are no longer referenced
+	if err = runWithPbar(opts.PruneTagsPbar, pruneTags(db, rs)); err != nil {
+		return err
+	}
 	var commitsToRemove, survivingCommits [][]byte
 	if err = runWithPbar(opts.FindCommitsPbar, func(pbarAdd func()) (err error) {
 		commitsToRemove, survivingCommits, err = findCommitsToRemove(db, rs, pbarAdd)
@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package query
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/wrgl/wrgl/pkg/dprof"
+	"github.com/wrgl/wrgl/pkg/rowfilter"
+)
+
+// aggregator accumulates values of a single column for an aggregate function
+type aggregator struct {
+	item *selectItem
+	// col is the index of the input column, -1 for COUNT(*)
+	col   int
+	count int
+	sum   float64
+	best  string
+}
+
+func (a *aggregator) reset() {
+	a.count = 0
+	a.sum = 0
+	a.best = ""
+}
+
+func (a *aggregator) add(row []string) error {
+	if a.col < 0 {
+		a.count++
+		return nil
+	}
+	v := row[a.col]
+	if v == "" {
+		return nil
+	}
+	switch a.item.fn {
+	case "SUM", "AVG":
+		f, ok := dprof.ParseNumber(v)
+		if !ok {
+			return fmt.Errorf("%s: %q is not a number", a.item.name(), v)
+		}
+		a.sum += f
+	case "MIN":
+		if a.count == 0 || rowfilter.Compare(v, a.best) < 0 {
+			a.best = v
+		}
+	case "MAX":
+		if a.count == 0 || rowfilter.Compare(v, a.best) > 0 {
+			a.best = v
+		}
+	}
+	a.count++
+	return nil
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func (a *aggregator) result() string {
+	switch a.item.fn {
+	case "COUNT":
+		return strconv.Itoa(a.count)
+	case "SUM":
+		if a.count == 0 {
+			return ""
+		}
+		return formatNumber(a.sum)
+	case "AVG":
+		if a.count == 0 {
+			return ""
+		}
+		return formatNumber(a.sum / float64(a.count))
+	}
+	return a.best
+}
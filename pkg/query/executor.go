@@ -0,0 +1,506 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package query
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/wrgl/wrgl/pkg/objects"
+	"github.com/wrgl/wrgl/pkg/rowfilter"
+)
+
+// maxLookupKeys is the maximum number of primary key lookups that a WHERE
+// condition is turned into before falling back to scanning the table
+const maxLookupKeys = 10000
+
+var errLimitReached = errors.New("limit reached")
+
+type orderKey struct {
+	// col is the index of the output column
+	col  int
+	desc bool
+}
+
+// Executor runs a statement against the tables of the commits it reads
+// from. Rows of the FROM table and the JOIN table are combined into a single
+// row: columns of the FROM table followed by columns of the JOIN table.
+type Executor struct {
+	db      objects.Store
+	stmt    *Statement
+	tables  []*objects.Table
+	aliases []string
+	offsets []int
+	width   int
+	on      *rowfilter.Filter
+	where   *rowfilter.Filter
+
+	columns []string
+	// sourceCols holds the index in the combined row of each output column,
+	// or -1 for aggregates, followed by indices of columns that are only
+	// used in ORDER BY
+	sourceCols []int
+
+	aggregate bool
+	groupBy   []int
+	// inputs are indices in the combined row of columns needed to compute
+	// groups and aggregates, starting with columns in groupBy
+	inputs      []int
+	aggregators []*aggregator
+	// outputs maps each output column to either a position in inputs or,
+	// for negative values -(i+1), the i-th aggregator
+	outputs []int
+
+	orderBy []orderKey
+}
+
+// NewExecutor binds stmt to tables, which are tables of the commits returned
+// by stmt.Commits(), in the same order.
+func NewExecutor(db objects.Store, stmt *Statement, tables []*objects.Table) (*Executor, error) {
+	if len(tables) != len(stmt.Commits()) {
+		return nil, fmt.Errorf("expecting %d tables, got %d", len(stmt.Commits()), len(tables))
+	}
+	e := &Executor{
+		db:      db,
+		stmt:    stmt,
+		tables:  tables,
+		aliases: []string{stmt.from.alias},
+	}
+	if stmt.join != nil {
+		if stmt.join.alias == stmt.from.alias {
+			return nil, fmt.Errorf("both tables are named %q, use AS to give one of them a different name", stmt.from.alias)
+		}
+		e.aliases = append(e.aliases, stmt.join.alias)
+	}
+	for _, tbl := range tables {
+		e.offsets = append(e.offsets, e.width)
+		e.width += len(tbl.Columns)
+	}
+	var err error
+	if stmt.on != "" {
+		if e.on, err = rowfilter.Parse(stmt.on, e.resolve); err != nil {
+			return nil, fmt.Errorf("error parsing ON condition: %v", err)
+		}
+	}
+	if stmt.where != "" {
+		if e.where, err = rowfilter.Parse(stmt.where, e.resolve); err != nil {
+			return nil, fmt.Errorf("error parsing WHERE condition: %v", err)
+		}
+	}
+	if err = e.bindItems(); err != nil {
+		return nil, err
+	}
+	if err = e.bindOrderBy(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Columns returns names of output columns
+func (e *Executor) Columns() []string {
+	return e.columns
+}
+
+// resolve returns the index of a column in the combined row. Columns can be
+// qualified with a table alias, e.g. "a.name".
+func (e *Executor) resolve(name string) (int, error) {
+	for i, alias := range e.aliases {
+		if col := strings.TrimPrefix(name, alias+"."); col != name {
+			for j, c := range e.tables[i].Columns {
+				if c == col {
+					return e.offsets[i] + j, nil
+				}
+			}
+		}
+	}
+	idx := -1
+	for i, tbl := range e.tables {
+		for j, c := range tbl.Columns {
+			if c == name {
+				if idx >= 0 {
+					return 0, fmt.Errorf("column %q is ambiguous, qualify it with a table name", name)
+				}
+				idx = e.offsets[i] + j
+			}
+		}
+	}
+	if idx < 0 {
+		return 0, fmt.Errorf("unknown column %q", name)
+	}
+	return idx, nil
+}
+
+// outputName returns the name of the output column for a column item, which
+// is either its alias or the column name without table alias
+func (e *Executor) outputName(item *selectItem, idx int) string {
+	if item.alias != "" {
+		return item.alias
+	}
+	for i := len(e.offsets) - 1; i >= 0; i-- {
+		if idx >= e.offsets[i] {
+			return e.tables[i].Columns[idx-e.offsets[i]]
+		}
+	}
+	return item.column
+}
+
+func (e *Executor) bindItems() error {
+	for _, item := range e.stmt.items {
+		if item.fn != "" {
+			e.aggregate = true
+		}
+	}
+	if len(e.stmt.groupBy) > 0 {
+		e.aggregate = true
+	}
+	if !e.aggregate {
+		for _, item := range e.stmt.items {
+			if !item.star {
+				idx, err := e.resolve(item.column)
+				if err != nil {
+					return err
+				}
+				e.columns = append(e.columns, e.outputName(item, idx))
+				e.sourceCols = append(e.sourceCols, idx)
+				continue
+			}
+			found := false
+			for i, tbl := range e.tables {
+				if item.table != "" && item.table != e.aliases[i] {
+					continue
+				}
+				found = true
+				for j, c := range tbl.Columns {
+					e.columns = append(e.columns, c)
+					e.sourceCols = append(e.sourceCols, e.offsets[i]+j)
+				}
+			}
+			if !found {
+				return fmt.Errorf("unknown table %q", item.table)
+			}
+		}
+		return nil
+	}
+
+	for _, name := range e.stmt.groupBy {
+		idx, err := e.resolve(name)
+		if err != nil {
+			return err
+		}
+		e.groupBy = append(e.groupBy, idx)
+	}
+	e.inputs = append(e.inputs, e.groupBy...)
+	for _, item := range e.stmt.items {
+		if item.star {
+			return fmt.Errorf("* can't be used together with GROUP BY or aggregate functions")
+		}
+		if item.fn == "" {
+			idx, err := e.resolve(item.column)
+			if err != nil {
+				return err
+			}
+			e.columns = append(e.columns, e.outputName(item, idx))
+			pos := -1
+			for i, u := range e.groupBy {
+				if u == idx {
+					pos = i
+					break
+				}
+			}
+			if pos < 0 {
+				return fmt.Errorf("column %q must appear in GROUP BY or be used in an aggregate function", item.column)
+			}
+			e.outputs = append(e.outputs, pos)
+			e.sourceCols = append(e.sourceCols, idx)
+			continue
+		}
+		e.columns = append(e.columns, item.name())
+		agg := &aggregator{item: item, col: -1}
+		if item.column != "" {
+			idx, err := e.resolve(item.column)
+			if err != nil {
+				return err
+			}
+			agg.col = len(e.inputs)
+			e.inputs = append(e.inputs, idx)
+		}
+		e.aggregators = append(e.aggregators, agg)
+		e.outputs = append(e.outputs, -len(e.aggregators))
+		e.sourceCols = append(e.sourceCols, -1)
+	}
+	return nil
+}
+
+func (e *Executor) bindOrderBy() error {
+	for _, item := range e.stmt.orderBy {
+		col := -1
+		if item.position > 0 {
+			if item.position > len(e.columns) {
+				return fmt.Errorf("ORDER BY position %d is out of range", item.position)
+			}
+			col = item.position - 1
+		} else {
+			for i, name := range e.columns {
+				if name == item.name {
+					col = i
+					break
+				}
+			}
+			if col < 0 {
+				idx, err := e.resolve(item.name)
+				if err != nil {
+					return err
+				}
+				for i, u := range e.sourceCols {
+					if u == idx {
+						col = i
+						break
+					}
+				}
+			}
+			if col < 0 {
+				if e.aggregate {
+					return fmt.Errorf("ORDER BY column %q must appear in the select list", item.name)
+				}
+				// sort by a column that isn't selected, it is dropped
+				// from rows before they are emitted
+				idx, _ := e.resolve(item.name)
+				col = len(e.sourceCols)
+				e.sourceCols = append(e.sourceCols, idx)
+			}
+		}
+		e.orderBy = append(e.orderBy, orderKey{col: col, desc: item.desc})
+	}
+	return nil
+}
+
+// Run runs the statement, calling emit with each output row
+func (e *Executor) Run(emit func(row []string) error) (err error) {
+	limit := e.stmt.limit
+	if limit == 0 {
+		return nil
+	}
+	count := 0
+	emitLimited := func(row []string) error {
+		if err := emit(row[:len(e.columns)]); err != nil {
+			return err
+		}
+		count++
+		if limit > 0 && count >= limit {
+			return errLimitReached
+		}
+		return nil
+	}
+	output := emitLimited
+	var sorter *externalSorter
+	if len(e.orderBy) > 0 {
+		sorter, err = newExternalSorter()
+		if err != nil {
+			return err
+		}
+		defer sorter.close()
+		var key []byte
+		output = func(row []string) error {
+			key = key[:0]
+			for _, k := range e.orderBy {
+				key = encodeSortValue(key, row[k.col], k.desc)
+			}
+			return sorter.add(key, row)
+		}
+	}
+
+	consume := func(row []string) error {
+		out := make([]string, len(e.sourceCols))
+		for i, u := range e.sourceCols {
+			out[i] = row[u]
+		}
+		return output(out)
+	}
+	var finish func() error
+	if e.aggregate {
+		consume, finish, err = e.aggregateRows(output)
+		if err != nil {
+			return err
+		}
+	}
+	err = e.scan(func(row []string) error {
+		if e.where != nil && !e.where.Match(row) {
+			return nil
+		}
+		return consume(row)
+	})
+	if err == nil && finish != nil {
+		err = finish()
+	}
+	if err == nil && sorter != nil {
+		err = sorter.each(func(key string, row []string) error {
+			return emitLimited(row)
+		})
+	}
+	if errors.Is(err, errLimitReached) {
+		return nil
+	}
+	return err
+}
+
+// aggregateRows returns functions to consume combined rows and to output
+// aggregated rows once all rows are consumed. Rows are grouped by sorting
+// them on group values with the external sorter.
+func (e *Executor) aggregateRows(output func(row []string) error) (consume func(row []string) error, finish func() error, err error) {
+	project := func(row []string) []string {
+		in := make([]string, len(e.inputs))
+		for i, u := range e.inputs {
+			in[i] = row[u]
+		}
+		return in
+	}
+	flush := func(groupRow []string) error {
+		out := make([]string, len(e.outputs))
+		for i, v := range e.outputs {
+			if v >= 0 {
+				out[i] = groupRow[v]
+			} else {
+				out[i] = e.aggregators[-v-1].result()
+			}
+		}
+		for _, agg := range e.aggregators {
+			agg.reset()
+		}
+		return output(out)
+	}
+	add := func(in []string) error {
+		for _, agg := range e.aggregators {
+			if err := agg.add(in); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if len(e.groupBy) == 0 {
+		var last []string
+		return func(row []string) error {
+				last = project(row)
+				return add(last)
+			}, func() error {
+				return flush(last)
+			}, nil
+	}
+	sorter, err := newExternalSorter()
+	if err != nil {
+		return nil, nil, err
+	}
+	var key []byte
+	return func(row []string) error {
+			in := project(row)
+			key = key[:0]
+			for i := range e.groupBy {
+				key = encodeSortValue(key, in[i], false)
+			}
+			return sorter.add(key, in)
+		}, func() error {
+			defer sorter.close()
+			var prevKey string
+			var prevRow []string
+			err := sorter.each(func(key string, row []string) error {
+				if prevRow != nil && key != prevKey {
+					if err := flush(prevRow); err != nil {
+						return err
+					}
+				}
+				prevKey, prevRow = key, row
+				return add(row)
+			})
+			if err != nil || prevRow == nil {
+				return err
+			}
+			return flush(prevRow)
+		}, nil
+}
+
+// scan calls fn with each combined row
+func (e *Executor) scan(fn func(row []string) error) error {
+	if e.stmt.join == nil {
+		return e.scanFrom(fn)
+	}
+	j, err := e.newJoiner()
+	if err != nil {
+		return err
+	}
+	return e.scanFrom(func(row []string) error {
+		return j.join(row, fn)
+	})
+}
+
+// lookupKeys returns primary key values of FROM table rows that the WHERE
+// condition restricts the query to, or nil if the table should be scanned
+func (e *Executor) lookupKeys() [][]string {
+	tbl := e.tables[0]
+	if e.where == nil || len(tbl.PK) == 0 {
+		return nil
+	}
+	m := e.where.ColumnValues()
+	keys := [][]string{{}}
+	for _, u := range tbl.PK {
+		values, ok := m[int(u)]
+		if !ok {
+			return nil
+		}
+		seen := map[string]struct{}{}
+		next := make([][]string, 0, len(keys)*len(values))
+		for _, v := range values {
+			if _, ok := seen[v]; ok {
+				continue
+			}
+			seen[v] = struct{}{}
+			for _, k := range keys {
+				next = append(next, append(append(make([]string, 0, len(tbl.PK)), k...), v))
+			}
+		}
+		if len(next) > maxLookupKeys {
+			return nil
+		}
+		keys = next
+	}
+	return keys
+}
+
+// scanFrom calls fn with each row of the FROM table. Rows are looked up by
+// primary key if possible, otherwise blocks are read one by one.
+func (e *Executor) scanFrom(fn func(row []string) error) error {
+	tbl := e.tables[0]
+	if keys := e.lookupKeys(); keys != nil {
+		tblIdx, err := objects.GetTableIndex(e.db, tbl.Sum)
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			row, err := objects.LookupRow(e.db, tbl, tblIdx, k)
+			if err != nil {
+				return err
+			}
+			if row != nil {
+				if err = fn(row); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	var buf []byte
+	var blk [][]string
+	var err error
+	for _, sum := range tbl.Blocks {
+		blk, buf, err = objects.GetBlock(e.db, buf, sum)
+		if err != nil {
+			return err
+		}
+		for _, row := range blk {
+			if err = fn(row); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package query
+
+import (
+	"strconv"
+
+	"github.com/wrgl/wrgl/pkg/dprof"
+	"github.com/wrgl/wrgl/pkg/objects"
+)
+
+// joiner finds rows of the JOIN table that match each row of the FROM table.
+// If the ON condition pins every primary key column of the JOIN table to a
+// column of the FROM table, rows are looked up by primary key. Otherwise rows
+// of the JOIN table are loaded into memory, hashed by columns that the ON
+// condition requires to be equal if there are any.
+//
+// The ON condition compares numbers numerically, so numbers are hashed in
+// canonical form. Primary key lookups find keys exactly as written, like
+// "wrgl get" does, so a FROM table row with 10 doesn't find a JOIN table row
+// with primary key 010.
+type joiner struct {
+	e      *Executor
+	tbl    *objects.Table
+	offset int
+
+	// leftCols and rightCols are pairs of columns that must be equal, indices
+	// are in the FROM table and the JOIN table respectively
+	leftCols, rightCols []int
+
+	// lookup is true if rows are looked up by primary key using tblIdx
+	lookup bool
+	tblIdx [][]string
+	hashed map[string][][]string
+	enc    *objects.StrListEncoder
+	keyBuf []string
+}
+
+func (e *Executor) newJoiner() (*joiner, error) {
+	j := &joiner{
+		e:      e,
+		tbl:    e.tables[1],
+		offset: e.offsets[1],
+		enc:    objects.NewStrListEncoder(true),
+	}
+	for _, pair := range e.on.ColumnPairs() {
+		l, r := pair[0], pair[1]
+		if l >= j.offset {
+			l, r = r, l
+		}
+		if l < j.offset && r >= j.offset {
+			j.leftCols = append(j.leftCols, l)
+			j.rightCols = append(j.rightCols, r-j.offset)
+		}
+	}
+	if cols := j.pkLeftCols(); cols != nil {
+		j.leftCols = cols
+		j.rightCols = nil
+		j.lookup = true
+		var err error
+		j.tblIdx, err = objects.GetTableIndex(e.db, j.tbl.Sum)
+		if err != nil {
+			return nil, err
+		}
+		return j, nil
+	}
+	j.hashed = map[string][][]string{}
+	var buf []byte
+	var blk [][]string
+	var err error
+	for _, sum := range j.tbl.Blocks {
+		blk, buf, err = objects.GetBlock(e.db, buf, sum)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range blk {
+			k := j.key(row, j.rightCols)
+			j.hashed[k] = append(j.hashed[k], row)
+		}
+	}
+	return j, nil
+}
+
+// pkLeftCols returns columns of the FROM table that are paired with each
+// primary key column of the JOIN table, or nil if some aren't paired
+func (j *joiner) pkLeftCols() []int {
+	if len(j.tbl.PK) == 0 {
+		return nil
+	}
+	var cols []int
+	for _, u := range j.tbl.PK {
+		found := false
+		for i, r := range j.rightCols {
+			if r == int(u) {
+				cols = append(cols, j.leftCols[i])
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil
+		}
+	}
+	return cols
+}
+
+// key returns the hash key of values of cols in row, with numbers written in
+// canonical form
+func (j *joiner) key(row []string, cols []int) string {
+	j.keyBuf = j.keyBuf[:0]
+	for _, u := range cols {
+		v := row[u]
+		if f, ok := dprof.ParseNumber(v); ok {
+			if f == 0 {
+				// -0 equals 0
+				f = 0
+			}
+			v = strconv.FormatFloat(f, 'g', -1, 64)
+		}
+		j.keyBuf = append(j.keyBuf, v)
+	}
+	return string(j.enc.Encode(j.keyBuf))
+}
+
+// join calls fn with each combined row of left and a matching row of the JOIN
+// table. For a left join, left is combined with empty values if there's no
+// match.
+func (j *joiner) join(left []string, fn func(row []string) error) error {
+	var candidates [][]string
+	if j.lookup {
+		j.keyBuf = j.keyBuf[:0]
+		for _, u := range j.leftCols {
+			j.keyBuf = append(j.keyBuf, left[u])
+		}
+		row, err := objects.LookupRow(j.e.db, j.tbl, j.tblIdx, j.keyBuf)
+		if err != nil {
+			return err
+		}
+		if row != nil {
+			candidates = [][]string{row}
+		}
+	} else {
+		candidates = j.hashed[j.key(left, j.leftCols)]
+	}
+	matched := false
+	for _, right := range candidates {
+		row := make([]string, 0, j.e.width)
+		row = append(append(row, left...), right...)
+		if !j.e.on.Match(row) {
+			continue
+		}
+		matched = true
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	if !matched && j.e.stmt.leftJoin {
+		row := make([]string, j.e.width)
+		copy(row, left)
+		return fn(row)
+	}
+	return nil
+}
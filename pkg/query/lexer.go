@@ -0,0 +1,115 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package query
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenType int
+
+const (
+	tokEOF tokenType = iota
+	tokWord
+	tokQuoted
+	tokString
+	tokPunct
+)
+
+// token is a lexical token of a query. Only as much as needed to find clause
+// boundaries and parse select items is recognized here, conditions are
+// handed to package rowfilter as text.
+type token struct {
+	typ        tokenType
+	val        string
+	start, end int
+}
+
+func (t token) String() string {
+	if t.typ == tokEOF {
+		return "end of query"
+	}
+	return fmt.Sprintf("%q", t.val)
+}
+
+func (t token) isKeyword(kw string) bool {
+	return t.typ == tokWord && strings.EqualFold(t.val, kw)
+}
+
+func (t token) isPunct(p string) bool {
+	return t.typ == tokPunct && t.val == p
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_.-~^/:@", r)
+}
+
+func tokenize(s string) ([]token, error) {
+	var toks []token
+	runes := []rune(s)
+	// byte offsets of each rune so that tokens can be mapped back to s
+	offsets := make([]int, len(runes)+1)
+	off := 0
+	for i, r := range runes {
+		offsets[i] = off
+		off += len(string(r))
+	}
+	offsets[len(runes)] = off
+	n := len(runes)
+	for i := 0; i < n; {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '\'' || r == '"' || r == '`':
+			start := i
+			sb := &strings.Builder{}
+			i++
+			closed := false
+			for i < n {
+				if runes[i] == r {
+					if i+1 < n && runes[i+1] == r {
+						sb.WriteRune(r)
+						i += 2
+						continue
+					}
+					closed = true
+					i++
+					break
+				}
+				sb.WriteRune(runes[i])
+				i++
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated quote at position %d", start)
+			}
+			typ := tokQuoted
+			if r == '\'' {
+				typ = tokString
+			}
+			toks = append(toks, token{typ: typ, val: sb.String(), start: offsets[start], end: offsets[i]})
+		case isWordRune(r):
+			start := i
+			for i < n && isWordRune(runes[i]) {
+				i++
+			}
+			// "alias.*" is a single word
+			if i < n && runes[i] == '*' && runes[i-1] == '.' {
+				i++
+			}
+			toks = append(toks, token{typ: tokWord, val: string(runes[start:i]), start: offsets[start], end: offsets[i]})
+		default:
+			start := i
+			i++
+			// keep multi-character operators together
+			if i < n && strings.ContainsRune("<>!=", r) && strings.ContainsRune("<>=", runes[i]) {
+				i++
+			}
+			toks = append(toks, token{typ: tokPunct, val: string(runes[start:i]), start: offsets[start], end: offsets[i]})
+		}
+	}
+	return append(toks, token{typ: tokEOF, start: len(s), end: len(s)}), nil
+}
@@ -0,0 +1,377 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var aggregateFuncs = []string{"COUNT", "SUM", "AVG", "MIN", "MAX"}
+
+// clauseKeywords end a condition and can't be used as aliases without quotes
+var clauseKeywords = []string{"SELECT", "FROM", "AS", "JOIN", "INNER", "LEFT", "OUTER", "ON", "WHERE", "GROUP", "ORDER", "BY", "LIMIT"}
+
+type selectItem struct {
+	// star is true for "*" and "alias.*"
+	star  bool
+	table string
+
+	// fn is the upper-cased aggregate function name, empty if this item is
+	// not an aggregate
+	fn string
+
+	// column is the column name as written, empty for "*" and "COUNT(*)"
+	column string
+	alias  string
+}
+
+// name returns the output column name of a non-star item
+func (it *selectItem) name() string {
+	if it.alias != "" {
+		return it.alias
+	}
+	if it.fn != "" {
+		if it.column == "" {
+			return it.fn + "(*)"
+		}
+		return fmt.Sprintf("%s(%s)", it.fn, it.column)
+	}
+	return it.column
+}
+
+type source struct {
+	commit string
+	alias  string
+}
+
+type orderItem struct {
+	name string
+	// position is the 1-based position of the output column, 0 if name is
+	// used instead
+	position int
+	desc     bool
+}
+
+// Statement is a parsed SELECT statement
+type Statement struct {
+	items    []*selectItem
+	from     *source
+	join     *source
+	leftJoin bool
+	on       string
+	where    string
+	groupBy  []string
+	orderBy  []*orderItem
+	// limit is -1 if there's no LIMIT clause
+	limit int
+}
+
+// Commits returns names of commits that the statement reads from, as
+// written in the query. The first one is from the FROM clause, the second one
+// (if any) is from the JOIN clause.
+func (s *Statement) Commits() []string {
+	if s.join != nil {
+		return []string{s.from.commit, s.join.commit}
+	}
+	return []string{s.from.commit}
+}
+
+type parser struct {
+	sql  string
+	toks []token
+	off  int
+}
+
+// Parse parses a SELECT statement of the form:
+//
+//	SELECT items FROM commit [AS alias]
+//	[[INNER | LEFT [OUTER]] JOIN commit [AS alias] ON condition]
+//	[WHERE condition]
+//	[GROUP BY column, ...]
+//	[ORDER BY column [ASC | DESC], ...]
+//	[LIMIT n]
+func Parse(sql string) (*Statement, error) {
+	toks, err := tokenize(sql)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{sql: sql, toks: toks}
+	return p.parseStatement()
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.off]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.off]
+	if t.typ != tokEOF {
+		p.off++
+	}
+	return t
+}
+
+func (p *parser) unexpected(t token, expected string) error {
+	return fmt.Errorf("expected %s but got %s at position %d", expected, t, t.start)
+}
+
+func (p *parser) expectKeyword(kw string) error {
+	if t := p.next(); !t.isKeyword(kw) {
+		return p.unexpected(t, kw)
+	}
+	return nil
+}
+
+func isClauseKeyword(t token) bool {
+	for _, kw := range clauseKeywords {
+		if t.isKeyword(kw) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseName parses a column or alias name, which is either a word or a
+// double-quoted string
+func (p *parser) parseName(what string) (string, error) {
+	t := p.next()
+	if t.typ == tokQuoted || (t.typ == tokWord && !isClauseKeyword(t)) {
+		return t.val, nil
+	}
+	return "", p.unexpected(t, what)
+}
+
+// parseAlias parses an optional "[AS] alias"
+func (p *parser) parseAlias() (string, error) {
+	if p.peek().isKeyword("AS") {
+		p.next()
+		return p.parseName("alias")
+	}
+	if t := p.peek(); t.typ == tokQuoted || (t.typ == tokWord && !isClauseKeyword(t)) {
+		p.next()
+		return t.val, nil
+	}
+	return "", nil
+}
+
+func (p *parser) parseStatement() (*Statement, error) {
+	stmt := &Statement{limit: -1}
+	if err := p.expectKeyword("SELECT"); err != nil {
+		return nil, err
+	}
+	for {
+		item, err := p.parseSelectItem()
+		if err != nil {
+			return nil, err
+		}
+		stmt.items = append(stmt.items, item)
+		if !p.peek().isPunct(",") {
+			break
+		}
+		p.next()
+	}
+	if err := p.expectKeyword("FROM"); err != nil {
+		return nil, err
+	}
+	var err error
+	if stmt.from, err = p.parseSource(); err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.isKeyword("JOIN") || t.isKeyword("INNER") || t.isKeyword("LEFT") {
+		p.next()
+		if t.isKeyword("LEFT") {
+			stmt.leftJoin = true
+			if p.peek().isKeyword("OUTER") {
+				p.next()
+			}
+		}
+		if !t.isKeyword("JOIN") {
+			if err = p.expectKeyword("JOIN"); err != nil {
+				return nil, err
+			}
+		}
+		if stmt.join, err = p.parseSource(); err != nil {
+			return nil, err
+		}
+		if err = p.expectKeyword("ON"); err != nil {
+			return nil, err
+		}
+		if stmt.on, err = p.parseCondition("ON"); err != nil {
+			return nil, err
+		}
+	}
+	if p.peek().isKeyword("WHERE") {
+		p.next()
+		if stmt.where, err = p.parseCondition("WHERE"); err != nil {
+			return nil, err
+		}
+	}
+	if p.peek().isKeyword("GROUP") {
+		p.next()
+		if err = p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		for {
+			name, err := p.parseName("column name")
+			if err != nil {
+				return nil, err
+			}
+			stmt.groupBy = append(stmt.groupBy, name)
+			if !p.peek().isPunct(",") {
+				break
+			}
+			p.next()
+		}
+	}
+	if p.peek().isKeyword("ORDER") {
+		p.next()
+		if err = p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		for {
+			item, err := p.parseOrderItem()
+			if err != nil {
+				return nil, err
+			}
+			stmt.orderBy = append(stmt.orderBy, item)
+			if !p.peek().isPunct(",") {
+				break
+			}
+			p.next()
+		}
+	}
+	if p.peek().isKeyword("LIMIT") {
+		p.next()
+		t := p.next()
+		n, err := strconv.Atoi(t.val)
+		if t.typ != tokWord || err != nil || n < 0 {
+			return nil, p.unexpected(t, "a non-negative integer")
+		}
+		stmt.limit = n
+	}
+	if p.peek().isPunct(";") {
+		p.next()
+	}
+	if t := p.peek(); t.typ != tokEOF {
+		return nil, p.unexpected(t, "end of query")
+	}
+	return stmt, nil
+}
+
+func (p *parser) parseSelectItem() (*selectItem, error) {
+	t := p.peek()
+	if t.isPunct("*") {
+		p.next()
+		return &selectItem{star: true}, nil
+	}
+	if t.typ == tokWord && strings.HasSuffix(t.val, ".*") {
+		p.next()
+		return &selectItem{star: true, table: strings.TrimSuffix(t.val, ".*")}, nil
+	}
+	item := &selectItem{}
+	if t.typ == tokWord && p.toks[p.off+1].isPunct("(") {
+		for _, fn := range aggregateFuncs {
+			if t.isKeyword(fn) {
+				item.fn = fn
+			}
+		}
+		if item.fn == "" {
+			return nil, fmt.Errorf("unknown function %q at position %d", t.val, t.start)
+		}
+		p.next()
+		p.next()
+		if p.peek().isPunct("*") && item.fn == "COUNT" {
+			p.next()
+		} else {
+			name, err := p.parseName("column name")
+			if err != nil {
+				return nil, err
+			}
+			item.column = name
+		}
+		if t := p.next(); !t.isPunct(")") {
+			return nil, p.unexpected(t, "\")\"")
+		}
+	} else {
+		name, err := p.parseName("column name")
+		if err != nil {
+			return nil, err
+		}
+		item.column = name
+	}
+	alias, err := p.parseAlias()
+	if err != nil {
+		return nil, err
+	}
+	item.alias = alias
+	return item, nil
+}
+
+func (p *parser) parseSource() (*source, error) {
+	t := p.next()
+	if t.typ != tokQuoted && (t.typ != tokWord || isClauseKeyword(t)) {
+		return nil, p.unexpected(t, "commit or branch name")
+	}
+	src := &source{commit: t.val, alias: t.val}
+	alias, err := p.parseAlias()
+	if err != nil {
+		return nil, err
+	}
+	if alias != "" {
+		src.alias = alias
+	}
+	return src, nil
+}
+
+// parseCondition returns the text of the condition that follows, which ends at
+// the next clause keyword outside of parentheses.
+func (p *parser) parseCondition(clause string) (string, error) {
+	depth := 0
+	start := p.peek().start
+	end := start
+	for {
+		t := p.peek()
+		if t.typ == tokEOF || t.isPunct(";") || (depth == 0 && isClauseKeyword(t)) {
+			break
+		}
+		if t.isPunct("(") {
+			depth++
+		} else if t.isPunct(")") {
+			depth--
+		}
+		end = t.end
+		p.next()
+	}
+	if end == start {
+		return "", fmt.Errorf("expected condition after %s at position %d", clause, start)
+	}
+	return p.sql[start:end], nil
+}
+
+func (p *parser) parseOrderItem() (*orderItem, error) {
+	item := &orderItem{}
+	t := p.peek()
+	if n, err := strconv.Atoi(t.val); t.typ == tokWord && err == nil {
+		if n < 1 {
+			return nil, p.unexpected(t, "a positive column position")
+		}
+		p.next()
+		item.position = n
+	} else {
+		name, err := p.parseName("column name or position")
+		if err != nil {
+			return nil, err
+		}
+		item.name = name
+	}
+	if t := p.peek(); t.isKeyword("DESC") {
+		p.next()
+		item.desc = true
+	} else if t.isKeyword("ASC") {
+		p.next()
+	}
+	return item, nil
+}
@@ -0,0 +1,286 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package query
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wrgl/wrgl/pkg/factory"
+	"github.com/wrgl/wrgl/pkg/objects"
+	objmock "github.com/wrgl/wrgl/pkg/objects/mock"
+)
+
+func TestParse(t *testing.T) {
+	stmt, err := Parse(`SELECT a.id, "unit name" AS unit, COUNT(*), sum(b.price) total FROM main~1 AS a
+		LEFT JOIN other b ON a.id = b.id AND (b.price > 1 OR b.price = '')
+		WHERE a.country IN ('VN', 'FROM') GROUP BY a.id, "unit name" ORDER BY 2 DESC, total LIMIT 10;`)
+	require.NoError(t, err)
+	assert.Equal(t, []*selectItem{
+		{column: "a.id"},
+		{column: "unit name", alias: "unit"},
+		{fn: "COUNT"},
+		{fn: "SUM", column: "b.price", alias: "total"},
+	}, stmt.items)
+	assert.Equal(t, &source{commit: "main~1", alias: "a"}, stmt.from)
+	assert.Equal(t, &source{commit: "other", alias: "b"}, stmt.join)
+	assert.True(t, stmt.leftJoin)
+	assert.Equal(t, "a.id = b.id AND (b.price > 1 OR b.price = '')", stmt.on)
+	assert.Equal(t, "a.country IN ('VN', 'FROM')", stmt.where)
+	assert.Equal(t, []string{"a.id", "unit name"}, stmt.groupBy)
+	assert.Equal(t, []*orderItem{{position: 2, desc: true}, {name: "total"}}, stmt.orderBy)
+	assert.Equal(t, 10, stmt.limit)
+	assert.Equal(t, []string{"main~1", "other"}, stmt.Commits())
+
+	for _, c := range []struct {
+		sql string
+		err string
+	}{
+		{"SELECT", `expected column name but got end of query at position 6`},
+		{"SELECT * FROM", `expected commit or branch name but got end of query at position 13`},
+		{"SELECT * FROM main WHERE", `expected condition after WHERE at position 24`},
+		{"SELECT FOO(a) FROM main", `unknown function "FOO" at position 7`},
+		{"SELECT * FROM main LIMIT x", `expected a non-negative integer but got "x" at position 25`},
+		{"SELECT * FROM main JOIN other", `expected ON but got end of query at position 29`},
+	} {
+		_, err := Parse(c.sql)
+		assert.EqualError(t, err, c.err, c.sql)
+	}
+}
+
+func TestEncodeSortValue(t *testing.T) {
+	values := []string{"", "-10", "-1.5", "0", "2", "10", "100.5", "a", "a\x00", "ab", "b"}
+	for i := 1; i < len(values); i++ {
+		a := string(encodeSortValue(nil, values[i-1], false))
+		b := string(encodeSortValue(nil, values[i], false))
+		assert.Less(t, a, b, "%q < %q", values[i-1], values[i])
+		a = string(encodeSortValue(nil, values[i-1], true))
+		b = string(encodeSortValue(nil, values[i], true))
+		assert.Greater(t, a, b, "%q > %q desc", values[i-1], values[i])
+	}
+}
+
+func buildTable(t *testing.T, db objects.Store, rows []string, pk []uint32) *objects.Table {
+	t.Helper()
+	tbl, err := objects.GetTable(db, factory.BuildTable(t, db, rows, pk))
+	require.NoError(t, err)
+	return tbl
+}
+
+func runQuery(t *testing.T, db objects.Store, sql string, tables ...*objects.Table) (columns []string, rows [][]string) {
+	t.Helper()
+	stmt, err := Parse(sql)
+	require.NoError(t, err)
+	e, err := NewExecutor(db, stmt, tables)
+	require.NoError(t, err)
+	require.NoError(t, e.Run(func(row []string) error {
+		rows = append(rows, row)
+		return nil
+	}))
+	return e.Columns(), rows
+}
+
+func TestExecutor(t *testing.T) {
+	db := objmock.NewStore()
+	products := buildTable(t, db, []string{
+		"id,name,country,price",
+		"1,apple,VN,9.5",
+		"2,banana,VN,12",
+		"3,cherry,US,100",
+		"4,durian,JP,",
+	}, []uint32{0})
+	orders := buildTable(t, db, []string{
+		"order,product,qty",
+		"a,1,2",
+		"b,2,1",
+		"c,1,5",
+		"d,5,1",
+	}, []uint32{0})
+
+	for _, c := range []struct {
+		sql     string
+		columns []string
+		rows    [][]string
+	}{
+		{
+			"SELECT * FROM p WHERE country = 'VN'",
+			[]string{"id", "name", "country", "price"},
+			[][]string{{"1", "apple", "VN", "9.5"}, {"2", "banana", "VN", "12"}},
+		},
+		{
+			"SELECT name, price AS cost FROM p WHERE id IN ('4', '2', '4', '9')",
+			[]string{"name", "cost"},
+			[][]string{{"durian", ""}, {"banana", "12"}},
+		},
+		{
+			"SELECT product, qty FROM o WHERE o.order IN ('c', 'a', 'c', 'x')",
+			[]string{"product", "qty"},
+			[][]string{{"1", "5"}, {"1", "2"}},
+		},
+		{
+			"SELECT name FROM p ORDER BY price DESC LIMIT 2",
+			[]string{"name"},
+			[][]string{{"cherry"}, {"banana"}},
+		},
+		{
+			"SELECT name, price FROM p ORDER BY 2",
+			[]string{"name", "price"},
+			[][]string{{"durian", ""}, {"apple", "9.5"}, {"banana", "12"}, {"cherry", "100"}},
+		},
+		{
+			"SELECT country, COUNT(*), SUM(price), AVG(price), MIN(name), MAX(price) FROM p GROUP BY country ORDER BY country",
+			[]string{"country", "COUNT(*)", "SUM(price)", "AVG(price)", "MIN(name)", "MAX(price)"},
+			[][]string{
+				{"JP", "1", "", "", "durian", ""},
+				{"US", "1", "100", "100", "cherry", "100"},
+				{"VN", "2", "21.5", "10.75", "apple", "12"},
+			},
+		},
+		{
+			"SELECT COUNT(*) AS n, COUNT(price) FROM p",
+			[]string{"n", "COUNT(price)"},
+			[][]string{{"4", "3"}},
+		},
+		{
+			"SELECT COUNT(*) FROM p WHERE id = '9'",
+			[]string{"COUNT(*)"},
+			[][]string{{"0"}},
+		},
+		{
+			"SELECT o.order, p.name, qty FROM o JOIN p ON product = id ORDER BY o.order",
+			[]string{"order", "name", "qty"},
+			[][]string{{"a", "apple", "2"}, {"b", "banana", "1"}, {"c", "apple", "5"}},
+		},
+		{
+			"SELECT o.order, name FROM o LEFT JOIN p ON product = id AND country = 'VN' WHERE qty < 5",
+			[]string{"order", "name"},
+			[][]string{{"a", "apple"}, {"b", "banana"}, {"d", ""}},
+		},
+		{
+			"SELECT name, SUM(qty) AS total FROM p JOIN o ON o.product = p.id GROUP BY name ORDER BY total DESC",
+			[]string{"name", "total"},
+			[][]string{{"apple", "7"}, {"banana", "1"}},
+		},
+		{
+			"SELECT p.name, o.order FROM p JOIN o ON qty > 4",
+			[]string{"name", "order"},
+			[][]string{{"apple", "c"}, {"banana", "c"}, {"cherry", "c"}, {"durian", "c"}},
+		},
+	} {
+		stmt, err := Parse(c.sql)
+		require.NoError(t, err, c.sql)
+		tables := []*objects.Table{}
+		for _, name := range stmt.Commits() {
+			if name == "p" {
+				tables = append(tables, products)
+			} else {
+				tables = append(tables, orders)
+			}
+		}
+		columns, rows := runQuery(t, db, c.sql, tables...)
+		assert.Equal(t, c.columns, columns, c.sql)
+		assert.Equal(t, c.rows, rows, c.sql)
+	}
+
+	for _, c := range []struct {
+		sql string
+		err string
+	}{
+		{"SELECT foo FROM p", `unknown column "foo"`},
+		{"SELECT * FROM p WHERE foo = 1", `error parsing WHERE condition: unknown column "foo"`},
+		{"SELECT name, COUNT(*) FROM p", `column "name" must appear in GROUP BY or be used in an aggregate function`},
+		{"SELECT * FROM p GROUP BY country", `* can't be used together with GROUP BY or aggregate functions`},
+		{"SELECT country, COUNT(*) FROM p GROUP BY country ORDER BY price", `ORDER BY column "price" must appear in the select list`},
+		{"SELECT name FROM p ORDER BY 2", `ORDER BY position 2 is out of range`},
+		{"SELECT id FROM p JOIN p ON id = id", `both tables are named "p", use AS to give one of them a different name`},
+		{"SELECT id FROM p JOIN p AS q ON p.id = q.id", `column "id" is ambiguous, qualify it with a table name`},
+	} {
+		stmt, err := Parse(c.sql)
+		require.NoError(t, err, c.sql)
+		tables := []*objects.Table{products}
+		if len(stmt.Commits()) > 1 {
+			tables = append(tables, products)
+		}
+		_, err = NewExecutor(db, stmt, tables)
+		assert.EqualError(t, err, c.err, c.sql)
+	}
+
+	stmt, err := Parse("SELECT SUM(name) FROM p")
+	require.NoError(t, err)
+	e, err := NewExecutor(db, stmt, []*objects.Table{products})
+	require.NoError(t, err)
+	assert.EqualError(t, e.Run(func(row []string) error { return nil }), `SUM(name): "apple" is not a number`)
+}
+
+func TestExecutorNumericKeys(t *testing.T) {
+	db := objmock.NewStore()
+	items := buildTable(t, db, []string{
+		"id,name",
+		"010,ten",
+		"2,two",
+		"abc,letters",
+	}, []uint32{0})
+	refs := buildTable(t, db, []string{
+		"ref,item",
+		"a,10",
+		"b,2.0",
+		"c,abc",
+		"d,3",
+	}, []uint32{0})
+
+	for _, c := range []struct {
+		sql  string
+		rows [][]string
+	}{
+		// primary keys are looked up exactly as written
+		{"SELECT name FROM i WHERE id = 10", nil},
+		{"SELECT name FROM i WHERE id = 010", [][]string{{"ten"}}},
+		{"SELECT name FROM i WHERE id = 2", [][]string{{"two"}}},
+		{"SELECT name FROM i WHERE id IN (1e1, 'abc', 2)", [][]string{{"letters"}, {"two"}}},
+		// the table is scanned otherwise, comparing numbers numerically
+		{"SELECT name FROM i WHERE id = 10 OR id = 10", [][]string{{"ten"}}},
+		{"SELECT name FROM i WHERE id >= 2.0 AND id <= 2", [][]string{{"two"}}},
+		{"SELECT r.ref, i.name FROM r JOIN i ON item = id", [][]string{{"c", "letters"}}},
+		// rows are hashed with numbers in canonical form
+		{"SELECT i.name, r.ref FROM i JOIN r ON id = item", [][]string{{"ten", "a"}, {"two", "b"}, {"letters", "c"}}},
+		{"SELECT i.name, r.ref FROM i LEFT JOIN r ON id = item AND ref != 'a'", [][]string{{"ten", ""}, {"two", "b"}, {"letters", "c"}}},
+	} {
+		stmt, err := Parse(c.sql)
+		require.NoError(t, err, c.sql)
+		tables := []*objects.Table{}
+		for _, name := range stmt.Commits() {
+			if name == "i" {
+				tables = append(tables, items)
+			} else {
+				tables = append(tables, refs)
+			}
+		}
+		_, rows := runQuery(t, db, c.sql, tables...)
+		assert.Equal(t, c.rows, rows, c.sql)
+	}
+}
+
+func TestExecutorManyBlocks(t *testing.T) {
+	db := objmock.NewStore()
+	rows := []string{"a,b,c"}
+	for i := 0; i < 600; i++ {
+		rows = append(rows, fmt.Sprintf("%d,%d,%d", i, i%3, i%7))
+	}
+	tbl := buildTable(t, db, rows, []uint32{0})
+	require.Greater(t, len(tbl.Blocks), 2)
+
+	_, result := runQuery(t, db, "SELECT b, COUNT(*), MAX(a) FROM t GROUP BY b", tbl)
+	assert.Equal(t, [][]string{{"0", "200", "597"}, {"1", "200", "598"}, {"2", "200", "599"}}, result)
+
+	_, result = runQuery(t, db, "SELECT a, c FROM t WHERE a IN (599, 300, 0) ORDER BY a DESC", tbl)
+	assert.Equal(t, [][]string{{"599", "4"}, {"300", "6"}, {"0", "0"}}, result)
+
+	_, result = runQuery(t, db, "SELECT x.a, y.a FROM t x JOIN t y ON x.c = y.a WHERE x.a > 597", tbl, tbl)
+	assert.Equal(t, [][]string{{"598", "3"}, {"599", "4"}}, result)
+
+	_, result = runQuery(t, db, "SELECT a FROM t ORDER BY c DESC, a LIMIT 3", tbl)
+	assert.Equal(t, [][]string{{"6"}, {"13"}, {"20"}}, result)
+}
@@ -0,0 +1,101 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package query
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+
+	"github.com/wrgl/wrgl/pkg/dprof"
+	"github.com/wrgl/wrgl/pkg/sorter"
+)
+
+// encodeSortValue appends to dst an encoding of v whose byte order matches
+// the order of values: empty values first, then numbers by value, then other
+// strings lexicographically. Encodings are prefix-free so they can be
+// concatenated to sort by multiple values, and inverted to sort descending.
+func encodeSortValue(dst []byte, v string, desc bool) []byte {
+	start := len(dst)
+	if v == "" {
+		dst = append(dst, 0)
+	} else if f, ok := dprof.ParseNumber(v); ok {
+		bits := math.Float64bits(f)
+		if f >= 0 {
+			bits ^= 1 << 63
+		} else {
+			bits = ^bits
+		}
+		dst = append(dst, 1)
+		dst = binary.BigEndian.AppendUint64(dst, bits)
+	} else {
+		dst = append(dst, 2)
+		for i := 0; i < len(v); i++ {
+			dst = append(dst, v[i])
+			if v[i] == 0 {
+				dst = append(dst, 0xff)
+			}
+		}
+		dst = append(dst, 0, 1)
+	}
+	if desc {
+		for i := start; i < len(dst); i++ {
+			dst[i] = ^dst[i]
+		}
+	}
+	return dst
+}
+
+// externalSorter sorts rows by an encoded key with package sorter, spilling
+// to disk when rows don't fit in memory. Rows with equal keys keep their
+// insertion order.
+type externalSorter struct {
+	s   *sorter.Sorter
+	seq uint64
+}
+
+func newExternalSorter() (*externalSorter, error) {
+	s, err := sorter.NewSorter()
+	if err != nil {
+		return nil, err
+	}
+	// the key is the only sorting column, a sequence number is appended to
+	// it so that the sorter doesn't drop rows with equal keys
+	s.PK = []uint32{0}
+	return &externalSorter{s: s}, nil
+}
+
+func (e *externalSorter) add(key []byte, row []string) error {
+	key = binary.BigEndian.AppendUint64(key, e.seq)
+	e.seq++
+	return e.s.AddRow(append([]string{string(key)}, row...))
+}
+
+// each calls fn with each row in sorted order along with its key
+func (e *externalSorter) each(fn func(key string, row []string) error) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errChan := make(chan error, 1)
+	ch := e.s.SortedRows(ctx, nil, errChan)
+	for rows := range ch {
+		for _, row := range rows.Rows {
+			if err := fn(row[0][:len(row[0])-8], row[1:]); err != nil {
+				cancel()
+				for range ch {
+				}
+				return err
+			}
+		}
+	}
+	select {
+	case err := <-errChan:
+		return err
+	default:
+		return nil
+	}
+}
+
+func (e *externalSorter) close() error {
+	return e.s.Close()
+}
@@ -16,6 +16,7 @@ import (
 const maxQueueGrow = 1 << 10
 
 // CommitsQueue is a queue sorted by commit time (newer commit first, older commit last).
+// Sums of tag objects are peeled to the commits they point to upon insertion.
 type CommitsQueue struct {
 	db      objects.Store
 	commits []*objects.Commit
@@ -40,7 +41,11 @@ func (q *CommitsQueue) Reset(initialSums [][]byte) error {
 		q.sums = q.sums[:0]
 	}
 	q.seen = map[string]struct{}{}
-	for _, v := range initialSums {
+	for _, sum := range initialSums {
+		v, _, err := PeelTag(q.db, sum)
+		if err != nil {
+			return fmt.Errorf("PeelTag %x error: %v", sum, err)
+		}
 		if _, ok := q.seen[string(v)]; ok {
 			continue
 		}
@@ -91,6 +96,10 @@ func (q *CommitsQueue) growSize() int {
 func (q *CommitsQueue) Insert(sum []byte) (err error) {
 	q.mutex.Lock()
 	defer q.mutex.Unlock()
+	sum, _, err = PeelTag(q.db, sum)
+	if err != nil {
+		return
+	}
 	if q.Seen(sum) {
 		return
 	}
@@ -109,6 +109,10 @@ func InterpretCommitName(db objects.Store, rs Store, commitStr string, excludeTa
 		if err != nil {
 			return
 		}
+		hash, _, err = PeelTag(db, hash)
+		if err != nil {
+			return
+		}
 		commit, err = objects.GetCommit(db, hash)
 		if err == nil {
 			hash, commit, err = PeelCommit(db, hash, commit, numPeel)
@@ -120,6 +124,10 @@ func InterpretCommitName(db objects.Store, rs Store, commitStr string, excludeTa
 		var commitSum []byte
 		name, commitSum, err = interpretRef(rs, name, excludeTag)
 		if err == nil {
+			commitSum, _, err = PeelTag(db, commitSum)
+			if err != nil {
+				return "", nil, nil, err
+			}
 			commit, err = objects.GetCommit(db, commitSum)
 			if err != nil {
 				return "", nil, nil, err
@@ -4,6 +4,7 @@
 package ref_test
 
 import (
+	"bytes"
 	"encoding/hex"
 	"fmt"
 	"testing"
@@ -154,3 +155,42 @@ func TestInterpretRefName(t *testing.T) {
 	assert.Equal(t, sum7, sum)
 	objhelpers.AssertCommitEqual(t, c7, c)
 }
+
+func TestInterpretAnnotatedTag(t *testing.T) {
+	db := objmock.NewStore()
+	rs, cleanup := refmock.NewStore(t)
+	defer cleanup()
+	sum1, _ := refhelpers.SaveTestCommit(t, db, nil)
+	sum2, commit2 := refhelpers.SaveTestCommit(t, db, [][]byte{sum1})
+	tag := objhelpers.RandomTag()
+	tag.Target = sum2
+	buf := bytes.NewBuffer(nil)
+	_, err := tag.WriteTo(buf)
+	require.NoError(t, err)
+	tagSum, err := objects.SaveTag(db, buf.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, ref.SaveAnnotatedTag(rs, "v1", tagSum, tag))
+
+	target, peeled, err := ref.PeelTag(db, tagSum)
+	require.NoError(t, err)
+	assert.Equal(t, sum2, target)
+	objhelpers.AssertTagEqual(t, tag, peeled)
+	target, peeled, err = ref.PeelTag(db, sum2)
+	require.NoError(t, err)
+	assert.Equal(t, sum2, target)
+	assert.Nil(t, peeled)
+
+	name, sum, commit, err := ref.InterpretCommitName(db, rs, "v1", false)
+	require.NoError(t, err)
+	assert.Equal(t, "tags/v1", name)
+	assert.Equal(t, sum2, sum)
+	objhelpers.AssertCommitEqual(t, commit2, commit)
+
+	_, sum, _, err = ref.InterpretCommitName(db, rs, "v1^", false)
+	require.NoError(t, err)
+	assert.Equal(t, sum1, sum)
+
+	_, sum, _, err = ref.InterpretCommitName(db, rs, hex.EncodeToString(tagSum), false)
+	require.NoError(t, err)
+	assert.Equal(t, sum2, sum)
+}
@@ -83,6 +83,26 @@ func SaveTag(s Store, name string, sum []byte) error {
 	return s.Set(tagRef(name), sum)
 }
 
+// SaveAnnotatedTag points a tag to a tag object and records the tagger in the
+// tag's reflog.
+func SaveAnnotatedTag(s Store, name string, sum []byte, tag *objects.Tag) error {
+	return SaveRef(s, tagRef(name), sum, tag.TaggerName, tag.TaggerEmail, "tag", FirstLine(tag.Message), nil)
+}
+
+// PeelTag returns the sum of the commit that sum points to. If sum is the sum
+// of a tag object then the tag's target is returned along with the tag,
+// otherwise sum is returned as is.
+func PeelTag(db objects.Store, sum []byte) ([]byte, *objects.Tag, error) {
+	if !objects.TagExist(db, sum) {
+		return sum, nil, nil
+	}
+	tag, err := objects.GetTag(db, sum)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tag.Target, tag, nil
+}
+
 func SaveRemoteRef(s Store, remote, name string, commit []byte, authorName, authorEmail, action, message string) error {
 	return SaveRef(s, RemoteRef(remote, name), commit, authorName, authorEmail, action, message, nil)
 }
@@ -28,7 +28,7 @@ type Traveller struct {
 func NewTraveller(db objects.Store, rs Store, refName string) (*Traveller, error) {
 	reader, err := rs.LogReader(refName)
 	if err != nil {
-		return nil, fmt.Errorf("rs.LogReader err: %v", err)
+		return nil, fmt.Errorf("rs.LogReader err: %w", err)
 	}
 	nxt, err := reader.Read()
 	if err != nil {
@@ -73,7 +73,12 @@ func (rt *Traveller) Next() (*objects.Commit, error) {
 		if rt.done {
 			return nil, nil
 		}
-		rt.com, err = objects.GetCommit(rt.db, rt.Reflog.NewOID)
+		var sum []byte
+		sum, _, err = PeelTag(rt.db, rt.Reflog.NewOID)
+		if err != nil {
+			return nil, err
+		}
+		rt.com, err = objects.GetCommit(rt.db, sum)
 	} else {
 		if rt.Reflog.OldOID == nil && rt.Reflog.Action != "fetch" {
 			rt.com = nil
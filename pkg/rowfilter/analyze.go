@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package rowfilter
+
+// conjuncts returns conditions that are ANDed together at the top level of c
+func conjuncts(c condition) []condition {
+	if and, ok := c.(*andCondition); ok {
+		return append(conjuncts(and.left), conjuncts(and.right)...)
+	}
+	return []condition{c}
+}
+
+func isEquality(c *comparison) bool {
+	return c.op == "=" || c.op == "=="
+}
+
+// ColumnValues returns the values that each column is restricted to by
+// "column = value" and "column IN (values...)" conditions ANDed at the top
+// level of the expression. Values are returned as written, so numbers can also
+// match cells that are written differently, e.g. "010" for 10. Columns that
+// aren't restricted this way are not included.
+func (f *Filter) ColumnValues() map[int][]string {
+	m := map[int][]string{}
+	restrict := func(col column, values []string) {
+		prev, ok := m[int(col)]
+		if !ok {
+			m[int(col)] = values
+			return
+		}
+		res := []string{}
+		for _, v := range values {
+			for _, w := range prev {
+				if v == w {
+					res = append(res, v)
+					break
+				}
+			}
+		}
+		m[int(col)] = res
+	}
+	for _, c := range conjuncts(f.cond) {
+		switch v := c.(type) {
+		case *comparison:
+			if !isEquality(v) {
+				continue
+			}
+			if col, ok := v.left.(column); ok {
+				if lit, ok := v.right.(literal); ok {
+					restrict(col, []string{string(lit)})
+				}
+			} else if col, ok := v.right.(column); ok {
+				if lit, ok := v.left.(literal); ok {
+					restrict(col, []string{string(lit)})
+				}
+			}
+		case *inCondition:
+			col, ok := v.left.(column)
+			if !ok {
+				continue
+			}
+			values := make([]string, 0, len(v.list))
+			for _, o := range v.list {
+				lit, ok := o.(literal)
+				if !ok {
+					values = nil
+					break
+				}
+				values = append(values, string(lit))
+			}
+			if values != nil {
+				restrict(col, values)
+			}
+		}
+	}
+	return m
+}
+
+// ColumnPairs returns pairs of columns that must be equal according to
+// "column = column" conditions ANDed at the top level of the expression.
+func (f *Filter) ColumnPairs() [][2]int {
+	var pairs [][2]int
+	for _, c := range conjuncts(f.cond) {
+		if v, ok := c.(*comparison); ok && isEquality(v) {
+			left, ok1 := v.left.(column)
+			right, ok2 := v.right.(column)
+			if ok1 && ok2 {
+				pairs = append(pairs, [2]int{int(left), int(right)})
+			}
+		}
+	}
+	return pairs
+}
@@ -0,0 +1,35 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package rowfilter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestColumnValues(t *testing.T) {
+	columns := []string{"a", "b", "c"}
+	for i, c := range []struct {
+		expr   string
+		values map[int][]string
+		pairs  [][2]int
+	}{
+		{"a = 1", map[int][]string{0: {"1"}}, nil},
+		{"'x' = b AND c > 2", map[int][]string{1: {"x"}}, nil},
+		{"a IN (1, 2, 3) AND b = 'q' AND a IN (3, 1)", map[int][]string{0: {"3", "1"}, 1: {"q"}}, nil},
+		{"a = 1 OR b = 2", map[int][]string{}, nil},
+		{"a IN (1, b)", map[int][]string{}, nil},
+		{"NOT a = 1 AND (b = 2 AND c = a)", map[int][]string{1: {"2"}}, [][2]int{{2, 0}}},
+		{"a = 1 AND a = 2", map[int][]string{0: {}}, nil},
+		// numbers are returned as written
+		{"a IN (010, 1e1) AND b = 10.0", map[int][]string{0: {"010", "1e1"}, 1: {"10.0"}}, nil},
+	} {
+		f, err := New(c.expr, columns)
+		require.NoError(t, err)
+		assert.Equal(t, c.values, f.ColumnValues(), "case %d", i)
+		assert.Equal(t, c.pairs, f.ColumnPairs(), "case %d", i)
+	}
+}
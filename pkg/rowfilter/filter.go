@@ -0,0 +1,203 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+// Package rowfilter evaluates SQL-like filter expressions such as
+// "country = 'VN' AND price > 10" against table rows.
+package rowfilter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/wrgl/wrgl/pkg/dprof"
+)
+
+// Filter is a filter expression bound to a list of columns
+type Filter struct {
+	expr    string
+	columns []string
+	cond    condition
+}
+
+// New parses expr and resolves column names in expr against columns. Rows
+// given to Match must follow the same column order.
+func New(expr string, columns []string) (*Filter, error) {
+	m := make(map[string]int, len(columns))
+	for i, name := range columns {
+		m[name] = i
+	}
+	return Parse(expr, func(name string) (int, error) {
+		i, ok := m[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown column %q", name)
+		}
+		return i, nil
+	})
+}
+
+// Parse parses expr, calling resolve to turn each column name in expr into
+// the index of that column in rows given to Match.
+func Parse(expr string, resolve func(name string) (int, error)) (*Filter, error) {
+	toks, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks, resolve: resolve}
+	cond, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	return &Filter{
+		expr:    expr,
+		columns: p.referenced,
+		cond:    cond,
+	}, nil
+}
+
+// Columns returns names of columns referenced in the expression, in order of
+// first appearance.
+func (f *Filter) Columns() []string {
+	return f.columns
+}
+
+// String returns the original expression
+func (f *Filter) String() string {
+	return f.expr
+}
+
+// Match returns true if row satisfies the filter expression
+func (f *Filter) Match(row []string) bool {
+	return f.cond.match(row)
+}
+
+// Compare compares two cell values numerically if both are numbers,
+// otherwise lexicographically.
+func Compare(a, b string) int {
+	if x, ok := dprof.ParseNumber(a); ok {
+		if y, ok := dprof.ParseNumber(b); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+type operand interface {
+	value(row []string) string
+}
+
+type column int
+
+func (c column) value(row []string) string {
+	if int(c) >= len(row) {
+		return ""
+	}
+	return row[c]
+}
+
+type literal string
+
+func (l literal) value(row []string) string {
+	return string(l)
+}
+
+type condition interface {
+	match(row []string) bool
+}
+
+type andCondition struct {
+	left, right condition
+}
+
+func (c *andCondition) match(row []string) bool {
+	return c.left.match(row) && c.right.match(row)
+}
+
+type orCondition struct {
+	left, right condition
+}
+
+func (c *orCondition) match(row []string) bool {
+	return c.left.match(row) || c.right.match(row)
+}
+
+type notCondition struct {
+	cond condition
+}
+
+func (c *notCondition) match(row []string) bool {
+	return !c.cond.match(row)
+}
+
+type comparison struct {
+	op          string
+	left, right operand
+}
+
+func (c *comparison) match(row []string) bool {
+	v := Compare(c.left.value(row), c.right.value(row))
+	switch c.op {
+	case "=", "==":
+		return v == 0
+	case "!=", "<>":
+		return v != 0
+	case "<":
+		return v < 0
+	case "<=":
+		return v <= 0
+	case ">":
+		return v > 0
+	case ">=":
+		return v >= 0
+	}
+	return false
+}
+
+type inCondition struct {
+	left operand
+	list []operand
+}
+
+func (c *inCondition) match(row []string) bool {
+	v := c.left.value(row)
+	for _, o := range c.list {
+		if Compare(v, o.value(row)) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+type likeCondition struct {
+	left operand
+	re   *regexp.Regexp
+}
+
+// likePattern converts a LIKE pattern into a regular expression where "%"
+// matches any sequence of characters and "_" matches a single character.
+func likePattern(s string) *regexp.Regexp {
+	sb := &strings.Builder{}
+	sb.WriteString("^(?s)")
+	for _, r := range s {
+		switch r {
+		case '%':
+			sb.WriteString(".*")
+		case '_':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.MustCompile(sb.String())
+}
+
+func (c *likeCondition) match(row []string) bool {
+	return c.re.MatchString(c.left.value(row))
+}
@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package rowfilter
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilter(t *testing.T) {
+	columns := []string{"id", "country", "price", "unit name"}
+	rows := [][]string{
+		{"1", "VN", "9.5", "kg"},
+		{"2", "VN", "12", "box"},
+		{"3", "US", "100", "kg"},
+		{"4", "JP", "", "pack's"},
+	}
+	for i, c := range []struct {
+		expr    string
+		matched []string
+	}{
+		{"country = 'VN'", []string{"1", "2"}},
+		{"country = 'VN' AND price > 10", []string{"2"}},
+		{"country = 'VN' and price > 10", []string{"2"}},
+		{"price > 9.5", []string{"2", "3"}},
+		{"price >= 9.5", []string{"1", "2", "3"}},
+		{"price < 12", []string{"1", "4"}},
+		{"price <= 12", []string{"1", "2", "4"}},
+		{"price = 12.0", []string{"2"}},
+		{"price != 12", []string{"1", "3", "4"}},
+		{"price <> ''", []string{"1", "2", "3"}},
+		{"country = 'US' OR id = 4", []string{"3", "4"}},
+		{"NOT (country = 'VN' OR country = 'US')", []string{"4"}},
+		{"country IN ('US', 'JP')", []string{"3", "4"}},
+		{"country NOT IN ('US', 'JP')", []string{"1", "2"}},
+		{"\"unit name\" LIKE 'k%'", []string{"1", "3"}},
+		{"`unit name` NOT LIKE '_o_'", []string{"1", "3", "4"}},
+		{"\"unit name\" = 'pack''s'", []string{"4"}},
+		{"id > -1 AND (country = 'JP' OR price > 50)", []string{"3", "4"}},
+		{"price > id AND id < 3", []string{"1", "2"}},
+	} {
+		f, err := New(c.expr, columns)
+		require.NoError(t, err, "case %d", i)
+		assert.Equal(t, c.expr, f.String())
+		matched := []string{}
+		for _, row := range rows {
+			if f.Match(row) {
+				matched = append(matched, row[0])
+			}
+		}
+		assert.Equal(t, c.matched, matched, "case %d: %s", i, c.expr)
+	}
+}
+
+func TestFilterErrors(t *testing.T) {
+	columns := []string{"a", "b"}
+	for i, c := range []struct {
+		expr string
+		err  error
+	}{
+		{"", fmt.Errorf("empty expression")},
+		{"c = 1", fmt.Errorf("unknown column \"c\"")},
+		{"a = 'x", fmt.Errorf("unterminated quote at position 4")},
+		{"a = 1 b", fmt.Errorf("unexpected \"b\" at position 6")},
+		{"a 1", fmt.Errorf("expected operator but got \"1\" at position 2")},
+		{"(a = 1", fmt.Errorf("expected \")\" but got end of expression at position 6")},
+		{"a LIKE b", fmt.Errorf("expected string pattern after LIKE but got \"b\" at position 7")},
+		{"a IN (1 2)", fmt.Errorf("expected \",\" or \")\" but got \"2\" at position 8")},
+		{"a = AND", fmt.Errorf("unexpected \"AND\" at position 4")},
+		{"a ! 1", fmt.Errorf("unexpected character '!' at position 2")},
+		{"a = 1e", fmt.Errorf("invalid number \"1e\" at position 4")},
+	} {
+		_, err := New(c.expr, columns)
+		assert.Equal(t, c.err, err, "case %d", i)
+	}
+}
+
+func TestFilterColumns(t *testing.T) {
+	f, err := New("price > 10 AND (\"unit name\" = 'kg' OR price < id)", []string{"id", "price", "unit name"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"price", "unit name", "id"}, f.Columns())
+}
+
+func TestCompare(t *testing.T) {
+	for i, c := range []struct {
+		a, b   string
+		result int
+	}{
+		{"9.5", "12", -1},
+		{"12.0", "12", 0},
+		{"1e2", "100", 0},
+		{"abc", "12", 1},
+		// NaN and infinities are compared as text
+		{"NaN", "5", 1},
+		{"5", "NaN", -1},
+		{"Infinity", "inf", -1},
+		{"-Inf", "-1", 1},
+	} {
+		assert.Equal(t, c.result, Compare(c.a, c.b), "case %d: %q, %q", i, c.a, c.b)
+	}
+
+	f, err := New("price = 5", []string{"price"})
+	require.NoError(t, err)
+	assert.False(t, f.Match([]string{"NaN"}))
+	assert.True(t, f.Match([]string{"5.0"}))
+}
@@ -0,0 +1,132 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package rowfilter
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenType int
+
+const (
+	tokEOF tokenType = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOperator
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type token struct {
+	typ tokenType
+	val string
+	pos int
+
+	// quoted is true for identifiers enclosed in double quotes or backticks,
+	// which are never taken for keywords
+	quoted bool
+}
+
+func (t token) String() string {
+	switch t.typ {
+	case tokEOF:
+		return "end of expression"
+	case tokString:
+		return fmt.Sprintf("'%s'", t.val)
+	}
+	return fmt.Sprintf("%q", t.val)
+}
+
+// isKeyword returns true if t is an unquoted identifier equal to kw, ignoring
+// case.
+func (t token) isKeyword(kw string) bool {
+	return t.typ == tokIdent && !t.quoted && strings.EqualFold(t.val, kw)
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// tokenize splits s into tokens
+func tokenize(s string) ([]token, error) {
+	var toks []token
+	runes := []rune(s)
+	n := len(runes)
+	for i := 0; i < n; {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			toks = append(toks, token{typ: tokLParen, val: "(", pos: i})
+			i++
+		case r == ')':
+			toks = append(toks, token{typ: tokRParen, val: ")", pos: i})
+			i++
+		case r == ',':
+			toks = append(toks, token{typ: tokComma, val: ",", pos: i})
+			i++
+		case r == '\'' || r == '"' || r == '`':
+			start := i
+			sb := &strings.Builder{}
+			i++
+			closed := false
+			for i < n {
+				if runes[i] == r {
+					// a doubled quote is an escaped quote
+					if i+1 < n && runes[i+1] == r {
+						sb.WriteRune(r)
+						i += 2
+						continue
+					}
+					closed = true
+					i++
+					break
+				}
+				sb.WriteRune(runes[i])
+				i++
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated quote at position %d", start)
+			}
+			if r == '\'' {
+				toks = append(toks, token{typ: tokString, val: sb.String(), pos: start})
+			} else {
+				toks = append(toks, token{typ: tokIdent, val: sb.String(), pos: start, quoted: true})
+			}
+		case r == '=' || r == '!' || r == '<' || r == '>':
+			start := i
+			i++
+			if i < n && (runes[i] == '=' || (r == '<' && runes[i] == '>')) {
+				i++
+			}
+			op := string(runes[start:i])
+			if op == "!" {
+				return nil, fmt.Errorf("unexpected character '!' at position %d", start)
+			}
+			toks = append(toks, token{typ: tokOperator, val: op, pos: start})
+		case unicode.IsDigit(r) || ((r == '-' || r == '+' || r == '.') && i+1 < n && (unicode.IsDigit(runes[i+1]) || runes[i+1] == '.')):
+			start := i
+			i++
+			for i < n && (unicode.IsDigit(runes[i]) || runes[i] == '.' || runes[i] == 'e' || runes[i] == 'E' ||
+				((runes[i] == '-' || runes[i] == '+') && (runes[i-1] == 'e' || runes[i-1] == 'E'))) {
+				i++
+			}
+			toks = append(toks, token{typ: tokNumber, val: string(runes[start:i]), pos: start})
+		case isIdentRune(r):
+			start := i
+			for i < n && isIdentRune(runes[i]) {
+				i++
+			}
+			toks = append(toks, token{typ: tokIdent, val: string(runes[start:i]), pos: start})
+		default:
+			return nil, fmt.Errorf("unexpected character %q at position %d", r, i)
+		}
+	}
+	return append(toks, token{typ: tokEOF, val: "", pos: n}), nil
+}
@@ -0,0 +1,199 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package rowfilter
+
+import (
+	"fmt"
+
+	"github.com/wrgl/wrgl/pkg/dprof"
+	"github.com/wrgl/wrgl/pkg/slice"
+)
+
+var keywords = []string{"AND", "OR", "NOT", "IN", "LIKE"}
+
+type parser struct {
+	toks       []token
+	off        int
+	resolve    func(name string) (int, error)
+	referenced []string
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.off]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.off]
+	if t.typ != tokEOF {
+		p.off++
+	}
+	return t
+}
+
+func (p *parser) unexpected(t token) error {
+	return fmt.Errorf("unexpected %s at position %d", t, t.pos)
+}
+
+func (p *parser) expect(typ tokenType, desc string) error {
+	t := p.next()
+	if t.typ != typ {
+		return fmt.Errorf("expected %s but got %s at position %d", desc, t, t.pos)
+	}
+	return nil
+}
+
+func (p *parser) parse() (condition, error) {
+	if p.peek().typ == tokEOF {
+		return nil, fmt.Errorf("empty expression")
+	}
+	c, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.typ != tokEOF {
+		return nil, p.unexpected(t)
+	}
+	return c, nil
+}
+
+func (p *parser) parseOr() (condition, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().isKeyword("OR") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orCondition{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (condition, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().isKeyword("AND") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &andCondition{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (condition, error) {
+	if p.peek().isKeyword("NOT") {
+		p.next()
+		c, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notCondition{c}, nil
+	}
+	if p.peek().typ == tokLParen {
+		p.next()
+		c, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expect(tokRParen, "\")\""); err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+	return p.parsePredicate()
+}
+
+func (p *parser) parsePredicate() (condition, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	negate := false
+	if p.peek().isKeyword("NOT") {
+		p.next()
+		negate = true
+	}
+	var c condition
+	t := p.next()
+	switch {
+	case t.isKeyword("IN"):
+		c, err = p.parseIn(left)
+	case t.isKeyword("LIKE"):
+		pat := p.next()
+		if pat.typ != tokString {
+			return nil, fmt.Errorf("expected string pattern after LIKE but got %s at position %d", pat, pat.pos)
+		}
+		c = &likeCondition{left: left, re: likePattern(pat.val)}
+	case t.typ == tokOperator && !negate:
+		var right operand
+		right, err = p.parseOperand()
+		c = &comparison{op: t.val, left: left, right: right}
+	default:
+		return nil, fmt.Errorf("expected operator but got %s at position %d", t, t.pos)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if negate {
+		return &notCondition{c}, nil
+	}
+	return c, nil
+}
+
+func (p *parser) parseIn(left operand) (condition, error) {
+	if err := p.expect(tokLParen, "\"(\""); err != nil {
+		return nil, err
+	}
+	c := &inCondition{left: left}
+	for {
+		o, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		c.list = append(c.list, o)
+		t := p.next()
+		if t.typ == tokRParen {
+			return c, nil
+		}
+		if t.typ != tokComma {
+			return nil, fmt.Errorf("expected \",\" or \")\" but got %s at position %d", t, t.pos)
+		}
+	}
+}
+
+func (p *parser) parseOperand() (operand, error) {
+	t := p.next()
+	switch t.typ {
+	case tokString:
+		return literal(t.val), nil
+	case tokNumber:
+		if _, ok := dprof.ParseNumber(t.val); !ok {
+			return nil, fmt.Errorf("invalid number %s at position %d", t, t.pos)
+		}
+		return literal(t.val), nil
+	case tokIdent:
+		for _, kw := range keywords {
+			if t.isKeyword(kw) {
+				return nil, p.unexpected(t)
+			}
+		}
+		i, err := p.resolve(t.val)
+		if err != nil {
+			return nil, err
+		}
+		if !slice.StringSliceContains(p.referenced, t.val) {
+			p.referenced = append(p.referenced, t.val)
+		}
+		return column(i), nil
+	}
+	return nil, fmt.Errorf("expected column name or value but got %s at position %d", t, t.pos)
+}
@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package rowsource
+
+import (
+	"compress/bzip2"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	CompressionGzip  = "gzip"
+	CompressionZstd  = "zstd"
+	CompressionBzip2 = "bzip2"
+)
+
+var compressionExts = map[string]string{
+	".gz":   CompressionGzip,
+	".zst":  CompressionZstd,
+	".zstd": CompressionZstd,
+	".bz2":  CompressionBzip2,
+}
+
+// CompressionFromPath returns the compression of a file based on its
+// extension, or an empty string if the file isn't compressed.
+func CompressionFromPath(path string) string {
+	return compressionExts[strings.ToLower(filepath.Ext(path))]
+}
+
+// trimCompressionExt removes the compression extension from path so that the
+// extension before it can be inspected, e.g. "data.jsonl.gz" becomes
+// "data.jsonl"
+func trimCompressionExt(path string) string {
+	if CompressionFromPath(path) != "" {
+		return strings.TrimSuffix(path, filepath.Ext(path))
+	}
+	return path
+}
+
+// decompressReader closes the underlying decompressor as soon as it is drained
+// so that callers don't have to keep track of it
+type decompressReader struct {
+	r      io.Reader
+	closer io.Closer
+	err    error
+}
+
+func (r *decompressReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	n, err := r.r.Read(p)
+	if err != nil {
+		r.err = err
+		r.closer.Close()
+	}
+	return n, err
+}
+
+// NewDecompressor returns a reader that decompresses r as it is being read.
+func NewDecompressor(r io.Reader, compression string) (io.Reader, error) {
+	switch compression {
+	case CompressionGzip:
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("error reading gzip stream: %v", err)
+		}
+		return &decompressReader{r: gr, closer: gr}, nil
+	case CompressionZstd:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("error reading zstd stream: %v", err)
+		}
+		rc := zr.IOReadCloser()
+		return &decompressReader{r: rc, closer: rc}, nil
+	case CompressionBzip2:
+		return bzip2.NewReader(r), nil
+	}
+	return nil, fmt.Errorf("invalid compression %q, must be one of %s, %s, %s", compression, CompressionGzip, CompressionZstd, CompressionBzip2)
+}
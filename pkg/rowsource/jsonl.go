@@ -0,0 +1,141 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package rowsource
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// jsonlReader reads JSON Lines files where each line is a JSON object
+type jsonlReader struct {
+	r       *bufio.Reader
+	line    int
+	columns map[string]int
+	row     []string
+	// first holds values of the first object, which are returned after the
+	// header
+	first []string
+}
+
+// NewJSONLReader returns a reader of rows from JSON Lines. Column names are
+// the keys of the first object, in the order that they appear. Later objects
+// may leave out keys but must not have keys that the first object doesn't
+// have. Strings are read as is, null as empty string and other values as
+// their JSON text.
+func NewJSONLReader(r io.Reader) Reader {
+	return &jsonlReader{r: bufio.NewReader(r)}
+}
+
+func jsonValueString(raw json.RawMessage) (string, error) {
+	switch raw[0] {
+	case '"':
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return "", err
+		}
+		return s, nil
+	case 'n':
+		return "", nil
+	case '{', '[':
+		buf := bytes.NewBuffer(nil)
+		if err := json.Compact(buf, raw); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	}
+	return string(raw), nil
+}
+
+// readObject calls fn with each key and value of the object on the next
+// non-empty line
+func (r *jsonlReader) readObject(fn func(key, value string) error) error {
+	var line []byte
+	for len(bytes.TrimSpace(line)) == 0 {
+		var err error
+		line, err = r.r.ReadBytes('\n')
+		if err != nil && !(errors.Is(err, io.EOF) && len(line) > 0) {
+			return err
+		}
+		r.line++
+	}
+	dec := json.NewDecoder(bytes.NewReader(line))
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("line %d: %v", r.line, err)
+	}
+	if tok != json.Delim('{') {
+		return fmt.Errorf("line %d: expecting a JSON object", r.line)
+	}
+	for dec.More() {
+		tok, err = dec.Token()
+		if err != nil {
+			return fmt.Errorf("line %d: %v", r.line, err)
+		}
+		var raw json.RawMessage
+		if err = dec.Decode(&raw); err != nil {
+			return fmt.Errorf("line %d: %v", r.line, err)
+		}
+		v, err := jsonValueString(raw)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", r.line, err)
+		}
+		if err = fn(tok.(string), v); err != nil {
+			return err
+		}
+	}
+	if _, err = dec.Token(); err != nil {
+		return fmt.Errorf("line %d: %v", r.line, err)
+	}
+	if _, err = dec.Token(); !errors.Is(err, io.EOF) {
+		return fmt.Errorf("line %d: unexpected data after JSON object", r.line)
+	}
+	return nil
+}
+
+func (r *jsonlReader) Read() ([]string, error) {
+	if r.columns == nil {
+		r.columns = map[string]int{}
+		var header []string
+		err := r.readObject(func(key, value string) error {
+			if _, ok := r.columns[key]; ok {
+				return fmt.Errorf("line %d: duplicated key %q", r.line, key)
+			}
+			r.columns[key] = len(header)
+			header = append(header, key)
+			r.first = append(r.first, value)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return header, nil
+	}
+	if r.first != nil {
+		r.row, r.first = r.first, nil
+		return r.row, nil
+	}
+	if r.row == nil {
+		r.row = make([]string, len(r.columns))
+	}
+	for i := range r.row {
+		r.row[i] = ""
+	}
+	err := r.readObject(func(key, value string) error {
+		i, ok := r.columns[key]
+		if !ok {
+			return fmt.Errorf("line %d: unknown key %q, all keys must appear in the first object", r.line, key)
+		}
+		r.row[i] = value
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.row, nil
+}
@@ -0,0 +1,35 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package rowsource
+
+import (
+	"io"
+
+	"github.com/wrgl/wrgl/pkg/parquet"
+)
+
+type parquetReader struct {
+	r          *parquet.Reader
+	headerRead bool
+}
+
+// NewParquetReader returns a reader of rows from a Parquet file. Columns must
+// not be nested. Nulls are read as empty strings, other values are formatted
+// according to their logical type, e.g. dates as 2006-01-02 and timestamps
+// as RFC 3339.
+func NewParquetReader(r io.ReaderAt, size int64) (Reader, error) {
+	pr, err := parquet.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return &parquetReader{r: pr}, nil
+}
+
+func (r *parquetReader) Read() ([]string, error) {
+	if !r.headerRead {
+		r.headerRead = true
+		return r.r.Columns(), nil
+	}
+	return r.r.Read()
+}
@@ -0,0 +1,129 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+// Package rowsource reads rows from tabular files of different formats so
+// that they can be ingested the same way as CSV files.
+package rowsource
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Reader reads rows one at a time. The first row holds column names. Read
+// returns io.EOF after the last row. The returned slice may be reused by the
+// next call to Read.
+type Reader interface {
+	Read() ([]string, error)
+}
+
+const (
+	FormatCSV     = "csv"
+	FormatParquet = "parquet"
+	FormatJSONL   = "jsonl"
+	FormatXLSX    = "xlsx"
+)
+
+// Formats are all supported formats
+var Formats = []string{FormatCSV, FormatParquet, FormatJSONL, FormatXLSX}
+
+// FormatFromPath returns the format of a file based on its extension. A
+// compression extension such as ".gz" is skipped over. Files with unknown
+// extensions are assumed to be CSV files.
+func FormatFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(trimCompressionExt(path))) {
+	case ".parquet", ".pq":
+		return FormatParquet
+	case ".jsonl", ".ndjson":
+		return FormatJSONL
+	case ".xlsx":
+		return FormatXLSX
+	}
+	return FormatCSV
+}
+
+type options struct {
+	delimiter   rune
+	compression string
+}
+
+type Option func(o *options)
+
+// WithDelimiter sets the delimiter of CSV files. Defaults to comma.
+func WithDelimiter(delimiter rune) Option {
+	return func(o *options) {
+		o.delimiter = delimiter
+	}
+}
+
+// WithCompression makes NewReader decompress its input with the given
+// compression, which is one of the values returned by CompressionFromPath.
+// Input is not decompressed if compression is empty.
+func WithCompression(compression string) Option {
+	return func(o *options) {
+		o.compression = compression
+	}
+}
+
+// NewCSVReader returns a reader of CSV rows. Comma is used if delimiter is 0.
+func NewCSVReader(r io.Reader, delimiter rune) Reader {
+	cr := csv.NewReader(r)
+	if delimiter != 0 {
+		cr.Comma = delimiter
+	}
+	cr.ReuseRecord = true
+	return cr
+}
+
+// NewReader returns a reader of rows from r in the given format. Parquet and
+// XLSX files can only be read with random access, so r is read into memory
+// first unless it is an uncompressed regular file.
+func NewReader(r io.Reader, format string, opts ...Option) (Reader, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.compression != "" {
+		var err error
+		if r, err = NewDecompressor(r, o.compression); err != nil {
+			return nil, err
+		}
+	}
+	switch format {
+	case FormatCSV:
+		return NewCSVReader(r, o.delimiter), nil
+	case FormatJSONL:
+		return NewJSONLReader(r), nil
+	case FormatParquet:
+		ra, size, err := readerAt(r)
+		if err != nil {
+			return nil, err
+		}
+		return NewParquetReader(ra, size)
+	case FormatXLSX:
+		ra, size, err := readerAt(r)
+		if err != nil {
+			return nil, err
+		}
+		return NewXLSXReader(ra, size)
+	}
+	return nil, fmt.Errorf("invalid format %q, must be one of %s", format, strings.Join(Formats, ", "))
+}
+
+func readerAt(r io.Reader) (io.ReaderAt, int64, error) {
+	if f, ok := r.(*os.File); ok {
+		if fi, err := f.Stat(); err == nil && fi.Mode().IsRegular() {
+			return f, fi.Size(), nil
+		}
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	return bytes.NewReader(b), int64(len(b)), nil
+}
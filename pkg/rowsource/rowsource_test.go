@@ -0,0 +1,305 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package rowsource
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wrgl/wrgl/pkg/parquet"
+)
+
+func readAll(t *testing.T, r Reader) [][]string {
+	t.Helper()
+	var rows [][]string
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			return rows
+		}
+		require.NoError(t, err)
+		rows = append(rows, append([]string(nil), row...))
+	}
+}
+
+func TestFormatFromPath(t *testing.T) {
+	for path, format := range map[string]string{
+		"data.csv":          FormatCSV,
+		"data.tsv":          FormatCSV,
+		"-":                 FormatCSV,
+		"dir/data.parquet":  FormatParquet,
+		"data.jsonl":        FormatJSONL,
+		"data.ndjson":       FormatJSONL,
+		"/tmp/Data.XLSX":    FormatXLSX,
+		"data.parquet.json": FormatCSV,
+		"data.csv.gz":       FormatCSV,
+		"data.gz":           FormatCSV,
+		"data.jsonl.ZST":    FormatJSONL,
+		"data.parquet.bz2":  FormatParquet,
+	} {
+		assert.Equal(t, format, FormatFromPath(path), path)
+	}
+}
+
+func TestCompressionFromPath(t *testing.T) {
+	for path, compression := range map[string]string{
+		"data.csv":     "",
+		"data.csv.gz":  CompressionGzip,
+		"data.CSV.GZ":  CompressionGzip,
+		"data.zst":     CompressionZstd,
+		"data.csv.bz2": CompressionBzip2,
+		"data.gz.csv":  "",
+	} {
+		assert.Equal(t, compression, CompressionFromPath(path), path)
+	}
+}
+
+func TestNewReaderWithCompression(t *testing.T) {
+	rows := [][]string{{"a", "b"}, {"1", "2"}}
+
+	buf := bytes.NewBuffer(nil)
+	gw := gzip.NewWriter(buf)
+	_, err := gw.Write([]byte("a,b\n1,2\n"))
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), FormatCSV, WithCompression(CompressionGzip))
+	require.NoError(t, err)
+	assert.Equal(t, rows, readAll(t, r))
+
+	buf.Reset()
+	zw, err := zstd.NewWriter(buf)
+	require.NoError(t, err)
+	_, err = zw.Write([]byte(`{"a": 1, "b": 2}`))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	r, err = NewReader(bytes.NewReader(buf.Bytes()), FormatJSONL, WithCompression(CompressionZstd))
+	require.NoError(t, err)
+	assert.Equal(t, rows, readAll(t, r))
+
+	// bzip2 -c of "a,b\n1,2\n"
+	b, err := hex.DecodeString("425a6839314159265359bf87407f00000359000010000430003000200030c00869b28823278bb9229c28485fc3a03f80")
+	require.NoError(t, err)
+	r, err = NewReader(bytes.NewReader(b), FormatCSV, WithCompression(CompressionBzip2))
+	require.NoError(t, err)
+	assert.Equal(t, rows, readAll(t, r))
+
+	_, err = NewReader(strings.NewReader("a,b\n1,2\n3,4\n5,6\n"), FormatCSV, WithCompression(CompressionGzip))
+	assert.EqualError(t, err, "error reading gzip stream: gzip: invalid header")
+
+	_, err = NewReader(strings.NewReader(""), FormatCSV, WithCompression("lz4"))
+	assert.EqualError(t, err, `invalid compression "lz4", must be one of gzip, zstd, bzip2`)
+}
+
+func TestNewReader(t *testing.T) {
+	r, err := NewReader(strings.NewReader("a|b\n1|2\n"), FormatCSV, WithDelimiter('|'))
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"a", "b"}, {"1", "2"}}, readAll(t, r))
+
+	_, err = NewReader(strings.NewReader(""), "xml")
+	assert.EqualError(t, err, `invalid format "xml", must be one of csv, parquet, jsonl, xlsx`)
+}
+
+func TestJSONLReader(t *testing.T) {
+	r := NewJSONLReader(strings.NewReader(strings.Join([]string{
+		`{"id": 1, "name": "a\"b", "tags": ["x", "y"], "ok": true}`,
+		``,
+		`{"name": "c", "id": 2.50, "ok": null, "tags": {"k": 1}}`,
+		`{"id": 3}`,
+	}, "\n")))
+	assert.Equal(t, [][]string{
+		{"id", "name", "tags", "ok"},
+		{"1", `a"b`, `["x","y"]`, "true"},
+		{"2.50", "c", `{"k":1}`, ""},
+		{"3", "", "", ""},
+	}, readAll(t, r))
+
+	r = NewJSONLReader(strings.NewReader("{\"id\": 1}\n{\"id\": 2, \"name\": \"a\"}\n"))
+	_, err := r.Read()
+	require.NoError(t, err)
+	_, err = r.Read()
+	require.NoError(t, err)
+	_, err = r.Read()
+	assert.EqualError(t, err, `line 2: unknown key "name", all keys must appear in the first object`)
+
+	r = NewJSONLReader(strings.NewReader("[1, 2]\n"))
+	_, err = r.Read()
+	assert.EqualError(t, err, "line 1: expecting a JSON object")
+
+	r = NewJSONLReader(strings.NewReader(""))
+	_, err = r.Read()
+	assert.Equal(t, io.EOF, err)
+}
+
+func writeXLSX(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	buf := bytes.NewBuffer(nil)
+	zw := zip.NewWriter(buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestXLSXReader(t *testing.T) {
+	b := writeXLSX(t, map[string]string{
+		"xl/workbook.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Data" sheetId="1" r:id="rId3"/><sheet name="Other" sheetId="2" r:id="rId1"/></sheets>
+</workbook>`,
+		"xl/_rels/workbook.xml.rels": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet2.xml"/>
+</Relationships>`,
+		"xl/sharedStrings.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" count="4" uniqueCount="4">
+<si><t>id</t></si><si><t>name</t></si><si><t>active</t></si>
+<si><r><t>Ja</t></r><r><rPr><b/></rPr><t xml:space="preserve">ne &amp; co</t></r><rPh sb="0" eb="1"><t>x</t></rPh></si>
+</sst>`,
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row r="1"><c r="A1"><v>wrong sheet</v></c></row></sheetData></worksheet>`,
+		"xl/worksheets/sheet2.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<sheetData>
+<row r="2"><c r="A2" t="s"><v>0</v></c><c r="B2" t="s"><v>1</v></c><c r="C2" t="s"><v>2</v></c></row>
+<row r="3"><c r="A3"><v>1</v></c><c r="B3" t="s"><v>3</v></c><c r="C3" t="b"><v>1</v></c></row>
+<row r="4" spans="1:3"><c r="A4" s="1"/></row>
+<row r="5"><c r="A5"><f>A3+1</f><v>2</v></c><c r="C5" t="b"><v>0</v></c></row>
+<row r="6"><c r="A6"><v>3.5</v></c><c r="B6" t="inlineStr"><is><t>inline</t></is></c></row>
+</sheetData>
+</worksheet>`,
+	})
+	r, err := NewReader(bytes.NewReader(b), FormatXLSX)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"id", "name", "active"},
+		{"1", "Jane & co", "TRUE"},
+		{"2", "", "FALSE"},
+		{"3.5", "inline", ""},
+	}, readAll(t, r))
+
+	b = writeXLSX(t, map[string]string{
+		"xl/workbook.xml":          `<workbook><sheets><sheet name="Sheet1" sheetId="1"/></sheets></workbook>`,
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row><c><v>a</v></c></row><row><c><v>1</v></c><c><v>2</v></c></row></sheetData></worksheet>`,
+	})
+	r, err = NewXLSXReader(bytes.NewReader(b), int64(len(b)))
+	require.NoError(t, err)
+	_, err = r.Read()
+	require.NoError(t, err)
+	_, err = r.Read()
+	assert.EqualError(t, err, "row 2 has a value in column 2 but the first row only has 1 columns")
+
+	_, err = NewXLSXReader(strings.NewReader("a,b"), 3)
+	assert.Error(t, err)
+}
+
+func TestXLSXReaderDates(t *testing.T) {
+	styles := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<numFmts count="3">
+<numFmt numFmtId="164" formatCode="yyyy\-mm\-dd\ hh:mm"/>
+<numFmt numFmtId="165" formatCode="&quot;day&quot;\ 0.00"/>
+<numFmt numFmtId="166" formatCode="[Red][&lt;0]0;dd/mm/yyyy"/>
+</numFmts>
+<cellXfs count="5">
+<xf numFmtId="0"/><xf numFmtId="14"/><xf numFmtId="164"/><xf numFmtId="165"/><xf numFmtId="166"/>
+</cellXfs>
+</styleSheet>`
+	sheet := `<worksheet><sheetData>
+<row><c t="inlineStr"><is><t>a</t></is></c><c t="inlineStr"><is><t>b</t></is></c><c t="inlineStr"><is><t>c</t></is></c><c t="inlineStr"><is><t>d</t></is></c><c t="inlineStr"><is><t>e</t></is></c></row>
+<row><c s="0"><v>44927</v></c><c s="1"><v>44927</v></c><c s="2"><v>44927.5</v></c><c s="3"><v>44927</v></c><c s="4"><v>44927</v></c></row>
+<row><c s="1" t="inlineStr"><is><t>not a date</t></is></c><c s="1"/><c s="2"><v>0.25</v></c></row>
+</sheetData></worksheet>`
+	b := writeXLSX(t, map[string]string{
+		"xl/workbook.xml":          `<workbook><sheets><sheet name="Sheet1" sheetId="1"/></sheets></workbook>`,
+		"xl/styles.xml":            styles,
+		"xl/worksheets/sheet1.xml": sheet,
+	})
+	r, err := NewXLSXReader(bytes.NewReader(b), int64(len(b)))
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"a", "b", "c", "d", "e"},
+		{"44927", "2023-01-01", "2023-01-01T12:00:00Z", "44927", "44927"},
+		{"not a date", "", "1899-12-30T06:00:00Z", "", ""},
+	}, readAll(t, r))
+
+	b = writeXLSX(t, map[string]string{
+		"xl/workbook.xml":          `<workbook><workbookPr date1904="1"/><sheets><sheet name="Sheet1" sheetId="1"/></sheets></workbook>`,
+		"xl/styles.xml":            styles,
+		"xl/worksheets/sheet1.xml": sheet,
+	})
+	r, err = NewXLSXReader(bytes.NewReader(b), int64(len(b)))
+	require.NoError(t, err)
+	rows := readAll(t, r)
+	assert.Equal(t, []string{"44927", "2027-01-02", "2027-01-02T12:00:00Z", "44927", "44927"}, rows[1])
+}
+
+func TestParquetReader(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	w := parquet.NewWriter(buf, []parquet.Column{{Name: "id", Type: parquet.Int64}, {Name: "name", Type: parquet.String}})
+	require.NoError(t, w.Write([]string{"1", "a"}))
+	require.NoError(t, w.Write([]string{"", "b"}))
+	require.NoError(t, w.Close())
+
+	// read from a regular file
+	path := filepath.Join(t.TempDir(), "data.parquet")
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	r, err := NewReader(f, FormatFromPath(path))
+	require.NoError(t, err)
+	rows := [][]string{{"id", "name"}, {"1", "a"}, {"", "b"}}
+	assert.Equal(t, rows, readAll(t, r))
+
+	// read from a stream
+	r, err = NewReader(io.NopCloser(bytes.NewReader(buf.Bytes())), FormatParquet)
+	require.NoError(t, err)
+	assert.Equal(t, rows, readAll(t, r))
+
+	// read from a compressed file
+	gzBuf := bytes.NewBuffer(nil)
+	gw := gzip.NewWriter(gzBuf)
+	_, err = gw.Write(buf.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+	r, err = NewReader(gzBuf, FormatParquet, WithCompression(CompressionGzip))
+	require.NoError(t, err)
+	assert.Equal(t, rows, readAll(t, r))
+}
+
+func TestSQLReader(t *testing.T) {
+	db, err := OpenSQL("sqlite://" + filepath.Join(t.TempDir(), "data.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec(`CREATE TABLE users (id INTEGER, name TEXT, score REAL, joined DATETIME)`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO users VALUES (1, 'a', 1.5, '2022-01-02 03:04:05'), (2, NULL, NULL, NULL)`)
+	require.NoError(t, err)
+
+	rows, err := db.Query(`SELECT * FROM users ORDER BY id`)
+	require.NoError(t, err)
+	defer rows.Close()
+	assert.Equal(t, [][]string{
+		{"id", "name", "score", "joined"},
+		{"1", "a", "1.5", "2022-01-02T03:04:05Z"},
+		{"2", "", "", ""},
+	}, readAll(t, NewSQLReader(rows)))
+
+	_, err = OpenSQL("oracle://localhost/db")
+	assert.EqualError(t, err, `unsupported database "oracle", URL must start with postgres://, mysql:// or sqlite://`)
+}
@@ -0,0 +1,465 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package rowsource
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wrgl/wrgl/pkg/schema"
+)
+
+// dateFormat tells whether a number format shows numbers as dates
+type dateFormat uint8
+
+const (
+	notDate dateFormat = iota
+	// dateOnly formats show the date part of a number
+	dateOnly
+	// dateTime formats show the time part of a number, with or without the
+	// date part
+	dateTime
+)
+
+var (
+	// epoch1900 is the date of serial number 0 in the 1900 date system. It
+	// is December 30 rather than 31 because the 1900 date system considers
+	// 1900 a leap year, so serial numbers from March 1, 1900 onwards are
+	// converted correctly.
+	epoch1900 = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	epoch1904 = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// xlsxReader streams rows of the first worksheet of an XLSX workbook
+type xlsxReader struct {
+	dec           *xml.Decoder
+	closer        io.Closer
+	sharedStrings []string
+	// dateStyles tells the date format of each cell style
+	dateStyles []dateFormat
+	epoch      time.Time
+	// width is the number of columns, taken from the first row
+	width int
+	row   []string
+	// n is the number of rows read, which is used as the row number of rows
+	// that don't have one
+	n int
+}
+
+// NewXLSXReader returns a reader of rows from the first worksheet of an XLSX
+// workbook. The first non-empty row holds column names. Rows where all cells
+// are empty are skipped. Cell values are read as stored in the file, which
+// means formulas are read as their last calculated values. Numbers shown as
+// dates are read as YYYY-MM-DD, or in RFC 3339 format if their number format
+// shows the time of day.
+func NewXLSXReader(r io.ReaderAt, size int64) (Reader, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("not an XLSX file: %v", err)
+	}
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	sheetPath, date1904, err := readWorkbook(files)
+	if err != nil {
+		return nil, err
+	}
+	xr := &xlsxReader{epoch: epoch1900}
+	if date1904 {
+		xr.epoch = epoch1904
+	}
+	if f, ok := files["xl/sharedStrings.xml"]; ok {
+		if xr.sharedStrings, err = readSharedStrings(f); err != nil {
+			return nil, fmt.Errorf("error reading shared strings: %v", err)
+		}
+	}
+	if f, ok := files["xl/styles.xml"]; ok {
+		if xr.dateStyles, err = readDateStyles(f); err != nil {
+			return nil, fmt.Errorf("error reading styles: %v", err)
+		}
+	}
+	f, ok := files[sheetPath]
+	if !ok {
+		return nil, fmt.Errorf("worksheet %q not found", sheetPath)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	xr.dec = xml.NewDecoder(rc)
+	xr.closer = rc
+	return xr, nil
+}
+
+func openXML(f *zip.File, v interface{}) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return xml.NewDecoder(rc).Decode(v)
+}
+
+// readWorkbook returns the path of the first worksheet listed in the
+// workbook and whether the workbook uses the 1904 date system
+func readWorkbook(files map[string]*zip.File) (sheetPath string, date1904 bool, err error) {
+	wbFile, ok := files["xl/workbook.xml"]
+	if !ok {
+		return "", false, fmt.Errorf("not an XLSX file: xl/workbook.xml not found")
+	}
+	var wb struct {
+		Pr struct {
+			Date1904 string `xml:"date1904,attr"`
+		} `xml:"workbookPr"`
+		Sheets []struct {
+			ID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := openXML(wbFile, &wb); err != nil {
+		return "", false, fmt.Errorf("error reading workbook: %v", err)
+	}
+	if len(wb.Sheets) == 0 {
+		return "", false, fmt.Errorf("workbook has no worksheet")
+	}
+	date1904 = wb.Pr.Date1904 == "1" || wb.Pr.Date1904 == "true"
+	if relsFile, ok := files["xl/_rels/workbook.xml.rels"]; ok {
+		var rels struct {
+			Relationships []struct {
+				ID     string `xml:"Id,attr"`
+				Target string `xml:"Target,attr"`
+			} `xml:"Relationship"`
+		}
+		if err := openXML(relsFile, &rels); err != nil {
+			return "", false, fmt.Errorf("error reading workbook relationships: %v", err)
+		}
+		for _, rel := range rels.Relationships {
+			if rel.ID == wb.Sheets[0].ID {
+				if strings.HasPrefix(rel.Target, "/") {
+					return strings.TrimPrefix(rel.Target, "/"), date1904, nil
+				}
+				return path.Join("xl", rel.Target), date1904, nil
+			}
+		}
+	}
+	return "xl/worksheets/sheet1.xml", date1904, nil
+}
+
+// builtInDateFormats are the date formats among built-in number formats,
+// keyed by number format id
+var builtInDateFormats = map[int]dateFormat{
+	14: dateOnly, 15: dateOnly, 16: dateOnly, 17: dateOnly,
+	18: dateTime, 19: dateTime, 20: dateTime, 21: dateTime, 22: dateTime,
+	27: dateOnly, 28: dateOnly, 29: dateOnly, 30: dateOnly, 31: dateOnly,
+	32: dateTime, 33: dateTime, 34: dateOnly, 35: dateOnly, 36: dateOnly,
+	45: dateTime, 46: dateTime, 47: dateTime,
+	50: dateOnly, 51: dateOnly, 52: dateOnly, 53: dateOnly, 54: dateOnly,
+	55: dateTime, 56: dateTime, 57: dateOnly, 58: dateOnly,
+}
+
+// formatCodeDateFormat tells whether a custom number format code shows
+// numbers as dates. Only the first section of the code, which applies to
+// positive numbers, is looked at. Literal text, colors and conditions are
+// skipped over.
+func formatCodeDateFormat(code string) dateFormat {
+	var sb strings.Builder
+loop:
+	for i := 0; i < len(code); i++ {
+		c := code[i]
+		switch c {
+		case '"':
+			j := strings.IndexByte(code[i+1:], '"')
+			if j < 0 {
+				break loop
+			}
+			i += j + 1
+		case '\\', '_', '*':
+			i++
+		case '[':
+			j := strings.IndexByte(code[i+1:], ']')
+			if j < 0 {
+				break loop
+			}
+			// elapsed time such as [h] or [mm]
+			if tok := strings.ToLower(code[i+1 : i+1+j]); tok != "" && strings.Trim(tok, "hms") == "" {
+				sb.WriteString(tok)
+			}
+			i += j + 1
+		case ';':
+			break loop
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	s := strings.ToLower(sb.String())
+	switch {
+	case strings.ContainsAny(s, "hs") || strings.Contains(s, "am/pm"):
+		return dateTime
+	case strings.ContainsAny(s, "ymd"):
+		return dateOnly
+	}
+	return notDate
+}
+
+// readDateStyles returns the date format of each cell style
+func readDateStyles(f *zip.File) ([]dateFormat, error) {
+	var ss struct {
+		NumFmts []struct {
+			ID   int    `xml:"numFmtId,attr"`
+			Code string `xml:"formatCode,attr"`
+		} `xml:"numFmts>numFmt"`
+		CellXfs []struct {
+			NumFmtID int `xml:"numFmtId,attr"`
+		} `xml:"cellXfs>xf"`
+	}
+	if err := openXML(f, &ss); err != nil {
+		return nil, err
+	}
+	custom := make(map[int]string, len(ss.NumFmts))
+	for _, nf := range ss.NumFmts {
+		custom[nf.ID] = nf.Code
+	}
+	result := make([]dateFormat, len(ss.CellXfs))
+	for i, xf := range ss.CellXfs {
+		if code, ok := custom[xf.NumFmtID]; ok {
+			result[i] = formatCodeDateFormat(code)
+		} else {
+			result[i] = builtInDateFormats[xf.NumFmtID]
+		}
+	}
+	return result, nil
+}
+
+// formatDate formats a serial number as a date, or returns v unchanged if it
+// isn't a number
+func (r *xlsxReader) formatDate(v string, format dateFormat) string {
+	f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return v
+	}
+	t := r.epoch.Add(time.Duration(math.Round(f*86400)) * time.Second)
+	if format == dateOnly {
+		return t.Format(schema.DateLayout)
+	}
+	return t.Format(time.RFC3339)
+}
+
+// cellDateFormat returns the date format of a cell's style
+func (r *xlsxReader) cellDateFormat(el xml.StartElement) dateFormat {
+	s := attr(el, "s")
+	if s == "" {
+		return notDate
+	}
+	i, err := strconv.Atoi(s)
+	if err != nil || i < 0 || i >= len(r.dateStyles) {
+		return notDate
+	}
+	return r.dateStyles[i]
+}
+
+// readSharedStrings reads the text of each shared string, leaving out
+// phonetic hints
+func readSharedStrings(f *zip.File) ([]string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	dec := xml.NewDecoder(rc)
+	var result []string
+	var sb strings.Builder
+	var inText, inPhonetic bool
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return result, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "si":
+				sb.Reset()
+			case "t":
+				inText = !inPhonetic
+			case "rPh":
+				inPhonetic = true
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "si":
+				result = append(result, sb.String())
+			case "t":
+				inText = false
+			case "rPh":
+				inPhonetic = false
+			}
+		case xml.CharData:
+			if inText {
+				sb.Write(t)
+			}
+		}
+	}
+}
+
+// columnIndex returns the zero-based column index of a cell reference such
+// as "AB12"
+func columnIndex(ref string) (int, bool) {
+	var n int
+	var i int
+	for i < len(ref) && ref[i] >= 'A' && ref[i] <= 'Z' {
+		n = n*26 + int(ref[i]-'A') + 1
+		i++
+	}
+	if i == 0 {
+		return 0, false
+	}
+	return n - 1, true
+}
+
+func attr(el xml.StartElement, name string) string {
+	for _, a := range el.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// readCell reads the value of a cell whose start element has been read
+func (r *xlsxReader) readCell(el xml.StartElement) (string, error) {
+	typ := attr(el, "t")
+	var value strings.Builder
+	var inValue bool
+	for {
+		tok, err := r.dec.Token()
+		if err != nil {
+			return "", err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			// <v> holds the value, <t> inside <is> holds inline strings
+			inValue = t.Name.Local == "v" || t.Name.Local == "t"
+		case xml.EndElement:
+			if t.Name.Local == "c" {
+				v := value.String()
+				switch typ {
+				case "s":
+					i, err := strconv.Atoi(strings.TrimSpace(v))
+					if err != nil || i < 0 || i >= len(r.sharedStrings) {
+						return "", fmt.Errorf("cell %s: invalid shared string index %q", attr(el, "r"), v)
+					}
+					return r.sharedStrings[i], nil
+				case "b":
+					if v == "1" {
+						return "TRUE", nil
+					}
+					return "FALSE", nil
+				case "", "n":
+					if format := r.cellDateFormat(el); format != notDate && v != "" {
+						return r.formatDate(v, format), nil
+					}
+				}
+				return v, nil
+			}
+			inValue = false
+		case xml.CharData:
+			if inValue {
+				value.Write(t)
+			}
+		}
+	}
+}
+
+// readRow reads cells of a row whose start element has been read. It returns
+// values keyed by column index.
+func (r *xlsxReader) readRow() (map[int]string, int, error) {
+	cells := map[int]string{}
+	maxCol := -1
+	next := 0
+	for {
+		tok, err := r.dec.Token()
+		if err != nil {
+			return nil, 0, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local != "c" {
+				continue
+			}
+			col := next
+			if ref := attr(t, "r"); ref != "" {
+				var ok bool
+				if col, ok = columnIndex(ref); !ok {
+					return nil, 0, fmt.Errorf("invalid cell reference %q", ref)
+				}
+			}
+			next = col + 1
+			v, err := r.readCell(t)
+			if err != nil {
+				return nil, 0, err
+			}
+			if v != "" {
+				cells[col] = v
+				if col > maxCol {
+					maxCol = col
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Local == "row" {
+				return cells, maxCol, nil
+			}
+		}
+	}
+}
+
+func (r *xlsxReader) Read() ([]string, error) {
+	for {
+		tok, err := r.dec.Token()
+		if err == io.EOF {
+			r.closer.Close()
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		el, ok := tok.(xml.StartElement)
+		if !ok || el.Name.Local != "row" {
+			continue
+		}
+		r.n++
+		rowNo := attr(el, "r")
+		if rowNo == "" {
+			rowNo = strconv.Itoa(r.n)
+		} else if n, err := strconv.Atoi(rowNo); err == nil {
+			r.n = n
+		}
+		cells, maxCol, err := r.readRow()
+		if err != nil {
+			return nil, fmt.Errorf("row %s: %v", rowNo, err)
+		}
+		if len(cells) == 0 {
+			continue
+		}
+		if r.row == nil {
+			r.width = maxCol + 1
+			r.row = make([]string, r.width)
+		} else if maxCol >= r.width {
+			return nil, fmt.Errorf("row %s has a value in column %d but the first row only has %d columns", rowNo, maxCol+1, r.width)
+		}
+		for i := range r.row {
+			r.row[i] = cells[i]
+		}
+		return r.row, nil
+	}
+}
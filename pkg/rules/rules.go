@@ -0,0 +1,345 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+// Package rules checks rows against the validation rules of a branch as they
+// are read, so that a commit can be rejected with a report of offending rows.
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/wrgl/wrgl/pkg/api/payload"
+	"github.com/wrgl/wrgl/pkg/conf"
+	"github.com/wrgl/wrgl/pkg/objects"
+	"github.com/wrgl/wrgl/pkg/ref"
+	"github.com/wrgl/wrgl/pkg/rowsource"
+	"github.com/wrgl/wrgl/pkg/slice"
+)
+
+// MaxReportedViolations is the number of violations described in Error, the
+// rest are only counted
+const MaxReportedViolations = 20
+
+// Violation describes a value that breaks a rule
+type Violation struct {
+	// Row is the 1-based number of the row, not counting the header
+	Row     int
+	Column  string
+	Message string
+	// CSV is the location of the value if the input is a CSV file
+	CSV *payload.CSVLocation
+}
+
+func (v *Violation) String() string {
+	if v.CSV != nil {
+		return fmt.Sprintf("row %d (line %d, column %d): %s", v.Row, v.CSV.Line, v.CSV.Column, v.Message)
+	}
+	return fmt.Sprintf("row %d: %s", v.Row, v.Message)
+}
+
+// Error is returned after the last row has been read if any row breaks a
+// rule
+type Error struct {
+	// Violations are the first MaxReportedViolations violations
+	Violations []*Violation
+	// Count is the total number of violations
+	Count int
+}
+
+func (e *Error) Error() string {
+	noun := "violations"
+	if e.Count == 1 {
+		noun = "violation"
+	}
+	lines := []string{fmt.Sprintf("found %d rule %s:", e.Count, noun)}
+	for _, v := range e.Violations {
+		lines = append(lines, "  "+v.String())
+	}
+	if n := e.Count - len(e.Violations); n > 0 {
+		lines = append(lines, fmt.Sprintf("  and %d more", n))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// References holds primary key values of referenced branches by branch name
+type References map[string]map[string]struct{}
+
+// LoadReferences reads primary key values of all branches referenced by
+// rules
+func LoadReferences(db objects.Store, rs ref.Store, rules *conf.Rules) (References, error) {
+	refs := References{}
+	cols := make([]string, 0, len(rules.References))
+	for col := range rules.References {
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+	for _, col := range cols {
+		branch := rules.References[col]
+		if _, ok := refs[branch]; ok {
+			continue
+		}
+		sum, err := ref.GetHead(rs, branch)
+		if err != nil {
+			return nil, fmt.Errorf("error reading branch %q referenced by column %q: %v", branch, col, err)
+		}
+		com, err := objects.GetCommit(db, sum)
+		if err != nil {
+			return nil, err
+		}
+		tbl, err := objects.GetTable(db, com.Table)
+		if err != nil {
+			return nil, err
+		}
+		if len(tbl.PK) != 1 {
+			return nil, fmt.Errorf("branch %q referenced by column %q must have a single column primary key", branch, col)
+		}
+		values := make(map[string]struct{}, tbl.RowsCount)
+		var (
+			bb  []byte
+			blk [][]string
+		)
+		for _, blkSum := range tbl.Blocks {
+			blk, bb, err = objects.GetBlockColumns(db, bb, blkSum, tbl.PK)
+			if err != nil {
+				return nil, err
+			}
+			for _, row := range blk {
+				values[row[0]] = struct{}{}
+			}
+		}
+		refs[branch] = values
+	}
+	return refs, nil
+}
+
+// check returns a message describing how v breaks a rule, or an empty
+// string. row is the current row number.
+type check func(v string, row int) string
+
+type reader struct {
+	r        rowsource.Reader
+	rules    *conf.Rules
+	refs     References
+	patterns map[string]*regexp.Regexp
+	checks   [][]check
+	columns  []string
+	row      int
+	err      *Error
+}
+
+// fieldPositioner is implemented by readers that know where each field of
+// the last row is in the input, such as *csv.Reader
+type fieldPositioner interface {
+	FieldPos(field int) (line, column int)
+}
+
+// NewReader returns a reader that checks every row read from r against
+// rules. After the last row, it returns an *Error instead of io.EOF if any
+// rule was broken. refs must hold all branches referenced by rules (see
+// LoadReferences).
+func NewReader(r rowsource.Reader, rules *conf.Rules, refs References) (rowsource.Reader, error) {
+	rd := &reader{
+		r:        r,
+		rules:    rules,
+		refs:     refs,
+		patterns: map[string]*regexp.Regexp{},
+		err:      &Error{},
+	}
+	for col, s := range rules.Patterns {
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern for column %q: %v", col, err)
+		}
+		rd.patterns[col] = re
+	}
+	for col, rng := range rules.Ranges {
+		if rng != nil && rng.Min != nil && rng.Max != nil && *rng.Min > *rng.Max {
+			return nil, fmt.Errorf("invalid range for column %q: min is greater than max", col)
+		}
+	}
+	for col, branch := range rules.References {
+		if _, ok := refs[branch]; !ok {
+			return nil, fmt.Errorf("branch %q referenced by column %q is not loaded", branch, col)
+		}
+	}
+	return rd, nil
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// ruleColumns returns all columns named in rules
+func ruleColumns(rules *conf.Rules) []string {
+	cols := append([]string{}, rules.NotNull...)
+	cols = append(cols, rules.Unique...)
+	for col := range rules.Patterns {
+		cols = append(cols, col)
+	}
+	for col := range rules.Ranges {
+		cols = append(cols, col)
+	}
+	for col := range rules.Allowed {
+		cols = append(cols, col)
+	}
+	for col := range rules.References {
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+	return cols
+}
+
+// compile builds the checks of each column once column names are known
+func (r *reader) compile(columns []string) error {
+	r.columns = make([]string, len(columns))
+	copy(r.columns, columns)
+	for _, col := range ruleColumns(r.rules) {
+		if !slice.StringSliceContains(columns, col) {
+			return fmt.Errorf("column %q in branch rules not found", col)
+		}
+	}
+	r.checks = make([][]check, len(columns))
+	for i, col := range columns {
+		r.checks[i] = r.columnChecks(col)
+	}
+	return nil
+}
+
+func (r *reader) columnChecks(col string) (checks []check) {
+	if slice.StringSliceContains(r.rules.NotNull, col) {
+		checks = append(checks, func(v string, row int) string {
+			if v == "" {
+				return fmt.Sprintf("column %q must not be empty", col)
+			}
+			return ""
+		})
+	}
+	if slice.StringSliceContains(r.rules.Unique, col) {
+		seen := map[string]int{}
+		checks = append(checks, func(v string, row int) string {
+			if v == "" {
+				return ""
+			}
+			if first, ok := seen[v]; ok {
+				return fmt.Sprintf("value %q of column %q is not unique, first seen in row %d", v, col, first)
+			}
+			seen[v] = row
+			return ""
+		})
+	}
+	if re, ok := r.patterns[col]; ok {
+		checks = append(checks, func(v string, row int) string {
+			if v != "" && !re.MatchString(v) {
+				return fmt.Sprintf("value %q of column %q does not match pattern %q", v, col, re.String())
+			}
+			return ""
+		})
+	}
+	if rng := r.rules.Ranges[col]; rng != nil {
+		checks = append(checks, func(v string, row int) string {
+			if v == "" {
+				return ""
+			}
+			f, err := strconv.ParseFloat(v, 64)
+			// NaN would pass any range since it doesn't compare with numbers
+			if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+				return fmt.Sprintf("value %q of column %q is not a number", v, col)
+			}
+			if rng.Min != nil && f < *rng.Min {
+				return fmt.Sprintf("value %q of column %q is less than %s", v, col, formatFloat(*rng.Min))
+			}
+			if rng.Max != nil && f > *rng.Max {
+				return fmt.Sprintf("value %q of column %q is greater than %s", v, col, formatFloat(*rng.Max))
+			}
+			return ""
+		})
+	}
+	if allowed, ok := r.rules.Allowed[col]; ok {
+		set := make(map[string]struct{}, len(allowed))
+		for _, v := range allowed {
+			set[v] = struct{}{}
+		}
+		checks = append(checks, func(v string, row int) string {
+			if _, ok := set[v]; v != "" && !ok {
+				return fmt.Sprintf("value %q of column %q is not one of %s", v, col, strings.Join(allowed, ", "))
+			}
+			return ""
+		})
+	}
+	if branch, ok := r.rules.References[col]; ok {
+		values := r.refs[branch]
+		checks = append(checks, func(v string, row int) string {
+			if _, ok := values[v]; v != "" && !ok {
+				return fmt.Sprintf("value %q of column %q is not found in branch %q", v, col, branch)
+			}
+			return ""
+		})
+	}
+	return checks
+}
+
+func (r *reader) report(field int, msg string) {
+	r.err.Count++
+	if len(r.err.Violations) >= MaxReportedViolations {
+		return
+	}
+	v := &Violation{
+		Row:     r.row,
+		Column:  r.columns[field],
+		Message: msg,
+	}
+	if fp, ok := r.r.(fieldPositioner); ok {
+		startLine, _ := fp.FieldPos(0)
+		line, column := fp.FieldPos(field)
+		v.CSV = &payload.CSVLocation{
+			StartLine: startLine,
+			Line:      line,
+			Column:    column,
+		}
+	}
+	r.err.Violations = append(r.err.Violations, v)
+}
+
+func (r *reader) Read() ([]string, error) {
+	row, err := r.r.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) && r.err.Count > 0 {
+			return nil, r.err
+		}
+		return nil, err
+	}
+	if r.checks == nil {
+		if err := r.compile(row); err != nil {
+			return nil, err
+		}
+		return row, nil
+	}
+	r.row++
+	for i, checks := range r.checks {
+		if i >= len(row) {
+			break
+		}
+		for _, c := range checks {
+			if msg := c(row[i], r.row); msg != "" {
+				r.report(i, msg)
+			}
+		}
+	}
+	return row, nil
+}
+
+// FieldPos returns the position of a field of the last row read, or zeros if
+// the underlying reader doesn't know it
+func (r *reader) FieldPos(field int) (line, column int) {
+	if fp, ok := r.r.(fieldPositioner); ok {
+		return fp.FieldPos(field)
+	}
+	return 0, 0
+}
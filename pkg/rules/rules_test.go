@@ -0,0 +1,159 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wrgl/wrgl/pkg/api/payload"
+	"github.com/wrgl/wrgl/pkg/conf"
+	"github.com/wrgl/wrgl/pkg/factory"
+	objmock "github.com/wrgl/wrgl/pkg/objects/mock"
+	refmock "github.com/wrgl/wrgl/pkg/ref/mock"
+	"github.com/wrgl/wrgl/pkg/rowsource"
+)
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+func readAll(t *testing.T, r rowsource.Reader) error {
+	t.Helper()
+	for {
+		_, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func TestReader(t *testing.T) {
+	db := objmock.NewStore()
+	rs, cleanup := refmock.NewStore(t)
+	defer cleanup()
+	factory.CommitHead(t, db, rs, "vendors", []string{
+		"id,name",
+		"v1,Acme",
+		"v2,Globex",
+	}, []uint32{0})
+	rules := &conf.Rules{
+		NotNull:  []string{"sku"},
+		Unique:   []string{"sku"},
+		Patterns: map[string]string{"sku": `^[A-Z]{3}-\d+$`},
+		Ranges: map[string]*conf.Range{
+			"price": {Min: floatPtr(0), Max: floatPtr(100)},
+		},
+		Allowed:    map[string][]string{"status": {"active", "retired"}},
+		References: map[string]string{"vendor": "vendors"},
+	}
+	refs, err := LoadReferences(db, rs, rules)
+	require.NoError(t, err)
+
+	content := strings.Join([]string{
+		"id,sku,price,status,vendor",
+		"1,ABC-1,10,active,v1",
+		"2,ABC-2,,,",
+		"3,ABC-1,120,sold,v3",
+		"4,,abc,active,v2",
+		"5,abc,-1,retired,v1",
+	}, "\n")
+	r, err := NewReader(rowsource.NewCSVReader(strings.NewReader(content), 0), rules, refs)
+	require.NoError(t, err)
+	err = readAll(t, r)
+	assert.Equal(t, &Error{
+		Count: 8,
+		Violations: []*Violation{
+			{Row: 3, Column: "sku", Message: `value "ABC-1" of column "sku" is not unique, first seen in row 1`, CSV: &payload.CSVLocation{StartLine: 4, Line: 4, Column: 3}},
+			{Row: 3, Column: "price", Message: `value "120" of column "price" is greater than 100`, CSV: &payload.CSVLocation{StartLine: 4, Line: 4, Column: 9}},
+			{Row: 3, Column: "status", Message: `value "sold" of column "status" is not one of active, retired`, CSV: &payload.CSVLocation{StartLine: 4, Line: 4, Column: 13}},
+			{Row: 3, Column: "vendor", Message: `value "v3" of column "vendor" is not found in branch "vendors"`, CSV: &payload.CSVLocation{StartLine: 4, Line: 4, Column: 18}},
+			{Row: 4, Column: "sku", Message: `column "sku" must not be empty`, CSV: &payload.CSVLocation{StartLine: 5, Line: 5, Column: 3}},
+			{Row: 4, Column: "price", Message: `value "abc" of column "price" is not a number`, CSV: &payload.CSVLocation{StartLine: 5, Line: 5, Column: 4}},
+			{Row: 5, Column: "sku", Message: `value "abc" of column "sku" does not match pattern "^[A-Z]{3}-\\d+$"`, CSV: &payload.CSVLocation{StartLine: 6, Line: 6, Column: 3}},
+			{Row: 5, Column: "price", Message: `value "-1" of column "price" is less than 0`, CSV: &payload.CSVLocation{StartLine: 6, Line: 6, Column: 7}},
+		},
+	}, err)
+}
+
+func TestReaderRangeNaN(t *testing.T) {
+	content := strings.Join([]string{
+		"price",
+		"NaN",
+		"inf",
+		"-Infinity",
+		"5",
+	}, "\n")
+	r, err := NewReader(rowsource.NewCSVReader(strings.NewReader(content), 0), &conf.Rules{
+		Ranges: map[string]*conf.Range{"price": {Min: floatPtr(0), Max: floatPtr(10)}},
+	}, nil)
+	require.NoError(t, err)
+	err = readAll(t, r)
+	var rulesErr *Error
+	require.True(t, errors.As(err, &rulesErr))
+	messages := []string{}
+	for _, v := range rulesErr.Violations {
+		messages = append(messages, v.Message)
+	}
+	assert.Equal(t, []string{
+		`value "NaN" of column "price" is not a number`,
+		`value "inf" of column "price" is not a number`,
+		`value "-Infinity" of column "price" is not a number`,
+	}, messages)
+}
+
+func TestReaderReportLimit(t *testing.T) {
+	rows := []string{"a"}
+	for i := 0; i < MaxReportedViolations+5; i++ {
+		rows = append(rows, "y")
+	}
+	r, err := NewReader(rowsource.NewCSVReader(strings.NewReader(strings.Join(rows, "\n")), 0), &conf.Rules{
+		Allowed: map[string][]string{"a": {"x"}},
+	}, nil)
+	require.NoError(t, err)
+	err = readAll(t, r)
+	var rulesErr *Error
+	require.True(t, errors.As(err, &rulesErr))
+	assert.Equal(t, MaxReportedViolations+5, rulesErr.Count)
+	assert.Len(t, rulesErr.Violations, MaxReportedViolations)
+	lines := strings.Split(err.Error(), "\n")
+	assert.Equal(t, "found 25 rule violations:", lines[0])
+	assert.Equal(t, `  row 1 (line 2, column 1): value "y" of column "a" is not one of x`, lines[1])
+	assert.Equal(t, "  and 5 more", lines[len(lines)-1])
+}
+
+func TestReaderInvalidRules(t *testing.T) {
+	_, err := NewReader(rowsource.NewCSVReader(strings.NewReader("a\n1"), 0), &conf.Rules{
+		Patterns: map[string]string{"a": "("},
+	}, nil)
+	assert.Equal(t, fmt.Errorf("invalid pattern for column \"a\": error parsing regexp: missing closing ): `(`"), err)
+
+	_, err = NewReader(rowsource.NewCSVReader(strings.NewReader("a\n1"), 0), &conf.Rules{
+		Ranges: map[string]*conf.Range{"a": {Min: floatPtr(2), Max: floatPtr(1)}},
+	}, nil)
+	assert.Equal(t, fmt.Errorf(`invalid range for column "a": min is greater than max`), err)
+
+	r, err := NewReader(rowsource.NewCSVReader(strings.NewReader("a\n1"), 0), &conf.Rules{
+		NotNull: []string{"b"},
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Errorf(`column "b" in branch rules not found`), readAll(t, r))
+
+	db := objmock.NewStore()
+	rs, cleanup := refmock.NewStore(t)
+	defer cleanup()
+	_, err = LoadReferences(db, rs, &conf.Rules{References: map[string]string{"a": "missing"}})
+	assert.Error(t, err)
+	factory.CommitHead(t, db, rs, "composite", []string{"a,b", "1,2"}, []uint32{0, 1})
+	_, err = LoadReferences(db, rs, &conf.Rules{References: map[string]string{"a": "composite"}})
+	assert.Equal(t, fmt.Errorf(`branch "composite" referenced by column "a" must have a single column primary key`), err)
+}
@@ -0,0 +1,180 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+// Package schema defines column types that can be stored with a table and
+// the rules to validate and compare values of those types. Values are still
+// stored as strings, types only restrict which strings are accepted. Empty
+// values are accepted by all types.
+package schema
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	TypeString    = "string"
+	TypeInteger   = "integer"
+	TypeDecimal   = "decimal"
+	TypeBoolean   = "boolean"
+	TypeDate      = "date"
+	TypeTimestamp = "timestamp"
+
+	DateLayout = "2006-01-02"
+)
+
+// Types lists all supported types
+var Types = []string{TypeString, TypeInteger, TypeDecimal, TypeBoolean, TypeDate, TypeTimestamp}
+
+var (
+	decimalPattern   = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$`)
+	timestampLayouts = []string{
+		time.RFC3339,
+		"2006-01-02T15:04:05",
+		"2006-01-02 15:04:05Z07:00",
+		"2006-01-02 15:04:05",
+	}
+)
+
+func isType(typ string) bool {
+	for _, t := range Types {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseTypes parses column types written as COLUMN:TYPE and returns them as
+// a map of column name to type
+func ParseTypes(specs []string) (map[string]string, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+	m := make(map[string]string, len(specs))
+	for _, s := range specs {
+		i := strings.LastIndex(s, ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid column type %q, expecting COLUMN:TYPE", s)
+		}
+		col, typ := s[:i], strings.ToLower(s[i+1:])
+		if !isType(typ) {
+			return nil, fmt.Errorf("invalid type %q for column %q, must be one of %s", typ, col, strings.Join(Types, ", "))
+		}
+		m[col] = typ
+	}
+	return m, nil
+}
+
+// ColumnTypes returns the type of each column in columns. Columns without a
+// type are strings. It returns nil if types is empty.
+func ColumnTypes(types map[string]string, columns []string) ([]string, error) {
+	if len(types) == 0 {
+		return nil, nil
+	}
+	sl := make([]string, len(columns))
+	found := 0
+	for i, col := range columns {
+		if typ, ok := types[col]; ok {
+			sl[i] = typ
+			found++
+		} else {
+			sl[i] = TypeString
+		}
+	}
+	if found < len(types) {
+		for col := range types {
+			if !containsString(columns, col) {
+				return nil, fmt.Errorf("typed column %q not found in columns", col)
+			}
+		}
+	}
+	return sl, nil
+}
+
+func containsString(sl []string, s string) bool {
+	for _, v := range sl {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// IsNumeric returns true if values of typ are numbers
+func IsNumeric(typ string) bool {
+	return typ == TypeInteger || typ == TypeDecimal
+}
+
+// ParseTimestamp parses a timestamp in RFC 3339 format, with or without time
+// zone and with either "T" or a space between date and time
+func ParseTimestamp(v string) (t time.Time, err error) {
+	for _, layout := range timestampLayouts {
+		t, err = time.Parse(layout, v)
+		if err == nil {
+			return
+		}
+	}
+	return
+}
+
+// Validate returns an error if v is not a valid value of typ
+func Validate(typ, v string) error {
+	if v == "" {
+		return nil
+	}
+	var err error
+	switch typ {
+	case TypeInteger:
+		_, err = strconv.ParseInt(v, 10, 64)
+	case TypeDecimal:
+		if !decimalPattern.MatchString(v) {
+			err = strconv.ErrSyntax
+		}
+	case TypeBoolean:
+		_, err = strconv.ParseBool(v)
+	case TypeDate:
+		_, err = time.Parse(DateLayout, v)
+	case TypeTimestamp:
+		_, err = ParseTimestamp(v)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s %q", typ, v)
+	}
+	return nil
+}
+
+// Equal returns true if a and b are the same value of typ. Numbers are equal
+// if they differ by no more than tolerance, so "1.0" and "1" are equal.
+// Values that can't be parsed are only equal if they are identical.
+func Equal(typ, a, b string, tolerance float64) bool {
+	if a == b {
+		return true
+	}
+	if a == "" || b == "" {
+		return false
+	}
+	switch typ {
+	case TypeInteger, TypeDecimal:
+		x, err1 := strconv.ParseFloat(a, 64)
+		y, err2 := strconv.ParseFloat(b, 64)
+		return err1 == nil && err2 == nil && math.Abs(x-y) <= tolerance
+	case TypeBoolean:
+		x, err1 := strconv.ParseBool(a)
+		y, err2 := strconv.ParseBool(b)
+		return err1 == nil && err2 == nil && x == y
+	case TypeDate:
+		x, err1 := time.Parse(DateLayout, a)
+		y, err2 := time.Parse(DateLayout, b)
+		return err1 == nil && err2 == nil && x.Equal(y)
+	case TypeTimestamp:
+		x, err1 := ParseTimestamp(a)
+		y, err2 := ParseTimestamp(b)
+		return err1 == nil && err2 == nil && x.Equal(y)
+	}
+	return false
+}
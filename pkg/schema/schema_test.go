@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright © 2022 Wrangle Ltd
+
+package schema
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTypes(t *testing.T) {
+	m, err := ParseTypes(nil)
+	require.NoError(t, err)
+	assert.Nil(t, m)
+
+	m, err = ParseTypes([]string{"id:integer", "price:Decimal", "a:b:date"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"id":    TypeInteger,
+		"price": TypeDecimal,
+		"a:b":   TypeDate,
+	}, m)
+
+	_, err = ParseTypes([]string{"id"})
+	assert.Equal(t, fmt.Errorf(`invalid column type "id", expecting COLUMN:TYPE`), err)
+	_, err = ParseTypes([]string{"id:number"})
+	assert.Equal(t, fmt.Errorf(`invalid type "number" for column "id", must be one of string, integer, decimal, boolean, date, timestamp`), err)
+}
+
+func TestColumnTypes(t *testing.T) {
+	sl, err := ColumnTypes(nil, []string{"a", "b"})
+	require.NoError(t, err)
+	assert.Nil(t, sl)
+
+	sl, err = ColumnTypes(map[string]string{"b": TypeDate}, []string{"a", "b"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{TypeString, TypeDate}, sl)
+
+	_, err = ColumnTypes(map[string]string{"c": TypeDate}, []string{"a", "b"})
+	assert.Equal(t, fmt.Errorf(`typed column "c" not found in columns`), err)
+}
+
+func TestValidate(t *testing.T) {
+	for _, c := range []struct {
+		typ   string
+		valid []string
+		bad   []string
+	}{
+		{TypeString, []string{"abc", "1"}, nil},
+		{TypeInteger, []string{"", "1", "-20", "+3"}, []string{"1.0", "abc", "1e3"}},
+		{TypeDecimal, []string{"1", "1.0", "-.5", "1.", "2e-3"}, []string{"abc", "NaN", "Inf", "0x1p-2", "1,000"}},
+		{TypeBoolean, []string{"true", "FALSE", "1", "f"}, []string{"yes", "2"}},
+		{TypeDate, []string{"2022-02-28"}, []string{"2022-02-30", "28/02/2022", "2022-02-28T00:00:00Z"}},
+		{TypeTimestamp, []string{"2022-02-28T10:00:00Z", "2022-02-28T10:00:00.123+07:00", "2022-02-28 10:00:00"}, []string{"2022-02-28", "10:00:00"}},
+	} {
+		for _, v := range c.valid {
+			assert.NoError(t, Validate(c.typ, v), "%s %q", c.typ, v)
+		}
+		for _, v := range c.bad {
+			assert.Equal(t, fmt.Errorf("invalid %s %q", c.typ, v), Validate(c.typ, v))
+		}
+	}
+}
+
+func TestEqual(t *testing.T) {
+	for _, c := range []struct {
+		typ       string
+		a, b      string
+		tolerance float64
+		equal     bool
+	}{
+		{TypeString, "1", "1.0", 0, false},
+		{TypeDecimal, "1", "1.0", 0, true},
+		{TypeDecimal, "1.001", "1", 0, false},
+		{TypeDecimal, "1.001", "1", 0.01, true},
+		{TypeDecimal, "", "0", 0.01, false},
+		{TypeInteger, "+3", "3", 0, true},
+		{TypeBoolean, "true", "1", 0, true},
+		{TypeBoolean, "true", "f", 0, false},
+		{TypeDate, "2022-01-01", "2022-01-02", 0, false},
+		{TypeTimestamp, "2022-02-28T10:00:00Z", "2022-02-28T17:00:00+07:00", 0, true},
+		{TypeTimestamp, "2022-02-28 10:00:00", "2022-02-28T10:00:00Z", 0, true},
+	} {
+		assert.Equal(t, c.equal, Equal(c.typ, c.a, c.b, c.tolerance), "%s %q %q", c.typ, c.a, c.b)
+	}
+}
@@ -6,16 +6,19 @@ package sorter
 import (
 	"bufio"
 	"context"
-	"encoding/csv"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/wrgl/wrgl/pkg/dprof"
 	"github.com/wrgl/wrgl/pkg/mem"
 	"github.com/wrgl/wrgl/pkg/objects"
 	"github.com/wrgl/wrgl/pkg/pbar"
+	"github.com/wrgl/wrgl/pkg/rowsource"
 	"github.com/wrgl/wrgl/pkg/slice"
 	"github.com/wrgl/wrgl/pkg/testutils"
 )
@@ -56,18 +59,69 @@ func writeChunk(rows [][]string) (*os.File, error) {
 	return f, nil
 }
 
+const (
+	// DuplicateFirst keeps the first row read of rows sharing a primary key
+	DuplicateFirst = "first"
+	// DuplicateLast keeps the last row read of rows sharing a primary key
+	DuplicateLast = "last"
+	// DuplicateError keeps the first row like DuplicateFirst, then fails
+	// with a *DuplicateKeysError once all rows are sorted
+	DuplicateError = "error"
+
+	// MaxReportedDuplicates is the number of duplicated primary keys listed
+	// in the message of a DuplicateKeysError
+	MaxReportedDuplicates = 20
+)
+
+// DuplicateModes are the ways rows sharing a primary key can be handled
+var DuplicateModes = []string{DuplicateFirst, DuplicateLast, DuplicateError}
+
+// Duplicate holds all rows that share a primary key, in the order they were
+// read. Lines are line numbers for CSV input, for other inputs they are row
+// numbers counting the header as row 1.
+type Duplicate struct {
+	PK    []string
+	Lines []int
+	Rows  [][]string
+}
+
+// DuplicateKeysError is sent by SortedBlocks in DuplicateError mode
+type DuplicateKeysError struct {
+	Duplicates []*Duplicate
+}
+
+func (e *DuplicateKeysError) Error() string {
+	lines := []string{fmt.Sprintf("found %d primary key(s) shared by more than one row:", len(e.Duplicates))}
+	for i, dup := range e.Duplicates {
+		if i == MaxReportedDuplicates {
+			lines = append(lines, fmt.Sprintf("  and %d more", len(e.Duplicates)-i))
+			break
+		}
+		nums := make([]string, len(dup.Lines))
+		for j, l := range dup.Lines {
+			nums[j] = strconv.Itoa(l)
+		}
+		lines = append(lines, fmt.Sprintf("  [%s] on lines %s", strings.Join(dup.PK, ", "), strings.Join(nums, ", ")))
+	}
+	return strings.Join(lines, "\n")
+}
+
 // Sorter sorts input CSV based on PK and output blocks of 255 rows each
 type Sorter struct {
-	PK        []uint32
-	runSize   uint64
-	size      uint64
-	pt        pbar.Bar
-	chunks    []io.Reader
-	profiler  *dprof.Profiler
-	current   [][]string
-	Columns   []string
-	cleanups  []func() error
-	delimiter rune
+	PK               []uint32
+	runSize          uint64
+	size             uint64
+	pt               pbar.Bar
+	chunks           []io.Reader
+	profiler         *dprof.Profiler
+	current          [][]string
+	Columns          []string
+	cleanups         []func() error
+	delimiter        rune
+	onDuplicate      string
+	duplicateHandler func(columns []string, dups []*Duplicate) error
+	duplicates       []*Duplicate
+	lineRow          []string
 }
 
 type SorterOption func(s *Sorter)
@@ -90,8 +144,26 @@ func WithProgressBar(pt pbar.Bar) SorterOption {
 	}
 }
 
+// WithDuplicateMode sets how SortedBlocks handles rows sharing a primary key,
+// mode must be one of DuplicateModes. Defaults to DuplicateFirst.
+func WithDuplicateMode(mode string) SorterOption {
+	return func(s *Sorter) {
+		s.onDuplicate = mode
+	}
+}
+
+// WithDuplicateHandler sets a function that SortedBlocks calls with the
+// output columns and all rows sharing a primary key once every row is
+// sorted. It is not called if there are no duplicates.
+func WithDuplicateHandler(handler func(columns []string, dups []*Duplicate) error) SorterOption {
+	return func(s *Sorter) {
+		s.duplicateHandler = handler
+	}
+}
+
+// SortRows sorts rows by pk. Rows with the same pk keep their order.
 func SortRows(blk [][]string, pk []uint32) {
-	sort.Slice(blk, func(i, j int) bool {
+	sort.SliceStable(blk, func(i, j int) bool {
 		return objects.StringSliceIsLess(pk, blk[i], blk[j])
 	})
 }
@@ -101,6 +173,11 @@ func NewSorter(opts ...SorterOption) (s *Sorter, err error) {
 	for _, opt := range opts {
 		opt(s)
 	}
+	if s.onDuplicate == "" {
+		s.onDuplicate = DuplicateFirst
+	} else if !slice.StringSliceContains(DuplicateModes, s.onDuplicate) {
+		return nil, fmt.Errorf("invalid duplicate mode %q, must be one of %s", s.onDuplicate, strings.Join(DuplicateModes, ", "))
+	}
 	if s.runSize == 0 {
 		s.runSize, err = getRunSize()
 		if err != nil {
@@ -127,6 +204,7 @@ func (s *Sorter) Reset() {
 	if s.cleanups != nil {
 		s.cleanups = s.cleanups[:0]
 	}
+	s.duplicates = nil
 }
 
 func (s *Sorter) AddRow(row []string) error {
@@ -174,12 +252,29 @@ func (s *Sorter) SetColumns(row []string) {
 	s.profiler = dprof.NewProfiler(s.Columns)
 }
 
+// SortFile reads and sorts rows of a CSV file, then closes it
 func (s *Sorter) SortFile(f io.ReadCloser, pk []string) (err error) {
-	r := csv.NewReader(f)
-	if s.delimiter != 0 {
-		r.Comma = s.delimiter
+	if err = s.SortReader(rowsource.NewCSVReader(f, s.delimiter), pk); err != nil {
+		return
 	}
-	r.ReuseRecord = true
+	return f.Close()
+}
+
+// fieldPositioner is implemented by readers that know where each field of
+// the last row is in the input, such as *csv.Reader
+type fieldPositioner interface {
+	FieldPos(field int) (line, column int)
+}
+
+// tracksLines returns true if the line number of each row is needed to
+// report duplicates
+func (s *Sorter) tracksLines() bool {
+	return s.onDuplicate == DuplicateError || s.duplicateHandler != nil
+}
+
+// SortReader reads and sorts rows from r. The first row read holds column
+// names.
+func (s *Sorter) SortReader(r rowsource.Reader, pk []string) (err error) {
 	row, err := r.Read()
 	if err != nil {
 		return
@@ -190,6 +285,8 @@ func (s *Sorter) SortFile(f io.ReadCloser, pk []string) (err error) {
 		return
 	}
 	s.size = 0
+	fp, _ := r.(fieldPositioner)
+	rowNum := 1
 	for {
 		row, err = r.Read()
 		if errors.Is(err, io.EOF) {
@@ -197,12 +294,27 @@ func (s *Sorter) SortFile(f io.ReadCloser, pk []string) (err error) {
 		} else if err != nil {
 			return
 		}
-		s.AddRow(row)
+		rowNum++
+		if s.tracksLines() {
+			// the line number is carried as an extra column that
+			// SortedBlocks removes
+			line := rowNum
+			if fp != nil {
+				if l, _ := fp.FieldPos(0); l > 0 {
+					line = l
+				}
+			}
+			s.lineRow = append(append(s.lineRow[:0], row...), strconv.Itoa(line))
+			row = s.lineRow
+		}
+		if err = s.AddRow(row); err != nil {
+			return
+		}
 	}
 	if s.pt != nil {
 		s.pt.Done()
 	}
-	return f.Close()
+	return nil
 }
 
 func (s *Sorter) TableSummary() *objects.TableProfile {
@@ -249,6 +361,9 @@ func (s *Sorter) pkIndices() []uint32 {
 	return sl
 }
 
+// SortedBlocks sends sorted rows in blocks of 255. Of rows sharing a primary
+// key, only one is kept depending on the duplicate mode (see
+// WithDuplicateMode).
 func (s *Sorter) SortedBlocks(ctx context.Context, removedCols map[int]struct{}, errChan chan<- error) (blocks chan *Block) {
 	blocks = make(chan *Block, 10)
 	pkIndices := s.pkIndices()
@@ -260,17 +375,68 @@ func (s *Sorter) SortedBlocks(ctx context.Context, removedCols map[int]struct{},
 		rowPK := make([]string, len(pkIndices))
 		prevRowPK := make([]string, len(pkIndices))
 		dec := objects.NewStrListDecoder(true)
+		dupDec := objects.NewStrListDecoder(false)
 		n := len(s.chunks)
 		chunkRows := make([]objects.StrList, n)
 		chunkEOF := make([]bool, n)
 		enc := objects.NewStrListEncoder(false)
 		SortRows(s.current, s.PK)
 		var currentBlock objects.StrList
-		remSl := make([]uint32, 0, len(removedCols))
+		remSl := make([]uint32, 0, len(removedCols)+1)
 		for i := range removedCols {
 			remSl = append(remSl, uint32(i))
 		}
+		trackLines := s.tracksLines()
+		if trackLines {
+			// drop the line number added by SortReader
+			remSl = append(remSl, uint32(len(s.Columns)))
+		}
 		r := objects.NewStrListEditor(remSl)
+
+		// the last row is held back until a row with a different primary key
+		// is found, so that it can still be replaced in DuplicateLast mode
+		var lastRow []byte
+		var lastLine int
+		hasLast := false
+		var dup *Duplicate
+		appendLastRow := func() bool {
+			m := len(blk)
+			blk = blk[:m+1]
+			if k := len(lastRow); k > cap(blk[m]) {
+				blk[m] = make([]byte, k)
+			} else {
+				blk[m] = blk[m][:k]
+			}
+			copy(blk[m], lastRow)
+			row := dec.Decode(lastRow)
+			if s.profiler != nil {
+				s.profiler.Process(row)
+			}
+			if len(blkPK) == 0 {
+				blkPK = blkPK[:len(pkIndices)]
+				slice.CopyValuesFromIndices(row, blkPK, pkIndices)
+			}
+			if len(blk) == 255 {
+				b := &Block{
+					Offset:    offset,
+					Block:     objects.CombineRowBytesIntoBlock(blk),
+					PK:        make([]string, len(blkPK)),
+					RowsCount: len(blk),
+				}
+				copy(b.PK, blkPK)
+				select {
+				case <-ctx.Done():
+					return false
+				default:
+					blocks <- b
+				}
+				offset++
+				blk = blk[:0]
+				blkPK = blkPK[:0]
+			}
+			return true
+		}
+
 		for {
 			minInd := 0
 			var minRow []byte
@@ -314,23 +480,40 @@ func (s *Sorter) SortedBlocks(ctx context.Context, removedCols map[int]struct{},
 				break
 			}
 
-			// append min row to block
+			var line int
+			if trackLines {
+				row := dec.Decode(minRow)
+				line, _ = strconv.Atoi(row[len(row)-1])
+			}
 			minRow = r.RemoveFrom(minRow)
 			row := dec.Decode(minRow)
 			slice.CopyValuesFromIndices(row, rowPK, pkIndices)
-			pkOK := pkIsDifferent(rowPK, prevRowPK)
-			if pkOK {
-				m := len(blk)
-				blk = blk[:m+1]
-				if k := len(minRow); k > cap(blk[m]) {
-					blk[m] = make([]byte, k)
-				} else {
-					blk[m] = blk[m][:k]
+			if hasLast && slice.StringSliceEqual(rowPK, prevRowPK) {
+				if trackLines {
+					if dup == nil {
+						dup = &Duplicate{
+							PK:    make([]string, len(rowPK)),
+							Lines: []int{lastLine},
+							Rows:  [][]string{dupDec.Decode(lastRow)},
+						}
+						copy(dup.PK, rowPK)
+						s.duplicates = append(s.duplicates, dup)
+					}
+					dup.Lines = append(dup.Lines, line)
+					dup.Rows = append(dup.Rows, dupDec.Decode(minRow))
 				}
-				copy(blk[m], minRow)
-				if s.profiler != nil {
-					s.profiler.Process(row)
+				if s.onDuplicate == DuplicateLast {
+					lastRow = append(lastRow[:0], minRow...)
 				}
+			} else {
+				if hasLast && !appendLastRow() {
+					return
+				}
+				lastRow = append(lastRow[:0], minRow...)
+				lastLine = line
+				hasLast = true
+				dup = nil
+				copy(prevRowPK, rowPK)
 			}
 
 			if minInd < n {
@@ -339,28 +522,9 @@ func (s *Sorter) SortedBlocks(ctx context.Context, removedCols map[int]struct{},
 				s.current = s.current[1:]
 				currentBlock = nil
 			}
-			if len(blkPK) == 0 {
-				blkPK = blkPK[:len(pkIndices)]
-				copy(blkPK, rowPK)
-			}
-			if len(blk) == 255 {
-				b := &Block{
-					Offset:    offset,
-					Block:     objects.CombineRowBytesIntoBlock(blk),
-					PK:        make([]string, len(blkPK)),
-					RowsCount: len(blk),
-				}
-				copy(b.PK, blkPK)
-				select {
-				case <-ctx.Done():
-					return
-				default:
-					blocks <- b
-				}
-				offset++
-				blk = blk[:0]
-				blkPK = blkPK[:0]
-			}
+		}
+		if hasLast && !appendLastRow() {
+			return
 		}
 		if len(blk) > 0 {
 			b := &Block{
@@ -377,6 +541,18 @@ func (s *Sorter) SortedBlocks(ctx context.Context, removedCols map[int]struct{},
 				blocks <- b
 			}
 		}
+		if len(s.duplicates) == 0 {
+			return
+		}
+		if s.duplicateHandler != nil {
+			if err := s.duplicateHandler(s.removeCols(s.Columns, removedCols), s.duplicates); err != nil {
+				errChan <- err
+				return
+			}
+		}
+		if s.onDuplicate == DuplicateError {
+			errChan <- &DuplicateKeysError{Duplicates: s.duplicates}
+		}
 	}()
 	return
 }
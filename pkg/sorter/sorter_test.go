@@ -251,3 +251,73 @@ func TestSorterDuplicatedPK(t *testing.T) {
 		assert.Len(t, blk, l)
 	}
 }
+
+func TestSorterDuplicateModes(t *testing.T) {
+	rows := [][]string{
+		{"id", "v"},
+		{"1", "a"},
+		{"2", "b"},
+		{"1", "c"},
+		{"3", "d"},
+		{"1", "e"},
+		{"3", "f"},
+	}
+	f := writeCSV(t, rows, ',')
+	defer os.Remove(f.Name())
+
+	sortAll := func(opts ...SorterOption) ([][]string, error) {
+		t.Helper()
+		f, err := os.Open(f.Name())
+		require.NoError(t, err)
+		// a small run size spreads rows across several chunks
+		s, err := NewSorter(append(opts, WithRunSize(16))...)
+		require.NoError(t, err)
+		defer s.Close()
+		require.NoError(t, s.SortFile(f, []string{"id"}))
+		errCh := make(chan error, 1)
+		result := [][]string{}
+		for obj := range s.SortedBlocks(context.Background(), nil, errCh) {
+			_, blk, err := objects.ReadBlockFrom(bytes.NewReader(obj.Block))
+			require.NoError(t, err)
+			result = append(result, blk...)
+		}
+		close(errCh)
+		return result, <-errCh
+	}
+
+	result, err := sortAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"1", "a"}, {"2", "b"}, {"3", "d"}}, result)
+
+	result, err = sortAll(WithDuplicateMode(DuplicateLast))
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"1", "e"}, {"2", "b"}, {"3", "f"}}, result)
+
+	var dups []*Duplicate
+	result, err = sortAll(WithDuplicateHandler(func(columns []string, d []*Duplicate) error {
+		assert.Equal(t, rows[0], columns)
+		dups = d
+		return nil
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"1", "a"}, {"2", "b"}, {"3", "d"}}, result)
+	assert.Equal(t, []*Duplicate{
+		{
+			PK:    []string{"1"},
+			Lines: []int{2, 4, 6},
+			Rows:  [][]string{{"1", "a"}, {"1", "c"}, {"1", "e"}},
+		},
+		{
+			PK:    []string{"3"},
+			Lines: []int{5, 7},
+			Rows:  [][]string{{"3", "d"}, {"3", "f"}},
+		},
+	}, dups)
+
+	_, err = sortAll(WithDuplicateMode(DuplicateError))
+	assert.Equal(t, &DuplicateKeysError{Duplicates: dups}, err)
+	assert.Equal(t, "found 2 primary key(s) shared by more than one row:\n  [1] on lines 2, 4, 6\n  [3] on lines 5, 7", err.Error())
+
+	_, err = NewSorter(WithDuplicateMode("random"))
+	assert.Equal(t, `invalid duplicate mode "random", must be one of first, last, error`, err.Error())
+}